@@ -17,9 +17,10 @@ package main
 import (
 	sdk "github.com/conduitio/conduit-connector-sdk"
 	as "github.com/miquido/conduit-connector-azure-storage"
+	asDestination "github.com/miquido/conduit-connector-azure-storage/destination"
 	asSource "github.com/miquido/conduit-connector-azure-storage/source"
 )
 
 func main() {
-	sdk.Serve(as.Specification, asSource.NewSource, nil)
+	sdk.Serve(as.Specification, asSource.NewSource, asDestination.NewDestination)
 }
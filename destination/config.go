@@ -0,0 +1,706 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package destination
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/miquido/conduit-connector-azure-storage/destination/format"
+	"github.com/miquido/conduit-connector-azure-storage/destination/mapping"
+	"github.com/miquido/conduit-connector-azure-storage/destination/naming"
+	"github.com/miquido/conduit-connector-azure-storage/destination/writer"
+	"github.com/miquido/conduit-connector-azure-storage/internal/compression"
+)
+
+const (
+	ConfigKeyConnectionString = "connectionString"
+	ConfigKeyContainerName    = "containerName"
+
+	ConfigKeyBlobNameTemplate    = "blobNameTemplate"
+	DefaultBlobNameTemplate      = naming.DefaultTemplate
+	DefaultBatchBlobNameTemplate = naming.DefaultBatchTemplate
+
+	ConfigKeyFormat = "format"
+	DefaultFormat   = format.FormatRaw
+
+	ConfigKeyBatchSize     = "batchSize"
+	DefaultBatchSize   int = 1000
+
+	ConfigKeyBatchBytes     = "batchBytes"
+	DefaultBatchBytes   int = 8 * 1024 * 1024
+
+	ConfigKeyBatchInterval = "batchInterval"
+	DefaultBatchInterval   = "10s"
+
+	ConfigKeyBlobType = "blobType"
+	DefaultBlobType   = BlobTypeBlock
+
+	ConfigKeyRotationInterval = "rotationInterval"
+	DefaultRotationInterval   = "0s"
+
+	ConfigKeyRotationBytes       = "rotationBytes"
+	DefaultRotationBytes   int64 = 0
+
+	ConfigKeyBlockSize       = "blockSize"
+	DefaultBlockSize   int64 = 8 * 1024 * 1024
+
+	ConfigKeyUploadParallelism     = "uploadParallelism"
+	DefaultUploadParallelism   int = 4
+
+	ConfigKeyBlockRetries     = "blockRetries"
+	DefaultBlockRetries   int = 3
+
+	ConfigKeyParquetSchema = "parquetSchema"
+
+	ConfigKeyParquetRowGroupSize     = "parquetRowGroupSize"
+	DefaultParquetRowGroupSize   int = format.DefaultParquetRowGroupSize
+
+	ConfigKeyAvroSchema = "avroSchema"
+
+	ConfigKeyAvroCodec = "avroCodec"
+	DefaultAvroCodec   = format.DefaultAvroCodec
+
+	ConfigKeyCSVColumns = "csvColumns"
+
+	ConfigKeyCSVDelimiter = "csvDelimiter"
+	DefaultCSVDelimiter   = string(format.DefaultCSVDelimiter)
+
+	ConfigKeyWritePolicy = "writePolicy"
+	DefaultWritePolicy   = writer.WritePolicyOverwrite
+
+	ConfigKeyConflictReaction = "conflictReaction"
+	DefaultConflictReaction   = writer.ConflictReactionFail
+
+	ConfigKeyConflictPrefix = "conflictPrefix"
+	DefaultConflictPrefix   = "conflicts/"
+
+	ConfigKeyMetadataMapping = "metadataMapping"
+
+	ConfigKeyHeadersMapping = "headersMapping"
+	DefaultHeadersMapping   = "content-type:" + mapping.HeaderContentType
+
+	ConfigKeyTagsMapping = "tagsMapping"
+
+	ConfigKeyAccessTier = "accessTier"
+
+	ConfigKeyAccessTierMetadataKey = "accessTierMetadataKey"
+
+	ConfigKeyImmutabilityPeriod = "immutabilityPeriod"
+	DefaultImmutabilityPeriod   = "0s"
+
+	ConfigKeyImmutabilityMode = "immutabilityMode"
+	DefaultImmutabilityMode   = writer.ImmutabilityModeUnlocked
+
+	ConfigKeyLegalHold = "legalHold"
+	DefaultLegalHold   = false
+
+	ConfigKeyExpiryPeriod = "expiryPeriod"
+	DefaultExpiryPeriod   = "0s"
+
+	ConfigKeyCompression = "compression"
+	DefaultCompression   = compression.None
+
+	ConfigKeyCompressionLevel     = "compressionLevel"
+	DefaultCompressionLevel   int = compression.DefaultLevel
+)
+
+// Below is a list of all supported blob types.
+// See: https://docs.microsoft.com/rest/api/storageservices/understanding-block-blobs--append-blobs--and-page-blobs
+const (
+	BlobTypeBlock  = "block"
+	BlobTypeAppend = "append"
+)
+
+type Config struct {
+	ConnectionString  string
+	ContainerName     string
+	BlobNameTemplate  *naming.Template
+	Format            format.Format
+	BatchSize         int
+	BatchBytes        int
+	BatchInterval     time.Duration
+	BlobType          string
+	RotationInterval  time.Duration
+	RotationBytes     int64
+	BlockSize         int64
+	UploadParallelism int
+	BlockRetries      int
+	FormatOptions     format.Options
+	WritePolicy       string
+	ConflictReaction  string
+	ConflictPrefix    string
+	PropertiesMapping mapping.Mapping
+	Retention         writer.Retention
+	Compression       compression.Algorithm
+	CompressionLevel  int
+}
+
+func ParseConfig(cfgRaw map[string]string) (_ Config, err error) {
+	cfg := Config{
+		ConnectionString: cfgRaw[ConfigKeyConnectionString],
+		ContainerName:    cfgRaw[ConfigKeyContainerName],
+	}
+
+	if cfg.ConnectionString == "" {
+		return Config{}, requiredConfigErr(ConfigKeyConnectionString)
+	}
+
+	if cfg.ContainerName == "" {
+		return Config{}, requiredConfigErr(ConfigKeyContainerName)
+	}
+
+	if cfg.Format, err = parseFormat(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.BlobType, err = parseBlobType(cfgRaw, cfg.Format); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.BlobNameTemplate, err = parseBlobNameTemplate(cfgRaw, cfg.Format, cfg.BlobType); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.BatchSize, err = parsePositiveInt(cfgRaw, ConfigKeyBatchSize, DefaultBatchSize); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.BatchBytes, err = parsePositiveInt(cfgRaw, ConfigKeyBatchBytes, DefaultBatchBytes); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.BatchInterval, err = parseBatchInterval(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.RotationInterval, err = parseRotationInterval(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.RotationBytes, err = parseRotationBytes(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.BlockSize, err = parseBlockSize(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.UploadParallelism, err = parsePositiveInt(cfgRaw, ConfigKeyUploadParallelism, DefaultUploadParallelism); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.BlockRetries, err = parseNonNegativeInt(cfgRaw, ConfigKeyBlockRetries, DefaultBlockRetries); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.FormatOptions.ParquetSchema, err = parseParquetSchema(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	cfg.FormatOptions.ParquetRowGroupSize, err = parsePositiveInt(cfgRaw, ConfigKeyParquetRowGroupSize, DefaultParquetRowGroupSize)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if cfg.FormatOptions.AvroSchema, err = parseAvroSchema(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.FormatOptions.AvroCodec, err = parseAvroCodec(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.FormatOptions.CSVColumns, err = parseCSVColumns(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.FormatOptions.CSVDelimiter, err = parseCSVDelimiter(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.WritePolicy, err = parseWritePolicy(cfgRaw, cfg.BlobType); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.ConflictReaction, err = parseConflictReaction(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	cfg.ConflictPrefix = cfgRaw[ConfigKeyConflictPrefix]
+	if cfg.ConflictPrefix == "" {
+		cfg.ConflictPrefix = DefaultConflictPrefix
+	}
+
+	if cfg.PropertiesMapping.Metadata, err = parseMappingRules(cfgRaw, ConfigKeyMetadataMapping, "", mapping.ParseRules); err != nil {
+		return Config{}, err
+	}
+
+	cfg.PropertiesMapping.Headers, err = parseMappingRules(cfgRaw, ConfigKeyHeadersMapping, DefaultHeadersMapping, mapping.ParseHeaderRules)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if cfg.PropertiesMapping.Tags, err = parseMappingRules(cfgRaw, ConfigKeyTagsMapping, "", mapping.ParseTagRules); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.PropertiesMapping.AccessTier, err = parseAccessTier(cfgRaw, cfg.BlobType); err != nil {
+		return Config{}, err
+	}
+
+	cfg.PropertiesMapping.AccessTierKey = cfgRaw[ConfigKeyAccessTierMetadataKey]
+	if cfg.PropertiesMapping.AccessTierKey != "" && cfg.BlobType == BlobTypeAppend {
+		return Config{}, fmt.Errorf("failed to parse %q config value: append blobs cannot be tiered", ConfigKeyAccessTierMetadataKey)
+	}
+
+	if cfg.Retention, err = parseRetention(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.Compression, err = parseCompression(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.CompressionLevel, err = parseCompressionLevel(cfgRaw, cfg.Compression); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+func requiredConfigErr(name string) error {
+	return fmt.Errorf("%q config value must be set", name)
+}
+
+func parseFormat(cfgRaw map[string]string) (format.Format, error) {
+	formatString, exists := cfgRaw[ConfigKeyFormat]
+	if !exists || formatString == "" {
+		return DefaultFormat, nil
+	}
+
+	f, err := format.Parse(formatString)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %q config value: %w", ConfigKeyFormat, err)
+	}
+
+	return f, nil
+}
+
+func parseBlobType(cfgRaw map[string]string, f format.Format) (string, error) {
+	blobType, exists := cfgRaw[ConfigKeyBlobType]
+	if !exists || blobType == "" {
+		return DefaultBlobType, nil
+	}
+
+	switch blobType {
+	case BlobTypeBlock:
+		return blobType, nil
+
+	case BlobTypeAppend:
+		// Only formats which can be concatenated can be appended to
+		if f != format.FormatRaw && f != format.FormatNDJSON {
+			return "", fmt.Errorf("failed to parse %q config value: %q format cannot be appended to", ConfigKeyBlobType, f)
+		}
+
+		return blobType, nil
+
+	default:
+		return "", fmt.Errorf("failed to parse %q config value: unsupported blob type %q", ConfigKeyBlobType, blobType)
+	}
+}
+
+func parseBlobNameTemplate(cfgRaw map[string]string, f format.Format, blobType string) (*naming.Template, error) {
+	templateString, exists := cfgRaw[ConfigKeyBlobNameTemplate]
+	if !exists || templateString == "" {
+		templateString = DefaultBlobNameTemplate
+
+		// Batches and append blobs need unique names, otherwise they would overwrite each other
+		if format.IsBatched(f) || blobType == BlobTypeAppend {
+			templateString = DefaultBatchBlobNameTemplate
+		}
+	}
+
+	t, err := naming.NewTemplate(templateString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q config value: %w", ConfigKeyBlobNameTemplate, err)
+	}
+
+	return t, nil
+}
+
+func parsePositiveInt(cfgRaw map[string]string, key string, defaultValue int) (int, error) {
+	valueString, exists := cfgRaw[key]
+	if !exists || valueString == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(valueString)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %q config value: %w", key, err)
+	}
+	if value <= 0 {
+		return 0, fmt.Errorf("failed to parse %q config value: value must be greater than 0, %d provided", key, value)
+	}
+
+	return value, nil
+}
+
+func parseNonNegativeInt(cfgRaw map[string]string, key string, defaultValue int) (int, error) {
+	valueString, exists := cfgRaw[key]
+	if !exists || valueString == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(valueString)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %q config value: %w", key, err)
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("failed to parse %q config value: value must not be negative, %d provided", key, value)
+	}
+
+	return value, nil
+}
+
+func parseBatchInterval(cfgRaw map[string]string) (time.Duration, error) {
+	batchIntervalString, exists := cfgRaw[ConfigKeyBatchInterval]
+	if !exists || batchIntervalString == "" {
+		batchIntervalString = DefaultBatchInterval
+	}
+
+	batchInterval, err := time.ParseDuration(batchIntervalString)
+	if err != nil {
+		return 0, fmt.Errorf(
+			"%q config value should be a valid duration",
+			ConfigKeyBatchInterval,
+		)
+	}
+	if batchInterval <= 0 {
+		return 0, fmt.Errorf(
+			"%q config value should be positive, got %s",
+			ConfigKeyBatchInterval,
+			batchInterval,
+		)
+	}
+
+	return batchInterval, nil
+}
+
+func parseRotationInterval(cfgRaw map[string]string) (time.Duration, error) {
+	rotationIntervalString, exists := cfgRaw[ConfigKeyRotationInterval]
+	if !exists || rotationIntervalString == "" {
+		rotationIntervalString = DefaultRotationInterval
+	}
+
+	rotationInterval, err := time.ParseDuration(rotationIntervalString)
+	if err != nil {
+		return 0, fmt.Errorf(
+			"%q config value should be a valid duration",
+			ConfigKeyRotationInterval,
+		)
+	}
+	if rotationInterval < 0 {
+		return 0, fmt.Errorf(
+			"%q config value should not be negative, got %s",
+			ConfigKeyRotationInterval,
+			rotationInterval,
+		)
+	}
+
+	return rotationInterval, nil
+}
+
+func parseRotationBytes(cfgRaw map[string]string) (int64, error) {
+	rotationBytesString, exists := cfgRaw[ConfigKeyRotationBytes]
+	if !exists || rotationBytesString == "" {
+		return DefaultRotationBytes, nil
+	}
+
+	rotationBytes, err := strconv.ParseInt(rotationBytesString, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %q config value: %w", ConfigKeyRotationBytes, err)
+	}
+	if rotationBytes < 0 {
+		return 0, fmt.Errorf("failed to parse %q config value: value must not be negative, %d provided", ConfigKeyRotationBytes, rotationBytes)
+	}
+
+	return rotationBytes, nil
+}
+
+func parseBlockSize(cfgRaw map[string]string) (int64, error) {
+	blockSizeString, exists := cfgRaw[ConfigKeyBlockSize]
+	if !exists || blockSizeString == "" {
+		return DefaultBlockSize, nil
+	}
+
+	blockSize, err := strconv.ParseInt(blockSizeString, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %q config value: %w", ConfigKeyBlockSize, err)
+	}
+	if blockSize <= 0 || blockSize > writer.MaxBlockSize {
+		return 0, fmt.Errorf(
+			"failed to parse %q config value: value must be between 1 and %d, %d provided",
+			ConfigKeyBlockSize,
+			writer.MaxBlockSize,
+			blockSize,
+		)
+	}
+
+	return blockSize, nil
+}
+
+func parseParquetSchema(cfgRaw map[string]string) (format.ParquetSchema, error) {
+	schemaString, exists := cfgRaw[ConfigKeyParquetSchema]
+	if !exists || schemaString == "" {
+		return nil, nil
+	}
+
+	schema, err := format.ParseParquetSchema(schemaString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q config value: %w", ConfigKeyParquetSchema, err)
+	}
+
+	return schema, nil
+}
+
+func parseAvroSchema(cfgRaw map[string]string) (string, error) {
+	schemaString, exists := cfgRaw[ConfigKeyAvroSchema]
+	if !exists || schemaString == "" {
+		return "", nil
+	}
+
+	schema, err := format.ParseAvroSchema(schemaString)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %q config value: %w", ConfigKeyAvroSchema, err)
+	}
+
+	return schema, nil
+}
+
+func parseAvroCodec(cfgRaw map[string]string) (string, error) {
+	codecString, exists := cfgRaw[ConfigKeyAvroCodec]
+	if !exists || codecString == "" {
+		return DefaultAvroCodec, nil
+	}
+
+	codec, err := format.ParseAvroCodec(codecString)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %q config value: %w", ConfigKeyAvroCodec, err)
+	}
+
+	return codec, nil
+}
+
+func parseCSVColumns(cfgRaw map[string]string) ([]string, error) {
+	columnsString, exists := cfgRaw[ConfigKeyCSVColumns]
+	if !exists || columnsString == "" {
+		return nil, nil
+	}
+
+	columns, err := format.ParseCSVColumns(columnsString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q config value: %w", ConfigKeyCSVColumns, err)
+	}
+
+	return columns, nil
+}
+
+func parseCSVDelimiter(cfgRaw map[string]string) (rune, error) {
+	delimiterString, exists := cfgRaw[ConfigKeyCSVDelimiter]
+	if !exists || delimiterString == "" {
+		delimiterString = DefaultCSVDelimiter
+	}
+
+	delimiter, err := format.ParseCSVDelimiter(delimiterString)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %q config value: %w", ConfigKeyCSVDelimiter, err)
+	}
+
+	return delimiter, nil
+}
+
+func parseWritePolicy(cfgRaw map[string]string, blobType string) (string, error) {
+	policy, exists := cfgRaw[ConfigKeyWritePolicy]
+	if !exists || policy == "" {
+		return DefaultWritePolicy, nil
+	}
+
+	switch policy {
+	case writer.WritePolicyOverwrite:
+		return policy, nil
+
+	case writer.WritePolicyCreateOnly, writer.WritePolicyIfMatchLastSeen:
+		// Append blobs are written by many requests, so they cannot be guarded by a single condition
+		if blobType == BlobTypeAppend {
+			return "", fmt.Errorf("failed to parse %q config value: %q policy cannot be used with append blobs", ConfigKeyWritePolicy, policy)
+		}
+
+		return policy, nil
+
+	default:
+		return "", fmt.Errorf("failed to parse %q config value: unsupported write policy %q", ConfigKeyWritePolicy, policy)
+	}
+}
+
+func parseConflictReaction(cfgRaw map[string]string) (string, error) {
+	reaction, exists := cfgRaw[ConfigKeyConflictReaction]
+	if !exists || reaction == "" {
+		return DefaultConflictReaction, nil
+	}
+
+	switch reaction {
+	case writer.ConflictReactionFail, writer.ConflictReactionSkip, writer.ConflictReactionConflictPrefix:
+		return reaction, nil
+
+	default:
+		return "", fmt.Errorf("failed to parse %q config value: unsupported conflict reaction %q", ConfigKeyConflictReaction, reaction)
+	}
+}
+
+func parseMappingRules(
+	cfgRaw map[string]string,
+	key string,
+	defaultValue string,
+	parse func(string) ([]mapping.Rule, error),
+) ([]mapping.Rule, error) {
+	rulesString, exists := cfgRaw[key]
+	if !exists || rulesString == "" {
+		rulesString = defaultValue
+	}
+	if rulesString == "" {
+		return nil, nil
+	}
+
+	rules, err := parse(rulesString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q config value: %w", key, err)
+	}
+
+	return rules, nil
+}
+
+func parseAccessTier(cfgRaw map[string]string, blobType string) (azblob.AccessTier, error) {
+	tierString, exists := cfgRaw[ConfigKeyAccessTier]
+	if !exists || tierString == "" {
+		return "", nil
+	}
+
+	// Only block blobs can be tiered
+	if blobType == BlobTypeAppend {
+		return "", fmt.Errorf("failed to parse %q config value: append blobs cannot be tiered", ConfigKeyAccessTier)
+	}
+
+	tier, err := mapping.ParseAccessTier(tierString)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %q config value: %w", ConfigKeyAccessTier, err)
+	}
+
+	return tier, nil
+}
+
+func parseRetention(cfgRaw map[string]string) (writer.Retention, error) {
+	var (
+		retention writer.Retention
+		err       error
+	)
+
+	periodString, exists := cfgRaw[ConfigKeyImmutabilityPeriod]
+	if !exists || periodString == "" {
+		periodString = DefaultImmutabilityPeriod
+	}
+
+	retention.Period, err = time.ParseDuration(periodString)
+	if err != nil {
+		return writer.Retention{}, fmt.Errorf("%q config value should be a valid duration", ConfigKeyImmutabilityPeriod)
+	}
+	if retention.Period < 0 {
+		return writer.Retention{}, fmt.Errorf(
+			"%q config value should not be negative, got %s",
+			ConfigKeyImmutabilityPeriod,
+			retention.Period,
+		)
+	}
+
+	modeString, exists := cfgRaw[ConfigKeyImmutabilityMode]
+	if !exists || modeString == "" {
+		modeString = DefaultImmutabilityMode
+	}
+
+	if retention.Mode, err = writer.ParseImmutabilityMode(modeString); err != nil {
+		return writer.Retention{}, fmt.Errorf("failed to parse %q config value: %w", ConfigKeyImmutabilityMode, err)
+	}
+
+	retention.LegalHold = DefaultLegalHold
+
+	if legalHoldString, exists := cfgRaw[ConfigKeyLegalHold]; exists && legalHoldString != "" {
+		if retention.LegalHold, err = strconv.ParseBool(legalHoldString); err != nil {
+			return writer.Retention{}, fmt.Errorf("failed to parse %q config value: %w", ConfigKeyLegalHold, err)
+		}
+	}
+
+	expiryString, exists := cfgRaw[ConfigKeyExpiryPeriod]
+	if !exists || expiryString == "" {
+		expiryString = DefaultExpiryPeriod
+	}
+
+	retention.Expiry, err = time.ParseDuration(expiryString)
+	if err != nil {
+		return writer.Retention{}, fmt.Errorf("%q config value should be a valid duration", ConfigKeyExpiryPeriod)
+	}
+	if retention.Expiry < 0 {
+		return writer.Retention{}, fmt.Errorf(
+			"%q config value should not be negative, got %s",
+			ConfigKeyExpiryPeriod,
+			retention.Expiry,
+		)
+	}
+
+	return retention, nil
+}
+
+func parseCompression(cfgRaw map[string]string) (compression.Algorithm, error) {
+	compressionString, exists := cfgRaw[ConfigKeyCompression]
+	if !exists || compressionString == "" {
+		return DefaultCompression, nil
+	}
+
+	algorithm, err := compression.Parse(compressionString)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %q config value: %w", ConfigKeyCompression, err)
+	}
+
+	return algorithm, nil
+}
+
+func parseCompressionLevel(cfgRaw map[string]string, algorithm compression.Algorithm) (int, error) {
+	levelString, exists := cfgRaw[ConfigKeyCompressionLevel]
+	if !exists || levelString == "" {
+		return DefaultCompressionLevel, nil
+	}
+
+	level, err := strconv.Atoi(levelString)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %q config value: %w", ConfigKeyCompressionLevel, err)
+	}
+
+	if err := compression.ValidateLevel(algorithm, level); err != nil {
+		return 0, fmt.Errorf("failed to parse %q config value: %w", ConfigKeyCompressionLevel, err)
+	}
+
+	return level, nil
+}
@@ -0,0 +1,644 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package destination
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/jaswdr/faker"
+	"github.com/miquido/conduit-connector-azure-storage/destination/format"
+	"github.com/miquido/conduit-connector-azure-storage/destination/mapping"
+	"github.com/miquido/conduit-connector-azure-storage/destination/writer"
+	"github.com/miquido/conduit-connector-azure-storage/internal/compression"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	fakerInstance := faker.New()
+
+	for _, tt := range []struct {
+		name  string
+		error string
+		cfg   map[string]string
+	}{
+		{
+			name:  "Connection String is empty",
+			error: fmt.Sprintf("%q config value must be set", ConfigKeyConnectionString),
+			cfg: map[string]string{
+				"nonExistentKey": "value",
+			},
+		},
+		{
+			name:  "Container Name is empty",
+			error: fmt.Sprintf("%q config value must be set", ConfigKeyContainerName),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Blob Name Template cannot be parsed",
+			error: fmt.Sprintf("failed to parse %q config value: invalid template: template: blobName:1: unclosed action", ConfigKeyBlobNameTemplate),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyBlobNameTemplate: "{{.Key",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Blob Name Template references unknown field",
+			error: fmt.Sprintf("failed to parse %q config value: could not execute template: template: blobName:1:2: executing \"blobName\" at <.Table>: can't evaluate field Table in type naming.data", ConfigKeyBlobNameTemplate),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyBlobNameTemplate: "{{.Table}}",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Format is not supported",
+			error: fmt.Sprintf("failed to parse %q config value: unsupported format \"xml\"", ConfigKeyFormat),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyFormat:           "xml",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Batch Size is not valid integer string",
+			error: fmt.Sprintf("failed to parse %q config value: strconv.Atoi: parsing \"non-integer format string\": invalid syntax", ConfigKeyBatchSize),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyBatchSize:        "non-integer format string",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Batch Size is zero",
+			error: fmt.Sprintf("failed to parse %q config value: value must be greater than 0, 0 provided", ConfigKeyBatchSize),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyBatchSize:        "0",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Batch Bytes is negative",
+			error: fmt.Sprintf("failed to parse %q config value: value must be greater than 0, -1 provided", ConfigKeyBatchBytes),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyBatchBytes:       "-1",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Batch Interval has invalid format",
+			error: fmt.Sprintf("%q config value should be a valid duration", ConfigKeyBatchInterval),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyBatchInterval:    "non-date format string",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Batch Interval is zero",
+			error: fmt.Sprintf("%q config value should be positive, got 0s", ConfigKeyBatchInterval),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyBatchInterval:    "0s",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Blob Type is not supported",
+			error: fmt.Sprintf("failed to parse %q config value: unsupported blob type \"page\"", ConfigKeyBlobType),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyBlobType:         "page",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Rotation Interval is negative",
+			error: fmt.Sprintf("%q config value should not be negative, got -1s", ConfigKeyRotationInterval),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyRotationInterval: "-1s",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Rotation Bytes is negative",
+			error: fmt.Sprintf("failed to parse %q config value: value must not be negative, -1 provided", ConfigKeyRotationBytes),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyRotationBytes:    "-1",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Format cannot be appended to",
+			error: fmt.Sprintf("failed to parse %q config value: \"parquet\" format cannot be appended to", ConfigKeyBlobType),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyFormat:           "parquet",
+				ConfigKeyBlobType:         "append",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Block Size exceeds the limit",
+			error: fmt.Sprintf("failed to parse %q config value: value must be between 1 and 104857600, 104857601 provided", ConfigKeyBlockSize),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyBlockSize:        "104857601",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Upload Parallelism is zero",
+			error: fmt.Sprintf("failed to parse %q config value: value must be greater than 0, 0 provided", ConfigKeyUploadParallelism),
+			cfg: map[string]string{
+				ConfigKeyConnectionString:  fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:     fakerInstance.Lorem().Word(),
+				ConfigKeyUploadParallelism: "0",
+				"nonExistentKey":           "value",
+			},
+		},
+		{
+			name:  "Block Retries is negative",
+			error: fmt.Sprintf("failed to parse %q config value: value must not be negative, -1 provided", ConfigKeyBlockRetries),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyBlockRetries:     "-1",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Parquet Schema has unsupported type",
+			error: fmt.Sprintf("failed to parse %q config value: column \"id\" has unsupported type \"uuid\"", ConfigKeyParquetSchema),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyParquetSchema:    "id:uuid",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Parquet Row Group Size is zero",
+			error: fmt.Sprintf("failed to parse %q config value: value must be greater than 0, 0 provided", ConfigKeyParquetRowGroupSize),
+			cfg: map[string]string{
+				ConfigKeyConnectionString:    fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:       fakerInstance.Lorem().Word(),
+				ConfigKeyParquetRowGroupSize: "0",
+				"nonExistentKey":             "value",
+			},
+		},
+		{
+			name:  "Avro Schema does not describe a record",
+			error: fmt.Sprintf("failed to parse %q config value: schema is expected to be an Avro record", ConfigKeyAvroSchema),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyAvroSchema:       `"string"`,
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Avro Codec is not supported",
+			error: fmt.Sprintf("failed to parse %q config value: unsupported Avro codec \"zstandard\"", ConfigKeyAvroCodec),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyAvroCodec:        "zstandard",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "CSV Columns are duplicated",
+			error: fmt.Sprintf("failed to parse %q config value: column \"id\" is duplicated", ConfigKeyCSVColumns),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyCSVColumns:       "id,name,id",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "CSV Delimiter has more than one character",
+			error: fmt.Sprintf("failed to parse %q config value: delimiter is expected to be a single character, got \";;\"", ConfigKeyCSVDelimiter),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyCSVDelimiter:     ";;",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Write Policy is not supported",
+			error: fmt.Sprintf("failed to parse %q config value: unsupported write policy \"ifNewer\"", ConfigKeyWritePolicy),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyWritePolicy:      "ifNewer",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Write Policy cannot guard append blobs",
+			error: fmt.Sprintf("failed to parse %q config value: \"createOnly\" policy cannot be used with append blobs", ConfigKeyWritePolicy),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyBlobType:         "append",
+				ConfigKeyWritePolicy:      "createOnly",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Conflict Reaction is not supported",
+			error: fmt.Sprintf("failed to parse %q config value: unsupported conflict reaction \"retry\"", ConfigKeyConflictReaction),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyConflictReaction: "retry",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Headers Mapping references unsupported header",
+			error: fmt.Sprintf("failed to parse %q config value: header \"Expires\" is not supported", ConfigKeyHeadersMapping),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyHeadersMapping:   "expires:Expires",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Tags Mapping has too many tags",
+			error: fmt.Sprintf("failed to parse %q config value: blob can have at most 10 tags, 11 mapped", ConfigKeyTagsMapping),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyTagsMapping:      "a,b,c,d,e,f,g,h,i,j,k",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Access Tier is not supported",
+			error: fmt.Sprintf("failed to parse %q config value: unsupported access tier \"Frozen\"", ConfigKeyAccessTier),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyAccessTier:       "Frozen",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Access Tier is set for append blobs",
+			error: fmt.Sprintf("failed to parse %q config value: append blobs cannot be tiered", ConfigKeyAccessTier),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyBlobType:         "append",
+				ConfigKeyAccessTier:       "Cool",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Immutability Period is negative",
+			error: fmt.Sprintf("%q config value should not be negative, got -1h0m0s", ConfigKeyImmutabilityPeriod),
+			cfg: map[string]string{
+				ConfigKeyConnectionString:   fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:      fakerInstance.Lorem().Word(),
+				ConfigKeyImmutabilityPeriod: "-1h",
+				"nonExistentKey":            "value",
+			},
+		},
+		{
+			name:  "Expiry Period is negative",
+			error: fmt.Sprintf("%q config value should not be negative, got -1h0m0s", ConfigKeyExpiryPeriod),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyExpiryPeriod:     "-1h",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Immutability Mode is not supported",
+			error: fmt.Sprintf("failed to parse %q config value: unsupported immutability mode \"Locked\"", ConfigKeyImmutabilityMode),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyImmutabilityMode: "Locked",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Legal Hold is not a boolean",
+			error: fmt.Sprintf("failed to parse %q config value: strconv.ParseBool: parsing \"yes\": invalid syntax", ConfigKeyLegalHold),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyLegalHold:        "yes",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Compression is not supported",
+			error: fmt.Sprintf("failed to parse %q config value: unsupported compression \"brotli\"", ConfigKeyCompression),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyCompression:      "brotli",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Compression Level is out of range",
+			error: fmt.Sprintf("failed to parse %q config value: gzip compression level is expected to be between 1 and 9, got 12", ConfigKeyCompressionLevel),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyCompression:      "gzip",
+				ConfigKeyCompressionLevel: "12",
+				"nonExistentKey":          "value",
+			},
+		},
+		{
+			name:  "Compression Level is set for snappy",
+			error: fmt.Sprintf("failed to parse %q config value: snappy compression does not support levels", ConfigKeyCompressionLevel),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyCompression:      "snappy",
+				ConfigKeyCompressionLevel: "3",
+				"nonExistentKey":          "value",
+			},
+		},
+	} {
+		t.Run(fmt.Sprintf("Fails when: %s", tt.name), func(t *testing.T) {
+			_, err := ParseConfig(tt.cfg)
+
+			require.EqualError(t, err, tt.error)
+		})
+	}
+
+	t.Run("Returns config when all required config values were provided", func(t *testing.T) {
+		cfgRaw := map[string]string{
+			ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+			ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+			"nonExistentKey":          "value",
+		}
+
+		config, err := ParseConfig(cfgRaw)
+
+		require.NoError(t, err)
+		require.Equal(t, cfgRaw[ConfigKeyConnectionString], config.ConnectionString)
+		require.Equal(t, cfgRaw[ConfigKeyContainerName], config.ContainerName)
+		require.NotNil(t, config.BlobNameTemplate)
+		require.Equal(t, DefaultFormat, config.Format)
+		require.Equal(t, DefaultBatchSize, config.BatchSize)
+		require.Equal(t, DefaultBatchBytes, config.BatchBytes)
+		require.Equal(t, 10*time.Second, config.BatchInterval)
+		require.Equal(t, BlobTypeBlock, config.BlobType)
+		require.Zero(t, config.RotationInterval)
+		require.Zero(t, config.RotationBytes)
+		require.Equal(t, DefaultBlockSize, config.BlockSize)
+		require.Equal(t, DefaultUploadParallelism, config.UploadParallelism)
+		require.Equal(t, DefaultBlockRetries, config.BlockRetries)
+		require.Empty(t, config.FormatOptions.ParquetSchema)
+		require.Equal(t, DefaultParquetRowGroupSize, config.FormatOptions.ParquetRowGroupSize)
+		require.Empty(t, config.FormatOptions.AvroSchema)
+		require.Equal(t, DefaultAvroCodec, config.FormatOptions.AvroCodec)
+		require.Empty(t, config.FormatOptions.CSVColumns)
+		require.Equal(t, DefaultWritePolicy, config.WritePolicy)
+		require.Equal(t, DefaultConflictReaction, config.ConflictReaction)
+		require.Equal(t, DefaultConflictPrefix, config.ConflictPrefix)
+		require.Equal(t, mapping.Mapping{
+			Headers: []mapping.Rule{{From: "content-type", To: mapping.HeaderContentType}},
+		}, config.PropertiesMapping)
+		require.Equal(t, writer.Retention{Mode: writer.ImmutabilityModeUnlocked}, config.Retention)
+		require.Equal(t, compression.None, config.Compression)
+		require.Equal(t, compression.DefaultLevel, config.CompressionLevel)
+		require.Equal(t, ',', config.FormatOptions.CSVDelimiter)
+	})
+
+	t.Run("Returns config when all config values were provided", func(t *testing.T) {
+		var (
+			batchSize  = fakerInstance.IntBetween(1, 10000)
+			batchBytes = fakerInstance.IntBetween(1, 1024*1024)
+		)
+
+		cfgRaw := map[string]string{
+			ConfigKeyConnectionString:  fakerInstance.Internet().Query(),
+			ConfigKeyContainerName:     fakerInstance.Lorem().Word(),
+			ConfigKeyBlobNameTemplate:  "{{.Key}}",
+			ConfigKeyFormat:            "ndjson",
+			ConfigKeyBatchSize:         strconv.Itoa(batchSize),
+			ConfigKeyBatchBytes:        strconv.Itoa(batchBytes),
+			ConfigKeyBatchInterval:     "1m30s",
+			ConfigKeyBlobType:          "append",
+			ConfigKeyRotationInterval:  "1h",
+			ConfigKeyRotationBytes:     "1048576",
+			ConfigKeyBlockSize:         "104857600",
+			ConfigKeyUploadParallelism: "16",
+			ConfigKeyBlockRetries:      "0",
+			"nonExistentKey":           "value",
+		}
+
+		config, err := ParseConfig(cfgRaw)
+
+		require.NoError(t, err)
+		require.Equal(t, cfgRaw[ConfigKeyConnectionString], config.ConnectionString)
+		require.Equal(t, cfgRaw[ConfigKeyContainerName], config.ContainerName)
+		require.NotNil(t, config.BlobNameTemplate)
+		require.Equal(t, format.FormatNDJSON, config.Format)
+		require.Equal(t, batchSize, config.BatchSize)
+		require.Equal(t, batchBytes, config.BatchBytes)
+		require.Equal(t, 90*time.Second, config.BatchInterval)
+		require.Equal(t, BlobTypeAppend, config.BlobType)
+		require.Equal(t, time.Hour, config.RotationInterval)
+		require.EqualValues(t, 1048576, config.RotationBytes)
+		require.EqualValues(t, 104857600, config.BlockSize)
+		require.Equal(t, 16, config.UploadParallelism)
+		require.Zero(t, config.BlockRetries)
+	})
+
+	t.Run("Returns config with Parquet options", func(t *testing.T) {
+		config, err := ParseConfig(map[string]string{
+			ConfigKeyConnectionString:    fakerInstance.Internet().Query(),
+			ConfigKeyContainerName:       fakerInstance.Lorem().Word(),
+			ConfigKeyFormat:              "parquet",
+			ConfigKeyParquetSchema:       "id:int64, name:string",
+			ConfigKeyParquetRowGroupSize: "500",
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, format.FormatParquet, config.Format)
+		require.Equal(t, format.ParquetSchema{
+			{Name: "id", Type: format.ParquetTypeInt64},
+			{Name: "name", Type: format.ParquetTypeString},
+		}, config.FormatOptions.ParquetSchema)
+		require.Equal(t, 500, config.FormatOptions.ParquetRowGroupSize)
+	})
+
+	t.Run("Returns config with Avro options", func(t *testing.T) {
+		schema := `{"type":"record","name":"User","fields":[{"name":"id","type":"long"}]}`
+
+		config, err := ParseConfig(map[string]string{
+			ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+			ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+			ConfigKeyFormat:           "avro",
+			ConfigKeyAvroSchema:       schema,
+			ConfigKeyAvroCodec:        "deflate",
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, format.FormatAvro, config.Format)
+		require.Equal(t, schema, config.FormatOptions.AvroSchema)
+		require.Equal(t, format.AvroCodecDeflate, config.FormatOptions.AvroCodec)
+	})
+
+	t.Run("Returns config with CSV options", func(t *testing.T) {
+		config, err := ParseConfig(map[string]string{
+			ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+			ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+			ConfigKeyFormat:           "csv",
+			ConfigKeyCSVColumns:       "id, address.city",
+			ConfigKeyCSVDelimiter:     `\t`,
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, format.FormatCSV, config.Format)
+		require.Equal(t, []string{"id", "address.city"}, config.FormatOptions.CSVColumns)
+		require.Equal(t, '\t', config.FormatOptions.CSVDelimiter)
+	})
+
+	t.Run("Returns config with write policy options", func(t *testing.T) {
+		config, err := ParseConfig(map[string]string{
+			ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+			ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+			ConfigKeyWritePolicy:      "ifMatchLastSeen",
+			ConfigKeyConflictReaction: "conflictPrefix",
+			ConfigKeyConflictPrefix:   "clashes/",
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, writer.WritePolicyIfMatchLastSeen, config.WritePolicy)
+		require.Equal(t, writer.ConflictReactionConflictPrefix, config.ConflictReaction)
+		require.Equal(t, "clashes/", config.ConflictPrefix)
+	})
+
+	t.Run("Returns config with properties mapping", func(t *testing.T) {
+		config, err := ParseConfig(map[string]string{
+			ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+			ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+			ConfigKeyMetadataMapping:  "table,source:origin",
+			ConfigKeyHeadersMapping:   "encoding:content-encoding",
+			ConfigKeyTagsMapping:      "tenant",
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, mapping.Mapping{
+			Metadata: []mapping.Rule{{From: "table", To: "table"}, {From: "source", To: "origin"}},
+			Headers:  []mapping.Rule{{From: "encoding", To: mapping.HeaderContentEncoding}},
+			Tags:     []mapping.Rule{{From: "tenant", To: "tenant"}},
+		}, config.PropertiesMapping)
+	})
+
+	t.Run("Returns config with access tier and retention", func(t *testing.T) {
+		config, err := ParseConfig(map[string]string{
+			ConfigKeyConnectionString:      fakerInstance.Internet().Query(),
+			ConfigKeyContainerName:         fakerInstance.Lorem().Word(),
+			ConfigKeyAccessTier:            "archive",
+			ConfigKeyAccessTierMetadataKey: "tier",
+			ConfigKeyImmutabilityPeriod:    "720h",
+			ConfigKeyImmutabilityMode:      "locked",
+			ConfigKeyLegalHold:             "true",
+			ConfigKeyExpiryPeriod:          "2160h",
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, mapping.AccessTierArchive, config.PropertiesMapping.AccessTier)
+		require.Equal(t, "tier", config.PropertiesMapping.AccessTierKey)
+		require.Equal(t, writer.Retention{
+			Period:    720 * time.Hour,
+			Mode:      writer.ImmutabilityModeLocked,
+			LegalHold: true,
+			Expiry:    2160 * time.Hour,
+		}, config.Retention)
+	})
+
+	t.Run("Returns config with compression", func(t *testing.T) {
+		config, err := ParseConfig(map[string]string{
+			ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+			ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+			ConfigKeyCompression:      "zstd",
+			ConfigKeyCompressionLevel: "19",
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, compression.Zstd, config.Compression)
+		require.Equal(t, 19, config.CompressionLevel)
+	})
+
+	t.Run("Append blobs use unique blob names by default", func(t *testing.T) {
+		config, err := ParseConfig(map[string]string{
+			ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+			ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+			ConfigKeyBlobType:         "append",
+		})
+		require.NoError(t, err)
+
+		name, err := config.BlobNameTemplate.Execute(sdk.Record{Key: sdk.RawData("key")}, time.Date(2022, 7, 14, 12, 0, 0, 1, time.UTC))
+		require.NoError(t, err)
+		require.Equal(t, "2022/07/14/12-00-00.000000001", name)
+	})
+
+	t.Run("Batched formats use unique blob names by default", func(t *testing.T) {
+		config, err := ParseConfig(map[string]string{
+			ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+			ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+			ConfigKeyFormat:           "ndjson",
+		})
+		require.NoError(t, err)
+
+		name, err := config.BlobNameTemplate.Execute(sdk.Record{Key: sdk.RawData("key")}, time.Date(2022, 7, 14, 12, 0, 0, 1, time.UTC))
+		require.NoError(t, err)
+		require.Equal(t, "2022/07/14/12-00-00.000000001", name)
+	})
+}
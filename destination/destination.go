@@ -0,0 +1,294 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package destination
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/destination/format"
+	"github.com/miquido/conduit-connector-azure-storage/destination/writer"
+	"github.com/miquido/conduit-connector-azure-storage/internal"
+	"github.com/miquido/conduit-connector-azure-storage/internal/compression"
+)
+
+var ErrEmptyRecordKey = errors.New("record key is empty")
+
+// MetadataKeyETag is the key of the record metadata holding the ETag the blob was last seen with.
+const MetadataKeyETag = "etag"
+
+type Destination struct {
+	sdk.UnimplementedDestination
+
+	config          Config
+	containerClient *azblob.ContainerClient
+	uploader        *writer.BlockUploader
+	blobWriter      *writer.BlobWriter
+	writer          writer.Writer
+}
+
+func NewDestination() sdk.Destination {
+	return &Destination{}
+}
+
+func (d *Destination) Configure(_ context.Context, cfgRaw map[string]string) (err error) {
+	d.config, err = ParseConfig(cfgRaw)
+	if err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Destination) Open(ctx context.Context) error {
+	// Create account connection client
+	serviceClient, err := azblob.NewServiceClientFromConnectionString(d.config.ConnectionString, &azblob.ClientOptions{
+		Retry:           writer.NewRetryOptions(d.config.BlockRetries),
+		PerCallPolicies: []policy.Policy{writer.NewRetentionPolicy()},
+	})
+	if err != nil {
+		return fmt.Errorf("connector open error: could not create account connection client: %w", err)
+	}
+
+	// Test account connection
+	accountInfo, err := serviceClient.GetAccountInfo(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("connector open error: could not establish a connection: %w", err)
+	}
+	if accountInfo.RawResponse.StatusCode != http.StatusOK {
+		return fmt.Errorf("connector open error: could not establish a connection: unexpected response status %d", accountInfo.RawResponse.StatusCode)
+	}
+
+	// Set Blob Expiry requests are accepted only by accounts with a hierarchical namespace
+	if d.config.Retention.Expiry > 0 && (accountInfo.IsHierarchicalNamespaceEnabled == nil || !*accountInfo.IsHierarchicalNamespaceEnabled) {
+		return fmt.Errorf("connector open error: %q requires an account with a hierarchical namespace enabled", ConfigKeyExpiryPeriod)
+	}
+
+	// Create container client
+	containerClient, err := serviceClient.NewContainerClient(d.config.ContainerName)
+	if err != nil {
+		return fmt.Errorf("connector open error: could not create container connection client: %w", err)
+	}
+
+	// Check if container exists
+	_, err = containerClient.GetProperties(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("connector open error: could not create container connection client: %w", err)
+	}
+
+	d.containerClient = containerClient
+
+	d.uploader, err = writer.NewBlockUploader(d.config.BlockSize, d.config.UploadParallelism)
+	if err != nil {
+		return fmt.Errorf("connector open error: couldn't create a block uploader: %w", err)
+	}
+
+	d.blobWriter, err = writer.NewBlobWriter(
+		containerClient,
+		d.uploader,
+		d.config.WritePolicy,
+		d.config.ConflictReaction,
+		d.config.ConflictPrefix,
+		d.config.Retention,
+	)
+	if err != nil {
+		return fmt.Errorf("connector open error: couldn't create a blob writer: %w", err)
+	}
+
+	// Records written in batched formats or to append blobs are cached by the writer,
+	// other records are written right away
+	switch {
+	case d.config.BlobType == BlobTypeAppend:
+		var encoder format.Encoder = format.LinesEncoder{}
+
+		if format.IsBatched(d.config.Format) {
+			if encoder, err = format.NewEncoder(d.config.Format, d.config.FormatOptions); err != nil {
+				return fmt.Errorf("connector open error: %w", err)
+			}
+		}
+
+		d.writer, err = writer.NewAppendWriter(
+			containerClient,
+			format.Compress(encoder, d.config.Compression, d.config.CompressionLevel),
+			d.config.BlobNameTemplate,
+			d.config.PropertiesMapping,
+			d.config.Retention,
+			d.config.BatchSize,
+			d.config.BatchBytes,
+			d.config.BatchInterval,
+			d.config.RotationInterval,
+			d.config.RotationBytes,
+		)
+		if err != nil {
+			return fmt.Errorf("connector open error: couldn't create an append writer: %w", err)
+		}
+
+	case format.IsBatched(d.config.Format):
+		encoder, err := format.NewEncoder(d.config.Format, d.config.FormatOptions)
+		if err != nil {
+			return fmt.Errorf("connector open error: %w", err)
+		}
+
+		d.writer, err = writer.NewBatchWriter(
+			d.blobWriter,
+			format.Compress(encoder, d.config.Compression, d.config.CompressionLevel),
+			d.config.BlobNameTemplate,
+			d.config.PropertiesMapping,
+			d.config.BatchSize,
+			d.config.BatchBytes,
+			d.config.BatchInterval,
+		)
+		if err != nil {
+			return fmt.Errorf("connector open error: couldn't create a batch writer: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (d *Destination) WriteAsync(ctx context.Context, record sdk.Record, ack sdk.AckFunc) error {
+	if d.writer == nil {
+		return sdk.ErrUnimplemented
+	}
+
+	if err := d.writer.Write(ctx, record, ack); err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Destination) Write(ctx context.Context, record sdk.Record) error {
+	blobName, err := d.blobName(ctx, record)
+	if err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
+
+	switch record.Metadata["action"] {
+	case internal.OperationDelete:
+		if err := d.deleteBlob(ctx, blobName); err != nil {
+			return fmt.Errorf("write error: could not delete blob %q: %w", blobName, err)
+		}
+
+	default:
+		if err := d.uploadBlob(ctx, blobName, record); err != nil {
+			return fmt.Errorf("write error: could not upload blob %q: %w", blobName, err)
+		}
+	}
+
+	return nil
+}
+
+func (d *Destination) Flush(ctx context.Context) error {
+	if d.writer == nil {
+		return nil
+	}
+
+	if err := d.writer.Flush(ctx); err != nil {
+		return fmt.Errorf("flush error: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Destination) Teardown(_ context.Context) error {
+	if d.writer != nil {
+		d.writer.Stop()
+		d.writer = nil
+	}
+
+	d.containerClient = nil
+	d.uploader = nil
+	d.blobWriter = nil
+
+	return nil
+}
+
+// blobName renders the configured blob name template for the record.
+// The record's key is used instead whenever the template fails or renders an empty name.
+func (d *Destination) blobName(ctx context.Context, record sdk.Record) (string, error) {
+	var key string
+	if record.Key != nil {
+		key = string(record.Key.Bytes())
+	}
+
+	name, err := d.config.BlobNameTemplate.Execute(record, time.Now().UTC())
+	if err != nil {
+		sdk.Logger(ctx).Warn().Err(err).Str("key", key).Msg("falling back to the record key as the blob name")
+
+		name = ""
+	}
+
+	if name == "" {
+		name = key
+	}
+
+	if name == "" {
+		return "", ErrEmptyRecordKey
+	}
+
+	// Compressed blobs are named with the compression's extension, so deleting the record removes the same blob
+	if extension := compression.Extension(d.config.Compression); !strings.HasSuffix(name, extension) {
+		name += extension
+	}
+
+	return name, nil
+}
+
+// uploadBlob writes the record's payload, compressed when configured, into the blob, with the properties mapped
+// from the record's metadata.
+// The ETag found in the record's metadata, e.g. read by the source, is the one the blob is expected to have with
+// the ifMatchLastSeen write policy.
+func (d *Destination) uploadBlob(ctx context.Context, blobName string, record sdk.Record) error {
+	var contents []byte
+	if record.Payload != nil {
+		contents = record.Payload.Bytes()
+	}
+
+	contents, err := compression.Compress(contents, d.config.Compression, d.config.CompressionLevel)
+	if err != nil {
+		return fmt.Errorf("could not compress the payload: %w", err)
+	}
+
+	props := d.config.PropertiesMapping.Apply(record.Metadata)
+
+	if encoding := compression.ContentEncoding(d.config.Compression); encoding != "" {
+		if props.Headers == nil {
+			props.Headers = &azblob.BlobHTTPHeaders{}
+		}
+
+		props.Headers.BlobContentEncoding = to.Ptr(encoding)
+	}
+
+	return d.blobWriter.Write(ctx, blobName, bytes.NewReader(contents), &azblob.BlockBlobCommitBlockListOptions{
+		Metadata:        props.Metadata,
+		BlobHTTPHeaders: props.Headers,
+		BlobTagsMap:     props.Tags,
+		Tier:            props.AccessTier,
+	}, record.Metadata[MetadataKeyETag])
+}
+
+func (d *Destination) deleteBlob(ctx context.Context, blobName string) error {
+	return d.blobWriter.Delete(ctx, blobName)
+}
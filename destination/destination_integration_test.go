@@ -0,0 +1,898 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build integration
+
+package destination
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/jaswdr/faker"
+	"github.com/linkedin/goavro/v2"
+	"github.com/miquido/conduit-connector-azure-storage/destination/format"
+	"github.com/miquido/conduit-connector-azure-storage/destination/writer"
+	"github.com/miquido/conduit-connector-azure-storage/internal"
+	"github.com/miquido/conduit-connector-azure-storage/internal/compression"
+	helper "github.com/miquido/conduit-connector-azure-storage/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDestination_FailsWhenConnectionStringIsInvalid(t *testing.T) {
+	ctx := context.Background()
+
+	var cfgRaw = map[string]string{
+		ConfigKeyConnectionString: "invalid connection string",
+		ConfigKeyContainerName:    "destination-integration-tests",
+	}
+
+	dest := NewDestination()
+
+	require.NoError(t, dest.Configure(ctx, cfgRaw))
+
+	t.Cleanup(func() {
+		_ = dest.Teardown(ctx)
+	})
+
+	require.ErrorContains(t, dest.Open(ctx), "connector open error: could not create account connection client: connection string is either blank or malformed.")
+}
+
+func TestDestination_FailsWhenContainerDoesNotExist(t *testing.T) {
+	ctx := context.Background()
+
+	var cfgRaw = map[string]string{
+		ConfigKeyConnectionString: helper.GetConnectionString(),
+		ConfigKeyContainerName:    "destination-integration-tests",
+	}
+
+	dest := NewDestination()
+
+	require.NoError(t, dest.Configure(ctx, cfgRaw))
+
+	t.Cleanup(func() {
+		_ = dest.Teardown(ctx)
+	})
+
+	require.ErrorContains(t, dest.Open(ctx), "Description=The specified container does not exist.")
+}
+
+func TestDestination_FailsWhenAccountDoesNotSupportBlobExpiry(t *testing.T) {
+	ctx := context.Background()
+
+	// Azurite emulates an account without a hierarchical namespace
+	var cfgRaw = map[string]string{
+		ConfigKeyConnectionString: helper.GetConnectionString(),
+		ConfigKeyContainerName:    "destination-integration-tests",
+		ConfigKeyExpiryPeriod:     "24h",
+	}
+
+	dest := NewDestination()
+
+	require.NoError(t, dest.Configure(ctx, cfgRaw))
+
+	t.Cleanup(func() {
+		_ = dest.Teardown(ctx)
+	})
+
+	require.EqualError(t, dest.Open(ctx), "connector open error: \"expiryPeriod\" requires an account with a hierarchical namespace enabled")
+}
+
+func TestDestination_WritesAndDeletesBlobs(t *testing.T) {
+	ctx := context.Background()
+	fakerInstance := faker.New()
+
+	var (
+		containerName = "destination-integration-tests"
+
+		cfgRaw = map[string]string{
+			ConfigKeyConnectionString: helper.GetConnectionString(),
+			ConfigKeyContainerName:    containerName,
+		}
+	)
+
+	containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+	var (
+		blobName        = "written-by-destination.txt"
+		blobContents    = fakerInstance.Lorem().Sentence(16)
+		updatedContents = fakerInstance.Lorem().Sentence(16)
+	)
+
+	dest := NewDestination()
+
+	require.NoError(t, dest.Configure(ctx, cfgRaw))
+	require.NoError(t, dest.Open(ctx))
+
+	t.Cleanup(func() {
+		_ = dest.Teardown(ctx)
+	})
+
+	t.Run("Inserted record is written as a new blob", func(t *testing.T) {
+		require.NoError(t, dest.Write(ctx, sdk.Record{
+			Metadata: map[string]string{"action": internal.OperationInsert},
+			Key:      sdk.RawData(blobName),
+			Payload:  sdk.RawData(blobContents),
+		}))
+
+		contents, err := helper.ReadBlob(containerClient, blobName)
+		require.NoError(t, err)
+		require.Equal(t, blobContents, contents)
+	})
+
+	t.Run("Updated record overwrites the blob", func(t *testing.T) {
+		require.NoError(t, dest.Write(ctx, sdk.Record{
+			Metadata: map[string]string{"action": internal.OperationUpdate},
+			Key:      sdk.RawData(blobName),
+			Payload:  sdk.RawData(updatedContents),
+		}))
+
+		contents, err := helper.ReadBlob(containerClient, blobName)
+		require.NoError(t, err)
+		require.Equal(t, updatedContents, contents)
+	})
+
+	t.Run("Deleted record removes the blob", func(t *testing.T) {
+		require.NoError(t, dest.Write(ctx, sdk.Record{
+			Metadata: map[string]string{"action": internal.OperationDelete},
+			Key:      sdk.RawData(blobName),
+		}))
+
+		_, err := helper.ReadBlob(containerClient, blobName)
+		require.ErrorContains(t, err, "BlobNotFound")
+	})
+
+	t.Run("Deleting a missing blob succeeds", func(t *testing.T) {
+		require.NoError(t, dest.Write(ctx, sdk.Record{
+			Metadata: map[string]string{"action": internal.OperationDelete},
+			Key:      sdk.RawData(blobName),
+		}))
+	})
+}
+
+func TestDestination_UploadsLargeBlobsInBlocks(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		containerName = "destination-integration-tests"
+
+		cfgRaw = map[string]string{
+			ConfigKeyConnectionString:  helper.GetConnectionString(),
+			ConfigKeyContainerName:     containerName,
+			ConfigKeyBlockSize:         "65536",
+			ConfigKeyUploadParallelism: "3",
+		}
+	)
+
+	containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+	dest := NewDestination()
+
+	require.NoError(t, dest.Configure(ctx, cfgRaw))
+	require.NoError(t, dest.Open(ctx))
+
+	t.Cleanup(func() {
+		_ = dest.Teardown(ctx)
+	})
+
+	blobContents := strings.Repeat("0123456789abcdef", 65536)
+
+	require.NoError(t, dest.Write(ctx, sdk.Record{
+		Metadata: map[string]string{"action": internal.OperationInsert},
+		Key:      sdk.RawData("large.bin"),
+		Payload:  sdk.RawData(blobContents),
+	}))
+
+	blockBlobClient, err := containerClient.NewBlockBlobClient("large.bin")
+	require.NoError(t, err)
+
+	blockList, err := blockBlobClient.GetBlockList(ctx, azblob.BlockListTypeCommitted, nil)
+	require.NoError(t, err)
+	require.Len(t, blockList.CommittedBlocks, 16)
+
+	contents, err := helper.ReadBlob(containerClient, "large.bin")
+	require.NoError(t, err)
+	require.Equal(t, blobContents, contents)
+}
+
+func TestDestination_GuardsBlobsWithWritePolicy(t *testing.T) {
+	ctx := context.Background()
+	fakerInstance := faker.New()
+
+	var containerName = "destination-integration-tests"
+
+	openDestination := func(t *testing.T, cfg map[string]string) sdk.Destination {
+		cfgRaw := map[string]string{
+			ConfigKeyConnectionString: helper.GetConnectionString(),
+			ConfigKeyContainerName:    containerName,
+		}
+		for key, value := range cfg {
+			cfgRaw[key] = value
+		}
+
+		dest := NewDestination()
+
+		require.NoError(t, dest.Configure(ctx, cfgRaw))
+		require.NoError(t, dest.Open(ctx))
+
+		t.Cleanup(func() {
+			_ = dest.Teardown(ctx)
+		})
+
+		return dest
+	}
+
+	newRecord := func(blobName, contents string) sdk.Record {
+		return sdk.Record{
+			Metadata: map[string]string{"action": internal.OperationInsert},
+			Key:      sdk.RawData(blobName),
+			Payload:  sdk.RawData(contents),
+		}
+	}
+
+	t.Run("Create Only policy fails when blob exists", func(t *testing.T) {
+		containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+		require.NoError(t, helper.CreateBlob(containerClient, "existing.txt", "text/plain", "original"))
+
+		dest := openDestination(t, map[string]string{
+			ConfigKeyWritePolicy: writer.WritePolicyCreateOnly,
+		})
+
+		require.NoError(t, dest.Write(ctx, newRecord("new.txt", "created")))
+		require.Equal(t, writer.WritePathCreated, writePath(t, containerClient, "new.txt"))
+
+		err := dest.Write(ctx, newRecord("existing.txt", fakerInstance.Lorem().Sentence(8)))
+		require.ErrorIs(t, err, writer.ErrWriteConflict)
+
+		contents, err := helper.ReadBlob(containerClient, "existing.txt")
+		require.NoError(t, err)
+		require.Equal(t, "original", contents)
+	})
+
+	t.Run("Create Only policy skips the existing blob", func(t *testing.T) {
+		containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+		require.NoError(t, helper.CreateBlob(containerClient, "existing.txt", "text/plain", "original"))
+
+		dest := openDestination(t, map[string]string{
+			ConfigKeyWritePolicy:      writer.WritePolicyCreateOnly,
+			ConfigKeyConflictReaction: writer.ConflictReactionSkip,
+		})
+
+		require.NoError(t, dest.Write(ctx, newRecord("existing.txt", fakerInstance.Lorem().Sentence(8))))
+
+		contents, err := helper.ReadBlob(containerClient, "existing.txt")
+		require.NoError(t, err)
+		require.Equal(t, "original", contents)
+	})
+
+	t.Run("Create Only policy writes the conflicting blob with the prefix", func(t *testing.T) {
+		containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+		require.NoError(t, helper.CreateBlob(containerClient, "existing.txt", "text/plain", "original"))
+
+		dest := openDestination(t, map[string]string{
+			ConfigKeyWritePolicy:      writer.WritePolicyCreateOnly,
+			ConfigKeyConflictReaction: writer.ConflictReactionConflictPrefix,
+			ConfigKeyConflictPrefix:   "clashes/",
+		})
+
+		require.NoError(t, dest.Write(ctx, newRecord("existing.txt", "conflicting")))
+
+		contents, err := helper.ReadBlob(containerClient, "existing.txt")
+		require.NoError(t, err)
+		require.Equal(t, "original", contents)
+
+		contents, err = helper.ReadBlob(containerClient, "clashes/existing.txt")
+		require.NoError(t, err)
+		require.Equal(t, "conflicting", contents)
+		require.Equal(t, writer.WritePathConflict, writePath(t, containerClient, "clashes/existing.txt"))
+	})
+
+	t.Run("If Match Last Seen policy fails when blob was modified by another writer", func(t *testing.T) {
+		containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+		dest := openDestination(t, map[string]string{
+			ConfigKeyWritePolicy: writer.WritePolicyIfMatchLastSeen,
+		})
+
+		require.NoError(t, dest.Write(ctx, newRecord("blob.txt", "first")))
+		require.Equal(t, writer.WritePathCreated, writePath(t, containerClient, "blob.txt"))
+
+		require.NoError(t, dest.Write(ctx, newRecord("blob.txt", "second")))
+		require.Equal(t, writer.WritePathMatched, writePath(t, containerClient, "blob.txt"))
+
+		require.NoError(t, helper.CreateBlob(containerClient, "blob.txt", "text/plain", "modified elsewhere"))
+
+		err := dest.Write(ctx, newRecord("blob.txt", "third"))
+		require.ErrorIs(t, err, writer.ErrWriteConflict)
+
+		contents, err := helper.ReadBlob(containerClient, "blob.txt")
+		require.NoError(t, err)
+		require.Equal(t, "modified elsewhere", contents)
+	})
+
+	t.Run("If Match Last Seen policy updates the blob written before a restart", func(t *testing.T) {
+		containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+		cfg := map[string]string{
+			ConfigKeyWritePolicy: writer.WritePolicyIfMatchLastSeen,
+		}
+
+		require.NoError(t, openDestination(t, cfg).Write(ctx, newRecord("blob.txt", "first")))
+		require.NoError(t, openDestination(t, cfg).Write(ctx, newRecord("blob.txt", "second")))
+		require.Equal(t, writer.WritePathMatched, writePath(t, containerClient, "blob.txt"))
+
+		contents, err := helper.ReadBlob(containerClient, "blob.txt")
+		require.NoError(t, err)
+		require.Equal(t, "second", contents)
+	})
+
+	t.Run("Overwrite policy tells the blob was overwritten", func(t *testing.T) {
+		containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+		dest := openDestination(t, nil)
+
+		require.NoError(t, dest.Write(ctx, newRecord("blob.txt", "contents")))
+		require.Equal(t, writer.WritePathOverwritten, writePath(t, containerClient, "blob.txt"))
+	})
+}
+
+func TestDestination_MapsRecordMetadataToBlobProperties(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		containerName = "destination-integration-tests"
+
+		cfgRaw = map[string]string{
+			ConfigKeyConnectionString: helper.GetConnectionString(),
+			ConfigKeyContainerName:    containerName,
+			ConfigKeyMetadataMapping:  "source-table:table",
+			ConfigKeyHeadersMapping:   "content-type:Content-Type,cache:Cache-Control,disposition:Content-Disposition",
+			ConfigKeyTagsMapping:      "tenant",
+		}
+	)
+
+	containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+	dest := NewDestination()
+
+	require.NoError(t, dest.Configure(ctx, cfgRaw))
+	require.NoError(t, dest.Open(ctx))
+
+	t.Cleanup(func() {
+		_ = dest.Teardown(ctx)
+	})
+
+	require.NoError(t, dest.Write(ctx, sdk.Record{
+		Metadata: map[string]string{
+			"action":       internal.OperationInsert,
+			"source-table": "orders",
+			"content-type": "application/json",
+			"cache":        "no-cache",
+			"disposition":  "attachment",
+			"tenant":       "acme",
+		},
+		Key:     sdk.RawData("mapped.json"),
+		Payload: sdk.RawData(`{"id":1}`),
+	}))
+
+	blobClient, err := containerClient.NewBlobClient("mapped.json")
+	require.NoError(t, err)
+
+	props, err := blobClient.GetProperties(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, "application/json", *props.ContentType)
+	require.Equal(t, "no-cache", *props.CacheControl)
+	require.Equal(t, "attachment", *props.ContentDisposition)
+
+	var table string
+	for key, value := range props.Metadata {
+		if strings.EqualFold(key, "table") {
+			table = value
+		}
+	}
+	require.Equal(t, "orders", table)
+
+	tags, err := blobClient.GetTags(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, tags.BlobTagSet, 1)
+	require.Equal(t, "tenant", *tags.BlobTagSet[0].Key)
+	require.Equal(t, "acme", *tags.BlobTagSet[0].Value)
+}
+
+func TestDestination_WritesBlobsInAccessTier(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		containerName = "destination-integration-tests"
+
+		cfgRaw = map[string]string{
+			ConfigKeyConnectionString:      helper.GetConnectionString(),
+			ConfigKeyContainerName:         containerName,
+			ConfigKeyAccessTier:            "Cool",
+			ConfigKeyAccessTierMetadataKey: "tier",
+		}
+	)
+
+	containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+	dest := NewDestination()
+
+	require.NoError(t, dest.Configure(ctx, cfgRaw))
+	require.NoError(t, dest.Open(ctx))
+
+	t.Cleanup(func() {
+		_ = dest.Teardown(ctx)
+	})
+
+	for blobName, metadata := range map[string]map[string]string{
+		"cool.txt":    {"action": internal.OperationInsert},
+		"archive.txt": {"action": internal.OperationInsert, "tier": "archive"},
+	} {
+		require.NoError(t, dest.Write(ctx, sdk.Record{
+			Metadata: metadata,
+			Key:      sdk.RawData(blobName),
+			Payload:  sdk.RawData(blobName),
+		}))
+	}
+
+	for blobName, tier := range map[string]string{
+		"cool.txt":    "Cool",
+		"archive.txt": "Archive",
+	} {
+		blobClient, err := containerClient.NewBlobClient(blobName)
+		require.NoError(t, err)
+
+		props, err := blobClient.GetProperties(ctx, nil)
+		require.NoError(t, err)
+		require.Equal(t, tier, *props.AccessTier)
+	}
+}
+
+func TestDestination_CompressesBlobs(t *testing.T) {
+	ctx := context.Background()
+	fakerInstance := faker.New()
+
+	var containerName = "destination-integration-tests"
+
+	for _, tt := range []struct {
+		format      format.Format
+		compression compression.Algorithm
+		blobName    string
+	}{
+		{format: format.FormatRaw, compression: compression.Gzip, blobName: "record.txt.gz"},
+		{format: format.FormatRaw, compression: compression.Snappy, blobName: "record.txt.sz"},
+		{format: format.FormatNDJSON, compression: compression.Zstd, blobName: "batch.ndjson.zst"},
+	} {
+		t.Run(fmt.Sprintf("%s in %s format", tt.compression, tt.format), func(t *testing.T) {
+			containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+			blobNameTemplate := "record.txt"
+			if format.IsBatched(tt.format) {
+				blobNameTemplate = "batch"
+			}
+
+			dest := NewDestination()
+
+			require.NoError(t, dest.Configure(ctx, map[string]string{
+				ConfigKeyConnectionString: helper.GetConnectionString(),
+				ConfigKeyContainerName:    containerName,
+				ConfigKeyFormat:           tt.format,
+				ConfigKeyBlobNameTemplate: blobNameTemplate,
+				ConfigKeyCompression:      tt.compression,
+			}))
+			require.NoError(t, dest.Open(ctx))
+
+			t.Cleanup(func() {
+				_ = dest.Teardown(ctx)
+			})
+
+			payload := fakerInstance.Lorem().Sentence(32)
+			record := sdk.Record{
+				Metadata: map[string]string{"action": internal.OperationInsert},
+				Key:      sdk.RawData("record.txt"),
+				Payload:  sdk.RawData(payload),
+			}
+
+			if format.IsBatched(tt.format) {
+				require.NoError(t, dest.WriteAsync(ctx, record, func(err error) error { return err }))
+				require.NoError(t, dest.Flush(ctx))
+			} else {
+				require.NoError(t, dest.Write(ctx, record))
+			}
+
+			blobClient, err := containerClient.NewBlobClient(tt.blobName)
+			require.NoError(t, err)
+
+			downloadResponse, err := blobClient.Download(ctx, nil)
+			require.NoError(t, err)
+
+			body := downloadResponse.Body(nil)
+			defer body.Close()
+
+			// Gzip encoded bodies are decompressed by the HTTP client already
+			encoding := tt.compression
+			if downloadResponse.ContentEncoding == nil {
+				encoding = ""
+			}
+
+			reader, err := compression.NewReader(body, encoding)
+			require.NoError(t, err)
+
+			contents, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			require.Contains(t, string(contents), payload)
+		})
+	}
+}
+
+// writePath reads the blob metadata telling which write path was taken. Metadata keys are compared ignoring
+// the case, since they are returned as HTTP headers.
+func writePath(t *testing.T, containerClient *azblob.ContainerClient, blobName string) string {
+	blobClient, err := containerClient.NewBlobClient(blobName)
+	require.NoError(t, err)
+
+	props, err := blobClient.GetProperties(context.Background(), nil)
+	require.NoError(t, err)
+
+	for key, value := range props.Metadata {
+		if strings.EqualFold(key, writer.WritePathMetadataKey) {
+			return value
+		}
+	}
+
+	return ""
+}
+
+func TestDestination_WritesBlobsUsingBlobNameTemplate(t *testing.T) {
+	ctx := context.Background()
+	fakerInstance := faker.New()
+
+	var (
+		containerName = "destination-integration-tests"
+
+		cfgRaw = map[string]string{
+			ConfigKeyConnectionString: helper.GetConnectionString(),
+			ConfigKeyContainerName:    containerName,
+			ConfigKeyBlobNameTemplate: `events/{{.Metadata.table}}/{{.CreatedAt.Format "2006/01/02"}}/{{.Key}}.json`,
+		}
+	)
+
+	containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+	var (
+		blobContents = fakerInstance.Lorem().Sentence(16)
+		createdAt    = time.Date(2022, 7, 14, 12, 0, 0, 0, time.UTC)
+	)
+
+	dest := NewDestination()
+
+	require.NoError(t, dest.Configure(ctx, cfgRaw))
+	require.NoError(t, dest.Open(ctx))
+
+	t.Cleanup(func() {
+		_ = dest.Teardown(ctx)
+	})
+
+	require.NoError(t, dest.Write(ctx, sdk.Record{
+		Metadata:  map[string]string{"action": internal.OperationInsert, "table": "users"},
+		Key:       sdk.RawData("42"),
+		Payload:   sdk.RawData(blobContents),
+		CreatedAt: createdAt,
+	}))
+
+	contents, err := helper.ReadBlob(containerClient, "events/users/2022/07/14/42.json")
+	require.NoError(t, err)
+	require.Equal(t, blobContents, contents)
+}
+
+func TestDestination_WritesBatchesOfRecordsAsNDJSON(t *testing.T) {
+	ctx := context.Background()
+	fakerInstance := faker.New()
+
+	var (
+		containerName = "destination-integration-tests"
+
+		cfgRaw = map[string]string{
+			ConfigKeyConnectionString: helper.GetConnectionString(),
+			ConfigKeyContainerName:    containerName,
+			ConfigKeyBlobNameTemplate: "batch-{{.Key}}",
+			ConfigKeyFormat:           "ndjson",
+			ConfigKeyBatchSize:        "3",
+		}
+	)
+
+	containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+	records := []sdk.Record{
+		{
+			Position:  sdk.Position("1"),
+			Metadata:  map[string]string{"action": internal.OperationInsert, "content-type": "text/plain"},
+			Key:       sdk.RawData("1"),
+			Payload:   sdk.RawData(fakerInstance.Lorem().Sentence(16)),
+			CreatedAt: time.Date(2022, 7, 14, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			Position:  sdk.Position("2"),
+			Metadata:  map[string]string{"action": internal.OperationUpdate, "content-type": "text/plain"},
+			Key:       sdk.RawData("2"),
+			Payload:   sdk.RawData(fakerInstance.Lorem().Sentence(16)),
+			CreatedAt: time.Date(2022, 7, 14, 12, 0, 1, 0, time.UTC),
+		},
+		{
+			Position:  sdk.Position("3"),
+			Metadata:  map[string]string{"action": internal.OperationDelete},
+			Key:       sdk.RawData("1"),
+			CreatedAt: time.Date(2022, 7, 14, 12, 0, 2, 0, time.UTC),
+		},
+	}
+
+	dest := NewDestination()
+
+	require.NoError(t, dest.Configure(ctx, cfgRaw))
+	require.NoError(t, dest.Open(ctx))
+
+	t.Cleanup(func() {
+		_ = dest.Teardown(ctx)
+	})
+
+	var acked []sdk.Position
+
+	for _, record := range records {
+		record := record
+
+		require.NoError(t, dest.WriteAsync(ctx, record, func(err error) error {
+			require.NoError(t, err)
+
+			acked = append(acked, record.Position)
+
+			return nil
+		}))
+	}
+
+	require.NoError(t, dest.Flush(ctx))
+	require.Equal(t, []sdk.Position{records[0].Position, records[1].Position, records[2].Position}, acked)
+
+	contents, err := helper.ReadBlob(containerClient, "batch-1.ndjson")
+	require.NoError(t, err)
+
+	decoded, err := format.DecodeNDJSON(strings.NewReader(contents))
+	require.NoError(t, err)
+	require.Equal(t, records, decoded)
+}
+
+func TestDestination_WritesBatchesOfRecordsAsParquet(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		containerName = "destination-integration-tests"
+
+		cfgRaw = map[string]string{
+			ConfigKeyConnectionString: helper.GetConnectionString(),
+			ConfigKeyContainerName:    containerName,
+			ConfigKeyBlobNameTemplate: "users/{{.Key}}",
+			ConfigKeyFormat:           "parquet",
+			ConfigKeyBatchSize:        "2",
+		}
+	)
+
+	containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+	dest := NewDestination()
+
+	require.NoError(t, dest.Configure(ctx, cfgRaw))
+	require.NoError(t, dest.Open(ctx))
+
+	t.Cleanup(func() {
+		_ = dest.Teardown(ctx)
+	})
+
+	for i, payload := range []sdk.Data{
+		sdk.StructuredData{"id": 1, "name": "first"},
+		sdk.RawData(`{"id":2,"name":"second"}`),
+	} {
+		require.NoError(t, dest.WriteAsync(ctx, sdk.Record{
+			Position: sdk.Position(strconv.Itoa(i)),
+			Metadata: map[string]string{"action": internal.OperationInsert},
+			Key:      sdk.RawData(strconv.Itoa(i)),
+			Payload:  payload,
+		}, func(err error) error {
+			require.NoError(t, err)
+
+			return nil
+		}))
+	}
+
+	blobClient, err := containerClient.NewBlobClient("users/0.parquet")
+	require.NoError(t, err)
+
+	properties, err := blobClient.GetProperties(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, "application/vnd.apache.parquet", *properties.ContentType)
+
+	contents, err := helper.ReadBlob(containerClient, "users/0.parquet")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(contents, "PAR1"))
+	require.True(t, strings.HasSuffix(contents, "PAR1"))
+}
+
+func TestDestination_WritesBatchesOfRecordsAsAvro(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		containerName = "destination-integration-tests"
+
+		cfgRaw = map[string]string{
+			ConfigKeyConnectionString: helper.GetConnectionString(),
+			ConfigKeyContainerName:    containerName,
+			ConfigKeyBlobNameTemplate: "users/{{.Key}}",
+			ConfigKeyFormat:           "avro",
+			ConfigKeyAvroCodec:        "deflate",
+			ConfigKeyBatchSize:        "2",
+		}
+	)
+
+	containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+	dest := NewDestination()
+
+	require.NoError(t, dest.Configure(ctx, cfgRaw))
+	require.NoError(t, dest.Open(ctx))
+
+	t.Cleanup(func() {
+		_ = dest.Teardown(ctx)
+	})
+
+	for i, payload := range []sdk.Data{
+		sdk.StructuredData{"id": 1, "name": "first"},
+		sdk.RawData(`{"id":2,"name":"second"}`),
+	} {
+		require.NoError(t, dest.WriteAsync(ctx, sdk.Record{
+			Position: sdk.Position(strconv.Itoa(i)),
+			Metadata: map[string]string{"action": internal.OperationInsert},
+			Key:      sdk.RawData(strconv.Itoa(i)),
+			Payload:  payload,
+		}, func(err error) error {
+			require.NoError(t, err)
+
+			return nil
+		}))
+	}
+
+	blobClient, err := containerClient.NewBlobClient("users/0.avro")
+	require.NoError(t, err)
+
+	properties, err := blobClient.GetProperties(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, "application/avro", *properties.ContentType)
+
+	contents, err := helper.ReadBlob(containerClient, "users/0.avro")
+	require.NoError(t, err)
+
+	ocfReader, err := goavro.NewOCFReader(strings.NewReader(contents))
+	require.NoError(t, err)
+
+	var names []interface{}
+
+	for ocfReader.Scan() {
+		row, err := ocfReader.Read()
+		require.NoError(t, err)
+
+		names = append(names, row.(map[string]interface{})["name"])
+	}
+
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"string": "first"},
+		map[string]interface{}{"string": "second"},
+	}, names)
+}
+
+func TestDestination_WritesBatchesOfRecordsAsCSV(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		containerName = "destination-integration-tests"
+
+		cfgRaw = map[string]string{
+			ConfigKeyConnectionString: helper.GetConnectionString(),
+			ConfigKeyContainerName:    containerName,
+			ConfigKeyBlobNameTemplate: "users/{{.Key}}",
+			ConfigKeyFormat:           "csv",
+			ConfigKeyCSVColumns:       "id,name",
+			ConfigKeyBatchSize:        "2",
+		}
+	)
+
+	containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+	dest := NewDestination()
+
+	require.NoError(t, dest.Configure(ctx, cfgRaw))
+	require.NoError(t, dest.Open(ctx))
+
+	t.Cleanup(func() {
+		_ = dest.Teardown(ctx)
+	})
+
+	for i, payload := range []sdk.Data{
+		sdk.StructuredData{"id": 1, "name": "first"},
+		sdk.RawData(`{"id":2,"name":"second"}`),
+	} {
+		require.NoError(t, dest.WriteAsync(ctx, sdk.Record{
+			Position: sdk.Position(strconv.Itoa(i)),
+			Metadata: map[string]string{"action": internal.OperationInsert},
+			Key:      sdk.RawData(strconv.Itoa(i)),
+			Payload:  payload,
+		}, func(err error) error {
+			require.NoError(t, err)
+
+			return nil
+		}))
+	}
+
+	contents, err := helper.ReadBlob(containerClient, "users/0.csv")
+	require.NoError(t, err)
+	require.Equal(t, "id,name\r\n1,first\r\n2,second\r\n", contents)
+}
+
+func TestDestination_AppendsRecordsToAppendBlob(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		containerName = "destination-integration-tests"
+
+		cfgRaw = map[string]string{
+			ConfigKeyConnectionString: helper.GetConnectionString(),
+			ConfigKeyContainerName:    containerName,
+			ConfigKeyBlobNameTemplate: "logs/{{.Metadata.service}}",
+			ConfigKeyBlobType:         "append",
+			ConfigKeyBatchSize:        "2",
+		}
+	)
+
+	containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+	dest := NewDestination()
+
+	require.NoError(t, dest.Configure(ctx, cfgRaw))
+	require.NoError(t, dest.Open(ctx))
+
+	t.Cleanup(func() {
+		_ = dest.Teardown(ctx)
+	})
+
+	for _, line := range []string{"first line", "second line", "third line"} {
+		require.NoError(t, dest.WriteAsync(ctx, sdk.Record{
+			Metadata: map[string]string{"action": internal.OperationInsert, "service": "api"},
+			Key:      sdk.RawData(line),
+			Payload:  sdk.RawData(line),
+		}, func(err error) error {
+			require.NoError(t, err)
+
+			return nil
+		}))
+	}
+
+	require.NoError(t, dest.Flush(ctx))
+
+	contents, err := helper.ReadBlob(containerClient, "logs/api.log")
+	require.NoError(t, err)
+	require.Equal(t, "first line\nsecond line\nthird line\n", contents)
+}
@@ -0,0 +1,65 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package destination
+
+import (
+	"context"
+	"testing"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDestination(t *testing.T) {
+	t.Run("New Destination can be created", func(t *testing.T) {
+		require.IsType(t, &Destination{}, NewDestination())
+	})
+}
+
+func TestDestination_Write(t *testing.T) {
+	t.Run("Fails when record key is empty", func(t *testing.T) {
+		dest := NewDestination()
+
+		require.NoError(t, dest.Configure(context.Background(), map[string]string{
+			ConfigKeyConnectionString: "connection string",
+			ConfigKeyContainerName:    "container",
+		}))
+
+		err := dest.Write(context.Background(), sdk.Record{
+			Payload: sdk.RawData("payload"),
+		})
+
+		require.ErrorIs(t, err, ErrEmptyRecordKey)
+	})
+
+	t.Run("Fails when blob name template renders empty name and record key is empty", func(t *testing.T) {
+		dest := NewDestination()
+
+		require.NoError(t, dest.Configure(context.Background(), map[string]string{
+			ConfigKeyConnectionString: "connection string",
+			ConfigKeyContainerName:    "container",
+			ConfigKeyBlobNameTemplate: "{{.Metadata.table}}",
+		}))
+
+		err := dest.Write(context.Background(), sdk.Record{
+			Metadata: map[string]string{},
+			Payload:  sdk.RawData("payload"),
+		})
+
+		require.ErrorIs(t, err, ErrEmptyRecordKey)
+	})
+}
@@ -0,0 +1,443 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package format
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/linkedin/goavro/v2"
+	"github.com/miquido/conduit-connector-azure-storage/internal/avroschema"
+)
+
+// Below is a list of all supported Avro block codecs.
+const (
+	AvroCodecNull    = goavro.CompressionNullLabel
+	AvroCodecDeflate = goavro.CompressionDeflateLabel
+	AvroCodecSnappy  = goavro.CompressionSnappyLabel
+)
+
+// DefaultAvroCodec is the default codec used to compress blocks of Avro files.
+const DefaultAvroCodec = AvroCodecNull
+
+var avroNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseAvroCodec validates the name of the Avro block codec.
+func ParseAvroCodec(name string) (string, error) {
+	switch name {
+	case AvroCodecNull, AvroCodecDeflate, AvroCodecSnappy:
+		return name, nil
+
+	default:
+		return "", fmt.Errorf("unsupported Avro codec %q", name)
+	}
+}
+
+// ParseAvroSchema validates the Avro schema, which is expected to describe a record.
+func ParseAvroSchema(s string) (string, error) {
+	if _, err := goavro.NewCodec(s); err != nil {
+		return "", err
+	}
+
+	var schema map[string]interface{}
+
+	if err := json.Unmarshal([]byte(s), &schema); err != nil || schema["type"] != "record" {
+		return "", errors.New("schema is expected to be an Avro record")
+	}
+
+	return s, nil
+}
+
+// AvroEncoder writes the records' payloads as an Avro Object Container File, one Avro record per payload.
+// Payloads are expected to be structured data, or raw JSON objects.
+// When Schema is empty it is inferred from all the payloads of the batch, the same way ParquetEncoder infers its schema.
+type AvroEncoder struct {
+	Schema string
+	Codec  string
+}
+
+func (e AvroEncoder) Encode(w io.Writer, records []sdk.Record) error {
+	rows := make([]map[string]interface{}, len(records))
+
+	for i, record := range records {
+		row, err := structuredPayload(record)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+
+		rows[i] = row
+	}
+
+	schemaText := e.Schema
+	if schemaText == "" {
+		var err error
+
+		if schemaText, err = inferAvroSchema(rows); err != nil {
+			return fmt.Errorf("could not infer the schema: %w", err)
+		}
+	}
+
+	var schema interface{}
+
+	if err := json.Unmarshal([]byte(schemaText), &schema); err != nil {
+		return fmt.Errorf("could not parse the schema: %w", err)
+	}
+
+	codec := e.Codec
+	if codec == "" {
+		codec = DefaultAvroCodec
+	}
+
+	ocfWriter, err := goavro.NewOCFWriter(goavro.OCFConfig{
+		W:               w,
+		Schema:          schemaText,
+		CompressionName: codec,
+	})
+	if err != nil {
+		return err
+	}
+
+	converter := newAvroConverter(schema)
+	natives := make([]interface{}, len(rows))
+
+	for i, row := range rows {
+		if natives[i], err = converter.convert(schema, "", row); err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+	}
+
+	return ocfWriter.Append(natives)
+}
+
+func (AvroEncoder) ContentType() string {
+	return "application/avro"
+}
+
+func (AvroEncoder) Extension() string {
+	return ".avro"
+}
+
+// inferAvroSchema builds the schema of a record with one nullable field per column of the inferred Parquet schema.
+func inferAvroSchema(rows []map[string]interface{}) (string, error) {
+	columns := inferParquetSchema(rows)
+
+	fields := make([]map[string]interface{}, len(columns))
+
+	for i, column := range columns {
+		if !avroNameRegexp.MatchString(column.Name) {
+			return "", fmt.Errorf("field name %q is not a valid Avro name", column.Name)
+		}
+
+		var fieldType interface{}
+
+		switch column.Type {
+		case ParquetTypeBoolean:
+			fieldType = "boolean"
+		case ParquetTypeInt64:
+			fieldType = "long"
+		case ParquetTypeDouble:
+			fieldType = "double"
+		case ParquetTypeBytes:
+			fieldType = "bytes"
+		case ParquetTypeTimestamp:
+			fieldType = map[string]interface{}{"type": "long", "logicalType": "timestamp-millis"}
+		default:
+			fieldType = "string"
+		}
+
+		fields[i] = map[string]interface{}{
+			"name":    column.Name,
+			"type":    []interface{}{"null", fieldType},
+			"default": nil,
+		}
+	}
+
+	schema, err := json.Marshal(map[string]interface{}{
+		"type":   "record",
+		"name":   "Record",
+		"fields": fields,
+	})
+
+	return string(schema), err
+}
+
+// avroConverter converts decoded payloads into the native values expected by goavro for the given schema.
+type avroConverter struct {
+	names avroschema.Names
+}
+
+func newAvroConverter(schema interface{}) *avroConverter {
+	return &avroConverter{names: avroschema.NewNames(schema)}
+}
+
+func (c *avroConverter) convert(schema interface{}, namespace string, value interface{}) (interface{}, error) {
+	switch s := schema.(type) {
+	case string:
+		if named, exists := c.names.Lookup(s, namespace); exists {
+			return c.convert(named, namespace, value)
+		}
+
+		return avroPrimitive(s, "", value)
+
+	case []interface{}:
+		return c.convertUnion(s, namespace, value)
+
+	case map[string]interface{}:
+		return c.convertComplex(s, namespace, value)
+
+	default:
+		return nil, fmt.Errorf("unsupported schema %v", schema)
+	}
+}
+
+// convertUnion picks the first branch matching the Go type of the value, or the first branch the value converts to.
+func (c *avroConverter) convertUnion(branches []interface{}, namespace string, value interface{}) (interface{}, error) {
+	if value == nil {
+		for _, branch := range branches {
+			if branch == "null" {
+				return nil, nil
+			}
+		}
+
+		return nil, errors.New("null value is not allowed by the union")
+	}
+
+	for _, exactOnly := range []bool{true, false} {
+		for _, branch := range branches {
+			if branch == "null" || (exactOnly && !c.matches(branch, namespace, value)) {
+				continue
+			}
+
+			native, err := c.convert(branch, namespace, value)
+			if err != nil {
+				continue
+			}
+
+			return goavro.Union(c.names.TypeName(branch, namespace), native), nil
+		}
+	}
+
+	return nil, fmt.Errorf("value of type %T does not match any type of the union", value)
+}
+
+func (c *avroConverter) convertComplex(schema map[string]interface{}, namespace string, value interface{}) (interface{}, error) {
+	typeName, _ := schema["type"].(string)
+
+	if _, ok := schema["name"].(string); ok {
+		namespace = avroschema.Namespace(schema, namespace)
+	}
+
+	switch typeName {
+	case "record":
+		fields, ok := toStringMap(value)
+		if !ok {
+			return nil, fmt.Errorf("value of type %T cannot be stored as record", value)
+		}
+
+		schemaFields, _ := schema["fields"].([]interface{})
+		native := make(map[string]interface{}, len(schemaFields))
+
+		for _, f := range schemaFields {
+			field, _ := f.(map[string]interface{})
+			name, _ := field["name"].(string)
+
+			fieldValue, exists := fields[name]
+			if !exists {
+				// goavro uses the field's default value, or fails when there is none
+				if _, hasDefault := field["default"]; hasDefault {
+					continue
+				}
+			}
+
+			v, err := c.convert(field["type"], namespace, fieldValue)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", name, err)
+			}
+
+			native[name] = v
+		}
+
+		return native, nil
+
+	case "array":
+		items := reflect.ValueOf(value)
+		if value == nil || (items.Kind() != reflect.Slice && items.Kind() != reflect.Array) {
+			return nil, fmt.Errorf("value of type %T cannot be stored as array", value)
+		}
+
+		native := make([]interface{}, items.Len())
+
+		for i := range native {
+			v, err := c.convert(schema["items"], namespace, items.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+
+			native[i] = v
+		}
+
+		return native, nil
+
+	case "map":
+		entries, ok := toStringMap(value)
+		if !ok {
+			return nil, fmt.Errorf("value of type %T cannot be stored as map", value)
+		}
+
+		native := make(map[string]interface{}, len(entries))
+
+		for key, entry := range entries {
+			v, err := c.convert(schema["values"], namespace, entry)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", key, err)
+			}
+
+			native[key] = v
+		}
+
+		return native, nil
+
+	case "enum":
+		symbol, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("value of type %T cannot be stored as enum", value)
+		}
+
+		return symbol, nil
+
+	case "fixed":
+		return avroPrimitive("bytes", "", value)
+
+	default:
+		logicalType, _ := schema["logicalType"].(string)
+
+		return avroPrimitive(typeName, logicalType, value)
+	}
+}
+
+// matches indicates whether the Go type of the value corresponds to the schema without any conversion.
+func (c *avroConverter) matches(schema interface{}, namespace string, value interface{}) bool {
+	typeName := c.names.TypeName(schema, namespace)
+	if named, exists := c.names[typeName]; exists {
+		if m, ok := named.(map[string]interface{}); ok {
+			typeName, _ = m["type"].(string)
+		}
+	} else if m, ok := schema.(map[string]interface{}); ok {
+		typeName, _ = m["type"].(string)
+	}
+
+	switch value.(type) {
+	case bool:
+		return typeName == "boolean"
+	case int, int8, int16, int32, int64, uint8, uint16, uint32, json.Number:
+		return typeName == "int" || typeName == "long"
+	case float32, float64:
+		return typeName == "float" || typeName == "double"
+	case string:
+		return typeName == "string" || typeName == "enum"
+	case []byte:
+		return typeName == "bytes" || typeName == "fixed"
+	case time.Time:
+		return typeName == "long"
+	default:
+		if _, ok := toStringMap(value); ok {
+			return typeName == "record" || typeName == "map"
+		}
+
+		kind := reflect.ValueOf(value).Kind()
+
+		return typeName == "array" && (kind == reflect.Slice || kind == reflect.Array)
+	}
+}
+
+func avroPrimitive(typeName string, logicalType string, value interface{}) (interface{}, error) {
+	if value == nil {
+		if typeName != "null" {
+			return nil, fmt.Errorf("null value cannot be stored as %s", typeName)
+		}
+
+		return nil, nil
+	}
+
+	switch typeName {
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(v)
+		}
+
+	case "int":
+		return parquetValue(ParquetTypeInt32, value)
+
+	case "long":
+		if logicalType == "timestamp-millis" || logicalType == "timestamp-micros" {
+			return avroTimestamp(value)
+		}
+
+		return toInt64(value)
+
+	case "float":
+		return parquetValue(ParquetTypeFloat, value)
+
+	case "double":
+		return toFloat64(value)
+
+	case "string":
+		return parquetValue(ParquetTypeString, value)
+
+	case "bytes":
+		switch v := value.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			return []byte(v), nil
+		}
+	}
+
+	return nil, fmt.Errorf("value of type %T cannot be stored as %s", value, typeName)
+}
+
+// avroTimestamp keeps time values, which goavro converts to the precision of the logical type.
+// Numbers are expected to be already expressed in that precision.
+func avroTimestamp(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return time.Parse(time.RFC3339Nano, v)
+	default:
+		return toInt64(value)
+	}
+}
+
+func toStringMap(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case sdk.StructuredData:
+		return v, true
+	default:
+		return nil, false
+	}
+}
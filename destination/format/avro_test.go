@@ -0,0 +1,197 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package format
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAvroSchema(t *testing.T) {
+	t.Run("Fails when schema is invalid", func(t *testing.T) {
+		_, err := ParseAvroSchema(`{"type":"record","name":"User"}`)
+
+		require.Error(t, err)
+	})
+
+	t.Run("Fails when schema is not a record", func(t *testing.T) {
+		_, err := ParseAvroSchema(`{"type":"array","items":"long"}`)
+
+		require.EqualError(t, err, "schema is expected to be an Avro record")
+	})
+}
+
+func TestAvroEncoder_Encode(t *testing.T) {
+	records := []sdk.Record{
+		{
+			Key:     sdk.RawData("1"),
+			Payload: sdk.StructuredData{"id": 1, "name": "first", "price": 9.99, "active": true},
+		},
+		{
+			Key:     sdk.RawData("2"),
+			Payload: sdk.RawData(`{"id":2,"name":"second","price":10,"tags":["a","b"]}`),
+		},
+	}
+
+	for _, codec := range []string{AvroCodecNull, AvroCodecDeflate, AvroCodecSnappy} {
+		codec := codec
+
+		t.Run("Infers the schema from the payloads using "+codec+" codec", func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			require.NoError(t, AvroEncoder{Codec: codec}.Encode(&buffer, records))
+
+			schema, rows := readAvro(t, buffer.Bytes())
+
+			require.JSONEq(t, `{"type":"record","name":"Record","fields":[
+				{"name":"active","type":["null","boolean"],"default":null},
+				{"name":"id","type":["null","long"],"default":null},
+				{"name":"name","type":["null","string"],"default":null},
+				{"name":"price","type":["null","double"],"default":null},
+				{"name":"tags","type":["null","string"],"default":null}
+			]}`, schema)
+			require.Equal(t, []interface{}{
+				map[string]interface{}{
+					"active": map[string]interface{}{"boolean": true},
+					"id":     map[string]interface{}{"long": int64(1)},
+					"name":   map[string]interface{}{"string": "first"},
+					"price":  map[string]interface{}{"double": 9.99},
+					"tags":   nil,
+				},
+				map[string]interface{}{
+					"active": nil,
+					"id":     map[string]interface{}{"long": int64(2)},
+					"name":   map[string]interface{}{"string": "second"},
+					"price":  map[string]interface{}{"double": float64(10)},
+					"tags":   map[string]interface{}{"string": `["a","b"]`},
+				},
+			}, rows)
+		})
+	}
+
+	t.Run("Uses the provided schema", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		encoder := AvroEncoder{
+			Schema: `{"type":"record","name":"User","namespace":"com.example","fields":[
+				{"name":"id","type":"int"},
+				{"name":"createdAt","type":{"type":"long","logicalType":"timestamp-millis"}},
+				{"name":"tags","type":{"type":"array","items":"string"},"default":[]},
+				{"name":"address","type":["null",{"type":"record","name":"Address","fields":[{"name":"city","type":"string"}]}]},
+				{"name":"previous","type":["null","Address","long"],"default":null}
+			]}`,
+		}
+
+		createdAt := time.Date(2022, 7, 14, 12, 0, 0, 0, time.UTC)
+
+		require.NoError(t, encoder.Encode(&buffer, []sdk.Record{
+			{Payload: sdk.StructuredData{
+				"id":        1,
+				"createdAt": createdAt,
+				"tags":      []string{"a", "b"},
+				"address":   map[string]interface{}{"city": "Kraków"},
+				"previous":  map[string]interface{}{"city": "Warsaw"},
+				"unknown":   true,
+			}},
+			{Payload: sdk.RawData(`{"id":2,"createdAt":"2022-07-14T12:00:00Z","address":null,"previous":3}`)},
+		}))
+
+		_, rows := readAvro(t, buffer.Bytes())
+
+		require.Equal(t, []interface{}{
+			map[string]interface{}{
+				"id":        int32(1),
+				"createdAt": createdAt,
+				"tags":      []interface{}{"a", "b"},
+				"address":   map[string]interface{}{"com.example.Address": map[string]interface{}{"city": "Kraków"}},
+				"previous":  map[string]interface{}{"com.example.Address": map[string]interface{}{"city": "Warsaw"}},
+			},
+			map[string]interface{}{
+				"id":        int32(2),
+				"createdAt": createdAt,
+				"tags":      []interface{}{},
+				"address":   nil,
+				"previous":  map[string]interface{}{"long": int64(3)},
+			},
+		}, rows)
+	})
+
+	t.Run("Writes values of logical types in unions", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		encoder := AvroEncoder{
+			Schema: `{"type":"record","name":"Event","fields":[
+				{"name":"at","type":["null",{"type":"long","logicalType":"timestamp-millis"}],"default":null}
+			]}`,
+		}
+
+		at := time.Date(2022, 7, 14, 12, 0, 0, 0, time.UTC)
+
+		require.NoError(t, encoder.Encode(&buffer, []sdk.Record{
+			{Payload: sdk.StructuredData{"at": at}},
+		}))
+
+		_, rows := readAvro(t, buffer.Bytes())
+
+		require.Equal(t, []interface{}{
+			map[string]interface{}{"at": map[string]interface{}{"long.timestamp-millis": at}},
+		}, rows)
+	})
+
+	t.Run("Fails when value does not match the provided schema", func(t *testing.T) {
+		encoder := AvroEncoder{
+			Schema: `{"type":"record","name":"User","fields":[{"name":"id","type":"long"}]}`,
+		}
+
+		err := encoder.Encode(&bytes.Buffer{}, []sdk.Record{
+			{Payload: sdk.StructuredData{"id": "first"}},
+		})
+
+		require.EqualError(t, err, "record 0: field \"id\": strconv.ParseInt: parsing \"first\": invalid syntax")
+	})
+
+	t.Run("Fails when field name cannot be used in the inferred schema", func(t *testing.T) {
+		err := AvroEncoder{}.Encode(&bytes.Buffer{}, []sdk.Record{
+			{Payload: sdk.StructuredData{"first-name": "John"}},
+		})
+
+		require.EqualError(t, err, "could not infer the schema: field name \"first-name\" is not a valid Avro name")
+	})
+}
+
+func readAvro(t *testing.T, data []byte) (schema string, rows []interface{}) {
+	t.Helper()
+
+	ocfReader, err := goavro.NewOCFReader(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	for ocfReader.Scan() {
+		row, err := ocfReader.Read()
+		require.NoError(t, err)
+
+		rows = append(rows, row)
+	}
+
+	require.NoError(t, ocfReader.Err())
+
+	return ocfReader.Codec().Schema(), rows
+}
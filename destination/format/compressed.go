@@ -0,0 +1,78 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package format
+
+import (
+	"fmt"
+	"io"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/internal/compression"
+)
+
+// Compress wraps the encoder, so its output is compressed with the algorithm while being encoded.
+// The encoder is returned as is when the algorithm is compression.None.
+func Compress(encoder Encoder, algorithm compression.Algorithm, level int) Encoder {
+	if algorithm == compression.None || algorithm == "" {
+		return encoder
+	}
+
+	return CompressedEncoder{
+		Encoder:   encoder,
+		Algorithm: algorithm,
+		Level:     level,
+	}
+}
+
+// CompressedEncoder streams the output of the wrapped Encoder through the compressor, so the uncompressed file
+// is never kept in memory. Compressed files of all the supported algorithms can be concatenated, so they can be
+// appended to append blobs.
+type CompressedEncoder struct {
+	Encoder   Encoder
+	Algorithm compression.Algorithm
+	Level     int
+}
+
+func (e CompressedEncoder) Encode(w io.Writer, records []sdk.Record) error {
+	compressor, err := compression.NewWriter(w, e.Algorithm, e.Level)
+	if err != nil {
+		return err
+	}
+
+	if err := e.Encoder.Encode(compressor, records); err != nil {
+		_ = compressor.Close()
+
+		return err
+	}
+
+	if err := compressor.Close(); err != nil {
+		return fmt.Errorf("could not compress the data: %w", err)
+	}
+
+	return nil
+}
+
+func (e CompressedEncoder) ContentType() string {
+	return e.Encoder.ContentType()
+}
+
+// ContentEncoding returns the value of the Content-Encoding header of the compressed file.
+func (e CompressedEncoder) ContentEncoding() string {
+	return compression.ContentEncoding(e.Algorithm)
+}
+
+func (e CompressedEncoder) Extension() string {
+	return e.Encoder.Extension() + compression.Extension(e.Algorithm)
+}
@@ -0,0 +1,55 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package format
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/internal/compression"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompress(t *testing.T) {
+	t.Run("Returns the encoder as is without compression", func(t *testing.T) {
+		require.Equal(t, NDJSONEncoder{}, Compress(NDJSONEncoder{}, compression.None, 0))
+	})
+
+	t.Run("Compresses the encoded file", func(t *testing.T) {
+		encoder := Compress(LinesEncoder{}, compression.Zstd, 3)
+
+		require.Equal(t, "text/plain; charset=utf-8", encoder.ContentType())
+		require.Equal(t, ".log.zst", encoder.Extension())
+		require.Equal(t, "zstd", encoder.(CompressedEncoder).ContentEncoding())
+
+		var buffer bytes.Buffer
+
+		require.NoError(t, encoder.Encode(&buffer, []sdk.Record{
+			{Payload: sdk.RawData("first")},
+			{Payload: sdk.RawData("second")},
+		}))
+
+		reader, err := compression.NewReader(&buffer, "zstd")
+		require.NoError(t, err)
+
+		decompressed, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, "first\nsecond\n", string(decompressed))
+	})
+}
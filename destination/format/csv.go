@@ -0,0 +1,206 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package format
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// DefaultCSVDelimiter is the default character separating values of CSV files.
+const DefaultCSVDelimiter = ','
+
+// ParseCSVColumns parses the comma-separated list of CSV columns.
+func ParseCSVColumns(s string) ([]string, error) {
+	columns := strings.Split(s, ",")
+	seen := make(map[string]bool, len(columns))
+
+	for i, column := range columns {
+		column = strings.TrimSpace(column)
+
+		if column == "" {
+			return nil, fmt.Errorf("column %d has an empty name", i)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("column %q is duplicated", column)
+		}
+
+		seen[column] = true
+		columns[i] = column
+	}
+
+	return columns, nil
+}
+
+// ParseCSVDelimiter parses the single character separating values of CSV files. `\t` stands for the tab character.
+func ParseCSVDelimiter(s string) (rune, error) {
+	if s == `\t` {
+		return '\t', nil
+	}
+
+	delimiter, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) {
+		return 0, fmt.Errorf("delimiter is expected to be a single character, got %q", s)
+	}
+
+	if delimiter == '"' || delimiter == '\r' || delimiter == '\n' || delimiter == utf8.RuneError {
+		return 0, fmt.Errorf("delimiter %q is not supported", s)
+	}
+
+	return delimiter, nil
+}
+
+// CSVEncoder writes the records' payloads as rows of a CSV file, preceded by the header row, as described in RFC 4180.
+// Payloads are expected to be structured data, or raw JSON objects. Nested objects are flattened, so their fields
+// become separate columns named with the dot-separated path, e.g. `address.city`.
+// When Columns are empty they are inferred from all the payloads of the batch, sorted by name.
+type CSVEncoder struct {
+	Columns   []string
+	Delimiter rune
+}
+
+func (e CSVEncoder) Encode(w io.Writer, records []sdk.Record) error {
+	rows := make([]map[string]interface{}, len(records))
+
+	for i, record := range records {
+		payload, err := structuredPayload(record)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+
+		rows[i] = make(map[string]interface{})
+		flattenCSVRow(rows[i], "", payload)
+	}
+
+	columns := e.Columns
+	if len(columns) == 0 {
+		columns = inferCSVColumns(rows)
+	}
+	if len(columns) == 0 {
+		return errors.New("there are no columns to write")
+	}
+
+	writer := csv.NewWriter(w)
+	writer.UseCRLF = true
+
+	writer.Comma = e.Delimiter
+	if writer.Comma == 0 {
+		writer.Comma = DefaultCSVDelimiter
+	}
+
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+
+	line := make([]string, len(columns))
+
+	for i, row := range rows {
+		for j, column := range columns {
+			value, err := csvValue(row[column])
+			if err != nil {
+				return fmt.Errorf("record %d: could not convert field %q: %w", i, column, err)
+			}
+
+			line[j] = value
+		}
+
+		if err := writer.Write(line); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func (CSVEncoder) ContentType() string {
+	return "text/csv; charset=utf-8; header=present"
+}
+
+func (CSVEncoder) Extension() string {
+	return ".csv"
+}
+
+// flattenCSVRow copies the fields into the row, replacing nested objects with their fields prefixed with the path.
+func flattenCSVRow(row map[string]interface{}, prefix string, fields map[string]interface{}) {
+	for name, value := range fields {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flattenCSVRow(row, prefix+name+".", v)
+
+		case sdk.StructuredData:
+			flattenCSVRow(row, prefix+name+".", v)
+
+		default:
+			row[prefix+name] = value
+		}
+	}
+}
+
+func inferCSVColumns(rows []map[string]interface{}) []string {
+	seen := make(map[string]bool)
+
+	var columns []string
+
+	for _, row := range rows {
+		for column := range row {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+
+	sort.Strings(columns)
+
+	return columns
+}
+
+// csvValue formats the value of a single cell. Null values become empty cells, arrays are encoded as JSON.
+func csvValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, json.Number:
+		return fmt.Sprint(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	default:
+		encoded, err := json.Marshal(v)
+
+		return string(encoded), err
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package format
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCSVDelimiter(t *testing.T) {
+	t.Run("Fails when delimiter is a quote", func(t *testing.T) {
+		_, err := ParseCSVDelimiter(`"`)
+
+		require.EqualError(t, err, "delimiter \"\\\"\" is not supported")
+	})
+
+	t.Run("Returns tab delimiter", func(t *testing.T) {
+		delimiter, err := ParseCSVDelimiter(`\t`)
+
+		require.NoError(t, err)
+		require.Equal(t, '\t', delimiter)
+	})
+
+	t.Run("Returns multi-byte delimiter", func(t *testing.T) {
+		delimiter, err := ParseCSVDelimiter("§")
+
+		require.NoError(t, err)
+		require.Equal(t, '§', delimiter)
+	})
+}
+
+func TestCSVEncoder_Encode(t *testing.T) {
+	records := []sdk.Record{
+		{
+			Key: sdk.RawData("1"),
+			Payload: sdk.StructuredData{
+				"id":      1,
+				"name":    "Smith, John",
+				"note":    "said \"hi\"\nand left",
+				"address": map[string]interface{}{"city": "Kraków", "zip": nil},
+				"created": time.Date(2022, 7, 14, 12, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			Key:     sdk.RawData("2"),
+			Payload: sdk.RawData(`{"id":2,"price":10.5,"tags":["a","b"],"active":true}`),
+		},
+	}
+
+	t.Run("Infers the columns from the payloads", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		require.NoError(t, CSVEncoder{}.Encode(&buffer, records))
+		require.Equal(t, ""+
+			"active,address.city,address.zip,created,id,name,note,price,tags\r\n"+
+			",Kraków,,2022-07-14T12:00:00Z,1,\"Smith, John\",\"said \"\"hi\"\"\r\nand left\",,\r\n"+
+			"true,,,,2,,,10.5,\"[\"\"a\"\",\"\"b\"\"]\"\r\n",
+			buffer.String(),
+		)
+	})
+
+	t.Run("Uses the provided columns and delimiter", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		encoder := CSVEncoder{
+			Columns:   []string{"name", "id", "address.city", "missing"},
+			Delimiter: ';',
+		}
+
+		require.NoError(t, encoder.Encode(&buffer, records))
+		require.Equal(t, ""+
+			"name;id;address.city;missing\r\n"+
+			"Smith, John;1;Kraków;\r\n"+
+			";2;;\r\n",
+			buffer.String(),
+		)
+	})
+
+	t.Run("Fails when payload is not structured", func(t *testing.T) {
+		err := CSVEncoder{}.Encode(&bytes.Buffer{}, []sdk.Record{
+			{Payload: sdk.RawData("plain text")},
+		})
+
+		require.EqualError(t, err, "record 0: payload is neither structured data nor a JSON object")
+	})
+}
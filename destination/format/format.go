@@ -0,0 +1,127 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package format
+
+import (
+	"fmt"
+	"io"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+type Format = string
+
+// Below is a list of all supported output formats.
+// FormatRaw writes every record's payload into a separate blob, all the other formats write batches of records into
+// a single blob.
+const (
+	FormatRaw     Format = "raw"
+	FormatNDJSON  Format = "ndjson"
+	FormatParquet Format = "parquet"
+	FormatAvro    Format = "avro"
+	FormatCSV     Format = "csv"
+)
+
+// Options configure the encoders of the formats which need more than the format's name.
+type Options struct {
+	// ParquetSchema is the schema of Parquet files, it is inferred from the records when empty
+	ParquetSchema ParquetSchema
+
+	// ParquetRowGroupSize is the maximum number of rows in a single Parquet row group
+	ParquetRowGroupSize int
+
+	// AvroSchema is the schema of Avro files, it is inferred from the records when empty
+	AvroSchema string
+
+	// AvroCodec is the codec used to compress blocks of Avro files
+	AvroCodec string
+
+	// CSVColumns are the ordered columns of CSV files, they are inferred from the records when empty
+	CSVColumns []string
+
+	// CSVDelimiter is the character separating values of CSV files
+	CSVDelimiter rune
+}
+
+// Parse validates the name of the output format.
+func Parse(name string) (Format, error) {
+	switch name {
+	case FormatRaw, FormatNDJSON, FormatParquet, FormatAvro, FormatCSV:
+		return name, nil
+
+	default:
+		return "", fmt.Errorf("unsupported format %q", name)
+	}
+}
+
+// IsBatched indicates whether records written in given format are grouped into batches.
+func IsBatched(f Format) bool {
+	return f != FormatRaw
+}
+
+// NewEncoder creates the Encoder of the given batched format.
+func NewEncoder(f Format, options Options) (Encoder, error) {
+	switch f {
+	case FormatNDJSON:
+		return NDJSONEncoder{}, nil
+
+	case FormatParquet:
+		return ParquetEncoder{
+			Schema:       options.ParquetSchema,
+			RowGroupSize: options.ParquetRowGroupSize,
+		}, nil
+
+	case FormatAvro:
+		return AvroEncoder{
+			Schema: options.AvroSchema,
+			Codec:  options.AvroCodec,
+		}, nil
+
+	case FormatCSV:
+		return CSVEncoder{
+			Columns:   options.CSVColumns,
+			Delimiter: options.CSVDelimiter,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("no encoder available for format %q", f)
+	}
+}
+
+// KeepsOperation indicates whether the records encoded by the encoder keep their operation, so records with
+// the delete action can be written along with the other ones and told apart once decoded.
+func KeepsOperation(encoder Encoder) bool {
+	switch e := encoder.(type) {
+	case NDJSONEncoder:
+		return true
+
+	case CompressedEncoder:
+		return KeepsOperation(e.Encoder)
+
+	default:
+		return false
+	}
+}
+
+type Encoder interface {
+	// Encode writes all the records into w as a single file
+	Encode(w io.Writer, records []sdk.Record) error
+
+	// ContentType returns the MIME type of the encoded file
+	ContentType() string
+
+	// Extension returns the file extension, including the leading dot, of the encoded file
+	Extension() string
+}
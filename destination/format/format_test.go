@@ -0,0 +1,85 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package format
+
+import (
+	"testing"
+
+	"github.com/miquido/conduit-connector-azure-storage/internal/compression"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("Fails when format is not supported", func(t *testing.T) {
+		_, err := Parse("xml")
+
+		require.EqualError(t, err, "unsupported format \"xml\"")
+	})
+
+	t.Run("Returns supported format", func(t *testing.T) {
+		f, err := Parse("ndjson")
+
+		require.NoError(t, err)
+		require.Equal(t, FormatNDJSON, f)
+	})
+}
+
+func TestNewEncoder(t *testing.T) {
+	t.Run("Fails when format is not batched", func(t *testing.T) {
+		_, err := NewEncoder(FormatRaw, Options{})
+
+		require.EqualError(t, err, "no encoder available for format \"raw\"")
+	})
+
+	t.Run("Returns NDJSON encoder", func(t *testing.T) {
+		encoder, err := NewEncoder(FormatNDJSON, Options{})
+
+		require.NoError(t, err)
+		require.IsType(t, NDJSONEncoder{}, encoder)
+	})
+
+	t.Run("Returns Parquet encoder configured with options", func(t *testing.T) {
+		schema := ParquetSchema{{Name: "id", Type: ParquetTypeInt64}}
+
+		encoder, err := NewEncoder(FormatParquet, Options{ParquetSchema: schema, ParquetRowGroupSize: 10})
+
+		require.NoError(t, err)
+		require.Equal(t, ParquetEncoder{Schema: schema, RowGroupSize: 10}, encoder)
+	})
+
+	t.Run("Returns Avro encoder configured with options", func(t *testing.T) {
+		encoder, err := NewEncoder(FormatAvro, Options{AvroCodec: AvroCodecSnappy})
+
+		require.NoError(t, err)
+		require.Equal(t, AvroEncoder{Codec: AvroCodecSnappy}, encoder)
+	})
+
+	t.Run("Returns CSV encoder configured with options", func(t *testing.T) {
+		encoder, err := NewEncoder(FormatCSV, Options{CSVColumns: []string{"id"}, CSVDelimiter: ';'})
+
+		require.NoError(t, err)
+		require.Equal(t, CSVEncoder{Columns: []string{"id"}, Delimiter: ';'}, encoder)
+	})
+}
+
+func TestKeepsOperation(t *testing.T) {
+	require.True(t, KeepsOperation(NDJSONEncoder{}))
+	require.True(t, KeepsOperation(Compress(NDJSONEncoder{}, compression.Gzip, 0)))
+	require.False(t, KeepsOperation(LinesEncoder{}))
+	require.False(t, KeepsOperation(CSVEncoder{}))
+	require.False(t, KeepsOperation(Compress(AvroEncoder{}, compression.Gzip, 0)))
+}
@@ -0,0 +1,53 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package format
+
+import (
+	"bytes"
+	"io"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// LinesEncoder writes each record's payload as a separate line of text.
+// A trailing new line character is added to payloads that do not end with one already.
+type LinesEncoder struct{}
+
+func (LinesEncoder) Encode(w io.Writer, records []sdk.Record) error {
+	for _, record := range records {
+		var payload []byte
+		if record.Payload != nil {
+			payload = record.Payload.Bytes()
+		}
+
+		if !bytes.HasSuffix(payload, []byte("\n")) {
+			payload = append(payload[:len(payload):len(payload)], '\n')
+		}
+
+		if _, err := w.Write(payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (LinesEncoder) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func (LinesEncoder) Extension() string {
+	return ".log"
+}
@@ -0,0 +1,43 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package format
+
+import (
+	"bytes"
+	"testing"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinesEncoder_Encode(t *testing.T) {
+	t.Run("Writes every payload in a separate line", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		payload := []byte("already terminated\n")
+
+		require.NoError(t, LinesEncoder{}.Encode(&buffer, []sdk.Record{
+			{Payload: sdk.RawData("first")},
+			{Payload: sdk.RawData(payload[:len(payload)-1])},
+			{Payload: sdk.RawData(payload)},
+			{},
+		}))
+
+		require.Equal(t, "first\nalready terminated\nalready terminated\n\n", buffer.String())
+		require.Equal(t, "already terminated\n", string(payload), "payload was modified")
+	})
+}
@@ -0,0 +1,135 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package format
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// NDJSONEncoder writes each record as a separate JSON document, one per line.
+// All the record's fields are kept, so the records can be restored with DecodeNDJSON.
+type NDJSONEncoder struct{}
+
+func (NDJSONEncoder) Encode(w io.Writer, records []sdk.Record) error {
+	encoder := json.NewEncoder(w)
+
+	for _, record := range records {
+		if err := encoder.Encode(newNDJSONRecord(record)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (NDJSONEncoder) ContentType() string {
+	return "application/x-ndjson"
+}
+
+func (NDJSONEncoder) Extension() string {
+	return ".ndjson"
+}
+
+// DecodeNDJSON reads records written by NDJSONEncoder.
+func DecodeNDJSON(r io.Reader) ([]sdk.Record, error) {
+	var records []sdk.Record
+
+	decoder := json.NewDecoder(bufio.NewReader(r))
+
+	for {
+		var line ndjsonRecord
+
+		if err := decoder.Decode(&line); err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, nil
+			}
+
+			return nil, err
+		}
+
+		records = append(records, line.toRecord())
+	}
+}
+
+type ndjsonRecord struct {
+	Position  []byte            `json:"position"`
+	Metadata  map[string]string `json:"metadata"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Key       *ndjsonData       `json:"key"`
+	Payload   *ndjsonData       `json:"payload"`
+}
+
+// ndjsonData keeps the type of sdk.Data, so raw and structured data can be told apart when decoding.
+type ndjsonData struct {
+	RawData        *[]byte             `json:"rawData,omitempty"`
+	StructuredData *sdk.StructuredData `json:"structuredData,omitempty"`
+}
+
+func newNDJSONRecord(record sdk.Record) ndjsonRecord {
+	return ndjsonRecord{
+		Position:  record.Position,
+		Metadata:  record.Metadata,
+		CreatedAt: record.CreatedAt,
+		Key:       newNDJSONData(record.Key),
+		Payload:   newNDJSONData(record.Payload),
+	}
+}
+
+func newNDJSONData(data sdk.Data) *ndjsonData {
+	switch d := data.(type) {
+	case sdk.StructuredData:
+		return &ndjsonData{StructuredData: &d}
+
+	case nil:
+		return nil
+
+	default:
+		raw := d.Bytes()
+
+		return &ndjsonData{RawData: &raw}
+	}
+}
+
+func (r ndjsonRecord) toRecord() sdk.Record {
+	return sdk.Record{
+		Position:  r.Position,
+		Metadata:  r.Metadata,
+		CreatedAt: r.CreatedAt,
+		Key:       r.Key.toData(),
+		Payload:   r.Payload.toData(),
+	}
+}
+
+func (d *ndjsonData) toData() sdk.Data {
+	switch {
+	case d == nil:
+		return nil
+
+	case d.StructuredData != nil:
+		return *d.StructuredData
+
+	case d.RawData != nil:
+		return sdk.RawData(*d.RawData)
+
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package format
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNDJSONEncoder_Encode(t *testing.T) {
+	records := []sdk.Record{
+		{
+			Position:  sdk.Position("position-1"),
+			Metadata:  map[string]string{"action": "insert", "content-type": "text/plain"},
+			CreatedAt: time.Date(2022, 7, 14, 12, 0, 0, 123, time.UTC),
+			Key:       sdk.RawData("file-1.txt"),
+			Payload:   sdk.RawData("contents of the first file\n"),
+		},
+		{
+			Position:  sdk.Position("position-2"),
+			Metadata:  map[string]string{"action": "update"},
+			CreatedAt: time.Date(2022, 7, 14, 12, 0, 1, 0, time.UTC),
+			Key:       sdk.StructuredData{"id": "2"},
+			Payload:   sdk.StructuredData{"name": "second", "nested": map[string]interface{}{"ok": true}},
+		},
+		{
+			Position: sdk.Position("position-3"),
+			Metadata: map[string]string{"action": "delete"},
+			Key:      sdk.RawData("file-3.txt"),
+		},
+		{
+			Position: sdk.Position("position-4"),
+			Key:      sdk.RawData(""),
+			Payload:  sdk.StructuredData{},
+		},
+	}
+
+	t.Run("Writes one line per record", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		require.NoError(t, NDJSONEncoder{}.Encode(&buffer, records))
+		require.Len(t, strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n"), len(records))
+	})
+
+	t.Run("Records can be decoded without any loss", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		require.NoError(t, NDJSONEncoder{}.Encode(&buffer, records))
+
+		decoded, err := DecodeNDJSON(&buffer)
+		require.NoError(t, err)
+		require.Equal(t, records, decoded)
+	})
+}
+
+func TestDecodeNDJSON(t *testing.T) {
+	t.Run("Fails when a line is not valid JSON", func(t *testing.T) {
+		_, err := DecodeNDJSON(strings.NewReader("{\"key\":null}\nnot json\n"))
+
+		require.Error(t, err)
+	})
+
+	t.Run("Returns no records for empty input", func(t *testing.T) {
+		records, err := DecodeNDJSON(strings.NewReader(""))
+
+		require.NoError(t, err)
+		require.Empty(t, records)
+	})
+}
@@ -0,0 +1,445 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package format
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Below is a list of all supported Parquet column types.
+const (
+	ParquetTypeBoolean   = "boolean"
+	ParquetTypeInt32     = "int32"
+	ParquetTypeInt64     = "int64"
+	ParquetTypeFloat     = "float"
+	ParquetTypeDouble    = "double"
+	ParquetTypeString    = "string"
+	ParquetTypeBytes     = "bytes"
+	ParquetTypeTimestamp = "timestamp"
+)
+
+// DefaultParquetRowGroupSize is the default maximum number of rows in a single row group.
+const DefaultParquetRowGroupSize = 10_000
+
+var ErrPayloadIsNotStructured = errors.New("payload is neither structured data nor a JSON object")
+
+// ParquetField is a single, optional column of the Parquet file.
+type ParquetField struct {
+	Name string
+	Type string
+}
+
+// ParquetSchema is the ordered list of columns of the Parquet file.
+type ParquetSchema []ParquetField
+
+// ParseParquetSchema parses the schema written as a comma-separated list of `name:type` columns, e.g. `id:int64,name:string`.
+func ParseParquetSchema(s string) (ParquetSchema, error) {
+	var schema ParquetSchema
+
+	for _, column := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(column), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("column %q is expected to be written as name:type", column)
+		}
+
+		schema = append(schema, ParquetField{
+			Name: strings.TrimSpace(parts[0]),
+			Type: strings.ToLower(strings.TrimSpace(parts[1])),
+		})
+	}
+
+	if err := schema.validate(); err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+func (s ParquetSchema) validate() error {
+	if len(s) == 0 {
+		return errors.New("schema has no columns")
+	}
+
+	// The Parquet library capitalizes the first letter of the column name internally
+	names := make(map[string]bool, len(s))
+
+	for _, field := range s {
+		if field.Name == "" || strings.ContainsAny(field.Name, ",=\t") {
+			return fmt.Errorf("column name %q is not supported", field.Name)
+		}
+
+		internalName := strings.ToUpper(field.Name[:1]) + field.Name[1:]
+		if names[internalName] {
+			return fmt.Errorf("column name %q is duplicated", field.Name)
+		}
+		names[internalName] = true
+
+		if _, err := parquetMetadata(field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// metadata returns the columns' definitions understood by the Parquet library.
+func (s ParquetSchema) metadata() ([]string, error) {
+	metadata := make([]string, len(s))
+
+	for i, field := range s {
+		var err error
+
+		if metadata[i], err = parquetMetadata(field); err != nil {
+			return nil, err
+		}
+	}
+
+	return metadata, nil
+}
+
+func parquetMetadata(field ParquetField) (string, error) {
+	var typeDefinition string
+
+	switch field.Type {
+	case ParquetTypeBoolean:
+		typeDefinition = "type=BOOLEAN"
+	case ParquetTypeInt32:
+		typeDefinition = "type=INT32"
+	case ParquetTypeInt64:
+		typeDefinition = "type=INT64"
+	case ParquetTypeFloat:
+		typeDefinition = "type=FLOAT"
+	case ParquetTypeDouble:
+		typeDefinition = "type=DOUBLE"
+	case ParquetTypeString:
+		typeDefinition = "type=BYTE_ARRAY, convertedtype=UTF8"
+	case ParquetTypeBytes:
+		typeDefinition = "type=BYTE_ARRAY"
+	case ParquetTypeTimestamp:
+		typeDefinition = "type=INT64, convertedtype=TIMESTAMP_MILLIS"
+	default:
+		return "", fmt.Errorf("column %q has unsupported type %q", field.Name, field.Type)
+	}
+
+	return fmt.Sprintf("name=%s, %s, repetitiontype=OPTIONAL", field.Name, typeDefinition), nil
+}
+
+// ParquetEncoder writes the records' payloads as rows of a Parquet file, one column per payload's field.
+// Payloads are expected to be structured data, or raw JSON objects.
+// When Schema is empty it is inferred from all the payloads of the batch, with columns sorted by name.
+type ParquetEncoder struct {
+	Schema       ParquetSchema
+	RowGroupSize int
+}
+
+func (e ParquetEncoder) Encode(w io.Writer, records []sdk.Record) error {
+	rows := make([]map[string]interface{}, len(records))
+
+	for i, record := range records {
+		row, err := structuredPayload(record)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+
+		rows[i] = row
+	}
+
+	schema := e.Schema
+	if len(schema) == 0 {
+		schema = inferParquetSchema(rows)
+
+		if err := schema.validate(); err != nil {
+			return fmt.Errorf("could not infer the schema: %w", err)
+		}
+	}
+
+	metadata, err := schema.metadata()
+	if err != nil {
+		return err
+	}
+
+	pw, err := writer.NewCSVWriterFromWriter(metadata, w, 1)
+	if err != nil {
+		return err
+	}
+
+	rowGroupSize := e.RowGroupSize
+	if rowGroupSize < 1 {
+		rowGroupSize = DefaultParquetRowGroupSize
+	}
+
+	for i, row := range rows {
+		values := make([]interface{}, len(schema))
+
+		for j, field := range schema {
+			if values[j], err = parquetValue(field.Type, row[field.Name]); err != nil {
+				return fmt.Errorf("record %d: could not convert field %q: %w", i, field.Name, err)
+			}
+		}
+
+		if err := pw.Write(values); err != nil {
+			return err
+		}
+
+		if (i+1)%rowGroupSize == 0 {
+			if err := pw.Flush(true); err != nil {
+				return err
+			}
+		}
+	}
+
+	return pw.WriteStop()
+}
+
+func (ParquetEncoder) ContentType() string {
+	return "application/vnd.apache.parquet"
+}
+
+func (ParquetEncoder) Extension() string {
+	return ".parquet"
+}
+
+// structuredPayload returns the fields of the record's payload.
+func structuredPayload(record sdk.Record) (map[string]interface{}, error) {
+	switch payload := record.Payload.(type) {
+	case sdk.StructuredData:
+		return payload, nil
+
+	case nil:
+		return map[string]interface{}{}, nil
+
+	default:
+		var fields map[string]interface{}
+
+		if err := json.Unmarshal(payload.Bytes(), &fields); err != nil || fields == nil {
+			return nil, ErrPayloadIsNotStructured
+		}
+
+		return fields, nil
+	}
+}
+
+// inferParquetSchema picks the narrowest type able to hold all the values of every field.
+// Fields with conflicting types, nested values, or only null values are stored as strings.
+func inferParquetSchema(rows []map[string]interface{}) ParquetSchema {
+	types := make(map[string]string)
+
+	for _, row := range rows {
+		for name, value := range row {
+			valueType := inferParquetType(value)
+
+			switch current, exists := types[name]; {
+			case !exists || current == "":
+				types[name] = valueType
+
+			case valueType == "" || valueType == current:
+				// Keep the current type
+
+			case isParquetNumber(current) && isParquetNumber(valueType):
+				types[name] = ParquetTypeDouble
+
+			default:
+				types[name] = ParquetTypeString
+			}
+		}
+	}
+
+	schema := make(ParquetSchema, 0, len(types))
+
+	for name, fieldType := range types {
+		if fieldType == "" {
+			fieldType = ParquetTypeString
+		}
+
+		schema = append(schema, ParquetField{Name: name, Type: fieldType})
+	}
+
+	sort.Slice(schema, func(i, j int) bool {
+		return schema[i].Name < schema[j].Name
+	})
+
+	return schema
+}
+
+func inferParquetType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case bool:
+		return ParquetTypeBoolean
+	case int, int8, int16, int32, int64, uint8, uint16, uint32:
+		return ParquetTypeInt64
+	case float32:
+		return inferParquetFloatType(float64(v))
+	case float64:
+		return inferParquetFloatType(v)
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return ParquetTypeInt64
+		}
+		return ParquetTypeDouble
+	case time.Time:
+		return ParquetTypeTimestamp
+	case []byte:
+		return ParquetTypeBytes
+	default:
+		return ParquetTypeString
+	}
+}
+
+// inferParquetFloatType treats whole numbers as integers, as JSON decoders produce floats for all numbers.
+func inferParquetFloatType(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < math.MaxInt64 {
+		return ParquetTypeInt64
+	}
+
+	return ParquetTypeDouble
+}
+
+func isParquetNumber(t string) bool {
+	return t == ParquetTypeInt64 || t == ParquetTypeDouble
+}
+
+// parquetValue converts the value into the type expected by the Parquet library for the column of given type.
+func parquetValue(fieldType string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch fieldType {
+	case ParquetTypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(v)
+		}
+
+	case ParquetTypeInt32:
+		v, err := toInt64(value)
+		if err != nil {
+			return nil, err
+		}
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return nil, fmt.Errorf("value %d overflows int32", v)
+		}
+		return int32(v), nil
+
+	case ParquetTypeInt64:
+		return toInt64(value)
+
+	case ParquetTypeFloat:
+		v, err := toFloat64(value)
+		return float32(v), err
+
+	case ParquetTypeDouble:
+		return toFloat64(value)
+
+	case ParquetTypeString:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case []byte:
+			return string(v), nil
+		case time.Time:
+			return v.Format(time.RFC3339Nano), nil
+		default:
+			encoded, err := json.Marshal(v)
+			return string(encoded), err
+		}
+
+	case ParquetTypeBytes:
+		switch v := value.(type) {
+		case []byte:
+			return string(v), nil
+		case string:
+			return v, nil
+		}
+
+	case ParquetTypeTimestamp:
+		switch v := value.(type) {
+		case time.Time:
+			return v.UnixMilli(), nil
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, v)
+			return t.UnixMilli(), err
+		default:
+			return toInt64(value)
+		}
+	}
+
+	return nil, fmt.Errorf("value of type %T cannot be stored as %s", value, fieldType)
+}
+
+func toInt64(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case float32:
+		return toInt64(float64(v))
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("value %v is not a whole number", v)
+		}
+		return int64(v), nil
+	case json.Number:
+		return v.Int64()
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("value of type %T is not a number", value)
+	}
+}
+
+func toFloat64(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	default:
+		i, err := toInt64(value)
+		return float64(i), err
+	}
+}
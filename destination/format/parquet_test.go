@@ -0,0 +1,176 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestParseParquetSchema(t *testing.T) {
+	t.Run("Fails when column has no type", func(t *testing.T) {
+		_, err := ParseParquetSchema("id:int64,name")
+
+		require.EqualError(t, err, "column \"name\" is expected to be written as name:type")
+	})
+
+	t.Run("Fails when column names collide", func(t *testing.T) {
+		_, err := ParseParquetSchema("name:string,Name:string")
+
+		require.EqualError(t, err, "column name \"Name\" is duplicated")
+	})
+
+	t.Run("Fails when column name contains unsupported characters", func(t *testing.T) {
+		_, err := ParseParquetSchema("a=b:string")
+
+		require.EqualError(t, err, "column name \"a=b\" is not supported")
+	})
+
+	t.Run("Returns schema", func(t *testing.T) {
+		schema, err := ParseParquetSchema(" id : INT64 ,createdAt:timestamp")
+
+		require.NoError(t, err)
+		require.Equal(t, ParquetSchema{
+			{Name: "id", Type: ParquetTypeInt64},
+			{Name: "createdAt", Type: ParquetTypeTimestamp},
+		}, schema)
+	})
+}
+
+func TestParquetEncoder_Encode(t *testing.T) {
+	records := []sdk.Record{
+		{
+			Key:     sdk.RawData("1"),
+			Payload: sdk.StructuredData{"id": 1, "name": "first", "price": 9.99, "active": true},
+		},
+		{
+			Key:     sdk.RawData("2"),
+			Payload: sdk.RawData(`{"id":2,"name":"second","price":10,"tags":["a","b"]}`),
+		},
+		{
+			Key:     sdk.RawData("3"),
+			Payload: sdk.StructuredData{"id": 3, "name": nil, "price": 11.5, "active": false},
+		},
+	}
+
+	t.Run("Infers the schema from the payloads", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		require.NoError(t, ParquetEncoder{}.Encode(&buffer, records))
+
+		schema, rows, rowGroups := readParquet(t, buffer.Bytes())
+
+		require.Equal(t, []string{
+			"active:BOOLEAN",
+			"id:INT64",
+			"name:BYTE_ARRAY",
+			"price:DOUBLE",
+			"tags:BYTE_ARRAY",
+		}, schema)
+		require.Equal(t, 1, rowGroups)
+		require.JSONEq(t, `[
+			{"Active":true,"Id":1,"Name":"first","Price":9.99,"Tags":null},
+			{"Active":null,"Id":2,"Name":"second","Price":10,"Tags":"[\"a\",\"b\"]"},
+			{"Active":false,"Id":3,"Name":null,"Price":11.5,"Tags":null}
+		]`, rows)
+	})
+
+	t.Run("Uses the provided schema", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		encoder := ParquetEncoder{
+			Schema: ParquetSchema{
+				{Name: "name", Type: ParquetTypeString},
+				{Name: "id", Type: ParquetTypeInt32},
+				{Name: "missing", Type: ParquetTypeTimestamp},
+			},
+			RowGroupSize: 2,
+		}
+
+		require.NoError(t, encoder.Encode(&buffer, records))
+
+		schema, rows, rowGroups := readParquet(t, buffer.Bytes())
+
+		require.Equal(t, []string{"name:BYTE_ARRAY", "id:INT32", "missing:INT64"}, schema)
+		require.Equal(t, 2, rowGroups)
+		require.JSONEq(t, `[
+			{"Name":"first","Id":1,"Missing":null},
+			{"Name":"second","Id":2,"Missing":null},
+			{"Name":null,"Id":3,"Missing":null}
+		]`, rows)
+	})
+
+	t.Run("Converts timestamps", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		createdAt := time.Date(2022, 7, 14, 12, 0, 0, 0, time.UTC)
+
+		require.NoError(t, ParquetEncoder{}.Encode(&buffer, []sdk.Record{
+			{Payload: sdk.StructuredData{"createdAt": createdAt}},
+		}))
+
+		_, rows, _ := readParquet(t, buffer.Bytes())
+
+		require.JSONEq(t, `[{"CreatedAt":1657800000000}]`, rows)
+	})
+
+	t.Run("Fails when payload is not structured", func(t *testing.T) {
+		err := ParquetEncoder{}.Encode(&bytes.Buffer{}, []sdk.Record{
+			{Payload: sdk.RawData("plain text")},
+		})
+
+		require.EqualError(t, err, "record 0: payload is neither structured data nor a JSON object")
+	})
+
+	t.Run("Fails when value does not match the provided schema", func(t *testing.T) {
+		err := ParquetEncoder{Schema: ParquetSchema{{Name: "price", Type: ParquetTypeInt64}}}.Encode(&bytes.Buffer{}, records)
+
+		require.EqualError(t, err, "record 0: could not convert field \"price\": value 9.99 is not a whole number")
+	})
+}
+
+func readParquet(t *testing.T, data []byte) (schema []string, rows string, rowGroups int) {
+	t.Helper()
+
+	file, err := buffer.NewBufferFile(data)
+	require.NoError(t, err)
+
+	pr, err := reader.NewParquetReader(file, nil, 1)
+	require.NoError(t, err)
+
+	defer pr.ReadStop()
+
+	// The reader renames columns, so the names are taken from the original schema
+	for i, element := range pr.Footer.Schema[1:] {
+		schema = append(schema, pr.SchemaHandler.Infos[i+1].ExName+":"+element.Type.String())
+	}
+
+	values, err := pr.ReadByNumber(int(pr.GetNumRows()))
+	require.NoError(t, err)
+
+	encoded, err := json.Marshal(values)
+	require.NoError(t, err)
+
+	return schema, string(encoded), len(pr.Footer.RowGroups)
+}
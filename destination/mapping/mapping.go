@@ -0,0 +1,276 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mapping
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+// Below is a list of all HTTP headers the record metadata can be mapped to.
+const (
+	HeaderContentType        = "Content-Type"
+	HeaderContentEncoding    = "Content-Encoding"
+	HeaderCacheControl       = "Cache-Control"
+	HeaderContentDisposition = "Content-Disposition"
+)
+
+const (
+	// MaxTags is the maximum number of index tags a single blob can have.
+	// See: https://docs.microsoft.com/azure/storage/blobs/storage-manage-find-blobs#setting-blob-index-tags
+	MaxTags = 10
+
+	// MaxTagKeyLength and MaxTagValueLength are the maximum lengths of the index tag's key and value.
+	MaxTagKeyLength   = 128
+	MaxTagValueLength = 256
+)
+
+// Below is a list of all access tiers the blob can be written in.
+// See: https://docs.microsoft.com/azure/storage/blobs/access-tiers-overview
+const (
+	AccessTierHot     = azblob.AccessTierHot
+	AccessTierCool    = azblob.AccessTierCool
+	AccessTierCold    = azblob.AccessTier("Cold")
+	AccessTierArchive = azblob.AccessTierArchive
+)
+
+// ParseAccessTier parses the name of the access tier, ignoring the case.
+func ParseAccessTier(s string) (azblob.AccessTier, error) {
+	for _, tier := range []azblob.AccessTier{AccessTierHot, AccessTierCool, AccessTierCold, AccessTierArchive} {
+		if strings.EqualFold(s, string(tier)) {
+			return tier, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported access tier %q", s)
+}
+
+// Rule copies the value of the From record metadata key into the To blob property.
+type Rule struct {
+	From string
+	To   string
+}
+
+// ParseRules parses the comma-separated list of `from:to` rules. When `:to` is omitted, the property is named after
+// the record metadata key.
+func ParseRules(s string) ([]Rule, error) {
+	var rules []Rule
+
+	for i, entry := range strings.Split(s, ",") {
+		from, to, found := strings.Cut(entry, ":")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+
+		if !found {
+			to = from
+		}
+
+		if from == "" || to == "" {
+			return nil, fmt.Errorf("rule %d is expected to be written as from:to", i)
+		}
+
+		rules = append(rules, Rule{From: from, To: to})
+	}
+
+	return rules, nil
+}
+
+// ParseHeaderRules parses the comma-separated list of `from:header` rules, where header is one of the supported
+// HTTP headers, matched ignoring the case.
+func ParseHeaderRules(s string) ([]Rule, error) {
+	rules, err := ParseRules(s)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(rules))
+
+	for i, rule := range rules {
+		header, err := canonicalHeader(rule.To)
+		if err != nil {
+			return nil, err
+		}
+		if seen[header] {
+			return nil, fmt.Errorf("header %q is mapped more than once", header)
+		}
+
+		seen[header] = true
+		rules[i].To = header
+	}
+
+	return rules, nil
+}
+
+// ParseTagRules parses the comma-separated list of `from:tag` rules. Up to MaxTags rules are allowed.
+func ParseTagRules(s string) ([]Rule, error) {
+	rules, err := ParseRules(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rules) > MaxTags {
+		return nil, fmt.Errorf("blob can have at most %d tags, %d mapped", MaxTags, len(rules))
+	}
+
+	return rules, nil
+}
+
+// Mapping routes the record metadata into the blob's user metadata, HTTP headers, index tags and access tier.
+type Mapping struct {
+	Metadata []Rule
+	Headers  []Rule
+	Tags     []Rule
+
+	// AccessTier is the access tier of written blobs. Empty tier leaves the choice to the account's default.
+	AccessTier azblob.AccessTier
+
+	// AccessTierKey is the record metadata key holding the access tier of the blob, overriding AccessTier.
+	AccessTierKey string
+}
+
+// Properties are the blob properties the record metadata was mapped to.
+type Properties struct {
+	Metadata   map[string]string
+	Headers    *azblob.BlobHTTPHeaders
+	Tags       map[string]string
+	AccessTier *azblob.AccessTier
+}
+
+// Apply maps the record metadata into blob properties. Keys missing in the record metadata are skipped.
+// Metadata names, tag keys and tag values are sanitized, so they are accepted by the Blob Storage.
+// The access tier read from the record metadata falls back to AccessTier when it is not a supported tier.
+func (m Mapping) Apply(metadata map[string]string) Properties {
+	var props Properties
+
+	if m.AccessTier != "" {
+		props.AccessTier = to.Ptr(m.AccessTier)
+	}
+
+	if m.AccessTierKey != "" {
+		if tier, err := ParseAccessTier(metadata[m.AccessTierKey]); err == nil {
+			props.AccessTier = to.Ptr(tier)
+		}
+	}
+
+	for _, rule := range m.Metadata {
+		value, exists := metadata[rule.From]
+		if !exists {
+			continue
+		}
+
+		if props.Metadata == nil {
+			props.Metadata = make(map[string]string)
+		}
+
+		props.Metadata[SanitizeMetadataName(rule.To)] = value
+	}
+
+	for _, rule := range m.Headers {
+		value, exists := metadata[rule.From]
+		if !exists || value == "" {
+			continue
+		}
+
+		if props.Headers == nil {
+			props.Headers = &azblob.BlobHTTPHeaders{}
+		}
+
+		switch rule.To {
+		case HeaderContentType:
+			props.Headers.BlobContentType = to.Ptr(value)
+		case HeaderContentEncoding:
+			props.Headers.BlobContentEncoding = to.Ptr(value)
+		case HeaderCacheControl:
+			props.Headers.BlobCacheControl = to.Ptr(value)
+		case HeaderContentDisposition:
+			props.Headers.BlobContentDisposition = to.Ptr(value)
+		}
+	}
+
+	for _, rule := range m.Tags {
+		value, exists := metadata[rule.From]
+		if !exists {
+			continue
+		}
+
+		if props.Tags == nil {
+			props.Tags = make(map[string]string)
+		}
+
+		props.Tags[sanitizeTag(rule.To, MaxTagKeyLength)] = sanitizeTag(value, MaxTagValueLength)
+	}
+
+	return props
+}
+
+// SanitizeMetadataName converts the name into a valid C# identifier, as required by the Blob Storage, by replacing
+// all other characters than ASCII letters, digits and underscores with underscores. Names starting with a digit are
+// prefixed with an underscore. Metadata names are case-insensitive, so the name is lowercased.
+// See: https://docs.microsoft.com/rest/api/storageservices/setting-and-retrieving-properties-and-metadata-for-blob-resources
+func SanitizeMetadataName(name string) string {
+	var builder strings.Builder
+
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			builder.WriteRune(r)
+		} else {
+			builder.WriteRune('_')
+		}
+	}
+
+	sanitized := builder.String()
+
+	if sanitized == "" || (sanitized[0] >= '0' && sanitized[0] <= '9') {
+		sanitized = "_" + sanitized
+	}
+
+	return sanitized
+}
+
+// sanitizeTag replaces the characters not allowed in index tags with underscores, and truncates the result
+// to maxLength characters.
+// See: https://docs.microsoft.com/azure/storage/blobs/storage-manage-find-blobs#setting-blob-index-tags
+func sanitizeTag(s string, maxLength int) string {
+	var builder strings.Builder
+
+	for _, r := range s {
+		if builder.Len() >= maxLength {
+			break
+		}
+
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			builder.WriteRune(r)
+		case strings.ContainsRune(" +-./:=_", r):
+			builder.WriteRune(r)
+		default:
+			builder.WriteRune('_')
+		}
+	}
+
+	return builder.String()
+}
+
+func canonicalHeader(name string) (string, error) {
+	for _, header := range []string{HeaderContentType, HeaderContentEncoding, HeaderCacheControl, HeaderContentDisposition} {
+		if strings.EqualFold(name, header) {
+			return header, nil
+		}
+	}
+
+	return "", fmt.Errorf("header %q is not supported", name)
+}
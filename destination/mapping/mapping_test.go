@@ -0,0 +1,134 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package mapping
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRules(t *testing.T) {
+	t.Run("Names the property after the record metadata key", func(t *testing.T) {
+		rules, err := ParseRules("table, source:origin")
+
+		require.NoError(t, err)
+		require.Equal(t, []Rule{
+			{From: "table", To: "table"},
+			{From: "source", To: "origin"},
+		}, rules)
+	})
+
+	t.Run("Fails when rule has an empty side", func(t *testing.T) {
+		_, err := ParseRules("table,:origin")
+
+		require.EqualError(t, err, "rule 1 is expected to be written as from:to")
+	})
+}
+
+func TestParseHeaderRules(t *testing.T) {
+	t.Run("Matches headers ignoring the case", func(t *testing.T) {
+		rules, err := ParseHeaderRules("content-type:content-type,disposition:CONTENT-DISPOSITION")
+
+		require.NoError(t, err)
+		require.Equal(t, []Rule{
+			{From: "content-type", To: HeaderContentType},
+			{From: "disposition", To: HeaderContentDisposition},
+		}, rules)
+	})
+
+	t.Run("Fails when header is not supported", func(t *testing.T) {
+		_, err := ParseHeaderRules("language:Content-Language")
+
+		require.EqualError(t, err, "header \"Content-Language\" is not supported")
+	})
+
+	t.Run("Fails when header is mapped twice", func(t *testing.T) {
+		_, err := ParseHeaderRules("a:Cache-Control,b:cache-control")
+
+		require.EqualError(t, err, "header \"Cache-Control\" is mapped more than once")
+	})
+}
+
+func TestParseTagRules(t *testing.T) {
+	_, err := ParseTagRules("a,b,c,d,e,f,g,h,i,j,k")
+
+	require.EqualError(t, err, "blob can have at most 10 tags, 11 mapped")
+}
+
+func TestMapping_Apply(t *testing.T) {
+	m := Mapping{
+		Metadata: []Rule{{From: "table", To: "source-table"}, {From: "missing", To: "missing"}},
+		Headers:  []Rule{{From: "content-type", To: HeaderContentType}, {From: "cache", To: HeaderCacheControl}},
+		Tags:     []Rule{{From: "tenant", To: "tenant#id"}},
+	}
+
+	props := m.Apply(map[string]string{
+		"table":        "orders",
+		"content-type": "application/json",
+		"cache":        "no-cache",
+		"tenant":       "acme & co",
+	})
+
+	require.Equal(t, map[string]string{"source_table": "orders"}, props.Metadata)
+	require.Equal(t, &azblob.BlobHTTPHeaders{
+		BlobContentType:  to.Ptr("application/json"),
+		BlobCacheControl: to.Ptr("no-cache"),
+	}, props.Headers)
+	require.Equal(t, map[string]string{"tenant_id": "acme _ co"}, props.Tags)
+
+	require.Equal(t, Properties{}, m.Apply(nil))
+}
+
+func TestMapping_ApplyAccessTier(t *testing.T) {
+	m := Mapping{AccessTier: AccessTierCool, AccessTierKey: "tier"}
+
+	require.Equal(t, to.Ptr(AccessTierArchive), m.Apply(map[string]string{"tier": "archive"}).AccessTier)
+	require.Equal(t, to.Ptr(AccessTierCold), m.Apply(map[string]string{"tier": "Cold"}).AccessTier)
+	require.Equal(t, to.Ptr(AccessTierCool), m.Apply(map[string]string{"tier": "premium"}).AccessTier)
+	require.Equal(t, to.Ptr(AccessTierCool), m.Apply(nil).AccessTier)
+	require.Nil(t, Mapping{}.Apply(map[string]string{"tier": "Hot"}).AccessTier)
+}
+
+func TestParseAccessTier(t *testing.T) {
+	tier, err := ParseAccessTier("hot")
+	require.NoError(t, err)
+	require.Equal(t, AccessTierHot, tier)
+
+	_, err = ParseAccessTier("P10")
+	require.EqualError(t, err, "unsupported access tier \"P10\"")
+}
+
+func TestSanitizeMetadataName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"content-type": "content_type",
+		"Table":        "table",
+		"2nd.value":    "_2nd_value",
+		"zażółć":       "za____",
+		"":             "_",
+	} {
+		require.Equal(t, expected, SanitizeMetadataName(name))
+	}
+}
+
+func TestSanitizeTag(t *testing.T) {
+	require.Equal(t, "a+b-c.d/e:f=g_h i", sanitizeTag("a+b-c.d/e:f=g_h i", MaxTagKeyLength))
+	require.Equal(t, strings.Repeat("x", MaxTagKeyLength), sanitizeTag(strings.Repeat("x", 200), MaxTagKeyLength))
+}
@@ -0,0 +1,97 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package naming
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+const (
+	// DefaultTemplate names the blob after the record's key.
+	DefaultTemplate = "{{.Key}}"
+
+	// DefaultBatchTemplate names the blob after the time it was written, so consecutive batches do not overwrite
+	// each other.
+	DefaultBatchTemplate = `{{.WrittenAt.Format "2006/01/02/15-04-05.000000000"}}`
+)
+
+// NewTemplate parses the given Go template text and checks whether it can be evaluated against a record.
+// Empty text results in DefaultTemplate being used.
+func NewTemplate(text string) (*Template, error) {
+	if strings.TrimSpace(text) == "" {
+		text = DefaultTemplate
+	}
+
+	// Missing metadata keys are rendered as empty strings instead of "<no value>"
+	tpl, err := template.New("blobName").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	t := &Template{template: tpl}
+
+	// Dry-run the template, so referencing unknown fields is reported up front
+	if _, err := t.Execute(sdk.Record{Metadata: map[string]string{}}, time.Time{}); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+type Template struct {
+	template *template.Template
+}
+
+// Execute renders the blob name for the given record written at the given time.
+// Leading and trailing whitespaces are removed from the result.
+func (t *Template) Execute(record sdk.Record, writtenAt time.Time) (string, error) {
+	var buffer bytes.Buffer
+
+	if err := t.template.Execute(&buffer, newData(record, writtenAt)); err != nil {
+		return "", fmt.Errorf("could not execute template: %w", err)
+	}
+
+	return strings.TrimSpace(buffer.String()), nil
+}
+
+// data is the view of sdk.Record available from within the template.
+type data struct {
+	Key       string
+	Metadata  map[string]string
+	Position  string
+	CreatedAt time.Time
+	WrittenAt time.Time
+}
+
+func newData(record sdk.Record, writtenAt time.Time) data {
+	d := data{
+		Metadata:  record.Metadata,
+		Position:  string(record.Position),
+		CreatedAt: record.CreatedAt,
+		WrittenAt: writtenAt,
+	}
+
+	if record.Key != nil {
+		d.Key = string(record.Key.Bytes())
+	}
+
+	return d
+}
@@ -0,0 +1,105 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package naming
+
+import (
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTemplate(t *testing.T) {
+	t.Run("Fails when template cannot be parsed", func(t *testing.T) {
+		tpl, err := NewTemplate("{{.Key")
+
+		require.Nil(t, tpl)
+		require.EqualError(t, err, "invalid template: template: blobName:1: unclosed action")
+	})
+
+	t.Run("Fails when template references unknown field", func(t *testing.T) {
+		tpl, err := NewTemplate("{{.Table}}")
+
+		require.Nil(t, tpl)
+		require.ErrorContains(t, err, "can't evaluate field Table")
+	})
+
+	t.Run("Uses default template when text is empty", func(t *testing.T) {
+		tpl, err := NewTemplate(" ")
+		require.NoError(t, err)
+
+		name, err := tpl.Execute(sdk.Record{Key: sdk.RawData("file.txt")}, time.Now())
+		require.NoError(t, err)
+		require.Equal(t, "file.txt", name)
+	})
+}
+
+func TestTemplate_Execute(t *testing.T) {
+	record := sdk.Record{
+		Key:       sdk.RawData("42"),
+		Metadata:  map[string]string{"table": "users"},
+		Position:  sdk.Position("position"),
+		CreatedAt: time.Date(2022, 7, 14, 12, 0, 0, 0, time.UTC),
+	}
+
+	for _, tt := range []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "Key",
+			template: "{{.Key}}.json",
+			expected: "42.json",
+		},
+		{
+			name:     "Metadata and CreatedAt",
+			template: `events/{{.Metadata.table}}/{{.CreatedAt.Format "2006/01/02"}}/{{.Key}}.json`,
+			expected: "events/users/2022/07/14/42.json",
+		},
+		{
+			name:     "Missing Metadata key",
+			template: "{{.Metadata.missing}}",
+			expected: "",
+		},
+		{
+			name:     "Position",
+			template: "{{.Position}}",
+			expected: "position",
+		},
+		{
+			name:     "WrittenAt",
+			template: DefaultBatchTemplate,
+			expected: "2022/07/15/08-30-00.000000000",
+		},
+		{
+			name:     "Surrounding whitespaces",
+			template: " {{.Key}}\n",
+			expected: "42",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tpl, err := NewTemplate(tt.template)
+			require.NoError(t, err)
+
+			name, err := tpl.Execute(record, time.Date(2022, 7, 15, 8, 30, 0, 0, time.UTC))
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, name)
+		})
+	}
+}
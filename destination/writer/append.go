@@ -0,0 +1,275 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/destination/format"
+	"github.com/miquido/conduit-connector-azure-storage/destination/mapping"
+	"github.com/miquido/conduit-connector-azure-storage/destination/naming"
+)
+
+const (
+	// MaxAppendBlocks is the maximum number of blocks a single append blob can consist of.
+	// See: https://docs.microsoft.com/rest/api/storageservices/append-block#remarks
+	MaxAppendBlocks int32 = 50_000
+
+	// MaxAppendBlockBytes is the maximum size of a single block appended to the append blob.
+	MaxAppendBlockBytes = 4 * 1024 * 1024
+)
+
+// NewAppendWriter creates a writer which appends the cached records to an append blob, using the same batching rules
+// as NewBatchWriter. A new append blob is started when the current one is about to exceed MaxAppendBlocks blocks or
+// rotationBytes bytes, or when it is older than rotationInterval. Zero rotationBytes or rotationInterval disables
+// the respective rule. Properties of every new blob are mapped from the metadata of the first record appended to it.
+// When the template renders the name of a blob which needs to be rotated too, e.g. a constant one, the new blob's
+// name is numbered, e.g. `app.1.log`.
+func NewAppendWriter(
+	client *azblob.ContainerClient,
+	encoder format.Encoder,
+	blobNameTemplate *naming.Template,
+	propertiesMapping mapping.Mapping,
+	retention Retention,
+	maxRecords int,
+	maxBytes int,
+	flushInterval time.Duration,
+	rotationInterval time.Duration,
+	rotationBytes int64,
+) (*BatchWriter, error) {
+	if rotationInterval < 0 {
+		return nil, fmt.Errorf("rotationInterval is expected to be greater than or equal to 0, got %s", rotationInterval)
+	}
+	if rotationBytes < 0 {
+		return nil, fmt.Errorf("rotationBytes is expected to be greater than or equal to 0, got %d", rotationBytes)
+	}
+	if err := retention.validate(); err != nil {
+		return nil, err
+	}
+
+	w, err := newBatchWriter(&appendTarget{
+		client:            client,
+		encoder:           encoder,
+		blobNameTemplate:  blobNameTemplate,
+		propertiesMapping: propertiesMapping,
+		retention:         retention,
+		rotationInterval:  rotationInterval,
+		rotationBytes:     rotationBytes,
+	}, maxRecords, maxBytes, flushInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	w.keepsOperation = format.KeepsOperation(encoder)
+
+	return w, nil
+}
+
+// appendTarget appends every batch of records to the current append blob, and rolls over to a new one when needed.
+type appendTarget struct {
+	client            *azblob.ContainerClient
+	encoder           format.Encoder
+	blobNameTemplate  *naming.Template
+	propertiesMapping mapping.Mapping
+	retention         Retention
+	rotationInterval  time.Duration
+	rotationBytes     int64
+
+	blobClient *azblob.AppendBlobClient
+	blobName   string
+	createdAt  time.Time
+	size       int64
+	blocks     int32
+
+	// baseName and number are the name rendered for the current blob and the number its name was given
+	baseName string
+	number   int
+}
+
+func (t *appendTarget) store(ctx context.Context, records []sdk.Record) error {
+	var buffer bytes.Buffer
+
+	if err := t.encoder.Encode(&buffer, records); err != nil {
+		return fmt.Errorf("could not encode the batch of %d records: %w", len(records), err)
+	}
+
+	data := buffer.Bytes()
+
+	for len(data) > 0 {
+		block := data
+		if len(block) > MaxAppendBlockBytes {
+			block = block[:MaxAppendBlockBytes]
+		}
+
+		if t.needsRotation(int64(len(block))) {
+			if err := t.rotate(ctx, records[0], int64(len(block))); err != nil {
+				return err
+			}
+		}
+
+		resp, err := t.blobClient.AppendBlock(ctx, streaming.NopCloser(bytes.NewReader(block)), nil)
+		if err != nil {
+			return fmt.Errorf("could not append block to blob %q: %w", t.blobName, err)
+		}
+
+		t.size += int64(len(block))
+		t.blocks++
+
+		if resp.BlobCommittedBlockCount != nil {
+			t.blocks = *resp.BlobCommittedBlockCount
+		}
+
+		data = data[len(block):]
+	}
+
+	return nil
+}
+
+// needsRotation indicates whether the block of given size should be appended to a new blob.
+func (t *appendTarget) needsRotation(blockSize int64) bool {
+	switch {
+	case t.blobClient == nil:
+		return true
+
+	case t.blocks >= MaxAppendBlocks:
+		return true
+
+	case t.rotationBytes > 0 && t.size > 0 && t.size+blockSize > t.rotationBytes:
+		return true
+
+	case t.rotationInterval > 0 && time.Since(t.createdAt) >= t.rotationInterval:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// rotate creates a new append blob named after the first record appended to it. When the blob already exists and
+// it can still be appended to, e.g. after the connector's restart, the writer continues appending to it. Otherwise,
+// the following numbered names are tried, until a blob which can be appended to is found or created.
+func (t *appendTarget) rotate(ctx context.Context, first sdk.Record, blockSize int64) error {
+	now := time.Now().UTC()
+
+	baseName, err := renderBlobName(t.blobNameTemplate, t.encoder, first, now)
+	if err != nil {
+		return err
+	}
+
+	// The blobs numbered before the current one were rotated already
+	number := 0
+	if baseName == t.baseName {
+		number = t.number + 1
+	}
+
+	for ; ; number++ {
+		blobName := numberedBlobName(baseName, t.encoder.Extension(), number)
+
+		opened, err := t.open(ctx, first, blobName, now, blockSize)
+		if err != nil {
+			t.blobClient = nil
+
+			return err
+		}
+
+		if opened {
+			t.baseName, t.number = baseName, number
+
+			return nil
+		}
+	}
+}
+
+// open creates the append blob, or opens the existing one, and tells whether the block of given size can be
+// appended to it.
+func (t *appendTarget) open(ctx context.Context, first sdk.Record, blobName string, now time.Time, blockSize int64) (bool, error) {
+	blobClient, err := t.client.NewAppendBlobClient(blobName)
+	if err != nil {
+		return false, fmt.Errorf("could not create append blob %q: %w", blobName, err)
+	}
+
+	t.blobClient, t.blobName, t.createdAt, t.size, t.blocks = blobClient, blobName, now, 0, 0
+
+	mapped := blobProperties(t.propertiesMapping, t.encoder, first)
+
+	createOptions := &azblob.AppendBlobCreateOptions{
+		HTTPHeaders: mapped.Headers,
+		Metadata:    mapped.Metadata,
+		TagsMap:     mapped.Tags,
+		BlobAccessConditions: &azblob.BlobAccessConditions{
+			ModifiedAccessConditions: &azblob.ModifiedAccessConditions{
+				IfNoneMatch: to.Ptr("*"),
+			},
+		},
+	}
+	t.retention.appendOptions(createOptions, now)
+
+	_, err = blobClient.Create(ctx, createOptions)
+	if err == nil {
+		// The blob is already created, so creating it again would conflict with itself
+		if _, err := t.retention.expire(ctx, &blobClient.BlobClient, blobName, ""); err != nil {
+			sdk.Logger(ctx).Warn().Err(err).Str("blob", blobName).Msg("the created append blob does not expire")
+		}
+
+		return true, nil
+	}
+
+	var storageErr *azblob.StorageError
+	if !errors.As(err, &storageErr) || storageErr.ErrorCode != azblob.StorageErrorCodeBlobAlreadyExists {
+		return false, fmt.Errorf("could not create append blob %q: %w", blobName, err)
+	}
+
+	// The blob already exists, so continue appending to it, unless it reached the limits
+	props, err := blobClient.GetProperties(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("could not read properties of append blob %q: %w", blobName, err)
+	}
+
+	if props.BlobType == nil || *props.BlobType != azblob.BlobTypeAppendBlob {
+		return false, fmt.Errorf("could not append to blob %q: blob already exists and it is not an append blob", blobName)
+	}
+
+	if props.ContentLength != nil {
+		t.size = *props.ContentLength
+	}
+	if props.BlobCommittedBlockCount != nil {
+		t.blocks = *props.BlobCommittedBlockCount
+	}
+	if props.CreationTime != nil {
+		t.createdAt = *props.CreationTime
+	}
+
+	return !t.needsRotation(blockSize), nil
+}
+
+// numberedBlobName inserts the number before the extension of the blob's name, e.g. `app.1.log`.
+// The first blob, numbered zero, keeps the name.
+func numberedBlobName(name, extension string, number int) string {
+	if number == 0 {
+		return name
+	}
+
+	return strings.TrimSuffix(name, extension) + "." + strconv.Itoa(number) + extension
+}
@@ -0,0 +1,175 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build integration
+
+package writer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/destination/format"
+	"github.com/miquido/conduit-connector-azure-storage/destination/mapping"
+	"github.com/miquido/conduit-connector-azure-storage/destination/naming"
+	helper "github.com/miquido/conduit-connector-azure-storage/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendWriter(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		containerName = "append-writer-integration-tests"
+	)
+
+	newRecord := func(payload string) sdk.Record {
+		return sdk.Record{
+			Position: sdk.Position(payload),
+			Key:      sdk.RawData("app.log"),
+			Payload:  sdk.RawData(payload),
+		}
+	}
+
+	blobNameTemplate, err := naming.NewTemplate("{{.Key}}")
+	require.NoError(t, err)
+
+	t.Run("Appends consecutive batches to the same blob", func(t *testing.T) {
+		containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+		w, err := NewAppendWriter(containerClient, format.LinesEncoder{}, blobNameTemplate, mapping.Mapping{}, Retention{}, 2, 1024*1024, time.Hour, 0, 0)
+		require.NoError(t, err)
+
+		t.Cleanup(w.Stop)
+
+		acks := newAckRecorder()
+
+		require.NoError(t, w.Write(ctx, newRecord("line 1"), acks.ack("1")))
+		require.NoError(t, w.Write(ctx, newRecord("line 2"), acks.ack("2")))
+		require.NoError(t, w.Write(ctx, newRecord("line 3"), acks.ack("3")))
+		require.NoError(t, w.Flush(ctx))
+		require.Equal(t, map[string]error{"1": nil, "2": nil, "3": nil}, acks.received())
+
+		contents, err := helper.ReadBlob(containerClient, "app.log")
+		require.NoError(t, err)
+		require.Equal(t, "line 1\nline 2\nline 3\n", contents)
+	})
+
+	t.Run("Continues appending to the existing blob after restart", func(t *testing.T) {
+		containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+		for _, line := range []string{"before restart", "after restart"} {
+			w, err := NewAppendWriter(containerClient, format.LinesEncoder{}, blobNameTemplate, mapping.Mapping{}, Retention{}, 1, 1024*1024, time.Hour, 0, 0)
+			require.NoError(t, err)
+
+			require.NoError(t, w.Write(ctx, newRecord(line), func(err error) error {
+				require.NoError(t, err)
+
+				return nil
+			}))
+
+			w.Stop()
+		}
+
+		contents, err := helper.ReadBlob(containerClient, "app.log")
+		require.NoError(t, err)
+		require.Equal(t, "before restart\nafter restart\n", contents)
+	})
+
+	t.Run("Rolls over to a new blob when Rotation Bytes is reached", func(t *testing.T) {
+		containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+		rotatingTemplate, err := naming.NewTemplate(naming.DefaultBatchTemplate)
+		require.NoError(t, err)
+
+		w, err := NewAppendWriter(containerClient, format.LinesEncoder{}, rotatingTemplate, mapping.Mapping{}, Retention{}, 1, 1024*1024, time.Hour, 0, 10)
+		require.NoError(t, err)
+
+		t.Cleanup(w.Stop)
+
+		acks := newAckRecorder()
+
+		require.NoError(t, w.Write(ctx, newRecord("12345"), acks.ack("1")))
+		require.NoError(t, w.Write(ctx, newRecord("6789"), acks.ack("2")))
+		require.NoError(t, w.Write(ctx, newRecord("rotated"), acks.ack("3")))
+		require.Equal(t, map[string]error{"1": nil, "2": nil, "3": nil}, acks.received())
+
+		names, err := helper.ListBlobNames(containerClient)
+		require.NoError(t, err)
+		require.Len(t, names, 2)
+
+		contents, err := helper.ReadBlob(containerClient, names[0])
+		require.NoError(t, err)
+		require.Equal(t, "12345\n6789\n", contents)
+
+		contents, err = helper.ReadBlob(containerClient, names[1])
+		require.NoError(t, err)
+		require.Equal(t, "rotated\n", contents)
+	})
+
+	t.Run("Rolls over to a new blob when Rotation Interval passes", func(t *testing.T) {
+		containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+		rotatingTemplate, err := naming.NewTemplate(naming.DefaultBatchTemplate)
+		require.NoError(t, err)
+
+		w, err := NewAppendWriter(containerClient, format.LinesEncoder{}, rotatingTemplate, mapping.Mapping{}, Retention{}, 1, 1024*1024, time.Hour, 500*time.Millisecond, 0)
+		require.NoError(t, err)
+
+		t.Cleanup(w.Stop)
+
+		acks := newAckRecorder()
+
+		require.NoError(t, w.Write(ctx, newRecord("first"), acks.ack("1")))
+		require.NoError(t, w.Write(ctx, newRecord("second"), acks.ack("2")))
+
+		time.Sleep(time.Second)
+
+		require.NoError(t, w.Write(ctx, newRecord("third"), acks.ack("3")))
+
+		names, err := helper.ListBlobNames(containerClient)
+		require.NoError(t, err)
+		require.Len(t, names, 2)
+	})
+
+	t.Run("Numbers the new blobs when the template renders the same name", func(t *testing.T) {
+		containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+		w, err := NewAppendWriter(containerClient, format.LinesEncoder{}, blobNameTemplate, mapping.Mapping{}, Retention{}, 1, 1024*1024, time.Hour, 0, 10)
+		require.NoError(t, err)
+
+		acks := newAckRecorder()
+
+		require.NoError(t, w.Write(ctx, newRecord("12345"), acks.ack("1")))
+		require.NoError(t, w.Write(ctx, newRecord("rotated"), acks.ack("2")))
+		w.Stop()
+
+		// After the restart, the full blobs are skipped
+		w, err = NewAppendWriter(containerClient, format.LinesEncoder{}, blobNameTemplate, mapping.Mapping{}, Retention{}, 1, 1024*1024, time.Hour, 0, 10)
+		require.NoError(t, err)
+
+		t.Cleanup(w.Stop)
+
+		require.NoError(t, w.Write(ctx, newRecord("restarted"), acks.ack("3")))
+		require.Equal(t, map[string]error{"1": nil, "2": nil, "3": nil}, acks.received())
+
+		for name, expected := range map[string]string{"app.log": "12345\n", "app.1.log": "rotated\n", "app.2.log": "restarted\n"} {
+			contents, err := helper.ReadBlob(containerClient, name)
+			require.NoError(t, err)
+			require.Equal(t, expected, contents)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package writer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/miquido/conduit-connector-azure-storage/destination/format"
+	"github.com/miquido/conduit-connector-azure-storage/destination/mapping"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAppendWriter(t *testing.T) {
+	t.Run("Fail to create writer with negative Rotation Interval", func(t *testing.T) {
+		w, err := NewAppendWriter(nil, format.LinesEncoder{}, nil, mapping.Mapping{}, Retention{}, 1, 1, time.Second, -time.Second, 0)
+		require.Nil(t, w)
+		require.EqualError(t, err, "rotationInterval is expected to be greater than or equal to 0, got -1s")
+	})
+
+	t.Run("Fail to create writer with negative Rotation Bytes", func(t *testing.T) {
+		w, err := NewAppendWriter(nil, format.LinesEncoder{}, nil, mapping.Mapping{}, Retention{}, 1, 1, time.Second, 0, -1)
+		require.Nil(t, w)
+		require.EqualError(t, err, "rotationBytes is expected to be greater than or equal to 0, got -1")
+	})
+
+	t.Run("Fail to create writer with invalid batching rules", func(t *testing.T) {
+		w, err := NewAppendWriter(nil, format.LinesEncoder{}, nil, mapping.Mapping{}, Retention{}, 0, 1, time.Second, 0, 0)
+		require.Nil(t, w)
+		require.EqualError(t, err, "maxRecords is expected to be greater than or equal to 1, got 0")
+	})
+}
+
+func TestAppendTarget_NeedsRotation(t *testing.T) {
+	blobClient := &azblob.AppendBlobClient{}
+
+	for _, tt := range []struct {
+		name      string
+		target    appendTarget
+		blockSize int64
+		expected  bool
+	}{
+		{
+			name:     "No blob was created yet",
+			target:   appendTarget{},
+			expected: true,
+		},
+		{
+			name:     "Blob has the maximum number of blocks",
+			target:   appendTarget{blobClient: blobClient, blocks: MaxAppendBlocks, createdAt: time.Now()},
+			expected: true,
+		},
+		{
+			name:      "Block would exceed Rotation Bytes",
+			target:    appendTarget{blobClient: blobClient, rotationBytes: 100, size: 90, createdAt: time.Now()},
+			blockSize: 11,
+			expected:  true,
+		},
+		{
+			name:      "Block exceeds Rotation Bytes on its own, but blob is empty",
+			target:    appendTarget{blobClient: blobClient, rotationBytes: 100, createdAt: time.Now()},
+			blockSize: 101,
+			expected:  false,
+		},
+		{
+			name:     "Blob is older than Rotation Interval",
+			target:   appendTarget{blobClient: blobClient, rotationInterval: time.Minute, createdAt: time.Now().Add(-time.Hour)},
+			expected: true,
+		},
+		{
+			name:      "Blob can still be appended to",
+			target:    appendTarget{blobClient: blobClient, rotationBytes: 100, rotationInterval: time.Hour, size: 10, blocks: 1, createdAt: time.Now()},
+			blockSize: 10,
+			expected:  false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, tt.target.needsRotation(tt.blockSize))
+		})
+	}
+}
+
+func TestNumberedBlobName(t *testing.T) {
+	require.Equal(t, "app.log", numberedBlobName("app.log", ".log", 0))
+	require.Equal(t, "app.1.log", numberedBlobName("app.log", ".log", 1))
+	require.Equal(t, "logs/app.12.ndjson.gz", numberedBlobName("logs/app.ndjson.gz", ".ndjson.gz", 12))
+}
@@ -0,0 +1,235 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/destination/format"
+	"github.com/miquido/conduit-connector-azure-storage/destination/mapping"
+	"github.com/miquido/conduit-connector-azure-storage/destination/naming"
+	"github.com/miquido/conduit-connector-azure-storage/internal"
+	"gopkg.in/tomb.v2"
+)
+
+var (
+	ErrBatchWriterIsStopped = errors.New("batch writer is stopped")
+	ErrDeleteNotEncodable   = errors.New("delete records cannot be written in the format, as it does not keep the operation")
+)
+
+// NewBatchWriter creates a writer which encodes the cached records into a single blob, whenever maxRecords records
+// or maxBytes bytes of keys and payloads are cached, or when flushInterval passes. Blobs are written by blobWriter,
+// and their properties are mapped from the metadata of the first record of the batch.
+// Records with the delete action are written to the batch like any other record, in the formats keeping the
+// operation of the records. Other formats fail to write them.
+func NewBatchWriter(
+	blobWriter *BlobWriter,
+	encoder format.Encoder,
+	blobNameTemplate *naming.Template,
+	propertiesMapping mapping.Mapping,
+	maxRecords int,
+	maxBytes int,
+	flushInterval time.Duration,
+) (*BatchWriter, error) {
+	w, err := newBatchWriter(&blockTarget{
+		blobWriter:        blobWriter,
+		encoder:           encoder,
+		blobNameTemplate:  blobNameTemplate,
+		propertiesMapping: propertiesMapping,
+	}, maxRecords, maxBytes, flushInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	w.keepsOperation = format.KeepsOperation(encoder)
+
+	return w, nil
+}
+
+// target stores batches of records in the container.
+type target interface {
+	store(ctx context.Context, records []sdk.Record) error
+}
+
+func newBatchWriter(t target, maxRecords int, maxBytes int, flushInterval time.Duration) (*BatchWriter, error) {
+	if maxRecords < 1 {
+		return nil, fmt.Errorf("maxRecords is expected to be greater than or equal to 1, got %d", maxRecords)
+	}
+	if maxBytes < 1 {
+		return nil, fmt.Errorf("maxBytes is expected to be greater than or equal to 1, got %d", maxBytes)
+	}
+	if flushInterval <= 0 {
+		return nil, fmt.Errorf("flushInterval is expected to be positive, got %s", flushInterval)
+	}
+
+	w := BatchWriter{
+		target:     t,
+		maxRecords: maxRecords,
+		maxBytes:   maxBytes,
+		ticker:     time.NewTicker(flushInterval),
+		tomb:       tomb.Tomb{},
+	}
+
+	w.tomb.Go(w.flusher)
+
+	return &w, nil
+}
+
+type BatchWriter struct {
+	target         target
+	maxRecords     int
+	maxBytes       int
+	keepsOperation bool
+	ticker         *time.Ticker
+	tomb           tomb.Tomb
+
+	mutex   sync.Mutex
+	records []sdk.Record
+	acks    []sdk.AckFunc
+	size    int
+}
+
+func (w *BatchWriter) Write(ctx context.Context, record sdk.Record, ack sdk.AckFunc) error {
+	if !w.tomb.Alive() {
+		return w.tomb.Err()
+	}
+
+	// Otherwise the delete would be written as the record's data
+	if record.Metadata["action"] == internal.OperationDelete && !w.keepsOperation {
+		return ErrDeleteNotEncodable
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.records = append(w.records, record)
+	w.acks = append(w.acks, ack)
+	w.size += recordSize(record)
+
+	if len(w.records) >= w.maxRecords || w.size >= w.maxBytes {
+		return w.flush(ctx)
+	}
+
+	return nil
+}
+
+func (w *BatchWriter) Flush(ctx context.Context) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.flush(ctx)
+}
+
+func (w *BatchWriter) Stop() {
+	w.ticker.Stop()
+	w.tomb.Kill(ErrBatchWriterIsStopped)
+	_ = w.tomb.Wait()
+}
+
+// flusher writes the cached records every flush interval, so records do not wait for the batch to fill up forever.
+func (w *BatchWriter) flusher() error {
+	for {
+		select {
+		case <-w.tomb.Dying():
+			return w.tomb.Err()
+
+		case <-w.ticker.C:
+			w.mutex.Lock()
+			err := w.flush(w.tomb.Context(context.Background()))
+			w.mutex.Unlock()
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// flush stores the cached records and acknowledges them. It is expected to be called with the mutex locked.
+func (w *BatchWriter) flush(ctx context.Context) error {
+	if len(w.records) == 0 {
+		return nil
+	}
+
+	records, acks := w.records, w.acks
+
+	w.records, w.acks, w.size = nil, nil, 0
+
+	storeErr := w.target.store(ctx, records)
+
+	// All records share the fate of the blob they were written to
+	for _, ack := range acks {
+		if err := ack(storeErr); err != nil {
+			return fmt.Errorf("could not acknowledge the record: %w", err)
+		}
+	}
+
+	return storeErr
+}
+
+// renderBlobName renders the blob name from the first record written to the blob, followed by the format's extension.
+func renderBlobName(tpl *naming.Template, encoder format.Encoder, first sdk.Record, writtenAt time.Time) (string, error) {
+	name, err := tpl.Execute(first, writtenAt)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", errors.New("blob name template rendered an empty name")
+	}
+
+	if !strings.HasSuffix(name, encoder.Extension()) {
+		name += encoder.Extension()
+	}
+
+	return name, nil
+}
+
+// blobProperties maps the metadata of the first record written to the blob into the blob's properties.
+// The Content-Type header is always the one of the format, and the Content-Encoding is the one of the compression.
+func blobProperties(propertiesMapping mapping.Mapping, encoder format.Encoder, first sdk.Record) mapping.Properties {
+	props := propertiesMapping.Apply(first.Metadata)
+
+	if props.Headers == nil {
+		props.Headers = &azblob.BlobHTTPHeaders{}
+	}
+
+	props.Headers.BlobContentType = to.Ptr(encoder.ContentType())
+
+	if compressed, ok := encoder.(format.CompressedEncoder); ok {
+		props.Headers.BlobContentEncoding = to.Ptr(compressed.ContentEncoding())
+	}
+
+	return props
+}
+
+func recordSize(record sdk.Record) (size int) {
+	if record.Key != nil {
+		size += len(record.Key.Bytes())
+	}
+
+	if record.Payload != nil {
+		size += len(record.Payload.Bytes())
+	}
+
+	return size
+}
@@ -0,0 +1,171 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build integration
+
+package writer
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/jaswdr/faker"
+	"github.com/miquido/conduit-connector-azure-storage/destination/format"
+	"github.com/miquido/conduit-connector-azure-storage/destination/mapping"
+	"github.com/miquido/conduit-connector-azure-storage/destination/naming"
+	helper "github.com/miquido/conduit-connector-azure-storage/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchWriter(t *testing.T) {
+	ctx := context.Background()
+	fakerInstance := faker.New()
+
+	var (
+		containerName = "batch-writer-integration-tests"
+	)
+
+	blobNameTemplate, err := naming.NewTemplate("{{.Key}}")
+	require.NoError(t, err)
+
+	uploader, err := NewBlockUploader(4*1024*1024, 2)
+	require.NoError(t, err)
+
+	newBlobWriter := func(containerClient *azblob.ContainerClient) *BlobWriter {
+		blobWriter, err := NewBlobWriter(containerClient, uploader, WritePolicyOverwrite, ConflictReactionFail, "", Retention{})
+		require.NoError(t, err)
+
+		return blobWriter
+	}
+
+	newRecord := func(key string) sdk.Record {
+		return sdk.Record{
+			Position: sdk.Position(key),
+			Metadata: map[string]string{"action": "insert"},
+			Key:      sdk.RawData(key),
+			Payload:  sdk.RawData(fakerInstance.Lorem().Sentence(8)),
+		}
+	}
+
+	t.Run("Flushes the batch when Max Records is reached", func(t *testing.T) {
+		containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+		w, err := NewBatchWriter(newBlobWriter(containerClient), format.NDJSONEncoder{}, blobNameTemplate, mapping.Mapping{}, 2, 1024*1024, time.Hour)
+		require.NoError(t, err)
+
+		t.Cleanup(w.Stop)
+
+		acks := newAckRecorder()
+
+		record1, record2, record3 := newRecord("batch-1"), newRecord("batch-1-second"), newRecord("batch-2")
+
+		require.NoError(t, w.Write(ctx, record1, acks.ack("batch-1")))
+		require.Empty(t, acks.received())
+
+		require.NoError(t, w.Write(ctx, record2, acks.ack("batch-1-second")))
+		require.Equal(t, map[string]error{"batch-1": nil, "batch-1-second": nil}, acks.received())
+
+		require.NoError(t, w.Write(ctx, record3, acks.ack("batch-2")))
+
+		contents, err := helper.ReadBlob(containerClient, "batch-1.ndjson")
+		require.NoError(t, err)
+
+		records, err := format.DecodeNDJSON(strings.NewReader(contents))
+		require.NoError(t, err)
+		require.Equal(t, []sdk.Record{record1, record2}, records)
+
+		// The third record is flushed explicitly
+		require.NoError(t, w.Flush(ctx))
+		require.Len(t, acks.received(), 3)
+
+		names, err := helper.ListBlobNames(containerClient)
+		require.NoError(t, err)
+		require.Equal(t, []string{"batch-1.ndjson", "batch-2.ndjson"}, names)
+	})
+
+	t.Run("Flushes the batch when Max Bytes is reached", func(t *testing.T) {
+		containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+		w, err := NewBatchWriter(newBlobWriter(containerClient), format.NDJSONEncoder{}, blobNameTemplate, mapping.Mapping{}, 100, 1, time.Hour)
+		require.NoError(t, err)
+
+		t.Cleanup(w.Stop)
+
+		acks := newAckRecorder()
+
+		require.NoError(t, w.Write(ctx, newRecord("by-size"), acks.ack("by-size")))
+		require.Equal(t, map[string]error{"by-size": nil}, acks.received())
+
+		_, err = helper.ReadBlob(containerClient, "by-size.ndjson")
+		require.NoError(t, err)
+	})
+
+	t.Run("Flushes the batch when Flush Interval passes", func(t *testing.T) {
+		containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+		w, err := NewBatchWriter(newBlobWriter(containerClient), format.NDJSONEncoder{}, blobNameTemplate, mapping.Mapping{}, 100, 1024*1024, 500*time.Millisecond)
+		require.NoError(t, err)
+
+		t.Cleanup(w.Stop)
+
+		acks := newAckRecorder()
+
+		require.NoError(t, w.Write(ctx, newRecord("by-time"), acks.ack("by-time")))
+		require.Empty(t, acks.received())
+
+		require.Eventually(t, func() bool {
+			return len(acks.received()) == 1
+		}, 5*time.Second, 100*time.Millisecond)
+
+		_, err = helper.ReadBlob(containerClient, "by-time.ndjson")
+		require.NoError(t, err)
+	})
+}
+
+type ackRecorder struct {
+	mutex sync.Mutex
+	acks  map[string]error
+}
+
+func newAckRecorder() *ackRecorder {
+	return &ackRecorder{acks: map[string]error{}}
+}
+
+func (r *ackRecorder) ack(name string) sdk.AckFunc {
+	return func(err error) error {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		r.acks[name] = err
+
+		return nil
+	}
+}
+
+func (r *ackRecorder) received() map[string]error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	out := make(map[string]error, len(r.acks))
+	for k, v := range r.acks {
+		out[k] = v
+	}
+
+	return out
+}
@@ -0,0 +1,121 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package writer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/destination/format"
+	"github.com/miquido/conduit-connector-azure-storage/destination/mapping"
+	"github.com/miquido/conduit-connector-azure-storage/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBatchWriter(t *testing.T) {
+	t.Run("Fail to create writer with Max Records less than 1", func(t *testing.T) {
+		w, err := NewBatchWriter(nil, format.NDJSONEncoder{}, nil, mapping.Mapping{}, 0, 1, time.Second)
+		require.Nil(t, w)
+		require.EqualError(t, err, "maxRecords is expected to be greater than or equal to 1, got 0")
+	})
+
+	t.Run("Fail to create writer with Max Bytes less than 1", func(t *testing.T) {
+		w, err := NewBatchWriter(nil, format.NDJSONEncoder{}, nil, mapping.Mapping{}, 1, 0, time.Second)
+		require.Nil(t, w)
+		require.EqualError(t, err, "maxBytes is expected to be greater than or equal to 1, got 0")
+	})
+
+	t.Run("Fail to create writer with non-positive Flush Interval", func(t *testing.T) {
+		w, err := NewBatchWriter(nil, format.NDJSONEncoder{}, nil, mapping.Mapping{}, 1, 1, 0)
+		require.Nil(t, w)
+		require.EqualError(t, err, "flushInterval is expected to be positive, got 0s")
+	})
+}
+
+func TestBatchWriter_Flush(t *testing.T) {
+	t.Run("Skips flushing when there are no records cached", func(t *testing.T) {
+		w, err := NewBatchWriter(nil, format.NDJSONEncoder{}, nil, mapping.Mapping{}, 1, 1, time.Hour)
+		require.NoError(t, err)
+
+		t.Cleanup(w.Stop)
+
+		require.NoError(t, w.Flush(context.Background()))
+	})
+}
+
+func TestBatchWriter_Write(t *testing.T) {
+	t.Run("Fails when writer is stopped", func(t *testing.T) {
+		w, err := NewBatchWriter(nil, format.NDJSONEncoder{}, nil, mapping.Mapping{}, 1, 1, time.Hour)
+		require.NoError(t, err)
+
+		w.Stop()
+
+		require.ErrorIs(t, w.Write(context.Background(), sdk.Record{}, nil), ErrBatchWriterIsStopped)
+	})
+
+	t.Run("Writes the delete records to the batch in formats keeping the operation", func(t *testing.T) {
+		target := &recordingTarget{}
+
+		w, err := newBatchWriter(target, 2, 1024, time.Hour)
+		require.NoError(t, err)
+
+		w.keepsOperation = true
+
+		t.Cleanup(w.Stop)
+
+		var acked int
+		ack := func(err error) error {
+			require.NoError(t, err)
+			acked++
+
+			return nil
+		}
+
+		inserted := sdk.Record{Key: sdk.RawData("a"), Metadata: map[string]string{"action": internal.OperationInsert}}
+		deleted := sdk.Record{Key: sdk.RawData("a"), Metadata: map[string]string{"action": internal.OperationDelete}}
+
+		require.NoError(t, w.Write(context.Background(), inserted, ack))
+		require.NoError(t, w.Write(context.Background(), deleted, ack))
+
+		require.Equal(t, []sdk.Record{inserted, deleted}, target.records)
+		require.Equal(t, 2, acked)
+	})
+
+	t.Run("Fails to write the delete records in formats not keeping the operation", func(t *testing.T) {
+		w, err := NewBatchWriter(nil, format.CSVEncoder{}, nil, mapping.Mapping{}, 1, 1, time.Hour)
+		require.NoError(t, err)
+
+		t.Cleanup(w.Stop)
+
+		deleted := sdk.Record{Key: sdk.RawData("a"), Metadata: map[string]string{"action": internal.OperationDelete}}
+
+		require.ErrorIs(t, w.Write(context.Background(), deleted, nil), ErrDeleteNotEncodable)
+	})
+}
+
+// recordingTarget records the records stored.
+type recordingTarget struct {
+	records []sdk.Record
+}
+
+func (t *recordingTarget) store(_ context.Context, records []sdk.Record) error {
+	t.records = append(t.records, records...)
+
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/destination/format"
+	"github.com/miquido/conduit-connector-azure-storage/destination/mapping"
+	"github.com/miquido/conduit-connector-azure-storage/destination/naming"
+)
+
+// blockTarget uploads every batch of records as a separate block blob.
+type blockTarget struct {
+	blobWriter        *BlobWriter
+	encoder           format.Encoder
+	blobNameTemplate  *naming.Template
+	propertiesMapping mapping.Mapping
+}
+
+func (t *blockTarget) store(ctx context.Context, records []sdk.Record) error {
+	blobName, err := renderBlobName(t.blobNameTemplate, t.encoder, records[0], time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	var buffer bytes.Buffer
+
+	if err := t.encoder.Encode(&buffer, records); err != nil {
+		return fmt.Errorf("could not encode the batch of %d records: %w", len(records), err)
+	}
+
+	props := blobProperties(t.propertiesMapping, t.encoder, records[0])
+
+	err = t.blobWriter.Write(ctx, blobName, bytes.NewReader(buffer.Bytes()), &azblob.BlockBlobCommitBlockListOptions{
+		Metadata:        props.Metadata,
+		BlobHTTPHeaders: props.Headers,
+		BlobTagsMap:     props.Tags,
+		Tier:            props.AccessTier,
+	}, "")
+	if err != nil {
+		return fmt.Errorf("could not upload blob %q: %w", blobName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,350 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// Below is a list of all supported write policies.
+// WritePolicyOverwrite writes blobs unconditionally, WritePolicyCreateOnly writes only blobs which do not exist yet,
+// and WritePolicyIfMatchLastSeen writes only blobs which were not modified since their ETag was last seen.
+const (
+	WritePolicyOverwrite       = "overwrite"
+	WritePolicyCreateOnly      = "createOnly"
+	WritePolicyIfMatchLastSeen = "ifMatchLastSeen"
+)
+
+// Below is a list of all supported reactions on the write conflict.
+// ConflictReactionFail fails the write, ConflictReactionSkip drops the data, and ConflictReactionConflictPrefix
+// writes the data to the blob of the same name prefixed with the conflict prefix.
+const (
+	ConflictReactionFail           = "fail"
+	ConflictReactionSkip           = "skip"
+	ConflictReactionConflictPrefix = "conflictPrefix"
+)
+
+// WritePathMetadataKey is the key of the blob metadata telling which path was taken when the blob was written.
+const WritePathMetadataKey = "conduit_write_path"
+
+// Below is a list of all values of the WritePathMetadataKey blob metadata.
+const (
+	WritePathOverwritten = "overwritten"
+	WritePathCreated     = "created"
+	WritePathMatched     = "matched"
+	WritePathConflict    = "conflict"
+)
+
+// MaxLastSeenETags is the number of blobs whose ETags of the last write are remembered. Once exceeded, the ETags
+// of the least recently written blobs are forgotten, and the current ETags of such blobs are read before writing.
+const MaxLastSeenETags = 10_000
+
+var ErrWriteConflict = errors.New("blob was modified by another writer")
+
+// NewBlobWriter creates a writer which uploads blobs using the uploader and the write policy.
+// Blobs which cannot be written because of a conflict are handled according to the reaction.
+// Written blobs are retained according to the retention, which requires the client to use NewRetentionPolicy.
+func NewBlobWriter(
+	client *azblob.ContainerClient,
+	uploader *BlockUploader,
+	policy string,
+	reaction string,
+	conflictPrefix string,
+	retention Retention,
+) (*BlobWriter, error) {
+	switch policy {
+	case WritePolicyOverwrite, WritePolicyCreateOnly, WritePolicyIfMatchLastSeen:
+	default:
+		return nil, fmt.Errorf("unsupported write policy %q", policy)
+	}
+
+	switch reaction {
+	case ConflictReactionFail, ConflictReactionSkip:
+	case ConflictReactionConflictPrefix:
+		if conflictPrefix == "" {
+			return nil, errors.New("conflictPrefix is expected to be set when conflicts are written with a prefix")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported conflict reaction %q", reaction)
+	}
+
+	if err := retention.validate(); err != nil {
+		return nil, err
+	}
+
+	return &BlobWriter{
+		client:         client,
+		uploader:       uploader,
+		policy:         policy,
+		reaction:       reaction,
+		conflictPrefix: conflictPrefix,
+		retention:      retention,
+		etags:          make(map[string]*list.Element),
+		recent:         list.New(),
+	}, nil
+}
+
+// BlobWriter uploads single blobs, guarding them with conditional headers required by the write policy.
+type BlobWriter struct {
+	client         *azblob.ContainerClient
+	uploader       *BlockUploader
+	policy         string
+	reaction       string
+	conflictPrefix string
+	retention      Retention
+
+	// etags keeps the ETags of blobs last written with the WritePolicyIfMatchLastSeen policy, and recent orders them
+	// from the most recently written one
+	mutex  sync.Mutex
+	etags  map[string]*list.Element
+	recent *list.List
+}
+
+// lastSeen is the ETag of the blob's last write.
+type lastSeen struct {
+	blobName string
+	etag     string
+}
+
+// Write uploads the data into the blob. With the WritePolicyIfMatchLastSeen policy, the blob is expected to have
+// the lastSeenETag, or else the ETag of the blob's last write. The ETag of a blob with neither, e.g. last written
+// before a restart, is read from the blob, and a blob which does not exist is expected not to be created meanwhile.
+func (w *BlobWriter) Write(
+	ctx context.Context,
+	blobName string,
+	data io.ReadSeeker,
+	options *azblob.BlockBlobCommitBlockListOptions,
+	lastSeenETag string,
+) error {
+	conditions, path, err := w.conditions(ctx, blobName, lastSeenETag)
+	if err != nil {
+		return err
+	}
+
+	etag, err := w.upload(ctx, blobName, data, options, conditions, path)
+	if err == nil {
+		w.remember(blobName, etag)
+
+		return nil
+	}
+
+	if !isConflict(err) {
+		return err
+	}
+
+	switch w.reaction {
+	case ConflictReactionSkip:
+		sdk.Logger(ctx).Warn().Err(err).Str("blob", blobName).Msg("skipping the blob modified by another writer")
+
+		return nil
+
+	case ConflictReactionConflictPrefix:
+		if _, err := data.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("could not rewind the data: %w", err)
+		}
+
+		conflictName := w.conflictPrefix + blobName
+
+		if _, err := w.upload(ctx, conflictName, data, options, nil, WritePathConflict); err != nil {
+			return fmt.Errorf("could not write the conflicting blob %q: %w", conflictName, err)
+		}
+
+		return nil
+
+	default:
+		return fmt.Errorf("%w: %s", ErrWriteConflict, err)
+	}
+}
+
+// Forget drops the last seen ETag of the blob, e.g. once the blob was deleted.
+func (w *BlobWriter) Forget(blobName string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if element, ok := w.etags[blobName]; ok {
+		w.recent.Remove(element)
+		delete(w.etags, blobName)
+	}
+}
+
+// Delete removes the blob together with its snapshots, and forgets its last seen ETag.
+func (w *BlobWriter) Delete(ctx context.Context, blobName string) error {
+	w.Forget(blobName)
+
+	return deleteBlob(ctx, w.client, blobName)
+}
+
+// conditions returns the conditions of writing the blob, and the write path taken when the conditions are met.
+func (w *BlobWriter) conditions(ctx context.Context, blobName, lastSeenETag string) (*azblob.ModifiedAccessConditions, string, error) {
+	switch w.policy {
+	case WritePolicyCreateOnly:
+		return &azblob.ModifiedAccessConditions{IfNoneMatch: to.Ptr("*")}, WritePathCreated, nil
+
+	case WritePolicyIfMatchLastSeen:
+		etag := w.lastSeenETag(blobName, lastSeenETag)
+
+		if etag == "" {
+			var err error
+
+			if etag, err = w.currentETag(ctx, blobName); err != nil {
+				return nil, "", err
+			}
+		}
+
+		// The blob does not exist, so it is expected not to be created meanwhile
+		if etag == "" {
+			return &azblob.ModifiedAccessConditions{IfNoneMatch: to.Ptr("*")}, WritePathCreated, nil
+		}
+
+		return &azblob.ModifiedAccessConditions{IfMatch: to.Ptr(etag)}, WritePathMatched, nil
+
+	default:
+		return nil, WritePathOverwritten, nil
+	}
+}
+
+func (w *BlobWriter) lastSeenETag(blobName, lastSeenETag string) string {
+	if lastSeenETag != "" {
+		return lastSeenETag
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if element, ok := w.etags[blobName]; ok {
+		return element.Value.(*lastSeen).etag
+	}
+
+	return ""
+}
+
+// currentETag reads the ETag of the blob, or returns an empty string when the blob does not exist.
+func (w *BlobWriter) currentETag(ctx context.Context, blobName string) (string, error) {
+	blobClient, err := w.client.NewBlobClient(blobName)
+	if err != nil {
+		return "", err
+	}
+
+	props, err := blobClient.GetProperties(ctx, nil)
+
+	var storageErr *azblob.StorageError
+	if errors.As(err, &storageErr) && storageErr.ErrorCode == azblob.StorageErrorCodeBlobNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not read the ETag of blob %q: %w", blobName, err)
+	}
+
+	if props.ETag == nil {
+		return "", nil
+	}
+
+	return *props.ETag, nil
+}
+
+func (w *BlobWriter) upload(
+	ctx context.Context,
+	blobName string,
+	data io.Reader,
+	options *azblob.BlockBlobCommitBlockListOptions,
+	conditions *azblob.ModifiedAccessConditions,
+	path string,
+) (string, error) {
+	blockBlobClient, err := w.client.NewBlockBlobClient(blobName)
+	if err != nil {
+		return "", err
+	}
+
+	var writeOptions azblob.BlockBlobCommitBlockListOptions
+	if options != nil {
+		writeOptions = *options
+	}
+
+	writeOptions.Metadata = make(map[string]string, len(writeOptions.Metadata)+1)
+	if options != nil {
+		for key, value := range options.Metadata {
+			writeOptions.Metadata[key] = value
+		}
+	}
+	writeOptions.Metadata[WritePathMetadataKey] = path
+
+	if conditions != nil {
+		writeOptions.BlobAccessConditions = &azblob.BlobAccessConditions{ModifiedAccessConditions: conditions}
+	}
+
+	ctx = withRetentionHeaders(ctx, w.retention.headers(time.Now().UTC()))
+
+	etag, err := w.uploader.Upload(ctx, blockBlobClient, data, &writeOptions)
+	if err != nil {
+		return "", err
+	}
+
+	// The blob is already written, so writing it again would conflict with itself
+	expiredETag, err := w.retention.expire(ctx, &blockBlobClient.BlobClient, blobName, etag)
+	if err != nil {
+		sdk.Logger(ctx).Warn().Err(err).Str("blob", blobName).Msg("the written blob does not expire")
+
+		return etag, nil
+	}
+
+	return expiredETag, nil
+}
+
+func (w *BlobWriter) remember(blobName, etag string) {
+	if w.policy != WritePolicyIfMatchLastSeen || etag == "" {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if element, ok := w.etags[blobName]; ok {
+		element.Value.(*lastSeen).etag = etag
+		w.recent.MoveToFront(element)
+
+		return
+	}
+
+	w.etags[blobName] = w.recent.PushFront(&lastSeen{blobName: blobName, etag: etag})
+
+	if w.recent.Len() > MaxLastSeenETags {
+		oldest := w.recent.Back()
+		w.recent.Remove(oldest)
+		delete(w.etags, oldest.Value.(*lastSeen).blobName)
+	}
+}
+
+// isConflict indicates whether the write failed because the blob already exists or its conditions were not met.
+func isConflict(err error) bool {
+	var storageErr *azblob.StorageError
+	if !errors.As(err, &storageErr) || storageErr.Response() == nil {
+		return false
+	}
+
+	statusCode := storageErr.StatusCode()
+
+	return statusCode == http.StatusConflict || statusCode == http.StatusPreconditionFailed
+}
@@ -0,0 +1,149 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBlobWriter(t *testing.T) {
+	t.Run("Fails when Write Policy is not supported", func(t *testing.T) {
+		w, err := NewBlobWriter(nil, nil, "ifNewer", ConflictReactionFail, "", Retention{})
+
+		require.Nil(t, w)
+		require.EqualError(t, err, "unsupported write policy \"ifNewer\"")
+	})
+
+	t.Run("Fails when Conflict Reaction is not supported", func(t *testing.T) {
+		w, err := NewBlobWriter(nil, nil, WritePolicyCreateOnly, "retry", "", Retention{})
+
+		require.Nil(t, w)
+		require.EqualError(t, err, "unsupported conflict reaction \"retry\"")
+	})
+
+	t.Run("Fails when Conflict Prefix is empty", func(t *testing.T) {
+		w, err := NewBlobWriter(nil, nil, WritePolicyCreateOnly, ConflictReactionConflictPrefix, "", Retention{})
+
+		require.Nil(t, w)
+		require.EqualError(t, err, "conflictPrefix is expected to be set when conflicts are written with a prefix")
+	})
+}
+
+func TestBlobWriter_Forget(t *testing.T) {
+	w, err := NewBlobWriter(nil, nil, WritePolicyIfMatchLastSeen, ConflictReactionFail, "", Retention{})
+	require.NoError(t, err)
+
+	w.remember("blob", "0x1")
+	require.Equal(t, "0x1", w.lastSeenETag("blob", ""))
+
+	w.Forget("blob")
+	require.NotContains(t, w.etags, "blob")
+	require.Equal(t, 0, w.recent.Len())
+}
+
+func TestBlobWriter_LastSeenETag(t *testing.T) {
+	t.Run("Prefers the given ETag", func(t *testing.T) {
+		w, err := NewBlobWriter(nil, nil, WritePolicyIfMatchLastSeen, ConflictReactionFail, "", Retention{})
+		require.NoError(t, err)
+
+		w.remember("blob", "0x1")
+		require.Equal(t, "0x2", w.lastSeenETag("blob", "0x2"))
+	})
+
+	t.Run("Reads the ETag of the blob not remembered, e.g. written before a restart", func(t *testing.T) {
+		transport := &recordingTransport{etag: "0x5"}
+
+		w, err := NewBlobWriter(newContainerClient(t, transport), nil, WritePolicyIfMatchLastSeen, ConflictReactionFail, "", Retention{})
+		require.NoError(t, err)
+
+		conditions, path, err := w.conditions(context.Background(), "blob", "")
+		require.NoError(t, err)
+		require.Equal(t, http.MethodHead, transport.method)
+		require.Equal(t, "/container/blob", transport.url.Path)
+		require.Equal(t, WritePathMatched, path)
+		require.Equal(t, "0x5", *conditions.IfMatch)
+		require.Nil(t, conditions.IfNoneMatch)
+	})
+
+	t.Run("Expects the blob which does not exist not to be created meanwhile", func(t *testing.T) {
+		transport := &recordingTransport{errorCode: azblob.StorageErrorCodeBlobNotFound}
+
+		w, err := NewBlobWriter(newContainerClient(t, transport), nil, WritePolicyIfMatchLastSeen, ConflictReactionFail, "", Retention{})
+		require.NoError(t, err)
+
+		conditions, path, err := w.conditions(context.Background(), "blob", "")
+		require.NoError(t, err)
+		require.Equal(t, WritePathCreated, path)
+		require.Equal(t, "*", *conditions.IfNoneMatch)
+		require.Nil(t, conditions.IfMatch)
+	})
+
+	t.Run("Does not read the ETag of the blob remembered", func(t *testing.T) {
+		transport := &recordingTransport{etag: "0x5"}
+
+		w, err := NewBlobWriter(newContainerClient(t, transport), nil, WritePolicyIfMatchLastSeen, ConflictReactionFail, "", Retention{})
+		require.NoError(t, err)
+
+		w.remember("blob", "0x1")
+
+		conditions, _, err := w.conditions(context.Background(), "blob", "")
+		require.NoError(t, err)
+		require.Empty(t, transport.method)
+		require.Equal(t, "0x1", *conditions.IfMatch)
+	})
+
+	t.Run("Forgets the least recently written blobs", func(t *testing.T) {
+		w, err := NewBlobWriter(nil, nil, WritePolicyIfMatchLastSeen, ConflictReactionFail, "", Retention{})
+		require.NoError(t, err)
+
+		for i := 0; i < MaxLastSeenETags; i++ {
+			w.remember(fmt.Sprintf("blob-%d", i), "0x1")
+		}
+
+		// Writing the first blob again makes the second one the least recently written
+		w.remember("blob-0", "0x2")
+		w.remember("new", "0x1")
+
+		require.Len(t, w.etags, MaxLastSeenETags)
+		require.Equal(t, "0x2", w.lastSeenETag("blob-0", ""))
+		require.Equal(t, "", w.lastSeenETag("blob-1", ""))
+		require.Equal(t, "0x1", w.lastSeenETag("new", ""))
+	})
+}
+
+func newContainerClient(t *testing.T, transport *recordingTransport) *azblob.ContainerClient {
+	client, err := azblob.NewContainerClientWithNoCredential("https://example.com/container", &azblob.ClientOptions{
+		Transport: transport,
+		Retry:     policy.RetryOptions{MaxRetries: -1},
+	})
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestIsConflict(t *testing.T) {
+	require.False(t, isConflict(errors.New("connection reset")))
+	require.False(t, isConflict(&azblob.StorageError{ErrorCode: azblob.StorageErrorCodeBlobAlreadyExists}))
+}
@@ -0,0 +1,240 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+// Below is a list of all supported modes of the immutability policy.
+// See: https://docs.microsoft.com/azure/storage/blobs/immutable-time-based-retention-policy-overview
+const (
+	ImmutabilityModeUnlocked = "unlocked"
+	ImmutabilityModeLocked   = "locked"
+)
+
+// Retention decides how long written blobs are protected from being modified or deleted, and when they expire.
+// Zero Period disables the immutability policy, LegalHold places the legal hold on the blob, and non-zero Expiry
+// deletes the blob once the Expiry passes from its write.
+type Retention struct {
+	Period    time.Duration
+	Mode      string
+	LegalHold bool
+	Expiry    time.Duration
+}
+
+// ParseImmutabilityMode parses the mode of the immutability policy.
+func ParseImmutabilityMode(s string) (string, error) {
+	switch s {
+	case ImmutabilityModeUnlocked, ImmutabilityModeLocked:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unsupported immutability mode %q", s)
+	}
+}
+
+// IsEnabled indicates whether written blobs are protected in any way.
+func (r Retention) IsEnabled() bool {
+	return r.Period > 0 || r.LegalHold
+}
+
+func (r Retention) validate() error {
+	if r.Period < 0 {
+		return fmt.Errorf("retention period is expected to be greater than or equal to 0, got %s", r.Period)
+	}
+
+	if r.Period > 0 {
+		if _, err := ParseImmutabilityMode(r.Mode); err != nil {
+			return err
+		}
+	}
+
+	if r.Expiry < 0 {
+		return fmt.Errorf("expiry is expected to be greater than or equal to 0, got %s", r.Expiry)
+	}
+
+	return nil
+}
+
+func (r Retention) policyMode() *azblob.BlobImmutabilityPolicyMode {
+	if r.Mode == ImmutabilityModeLocked {
+		return to.Ptr(azblob.BlobImmutabilityPolicyModeLocked)
+	}
+
+	return to.Ptr(azblob.BlobImmutabilityPolicyModeUnlocked)
+}
+
+// appendOptions sets the immutability policy and legal hold of the append blob created at the given time.
+func (r Retention) appendOptions(options *azblob.AppendBlobCreateOptions, createdAt time.Time) {
+	if r.Period > 0 {
+		options.ImmutabilityPolicyExpiry = to.Ptr(createdAt.Add(r.Period))
+		options.ImmutabilityPolicyMode = r.policyMode()
+	}
+
+	if r.LegalHold {
+		options.LegalHold = to.Ptr(true)
+	}
+}
+
+// headers returns the headers setting the immutability policy and legal hold of the block blob written
+// at the given time.
+func (r Retention) headers(writtenAt time.Time) http.Header {
+	if !r.IsEnabled() {
+		return nil
+	}
+
+	header := make(http.Header)
+
+	if r.Period > 0 {
+		header.Set("x-ms-immutability-policy-until-date", writtenAt.Add(r.Period).UTC().Format(http.TimeFormat))
+		header.Set("x-ms-immutability-policy-mode", string(*r.policyMode()))
+	}
+
+	if r.LegalHold {
+		header.Set("x-ms-legal-hold", strconv.FormatBool(true))
+	}
+
+	return header
+}
+
+// ExpiryError is the failure of setting the expiry of a blob which was already written.
+type ExpiryError struct {
+	BlobName string
+	Err      error
+}
+
+func (e *ExpiryError) Error() string {
+	return fmt.Sprintf("could not set expiry of blob %q: %s", e.BlobName, e.Err)
+}
+
+func (e *ExpiryError) Unwrap() error {
+	return e.Err
+}
+
+// expire sets the written blob to expire after the Expiry, and returns the blob's ETag after the change.
+// The given ETag is returned when the blob does not expire. Failures are returned as ExpiryError, so they are
+// told apart from failures of writing the blob.
+func (r Retention) expire(ctx context.Context, client *azblob.BlobClient, blobName, etag string) (string, error) {
+	if r.Expiry <= 0 {
+		return etag, nil
+	}
+
+	resp, err := sendBlobRequest(ctx, client, setExpiryRequest{expiry: r.Expiry})
+	if err != nil {
+		return "", &ExpiryError{BlobName: blobName, Err: err}
+	}
+
+	if resp.ETag != nil {
+		return *resp.ETag, nil
+	}
+
+	return etag, nil
+}
+
+// blobRequest is a request the SDK does not expose.
+type blobRequest interface {
+	// build turns the Get Blob Properties request into the blobRequest.
+	build(req *http.Request)
+}
+
+// setExpiryRequest is the Set Blob Expiry request relative to the time it is sent.
+// See: https://docs.microsoft.com/rest/api/storageservices/set-blob-expiry
+type setExpiryRequest struct {
+	expiry time.Duration
+}
+
+func (r setExpiryRequest) build(req *http.Request) {
+	query := req.URL.Query()
+	query.Set("comp", "expiry")
+
+	req.Method = http.MethodPut
+	req.URL.RawQuery = query.Encode()
+	req.Header.Set("x-ms-expiry-option", string(azblob.BlobExpiryOptionsRelativeToNow))
+	req.Header.Set("x-ms-expiry-time", strconv.FormatInt(r.expiry.Milliseconds(), 10))
+}
+
+// pendingRequest is the blobRequest to be sent by the retention policy.
+type pendingRequest struct {
+	request blobRequest
+	sent    bool
+}
+
+type pendingRequestKey struct{}
+
+type retentionHeadersKey struct{}
+
+// sendBlobRequest sends the request with the client's pipeline, so it is authorized and retried the same way as
+// requests exposed by the SDK. The request takes place of a Get Blob Properties request, which is the only one
+// the retention policy builds it from, and the response's headers are read as the Get Blob Properties ones.
+func sendBlobRequest(ctx context.Context, client *azblob.BlobClient, request blobRequest) (azblob.BlobGetPropertiesResponse, error) {
+	pending := &pendingRequest{request: request}
+
+	resp, err := client.GetProperties(context.WithValue(ctx, pendingRequestKey{}, pending), nil)
+	if !pending.sent {
+		return azblob.BlobGetPropertiesResponse{}, errors.New("client is expected to use the retention policy")
+	}
+
+	return resp, err
+}
+
+// withRetentionHeaders attaches the retention headers to the context of the requests writing the blob.
+func withRetentionHeaders(ctx context.Context, header http.Header) context.Context {
+	if header == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, retentionHeadersKey{}, header)
+}
+
+// NewRetentionPolicy creates the pipeline policy which sets the immutability policy and legal hold of block blobs,
+// and sends requests the SDK does not expose, e.g. Set Blob Expiry ones. The SDK does not expose the immutability
+// headers on Put Blob and Put Block List requests, so the policy adds the headers carried by the request's context.
+// It has to be one of the client's per-call policies, so the headers are signed.
+func NewRetentionPolicy() policy.Policy {
+	return retentionPolicy{}
+}
+
+type retentionPolicy struct{}
+
+func (retentionPolicy) Do(req *policy.Request) (*http.Response, error) {
+	if pending, ok := req.Raw().Context().Value(pendingRequestKey{}).(*pendingRequest); ok && req.Raw().Method == http.MethodHead {
+		pending.request.build(req.Raw())
+		pending.sent = true
+
+		return req.Next()
+	}
+
+	header, ok := req.Raw().Context().Value(retentionHeadersKey{}).(http.Header)
+
+	// Only Put Blob and Put Block List requests write the whole blob, staged blocks are not retained on their own
+	if ok && req.Raw().Method == http.MethodPut {
+		if comp := req.Raw().URL.Query().Get("comp"); comp == "" || comp == "blocklist" {
+			for key := range header {
+				req.Raw().Header.Set(key, header.Get(key))
+			}
+		}
+	}
+
+	return req.Next()
+}
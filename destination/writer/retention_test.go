@@ -0,0 +1,263 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package writer
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetention_Validate(t *testing.T) {
+	require.EqualError(t, Retention{Period: -time.Second}.validate(), "retention period is expected to be greater than or equal to 0, got -1s")
+	require.EqualError(t, Retention{Period: time.Hour, Mode: "forever"}.validate(), "unsupported immutability mode \"forever\"")
+	require.EqualError(t, Retention{Expiry: -time.Second}.validate(), "expiry is expected to be greater than or equal to 0, got -1s")
+	require.NoError(t, Retention{LegalHold: true}.validate())
+}
+
+func TestRetention_Headers(t *testing.T) {
+	writtenAt := time.Date(2022, 7, 14, 12, 0, 0, 0, time.UTC)
+
+	require.Nil(t, Retention{}.headers(writtenAt))
+
+	header := Retention{Period: 24 * time.Hour, Mode: ImmutabilityModeLocked, LegalHold: true}.headers(writtenAt)
+
+	require.Equal(t, "Fri, 15 Jul 2022 12:00:00 GMT", header.Get("x-ms-immutability-policy-until-date"))
+	require.Equal(t, "Locked", header.Get("x-ms-immutability-policy-mode"))
+	require.Equal(t, "true", header.Get("x-ms-legal-hold"))
+}
+
+func TestRetention_AppendOptions(t *testing.T) {
+	createdAt := time.Date(2022, 7, 14, 12, 0, 0, 0, time.UTC)
+
+	var options azblob.AppendBlobCreateOptions
+
+	Retention{Period: time.Hour, Mode: ImmutabilityModeUnlocked}.appendOptions(&options, createdAt)
+
+	require.Equal(t, to.Ptr(createdAt.Add(time.Hour)), options.ImmutabilityPolicyExpiry)
+	require.Equal(t, to.Ptr(azblob.BlobImmutabilityPolicyModeUnlocked), options.ImmutabilityPolicyMode)
+	require.Nil(t, options.LegalHold)
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	transport := &recordingTransport{}
+
+	pipeline := runtime.NewPipeline("test", "v0.0.0", runtime.PipelineOptions{
+		PerCall: []policy.Policy{NewRetentionPolicy()},
+	}, &policy.ClientOptions{
+		Transport: transport,
+		Retry:     policy.RetryOptions{MaxRetries: -1},
+	})
+
+	ctx := withRetentionHeaders(context.Background(), Retention{LegalHold: true}.headers(time.Now()))
+
+	send := func(method, url string) http.Header {
+		req, err := runtime.NewRequest(ctx, method, url)
+		require.NoError(t, err)
+
+		_, err = pipeline.Do(req)
+		require.NoError(t, err)
+
+		return transport.header
+	}
+
+	require.Equal(t, "true", send(http.MethodPut, "https://example.com/container/blob").Get("x-ms-legal-hold"))
+	require.Equal(t, "true", send(http.MethodPut, "https://example.com/container/blob?comp=blocklist").Get("x-ms-legal-hold"))
+	require.Empty(t, send(http.MethodPut, "https://example.com/container/blob?comp=block&blockid=a").Get("x-ms-legal-hold"))
+	require.Empty(t, send(http.MethodGet, "https://example.com/container/blob").Get("x-ms-legal-hold"))
+}
+
+func TestRetention_Expire(t *testing.T) {
+	ctx := context.Background()
+
+	newBlobClient := func(transport *recordingTransport, policies ...policy.Policy) *azblob.BlobClient {
+		client, err := azblob.NewBlobClientWithNoCredential("https://example.com/container/blob", &azblob.ClientOptions{
+			Transport:       transport,
+			Retry:           policy.RetryOptions{MaxRetries: -1},
+			PerCallPolicies: policies,
+		})
+		require.NoError(t, err)
+
+		return client
+	}
+
+	t.Run("Sends Set Blob Expiry request", func(t *testing.T) {
+		transport := &recordingTransport{etag: "0x2"}
+
+		etag, err := Retention{Expiry: 90 * time.Minute}.expire(ctx, newBlobClient(transport, NewRetentionPolicy()), "blob", "0x1")
+
+		require.NoError(t, err)
+		require.Equal(t, "0x2", etag)
+		require.Equal(t, http.MethodPut, transport.method)
+		require.Equal(t, "expiry", transport.url.Query().Get("comp"))
+		require.Equal(t, "RelativeToNow", transport.header.Get("x-ms-expiry-option"))
+		require.Equal(t, "5400000", transport.header.Get("x-ms-expiry-time"))
+	})
+
+	t.Run("Does nothing when blobs do not expire", func(t *testing.T) {
+		transport := &recordingTransport{}
+
+		etag, err := Retention{}.expire(ctx, newBlobClient(transport, NewRetentionPolicy()), "blob", "0x1")
+
+		require.NoError(t, err)
+		require.Equal(t, "0x1", etag)
+		require.Empty(t, transport.method)
+	})
+
+	t.Run("Fails when client does not use the retention policy", func(t *testing.T) {
+		transport := &recordingTransport{}
+
+		_, err := Retention{Expiry: time.Hour}.expire(ctx, newBlobClient(transport), "blob", "0x1")
+
+		var expiryErr *ExpiryError
+		require.ErrorAs(t, err, &expiryErr)
+		require.EqualError(t, err, "could not set expiry of blob \"blob\": client is expected to use the retention policy")
+		require.Equal(t, http.MethodHead, transport.method)
+	})
+}
+
+func TestBlobWriter_Retention(t *testing.T) {
+	ctx := context.Background()
+
+	retention := Retention{
+		Period:    24 * time.Hour,
+		Mode:      ImmutabilityModeLocked,
+		LegalHold: true,
+		Expiry:    90 * time.Minute,
+	}
+
+	newBlobWriter := func(t *testing.T, transport *sequenceTransport) *BlobWriter {
+		client, err := azblob.NewContainerClientWithNoCredential("https://example.com/container", &azblob.ClientOptions{
+			Transport:       transport,
+			Retry:           policy.RetryOptions{MaxRetries: -1},
+			PerCallPolicies: []policy.Policy{NewRetentionPolicy()},
+		})
+		require.NoError(t, err)
+
+		uploader, err := NewBlockUploader(1024, 1)
+		require.NoError(t, err)
+
+		w, err := NewBlobWriter(client, uploader, WritePolicyIfMatchLastSeen, ConflictReactionFail, "", retention)
+		require.NoError(t, err)
+
+		return w
+	}
+
+	t.Run("Writes the retained blob and then sets its expiry", func(t *testing.T) {
+		transport := &sequenceTransport{}
+
+		w := newBlobWriter(t, transport)
+
+		require.NoError(t, w.Write(ctx, "blob", strings.NewReader("data"), nil, "0x0"))
+		require.Len(t, transport.requests, 2)
+		require.Equal(t, "0x2", w.lastSeenETag("blob", ""))
+
+		write := transport.requests[0]
+		require.Equal(t, http.MethodPut, write.Method)
+		require.Equal(t, "/container/blob", write.URL.Path)
+		require.Empty(t, write.URL.Query().Get("comp"))
+		require.Equal(t, "0x0", write.Header.Get("If-Match"))
+		require.Equal(t, "Locked", write.Header.Get("x-ms-immutability-policy-mode"))
+		require.NotEmpty(t, write.Header.Get("x-ms-immutability-policy-until-date"))
+		require.Equal(t, "true", write.Header.Get("x-ms-legal-hold"))
+		require.Empty(t, write.Header.Get("x-ms-expiry-option"))
+
+		expiry := transport.requests[1]
+		require.Equal(t, http.MethodPut, expiry.Method)
+		require.Equal(t, "/container/blob", expiry.URL.Path)
+		require.Equal(t, "expiry", expiry.URL.Query().Get("comp"))
+		require.Equal(t, "RelativeToNow", expiry.Header.Get("x-ms-expiry-option"))
+		require.Equal(t, "5400000", expiry.Header.Get("x-ms-expiry-time"))
+		require.Empty(t, expiry.Header.Get("x-ms-legal-hold"))
+		require.Empty(t, expiry.Header.Get("If-Match"))
+	})
+
+	t.Run("Does not fail the written blob when its expiry cannot be set", func(t *testing.T) {
+		transport := &sequenceTransport{failExpiry: true}
+
+		w := newBlobWriter(t, transport)
+
+		require.NoError(t, w.Write(ctx, "blob", strings.NewReader("data"), nil, "0x0"))
+		require.Len(t, transport.requests, 2)
+		require.Equal(t, "0x1", w.lastSeenETag("blob", ""))
+	})
+}
+
+// sequenceTransport keeps all the sent requests, and responds to writes of blobs with the 0x1 ETag, and to
+// Set Blob Expiry requests with the 0x2 ETag, or with the server error, when failExpiry is set.
+type sequenceTransport struct {
+	requests   []*http.Request
+	failExpiry bool
+}
+
+func (t *sequenceTransport) Do(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req.Clone(req.Context()))
+
+	header := make(http.Header)
+
+	if req.URL.Query().Get("comp") != "expiry" {
+		header.Set("ETag", "0x1")
+
+		return &http.Response{StatusCode: http.StatusCreated, Header: header, Body: http.NoBody, Request: req}, nil
+	}
+
+	if t.failExpiry {
+		header.Set("x-ms-error-code", string(azblob.StorageErrorCodeInternalError))
+
+		return &http.Response{StatusCode: http.StatusInternalServerError, Header: header, Body: http.NoBody, Request: req}, nil
+	}
+
+	header.Set("ETag", "0x2")
+
+	return &http.Response{StatusCode: http.StatusOK, Header: header, Body: http.NoBody, Request: req}, nil
+}
+
+// recordingTransport keeps the method, URL and headers of the last sent request, and responds with the ETag, or
+// with the error code, when set.
+type recordingTransport struct {
+	method    string
+	url       *url.URL
+	header    http.Header
+	etag      string
+	errorCode azblob.StorageErrorCode
+}
+
+func (t *recordingTransport) Do(req *http.Request) (*http.Response, error) {
+	t.method, t.url, t.header = req.Method, req.URL, req.Header.Clone()
+
+	header := make(http.Header)
+	if t.etag != "" {
+		header.Set("ETag", t.etag)
+	}
+
+	if t.errorCode == azblob.StorageErrorCodeBlobNotFound {
+		header.Set("x-ms-error-code", string(t.errorCode))
+
+		return &http.Response{StatusCode: http.StatusNotFound, Header: header, Body: http.NoBody, Request: req}, nil
+	}
+
+	return &http.Response{StatusCode: http.StatusOK, Header: header, Body: http.NoBody, Request: req}, nil
+}
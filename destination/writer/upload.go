@@ -0,0 +1,301 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+const (
+	// MaxBlockSize is the maximum size of a single block staged in the block blob. The service accepts blocks of up
+	// to 4000 MiB, but every block being uploaded is kept in memory, so the size is capped well below that.
+	// See: https://docs.microsoft.com/rest/api/storageservices/put-block#remarks
+	MaxBlockSize int64 = 100 * 1024 * 1024
+
+	// MaxStagedBlocks is the maximum number of blocks a single block blob can consist of.
+	MaxStagedBlocks = 50_000
+
+	// blockRetryDelay is the delay before the first retry of a failed request, doubled with every next retry.
+	blockRetryDelay = time.Second
+)
+
+// NewRetryOptions creates the options of the client's retry policy, which retries every request failing with
+// a transient error up to retries times. Zero retries disable retrying.
+func NewRetryOptions(retries int) policy.RetryOptions {
+	// The policy treats zero MaxRetries as its default of 3 retries, and a negative one as no retries at all,
+	// so zero retries are passed as -1
+	maxRetries := int32(retries)
+	if retries == 0 {
+		maxRetries = -1
+	}
+
+	return policy.RetryOptions{
+		MaxRetries: maxRetries,
+		RetryDelay: blockRetryDelay,
+	}
+}
+
+// blockBlobClient is the part of azblob.BlockBlobClient used to upload blobs.
+type blockBlobClient interface {
+	Upload(ctx context.Context, body io.ReadSeekCloser, options *azblob.BlockBlobUploadOptions) (azblob.BlockBlobUploadResponse, error)
+	StageBlock(ctx context.Context, base64BlockID string, body io.ReadSeekCloser, options *azblob.BlockBlobStageBlockOptions) (azblob.BlockBlobStageBlockResponse, error)
+	CommitBlockList(ctx context.Context, base64BlockIDs []string, options *azblob.BlockBlobCommitBlockListOptions) (azblob.BlockBlobCommitBlockListResponse, error)
+}
+
+// NewBlockUploader creates an uploader which stages the data in blocks of blockSize bytes, uploading up to
+// parallelism blocks at once. Failed requests are retried by the client's retry policy, see NewRetryOptions.
+func NewBlockUploader(blockSize int64, parallelism int) (*BlockUploader, error) {
+	if blockSize < 1 || blockSize > MaxBlockSize {
+		return nil, fmt.Errorf("blockSize is expected to be between 1 and %d, got %d", MaxBlockSize, blockSize)
+	}
+	if parallelism < 1 {
+		return nil, fmt.Errorf("parallelism is expected to be greater than or equal to 1, got %d", parallelism)
+	}
+
+	return &BlockUploader{
+		blockSize:   blockSize,
+		parallelism: parallelism,
+		blocks: sync.Pool{
+			New: func() interface{} {
+				block := make([]byte, blockSize)
+
+				return &block
+			},
+		},
+	}, nil
+}
+
+// BlockUploader uploads data which fits into a single block with a single Put Blob request. Larger data is staged
+// with Put Block requests and committed atomically with a single Put Block List request, so the blob is either
+// written as a whole or not at all.
+type BlockUploader struct {
+	blockSize   int64
+	parallelism int
+
+	// blocks keeps the buffers of blocks already uploaded, so they are reused by the next ones
+	blocks sync.Pool
+}
+
+// Upload writes all the data read from the reader into the block blob, and returns the ETag of the written blob.
+// Blob's properties, metadata and access conditions are taken from options.
+func (u *BlockUploader) Upload(
+	ctx context.Context,
+	client *azblob.BlockBlobClient,
+	data io.Reader,
+	options *azblob.BlockBlobCommitBlockListOptions,
+) (string, error) {
+	return u.upload(ctx, client, data, options)
+}
+
+func (u *BlockUploader) upload(
+	ctx context.Context,
+	client blockBlobClient,
+	data io.Reader,
+	options *azblob.BlockBlobCommitBlockListOptions,
+) (string, error) {
+	head, err := u.readBlock(data)
+	if err != nil {
+		return "", fmt.Errorf("could not read the data: %w", err)
+	}
+
+	// Reading one byte more than the block size tells whether the data fits into a single block
+	var next [1]byte
+
+	n, err := io.ReadFull(data, next[:])
+	if err != nil && !errors.Is(err, io.EOF) {
+		u.blocks.Put(head)
+
+		return "", fmt.Errorf("could not read the data: %w", err)
+	}
+
+	if n == 0 {
+		defer u.blocks.Put(head)
+
+		resp, err := client.Upload(ctx, streaming.NopCloser(bytes.NewReader(*head)), uploadOptions(options))
+		if err != nil {
+			return "", err
+		}
+
+		return etagOf(resp.ETag), nil
+	}
+
+	blockIDs, err := u.stageBlocks(ctx, client, head, io.MultiReader(bytes.NewReader(next[:n]), data))
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.CommitBlockList(ctx, blockIDs, options)
+	if err != nil {
+		return "", fmt.Errorf("could not commit the list of %d blocks: %w", len(blockIDs), err)
+	}
+
+	return etagOf(resp.ETag), nil
+}
+
+// stageBlocks uploads the first block followed by the rest of the data as uncommitted blocks, and returns their IDs
+// in the order of the data.
+func (u *BlockUploader) stageBlocks(ctx context.Context, client blockBlobClient, first *[]byte, data io.Reader) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	prefix, err := blockIDPrefix()
+	if err != nil {
+		u.blocks.Put(first)
+
+		return nil, err
+	}
+
+	var (
+		blockIDs []string
+		slots    = make(chan struct{}, u.parallelism)
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		stageErr error
+	)
+
+	fail := func(err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if stageErr == nil {
+			stageErr = err
+			cancel()
+		}
+	}
+
+	// Blocks are read only once there is a free slot, so at most parallelism blocks are kept in memory
+	block := first
+
+	for ctx.Err() == nil {
+		slots <- struct{}{}
+
+		if block == nil {
+			if block, err = u.readBlock(data); err != nil {
+				fail(fmt.Errorf("could not read the data: %w", err))
+
+				break
+			}
+		}
+		if len(*block) == 0 {
+			break
+		}
+
+		index := len(blockIDs)
+		if index >= MaxStagedBlocks {
+			fail(fmt.Errorf("data does not fit into %d blocks of %d bytes", MaxStagedBlocks, u.blockSize))
+
+			break
+		}
+
+		blockID := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s-%06d", prefix, index)))
+		blockIDs = append(blockIDs, blockID)
+
+		wg.Add(1)
+
+		go func(block *[]byte) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			defer u.blocks.Put(block)
+
+			_, err := client.StageBlock(ctx, blockID, streaming.NopCloser(bytes.NewReader(*block)), nil)
+			if err != nil {
+				fail(fmt.Errorf("could not stage block %d: %w", index, err))
+			}
+		}(block)
+
+		block = nil
+	}
+
+	if block != nil {
+		u.blocks.Put(block)
+	}
+
+	wg.Wait()
+
+	if stageErr != nil {
+		return nil, stageErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return blockIDs, nil
+}
+
+// readBlock reads up to blockSize bytes into a buffer taken from the pool, which is expected to be put back once
+// the block was uploaded. Empty block is returned once all the data was read.
+func (u *BlockUploader) readBlock(data io.Reader) (*[]byte, error) {
+	block := u.blocks.Get().(*[]byte)
+	*block = (*block)[:u.blockSize]
+
+	n, err := io.ReadFull(data, *block)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		u.blocks.Put(block)
+
+		return nil, err
+	}
+
+	*block = (*block)[:n]
+
+	return block, nil
+}
+
+// etagOf returns the ETag of the written blob, or an empty one when the response has none.
+func etagOf(etag *string) string {
+	if etag == nil {
+		return ""
+	}
+
+	return *etag
+}
+
+// blockIDPrefix generates a random prefix of block IDs, so blocks staged by different uploads never collide.
+func blockIDPrefix() (string, error) {
+	random := make([]byte, 8)
+
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("could not generate block ID: %w", err)
+	}
+
+	return hex.EncodeToString(random), nil
+}
+
+// uploadOptions converts the options of committing the block list into the options of uploading the whole blob.
+func uploadOptions(options *azblob.BlockBlobCommitBlockListOptions) *azblob.BlockBlobUploadOptions {
+	if options == nil {
+		return nil
+	}
+
+	return &azblob.BlockBlobUploadOptions{
+		TagsMap:              options.BlobTagsMap,
+		Metadata:             options.Metadata,
+		Tier:                 options.Tier,
+		HTTPHeaders:          options.BlobHTTPHeaders,
+		BlobAccessConditions: options.BlobAccessConditions,
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package writer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBlockUploader(t *testing.T) {
+	t.Run("Fails when Block Size exceeds the limit", func(t *testing.T) {
+		u, err := NewBlockUploader(MaxBlockSize+1, 1)
+
+		require.Nil(t, u)
+		require.EqualError(t, err, "blockSize is expected to be between 1 and 104857600, got 104857601")
+	})
+
+	t.Run("Fails when Parallelism is zero", func(t *testing.T) {
+		u, err := NewBlockUploader(1, 0)
+
+		require.Nil(t, u)
+		require.EqualError(t, err, "parallelism is expected to be greater than or equal to 1, got 0")
+	})
+}
+
+func TestNewRetryOptions(t *testing.T) {
+	require.Equal(t, int32(3), NewRetryOptions(3).MaxRetries)
+	require.Equal(t, time.Second, NewRetryOptions(3).RetryDelay)
+
+	// Zero would make the policy use its default number of retries
+	require.Equal(t, int32(-1), NewRetryOptions(0).MaxRetries)
+}
+
+func TestBlockUploader_Upload(t *testing.T) {
+	ctx := context.Background()
+
+	newUploader := func(blockSize int64, parallelism int) *BlockUploader {
+		u, err := NewBlockUploader(blockSize, parallelism)
+		require.NoError(t, err)
+
+		return u
+	}
+
+	options := &azblob.BlockBlobCommitBlockListOptions{
+		Metadata: map[string]string{"source": "test"},
+		BlobHTTPHeaders: &azblob.BlobHTTPHeaders{
+			BlobContentType: to.Ptr("text/plain"),
+		},
+	}
+
+	t.Run("Uploads data fitting into a single block with a single request", func(t *testing.T) {
+		client := newFakeBlockBlobClient()
+
+		etag, err := newUploader(10, 2).upload(ctx, client, strings.NewReader("0123456789"), options)
+
+		require.NoError(t, err)
+		require.Equal(t, "uploaded", etag)
+
+		require.Equal(t, "0123456789", client.uploaded)
+		require.Equal(t, options.Metadata, client.uploadOptions.Metadata)
+		require.Equal(t, options.BlobHTTPHeaders, client.uploadOptions.HTTPHeaders)
+		require.Empty(t, client.staged)
+		require.Nil(t, client.committed)
+	})
+
+	t.Run("Stages larger data in blocks and commits them in order", func(t *testing.T) {
+		client := newFakeBlockBlobClient()
+		data := strings.Repeat("abcdefghij", 100)
+
+		etag, err := newUploader(64, 3).upload(ctx, client, strings.NewReader(data), options)
+
+		require.NoError(t, err)
+		require.Equal(t, "committed", etag)
+
+		require.Empty(t, client.uploaded)
+		require.Len(t, client.committed, 16)
+		require.Equal(t, options, client.commitOptions)
+		require.LessOrEqual(t, client.maxConcurrency, 3)
+
+		var committed strings.Builder
+		for _, blockID := range client.committed {
+			committed.WriteString(client.staged[blockID])
+		}
+
+		require.Equal(t, data, committed.String())
+	})
+
+	t.Run("Stages data larger by a single byte in two blocks", func(t *testing.T) {
+		client := newFakeBlockBlobClient()
+
+		_, err := newUploader(10, 2).upload(ctx, client, strings.NewReader("0123456789a"), nil)
+
+		require.NoError(t, err)
+		require.Len(t, client.committed, 2)
+		require.Equal(t, "0123456789", client.staged[client.committed[0]])
+		require.Equal(t, "a", client.staged[client.committed[1]])
+	})
+
+	t.Run("Fails without committing when block cannot be staged", func(t *testing.T) {
+		client := newFakeBlockBlobClient()
+		client.failures = 1
+
+		_, err := newUploader(4, 1).upload(ctx, client, strings.NewReader("0123456789"), nil)
+
+		require.EqualError(t, err, "could not stage block 0: connection reset")
+		require.Nil(t, client.committed)
+	})
+
+	t.Run("Does not retry failed requests itself", func(t *testing.T) {
+		client := newFakeBlockBlobClient()
+		client.uploadErr = errors.New("connection reset")
+
+		_, err := newUploader(10, 1).upload(ctx, client, strings.NewReader("0123"), nil)
+
+		require.EqualError(t, err, "connection reset")
+		require.Equal(t, 1, client.uploads)
+	})
+
+	t.Run("Reuses the buffers of uploaded blocks", func(t *testing.T) {
+		u := newUploader(4, 1)
+
+		block, err := u.readBlock(strings.NewReader("0123"))
+		require.NoError(t, err)
+		u.blocks.Put(block)
+
+		reused, err := u.readBlock(strings.NewReader("ab"))
+		require.NoError(t, err)
+		require.Equal(t, "ab", string(*reused))
+		require.Equal(t, 4, cap(*reused))
+	})
+}
+
+// fakeBlockBlobClient keeps the uploaded data in memory, and fails the first staged blocks when requested.
+type fakeBlockBlobClient struct {
+	mutex sync.Mutex
+
+	uploaded      string
+	uploadOptions *azblob.BlockBlobUploadOptions
+	uploadErr     error
+	uploads       int
+
+	staged         map[string]string
+	failures       int
+	concurrency    int
+	maxConcurrency int
+
+	committed     []string
+	commitOptions *azblob.BlockBlobCommitBlockListOptions
+}
+
+func newFakeBlockBlobClient() *fakeBlockBlobClient {
+	return &fakeBlockBlobClient{staged: make(map[string]string)}
+}
+
+func (c *fakeBlockBlobClient) Upload(_ context.Context, body io.ReadSeekCloser, options *azblob.BlockBlobUploadOptions) (azblob.BlockBlobUploadResponse, error) {
+	c.uploads++
+
+	if c.uploadErr != nil {
+		return azblob.BlockBlobUploadResponse{}, c.uploadErr
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return azblob.BlockBlobUploadResponse{}, err
+	}
+
+	c.uploaded, c.uploadOptions = string(data), options
+
+	resp := azblob.BlockBlobUploadResponse{}
+	resp.ETag = to.Ptr("uploaded")
+
+	return resp, nil
+}
+
+func (c *fakeBlockBlobClient) StageBlock(_ context.Context, blockID string, body io.ReadSeekCloser, _ *azblob.BlockBlobStageBlockOptions) (azblob.BlockBlobStageBlockResponse, error) {
+	c.mutex.Lock()
+	c.concurrency++
+	if c.concurrency > c.maxConcurrency {
+		c.maxConcurrency = c.concurrency
+	}
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		c.concurrency--
+		c.mutex.Unlock()
+	}()
+
+	// Give other blocks a chance to be staged at the same time
+	time.Sleep(time.Millisecond)
+
+	var data bytes.Buffer
+	if _, err := data.ReadFrom(body); err != nil {
+		return azblob.BlockBlobStageBlockResponse{}, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.failures > 0 {
+		c.failures--
+
+		return azblob.BlockBlobStageBlockResponse{}, errors.New("connection reset")
+	}
+
+	c.staged[blockID] = data.String()
+
+	return azblob.BlockBlobStageBlockResponse{}, nil
+}
+
+func (c *fakeBlockBlobClient) CommitBlockList(_ context.Context, blockIDs []string, options *azblob.BlockBlobCommitBlockListOptions) (azblob.BlockBlobCommitBlockListResponse, error) {
+	c.committed, c.commitOptions = blockIDs, options
+
+	resp := azblob.BlockBlobCommitBlockListResponse{}
+	resp.ETag = to.Ptr("committed")
+
+	return resp, nil
+}
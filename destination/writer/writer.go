@@ -0,0 +1,57 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+type Writer interface {
+	// Write caches the sdk.Record to be stored in the container later.
+	// The ack function is called once the record is stored, or storing it failed.
+	Write(ctx context.Context, record sdk.Record, ack sdk.AckFunc) error
+
+	// Flush stores all the cached records and calls their ack functions.
+	Flush(ctx context.Context) error
+
+	// Stop informs the writer to stop processing new records.
+	// All currently ongoing operations should be gracefully shut down.
+	Stop()
+}
+
+// deleteBlob removes the blob together with its snapshots. Deleting a blob which does not exist anymore is not
+// considered a failure.
+func deleteBlob(ctx context.Context, client *azblob.ContainerClient, blobName string) error {
+	blobClient, err := client.NewBlobClient(blobName)
+	if err != nil {
+		return err
+	}
+
+	_, err = blobClient.Delete(ctx, &azblob.BlobDeleteOptions{
+		DeleteSnapshots: to.Ptr(azblob.DeleteSnapshotsOptionTypeInclude),
+	})
+
+	var storageErr *azblob.StorageError
+	if errors.As(err, &storageErr) && storageErr.ErrorCode == azblob.StorageErrorCodeBlobNotFound {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,122 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package avroschema
+
+import "strings"
+
+// Names keeps the named types of an Avro schema, decoded from JSON, so they can be referenced by their full names
+// anywhere in the schema.
+type Names map[string]interface{}
+
+// NewNames walks the whole schema and keeps all the named types found.
+func NewNames(schema interface{}) Names {
+	n := make(Names)
+	n.register(schema, "")
+
+	return n
+}
+
+func (n Names) register(schema interface{}, namespace string) {
+	switch s := schema.(type) {
+	case []interface{}:
+		for _, branch := range s {
+			n.register(branch, namespace)
+		}
+
+	case map[string]interface{}:
+		if name, ok := s["name"].(string); ok {
+			namespace = Namespace(s, namespace)
+			n[FullName(name, namespace)] = s
+		}
+
+		if fields, ok := s["fields"].([]interface{}); ok {
+			for _, f := range fields {
+				if field, ok := f.(map[string]interface{}); ok {
+					n.register(field["type"], namespace)
+				}
+			}
+		}
+
+		// Types described with a nested schema, e.g. `{"type": {"type": "record", ...}}`
+		n.register(s["type"], namespace)
+		n.register(s["items"], namespace)
+		n.register(s["values"], namespace)
+	}
+}
+
+// Lookup returns the named type referenced by the name within the namespace, or by the full name.
+func (n Names) Lookup(name, namespace string) (interface{}, bool) {
+	if named, exists := n[FullName(name, namespace)]; exists {
+		return named, true
+	}
+
+	named, exists := n[name]
+
+	return named, exists
+}
+
+// TypeName returns the name goavro uses for the schema as a branch of a union, i.e. the full name of named types,
+// or the name of the type followed by its logical type, e.g. `long.timestamp-millis`.
+func (n Names) TypeName(schema interface{}, namespace string) string {
+	switch s := schema.(type) {
+	case string:
+		if _, exists := n[FullName(s, namespace)]; exists {
+			return FullName(s, namespace)
+		}
+
+		return s
+
+	case map[string]interface{}:
+		if name, ok := s["name"].(string); ok {
+			return FullName(name, Namespace(s, namespace))
+		}
+
+		if typeName, ok := s["type"].(string); ok {
+			if logicalType, ok := s["logicalType"].(string); ok {
+				return typeName + "." + logicalType
+			}
+
+			return typeName
+		}
+
+		return n.TypeName(s["type"], namespace)
+
+	default:
+		return ""
+	}
+}
+
+// Namespace returns the namespace of the named type, which is inherited from the enclosing type by default.
+func Namespace(schema map[string]interface{}, enclosing string) string {
+	name, _ := schema["name"].(string)
+
+	switch ns, ok := schema["namespace"].(string); {
+	case strings.Contains(name, "."):
+		return name[:strings.LastIndex(name, ".")]
+	case ok:
+		return ns
+	default:
+		return enclosing
+	}
+}
+
+// FullName returns the name qualified with the namespace, unless it is a full name already.
+func FullName(name, namespace string) string {
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+
+	return namespace + "." + name
+}
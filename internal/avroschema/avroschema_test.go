@@ -0,0 +1,74 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package avroschema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNames(t *testing.T) {
+	var schema interface{}
+
+	require.NoError(t, json.Unmarshal([]byte(`{"type":"record","name":"User","namespace":"com.example","fields":[
+		{"name":"address","type":{"type":"record","name":"Address","fields":[{"name":"city","type":"string"}]}},
+		{"name":"status","type":{"type":{"type":"enum","name":"org.example.Status","symbols":["ACTIVE"]}}},
+		{"name":"tags","type":{"type":"array","items":{"type":"fixed","name":"Tag","size":4}}},
+		{"name":"previous","type":["null","Address"]}
+	]}`), &schema))
+
+	names := NewNames(schema)
+
+	require.Len(t, names, 4)
+	require.Contains(t, names, "com.example.User")
+	require.Contains(t, names, "com.example.Address")
+	require.Contains(t, names, "org.example.Status")
+	require.Contains(t, names, "com.example.Tag")
+
+	t.Run("Looks up the names within the namespace or the full names", func(t *testing.T) {
+		_, exists := names.Lookup("Address", "com.example")
+		require.True(t, exists)
+
+		_, exists = names.Lookup("org.example.Status", "com.example")
+		require.True(t, exists)
+
+		_, exists = names.Lookup("Address", "")
+		require.False(t, exists)
+	})
+
+	t.Run("Names the branches of unions the way goavro does", func(t *testing.T) {
+		require.Equal(t, "null", names.TypeName("null", "com.example"))
+		require.Equal(t, "com.example.Address", names.TypeName("Address", "com.example"))
+		require.Equal(t, "com.example.Address", names.TypeName(map[string]interface{}{"type": "record", "name": "Address"}, "com.example"))
+		require.Equal(t, "long.timestamp-millis", names.TypeName(map[string]interface{}{"type": "long", "logicalType": "timestamp-millis"}, ""))
+		require.Equal(t, "array", names.TypeName(map[string]interface{}{"type": map[string]interface{}{"type": "array"}}, ""))
+	})
+}
+
+func TestNamespace(t *testing.T) {
+	require.Equal(t, "org.example", Namespace(map[string]interface{}{"name": "org.example.Status", "namespace": "com.example"}, "net.example"))
+	require.Equal(t, "com.example", Namespace(map[string]interface{}{"name": "Status", "namespace": "com.example"}, "net.example"))
+	require.Equal(t, "net.example", Namespace(map[string]interface{}{"name": "Status"}, "net.example"))
+}
+
+func TestFullName(t *testing.T) {
+	require.Equal(t, "com.example.User", FullName("User", "com.example"))
+	require.Equal(t, "org.example.User", FullName("org.example.User", "com.example"))
+	require.Equal(t, "User", FullName("User", ""))
+}
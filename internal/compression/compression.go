@@ -0,0 +1,185 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+type Algorithm = string
+
+// Below is a list of all supported compression algorithms.
+// Snappy data is written in the framing format, so it can be streamed and concatenated.
+// See: https://github.com/google/snappy/blob/main/framing_format.txt
+const (
+	None   Algorithm = "none"
+	Gzip   Algorithm = "gzip"
+	Zstd   Algorithm = "zstd"
+	Snappy Algorithm = "snappy"
+)
+
+// DefaultLevel stands for the default compression level of the algorithm.
+const DefaultLevel = 0
+
+// Parse parses the name of the compression algorithm.
+func Parse(s string) (Algorithm, error) {
+	switch s {
+	case None, Gzip, Zstd, Snappy:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unsupported compression %q", s)
+	}
+}
+
+// ValidateLevel checks whether the algorithm supports the compression level.
+// Gzip supports levels from 1 to 9, and zstd from 1 to 22. Snappy has no levels.
+func ValidateLevel(algorithm Algorithm, level int) error {
+	if level == DefaultLevel {
+		return nil
+	}
+
+	switch algorithm {
+	case Gzip:
+		if level < gzip.BestSpeed || level > gzip.BestCompression {
+			return fmt.Errorf("gzip compression level is expected to be between %d and %d, got %d", gzip.BestSpeed, gzip.BestCompression, level)
+		}
+	case Zstd:
+		if level < 1 || level > 22 {
+			return fmt.Errorf("zstd compression level is expected to be between 1 and 22, got %d", level)
+		}
+	default:
+		return fmt.Errorf("%s compression does not support levels", algorithm)
+	}
+
+	return nil
+}
+
+// ContentEncoding returns the value of the Content-Encoding header of data compressed with the algorithm.
+func ContentEncoding(algorithm Algorithm) string {
+	switch algorithm {
+	case Gzip, Zstd, Snappy:
+		return algorithm
+	default:
+		return ""
+	}
+}
+
+// Extension returns the file extension of data compressed with the algorithm.
+func Extension(algorithm Algorithm) string {
+	switch algorithm {
+	case Gzip:
+		return ".gz"
+	case Zstd:
+		return ".zst"
+	case Snappy:
+		return ".sz"
+	default:
+		return ""
+	}
+}
+
+// NewWriter creates a writer compressing the data written to w. The writer must be closed to flush the data.
+func NewWriter(w io.Writer, algorithm Algorithm, level int) (io.WriteCloser, error) {
+	if err := ValidateLevel(algorithm, level); err != nil {
+		return nil, err
+	}
+
+	switch algorithm {
+	case None:
+		return nopWriteCloser{w}, nil
+
+	case Gzip:
+		if level == DefaultLevel {
+			level = gzip.DefaultCompression
+		}
+
+		return gzip.NewWriterLevel(w, level)
+
+	case Zstd:
+		options := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if level != DefaultLevel {
+			options = append(options, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+
+		return zstd.NewWriter(w, options...)
+
+	case Snappy:
+		return snappy.NewBufferedWriter(w), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", algorithm)
+	}
+}
+
+// Compress returns the data compressed with the algorithm.
+func Compress(data []byte, algorithm Algorithm, level int) ([]byte, error) {
+	if algorithm == None {
+		return data, nil
+	}
+
+	var buffer bytes.Buffer
+
+	w, err := NewWriter(&buffer, algorithm, level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// NewReader creates a reader decompressing the data encoded with the given Content-Encoding.
+// Data with an unknown or empty encoding is read as is.
+func NewReader(r io.Reader, contentEncoding string) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case Gzip, "x-gzip":
+		return gzip.NewReader(r)
+
+	case Zstd:
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+
+		return decoder.IOReadCloser(), nil
+
+	case Snappy:
+		return io.NopCloser(snappy.NewReader(r)), nil
+
+	default:
+		return io.NopCloser(r), nil
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
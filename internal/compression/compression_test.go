@@ -0,0 +1,106 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package compression
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	_, err := Parse("lz4")
+
+	require.EqualError(t, err, "unsupported compression \"lz4\"")
+}
+
+func TestValidateLevel(t *testing.T) {
+	require.NoError(t, ValidateLevel(Snappy, DefaultLevel))
+	require.NoError(t, ValidateLevel(Zstd, 19))
+	require.EqualError(t, ValidateLevel(Gzip, 10), "gzip compression level is expected to be between 1 and 9, got 10")
+	require.EqualError(t, ValidateLevel(Zstd, 23), "zstd compression level is expected to be between 1 and 22, got 23")
+	require.EqualError(t, ValidateLevel(Snappy, 3), "snappy compression does not support levels")
+	require.EqualError(t, ValidateLevel(None, 3), "none compression does not support levels")
+}
+
+func TestCompress(t *testing.T) {
+	data := []byte(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 100))
+
+	for _, tt := range []struct {
+		algorithm Algorithm
+		level     int
+	}{
+		{algorithm: Gzip},
+		{algorithm: Gzip, level: 9},
+		{algorithm: Zstd},
+		{algorithm: Zstd, level: 1},
+		{algorithm: Snappy},
+	} {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			compressed, err := Compress(data, tt.algorithm, tt.level)
+			require.NoError(t, err)
+			require.Less(t, len(compressed), len(data))
+
+			require.Equal(t, data, decompress(t, compressed, ContentEncoding(tt.algorithm)))
+		})
+
+		t.Run(tt.algorithm+" streams can be concatenated", func(t *testing.T) {
+			first, err := Compress([]byte("first\n"), tt.algorithm, tt.level)
+			require.NoError(t, err)
+
+			second, err := Compress([]byte("second\n"), tt.algorithm, tt.level)
+			require.NoError(t, err)
+
+			require.Equal(t, "first\nsecond\n", string(decompress(t, append(first, second...), ContentEncoding(tt.algorithm))))
+		})
+	}
+
+	t.Run("None returns the data as is", func(t *testing.T) {
+		compressed, err := Compress(data, None, DefaultLevel)
+
+		require.NoError(t, err)
+		require.Equal(t, data, compressed)
+	})
+}
+
+func TestNewReader(t *testing.T) {
+	t.Run("Reads data of unknown encoding as is", func(t *testing.T) {
+		require.Equal(t, []byte("plain"), decompress(t, []byte("plain"), "br"))
+	})
+
+	t.Run("Matches the encoding ignoring the case", func(t *testing.T) {
+		compressed, err := Compress([]byte("data"), Gzip, DefaultLevel)
+		require.NoError(t, err)
+
+		require.Equal(t, []byte("data"), decompress(t, compressed, " GZIP "))
+	})
+}
+
+func decompress(t *testing.T, data []byte, contentEncoding string) []byte {
+	reader, err := NewReader(bytes.NewReader(data), contentEncoding)
+	require.NoError(t, err)
+
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	return decompressed
+}
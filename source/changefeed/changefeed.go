@@ -0,0 +1,334 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package changefeed reads the Azure Blob Storage change feed, which logs the changes of the account's blobs into
+// Avro files stored in the $blobchangefeed container.
+// See: https://docs.microsoft.com/azure/storage/blobs/storage-blob-change-feed
+package changefeed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+const (
+	// ContainerName is the name of the container the change feed is stored in.
+	ContainerName = "$blobchangefeed"
+
+	// MetaPath is the path of the file describing the whole change feed.
+	MetaPath = "meta/segments.json"
+
+	// SegmentsPrefix is the prefix of the segment manifests, named after the hour the segment begins,
+	// e.g. idx/segments/2022/05/30/1300/meta.json, so they are listed in the chronological order.
+	SegmentsPrefix = "idx/segments/"
+
+	// SegmentStatusFinalized is the status of the segments which are not going to be changed anymore.
+	SegmentStatusFinalized = "Finalized"
+)
+
+// Event types of the blob changes.
+const (
+	EventTypeBlobCreated           = "BlobCreated"
+	EventTypeBlobDeleted           = "BlobDeleted"
+	EventTypeBlobPropertiesUpdated = "BlobPropertiesUpdated"
+	EventTypeBlobSnapshotCreated   = "BlobSnapshotCreated"
+)
+
+// Schema is the Avro schema of the change feed events, in the version 1.
+const Schema = `{
+	"type": "record",
+	"name": "BlobChangeEvent",
+	"namespace": "com.microsoft.storage.blobchangefeed",
+	"fields": [
+		{"name": "schemaVersion", "type": "int"},
+		{"name": "topic", "type": "string"},
+		{"name": "subject", "type": "string"},
+		{"name": "eventType", "type": {
+			"type": "enum",
+			"name": "BlobChangeEventType",
+			"symbols": ["UnspecifiedEventType", "BlobCreated", "BlobDeleted", "BlobPropertiesUpdated", "BlobSnapshotCreated", "Control"]
+		}},
+		{"name": "eventTime", "type": "string"},
+		{"name": "id", "type": "string"},
+		{"name": "data", "type": {
+			"type": "record",
+			"name": "BlobChangeEventData",
+			"fields": [
+				{"name": "api", "type": "string"},
+				{"name": "clientRequestId", "type": "string"},
+				{"name": "requestId", "type": "string"},
+				{"name": "etag", "type": "string"},
+				{"name": "contentType", "type": "string"},
+				{"name": "contentLength", "type": "long"},
+				{"name": "blobType", "type": "string"},
+				{"name": "url", "type": "string"},
+				{"name": "sequencer", "type": "string"}
+			]
+		}}
+	]
+}`
+
+// Meta describes the whole change feed.
+type Meta struct {
+	// LastConsumable is the begin time of the first segment which cannot be read yet.
+	LastConsumable time.Time `json:"lastConsumable"`
+}
+
+// Segment lists the chunks of the changes made during the segment's interval, one chunk directory per shard.
+type Segment struct {
+	Version        int       `json:"version"`
+	Begin          time.Time `json:"begin"`
+	IntervalSecs   int       `json:"intervalSecs"`
+	Status         string    `json:"status"`
+	ChunkFilePaths []string  `json:"chunkFilePaths"`
+}
+
+// ParseMeta parses the meta/segments.json file.
+func ParseMeta(data []byte) (Meta, error) {
+	var meta Meta
+
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return Meta{}, fmt.Errorf("could not parse the change feed meta: %w", err)
+	}
+
+	return meta, nil
+}
+
+// ParseSegment parses the segment manifest.
+func ParseSegment(data []byte) (Segment, error) {
+	var segment Segment
+
+	if err := json.Unmarshal(data, &segment); err != nil {
+		return Segment{}, fmt.Errorf("could not parse the change feed segment: %w", err)
+	}
+
+	return segment, nil
+}
+
+// IsConsumable indicates whether the segment can be read, which is when it is finalized and begins before
+// the feed's last consumable time.
+func (s Segment) IsConsumable(meta Meta) bool {
+	return s.Status == SegmentStatusFinalized && s.Begin.Before(meta.LastConsumable)
+}
+
+// End returns the time the segment's interval ends.
+func (s Segment) End() time.Time {
+	return s.Begin.Add(time.Duration(s.IntervalSecs) * time.Second)
+}
+
+// ShardPrefixes returns the prefixes of the shards' chunk files, relative to the change feed container.
+// Chunk file paths start with the name of the container.
+func (s Segment) ShardPrefixes() []string {
+	prefixes := make([]string, 0, len(s.ChunkFilePaths))
+
+	for _, path := range s.ChunkFilePaths {
+		if i := strings.Index(path, "/"); i >= 0 {
+			path = path[i+1:]
+		}
+
+		prefixes = append(prefixes, path)
+	}
+
+	return prefixes
+}
+
+// Event is a single change of a blob.
+type Event struct {
+	Topic     string
+	Subject   string
+	EventType string
+	EventTime time.Time
+	ID        string
+	Data      EventData
+}
+
+// EventData describes the changed blob.
+type EventData struct {
+	API           string
+	ETag          string
+	ContentType   string
+	ContentLength int64
+	BlobType      string
+	URL           string
+	Sequencer     string
+}
+
+// Blob returns the container and the name of the changed blob, parsed from the event's subject,
+// e.g. /blobServices/default/containers/container/blobs/path/to/blob.
+func (e Event) Blob() (container, name string, ok bool) {
+	const (
+		containersPrefix = "/blobServices/default/containers/"
+		blobsSeparator   = "/blobs/"
+	)
+
+	if !strings.HasPrefix(e.Subject, containersPrefix) {
+		return "", "", false
+	}
+
+	subject := strings.TrimPrefix(e.Subject, containersPrefix)
+
+	i := strings.Index(subject, blobsSeparator)
+	if i < 0 {
+		return "", "", false
+	}
+
+	return subject[:i], subject[i+len(blobsSeparator):], true
+}
+
+// ReadEvents decodes all the events of the Avro chunk file.
+func ReadEvents(r io.Reader) ([]Event, error) {
+	ocfReader, err := goavro.NewOCFReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the change feed chunk: %w", err)
+	}
+
+	var events []Event
+
+	for ocfReader.Scan() {
+		datum, err := ocfReader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("could not read the change feed event: %w", err)
+		}
+
+		fields, ok := datum.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("could not read the change feed event: event is not a record")
+		}
+
+		event, err := parseEvent(fields)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	if err := ocfReader.Err(); err != nil {
+		return nil, fmt.Errorf("could not read the change feed chunk: %w", err)
+	}
+
+	return events, nil
+}
+
+// WriteEvents encodes the events into an Avro chunk file, e.g. to emulate the change feed.
+func WriteEvents(w io.Writer, events []Event) error {
+	ocfWriter, err := goavro.NewOCFWriter(goavro.OCFConfig{
+		W:      w,
+		Schema: Schema,
+	})
+	if err != nil {
+		return err
+	}
+
+	data := make([]interface{}, 0, len(events))
+
+	for _, e := range events {
+		data = append(data, map[string]interface{}{
+			"schemaVersion": int32(1),
+			"topic":         e.Topic,
+			"subject":       e.Subject,
+			"eventType":     e.EventType,
+			"eventTime":     e.EventTime.UTC().Format(time.RFC3339Nano),
+			"id":            e.ID,
+			"data": map[string]interface{}{
+				"api":             e.Data.API,
+				"clientRequestId": "",
+				"requestId":       "",
+				"etag":            e.Data.ETag,
+				"contentType":     e.Data.ContentType,
+				"contentLength":   e.Data.ContentLength,
+				"blobType":        e.Data.BlobType,
+				"url":             e.Data.URL,
+				"sequencer":       e.Data.Sequencer,
+			},
+		})
+	}
+
+	return ocfWriter.Append(data)
+}
+
+// parseEvent reads the event from the decoded Avro record. Fields of the schema versions written by the storage
+// service are read whether they are plain or nullable.
+func parseEvent(fields map[string]interface{}) (Event, error) {
+	event := Event{
+		Topic:     stringField(fields, "topic"),
+		Subject:   stringField(fields, "subject"),
+		EventType: stringField(fields, "eventType"),
+		ID:        stringField(fields, "id"),
+	}
+
+	if eventTime := stringField(fields, "eventTime"); eventTime != "" {
+		var err error
+
+		if event.EventTime, err = time.Parse(time.RFC3339Nano, eventTime); err != nil {
+			return Event{}, fmt.Errorf("could not parse the change feed event time: %w", err)
+		}
+	}
+
+	if data, ok := unwrapUnion(fields["data"]).(map[string]interface{}); ok {
+		event.Data = EventData{
+			API:           stringField(data, "api"),
+			ETag:          stringField(data, "etag"),
+			ContentType:   stringField(data, "contentType"),
+			ContentLength: longField(data, "contentLength"),
+			BlobType:      stringField(data, "blobType"),
+			URL:           stringField(data, "url"),
+			Sequencer:     stringField(data, "sequencer"),
+		}
+	}
+
+	return event, nil
+}
+
+func stringField(fields map[string]interface{}, name string) string {
+	s, _ := unwrapUnion(fields[name]).(string)
+
+	return s
+}
+
+func longField(fields map[string]interface{}, name string) int64 {
+	switch v := unwrapUnion(fields[name]).(type) {
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	default:
+		return 0
+	}
+}
+
+// unwrapUnion returns the value of the non-null union branch, which goavro decodes as a single-entry map.
+func unwrapUnion(v interface{}) interface{} {
+	if union, ok := v.(map[string]interface{}); ok && len(union) == 1 {
+		for name, value := range union {
+			// Records are maps too, so only the Avro type names are unwrapped
+			switch name {
+			case "string", "long", "int", "boolean", "double", "float", "bytes":
+				return value
+			}
+
+			if _, isRecord := value.(map[string]interface{}); isRecord {
+				return value
+			}
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,153 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package changefeed
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadEvents(t *testing.T) {
+	t.Run("Reads events written with the schema", func(t *testing.T) {
+		events := []Event{
+			{
+				Topic:     "/subscriptions/id/resourceGroups/group/providers/Microsoft.Storage/storageAccounts/account",
+				Subject:   "/blobServices/default/containers/container/blobs/logs/a.json",
+				EventType: EventTypeBlobCreated,
+				EventTime: time.Date(2022, 5, 30, 13, 15, 0, 123000000, time.UTC),
+				ID:        "e1",
+				Data: EventData{
+					API:           "PutBlob",
+					ETag:          "0x8D9",
+					ContentType:   "application/json",
+					ContentLength: 42,
+					BlobType:      "BlockBlob",
+					URL:           "https://account.blob.core.windows.net/container/logs/a.json",
+					Sequencer:     "00000000000000010000000000000002",
+				},
+			},
+			{
+				Subject:   "/blobServices/default/containers/container/blobs/logs/a.json",
+				EventType: EventTypeBlobDeleted,
+				EventTime: time.Date(2022, 5, 30, 13, 20, 0, 0, time.UTC),
+				ID:        "e2",
+			},
+		}
+
+		var buffer bytes.Buffer
+		require.NoError(t, WriteEvents(&buffer, events))
+
+		read, err := ReadEvents(&buffer)
+		require.NoError(t, err)
+		require.Equal(t, events, read)
+	})
+
+	t.Run("Reads nullable fields", func(t *testing.T) {
+		codecSchema := `{
+			"type": "record",
+			"name": "BlobChangeEvent",
+			"fields": [
+				{"name": "subject", "type": ["null", "string"]},
+				{"name": "eventType", "type": "string"},
+				{"name": "eventTime", "type": "string"},
+				{"name": "data", "type": ["null", {
+					"type": "record",
+					"name": "BlobChangeEventData",
+					"fields": [
+						{"name": "etag", "type": ["null", "string"]},
+						{"name": "contentLength", "type": ["null", "long"]}
+					]
+				}]}
+			]
+		}`
+
+		var buffer bytes.Buffer
+
+		ocfWriter, err := goavro.NewOCFWriter(goavro.OCFConfig{W: &buffer, Schema: codecSchema})
+		require.NoError(t, err)
+		require.NoError(t, ocfWriter.Append([]interface{}{
+			map[string]interface{}{
+				"subject":   goavro.Union("string", "/blobServices/default/containers/c/blobs/b"),
+				"eventType": EventTypeBlobPropertiesUpdated,
+				"eventTime": "2022-05-30T13:15:00Z",
+				"data": goavro.Union("BlobChangeEventData", map[string]interface{}{
+					"etag":          goavro.Union("string", "0x1"),
+					"contentLength": goavro.Union("long", int64(7)),
+				}),
+			},
+		}))
+
+		events, err := ReadEvents(&buffer)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, "/blobServices/default/containers/c/blobs/b", events[0].Subject)
+		require.Equal(t, EventTypeBlobPropertiesUpdated, events[0].EventType)
+		require.Equal(t, "0x1", events[0].Data.ETag)
+		require.Equal(t, int64(7), events[0].Data.ContentLength)
+	})
+
+	t.Run("Fails when the chunk is not an Avro file", func(t *testing.T) {
+		_, err := ReadEvents(bytes.NewReader([]byte("not avro")))
+
+		require.ErrorContains(t, err, "could not read the change feed chunk")
+	})
+}
+
+func TestEvent_Blob(t *testing.T) {
+	container, name, ok := Event{Subject: "/blobServices/default/containers/container/blobs/path/to/blobs/a.txt"}.Blob()
+	require.True(t, ok)
+	require.Equal(t, "container", container)
+	require.Equal(t, "path/to/blobs/a.txt", name)
+
+	_, _, ok = Event{Subject: "/blobServices/default/containers/container"}.Blob()
+	require.False(t, ok)
+}
+
+func TestParseSegment(t *testing.T) {
+	segment, err := ParseSegment([]byte(`{
+		"version": 0,
+		"begin": "2022-05-30T13:00:00.000Z",
+		"intervalSecs": 3600,
+		"status": "Finalized",
+		"chunkFilePaths": [
+			"$blobchangefeed/log/00/2022/05/30/1300/",
+			"$blobchangefeed/log/01/2022/05/30/1300/"
+		]
+	}`))
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"log/00/2022/05/30/1300/", "log/01/2022/05/30/1300/"}, segment.ShardPrefixes())
+	require.Equal(t, time.Date(2022, 5, 30, 14, 0, 0, 0, time.UTC), segment.End())
+
+	meta, err := ParseMeta([]byte(`{"version": 0, "lastConsumable": "2022-05-30T14:00:00.000Z"}`))
+	require.NoError(t, err)
+	require.True(t, segment.IsConsumable(meta))
+
+	meta.LastConsumable = segment.Begin
+	require.False(t, segment.IsConsumable(meta))
+
+	segment.Status = "Publishing"
+	meta.LastConsumable = segment.End()
+	require.False(t, segment.IsConsumable(meta))
+
+	_, err = ParseSegment([]byte("{"))
+	require.EqualError(t, err, "could not parse the change feed segment: unexpected end of JSON input")
+}
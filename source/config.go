@@ -17,39 +17,133 @@ package source
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
+
+	"github.com/miquido/conduit-connector-azure-storage/source/changefeed"
+	"github.com/miquido/conduit-connector-azure-storage/source/iterator"
 )
 
 const (
 	ConfigKeyConnectionString = "connectionString"
 	ConfigKeyContainerName    = "containerName"
 
+	ConfigKeyContainerNamePattern = "containerNamePattern"
+
 	ConfigKeyPollingPeriod = "pollingPeriod"
 	DefaultPollingPeriod   = "1s"
 
 	ConfigKeyMaxResults       = "maxResults"
 	DefaultMaxResults   int32 = 5000
+
+	ConfigKeyPrefix  = "prefix"
+	ConfigKeyInclude = "include"
+	ConfigKeyExclude = "exclude"
+
+	ConfigKeyDelimiter   = "delimiter"
+	ConfigKeyDirectories = "directories"
+	ConfigKeyMaxDepth    = "maxDepth"
+
+	ConfigKeyCDCMode = "cdcMode"
+	DefaultCDCMode   = CDCModePolling
+
+	ConfigKeyChangeFeedContainerName = "changeFeedContainerName"
+	DefaultChangeFeedContainerName   = changefeed.ContainerName
+
+	ConfigKeyEventGridAddress = "eventGridAddress"
+	DefaultEventGridAddress   = ":8080"
+
+	ConfigKeyEventGridSecret = "eventGridSecret"
+
+	ConfigKeyQueueName = "queueName"
+
+	ConfigKeyQueueVisibilityTimeout = "queueVisibilityTimeout"
+	DefaultQueueVisibilityTimeout   = "5m"
+
+	ConfigKeyDeleteDetection = "deleteDetection"
+	DefaultDeleteDetection   = DeleteDetectionSoftDelete
+
+	ConfigKeyStateDirectory = "stateDirectory"
+
+	ConfigKeyRecordSplitting = "recordSplitting"
+	DefaultRecordSplitting   = iterator.RecordSplittingNone
+
+	ConfigKeyCSVHeader = "csvHeader"
+	DefaultCSVHeader   = iterator.CSVHeaderAuto
+
+	ConfigKeyCSVDelimiter = "csvDelimiter"
+	DefaultCSVDelimiter   = string(iterator.DefaultCSVDelimiter)
+
+	ConfigKeyCSVQuote = "csvQuote"
+	DefaultCSVQuote   = string(iterator.DefaultCSVQuote)
+
+	ConfigKeyCSVInferTypes = "csvInferTypes"
+	DefaultCSVInferTypes   = true
+
+	ConfigKeyCSVKeyColumn = "csvKeyColumn"
+
+	ConfigKeyDecodeJSON = "decodeJSON"
+	DefaultDecodeJSON   = false
+
+	ConfigKeyInvalidJSON = "invalidJSON"
+	DefaultInvalidJSON   = iterator.InvalidJSONFail
+)
+
+// CDC modes, i.e. how the changes of the blobs are detected after the snapshot.
+const (
+	CDCModePolling      = "polling"
+	CDCModeChangeFeed   = "changeFeed"
+	CDCModeEventGrid    = "eventGrid"
+	CDCModeStorageQueue = "storageQueue"
+)
+
+// Delete detection methods of the polling CDC mode.
+const (
+	DeleteDetectionSoftDelete = "softDelete"
+	DeleteDetectionStateDiff  = "stateDiff"
 )
 
 type Config struct {
 	ConnectionString string
-	ContainerName    string
+	ContainerNames   []string
+	ContainerPattern *iterator.Pattern
 	PollingPeriod    time.Duration
 	MaxResults       int32
+	Filter           iterator.Filter
+
+	CDCMode                 string
+	ChangeFeedContainerName string
+	EventGridAddress        string
+	EventGridSecret         string
+	QueueName               string
+	QueueVisibilityTimeout  time.Duration
+	DeleteDetection         string
+	StateDirectory          string
+	RecordSplitting         string
+	SplitterOptions         iterator.SplitterOptions
 }
 
 func ParseConfig(cfgRaw map[string]string) (_ Config, err error) {
 	cfg := Config{
 		ConnectionString: cfgRaw[ConfigKeyConnectionString],
-		ContainerName:    cfgRaw[ConfigKeyContainerName],
+		ContainerNames:   parseContainerNames(cfgRaw),
 	}
 
 	if cfg.ConnectionString == "" {
 		return Config{}, requiredConfigErr(ConfigKeyConnectionString)
 	}
 
-	if cfg.ContainerName == "" {
-		return Config{}, requiredConfigErr(ConfigKeyContainerName)
+	if cfg.ContainerPattern, err = parsePattern(cfgRaw, ConfigKeyContainerNamePattern); err != nil {
+		return Config{}, err
+	}
+
+	if len(cfg.ContainerNames) == 0 && cfg.ContainerPattern == nil {
+		return Config{}, fmt.Errorf(
+			"%q or %q config value must be set",
+			ConfigKeyContainerName,
+			ConfigKeyContainerNamePattern,
+		)
 	}
 
 	if cfg.PollingPeriod, err = parsePollingPeriod(cfgRaw); err != nil {
@@ -60,6 +154,117 @@ func ParseConfig(cfgRaw map[string]string) (_ Config, err error) {
 		return Config{}, err
 	}
 
+	cfg.Filter.Prefix = cfgRaw[ConfigKeyPrefix]
+
+	if cfg.Filter.Include, err = parsePattern(cfgRaw, ConfigKeyInclude); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.Filter.Exclude, err = parsePattern(cfgRaw, ConfigKeyExclude); err != nil {
+		return Config{}, err
+	}
+
+	cfg.Filter.Delimiter = cfgRaw[ConfigKeyDelimiter]
+
+	if cfg.Filter.Directories, err = parsePattern(cfgRaw, ConfigKeyDirectories); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.Filter.MaxDepth, err = parseMaxDepth(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.Filter.Delimiter == "" && (cfg.Filter.Directories != nil || cfg.Filter.MaxDepth > 0) {
+		return Config{}, fmt.Errorf(
+			"%q and %q config values require %q to be set",
+			ConfigKeyDirectories,
+			ConfigKeyMaxDepth,
+			ConfigKeyDelimiter,
+		)
+	}
+
+	if cfg.CDCMode, err = parseCDCMode(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	cfg.ChangeFeedContainerName = cfgRaw[ConfigKeyChangeFeedContainerName]
+	if cfg.ChangeFeedContainerName == "" {
+		cfg.ChangeFeedContainerName = DefaultChangeFeedContainerName
+	}
+
+	cfg.EventGridAddress = cfgRaw[ConfigKeyEventGridAddress]
+	if cfg.EventGridAddress == "" {
+		cfg.EventGridAddress = DefaultEventGridAddress
+	}
+
+	cfg.EventGridSecret = cfgRaw[ConfigKeyEventGridSecret]
+	if cfg.CDCMode == CDCModeEventGrid && cfg.EventGridSecret == "" {
+		return Config{}, fmt.Errorf(
+			"%q config value must be set when %q is %q",
+			ConfigKeyEventGridSecret,
+			ConfigKeyCDCMode,
+			CDCModeEventGrid,
+		)
+	}
+
+	cfg.QueueName = cfgRaw[ConfigKeyQueueName]
+	if cfg.CDCMode == CDCModeStorageQueue && cfg.QueueName == "" {
+		return Config{}, fmt.Errorf(
+			"%q config value must be set when %q is %q",
+			ConfigKeyQueueName,
+			ConfigKeyCDCMode,
+			CDCModeStorageQueue,
+		)
+	}
+
+	if cfg.QueueVisibilityTimeout, err = parseQueueVisibilityTimeout(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.DeleteDetection, err = parseDeleteDetection(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	cfg.StateDirectory = cfgRaw[ConfigKeyStateDirectory]
+
+	if cfg.DeleteDetection == DeleteDetectionStateDiff {
+		if cfg.CDCMode != CDCModePolling {
+			return Config{}, fmt.Errorf(
+				"%q config value %q requires %q to be %q",
+				ConfigKeyDeleteDetection,
+				DeleteDetectionStateDiff,
+				ConfigKeyCDCMode,
+				CDCModePolling,
+			)
+		}
+
+		if cfg.StateDirectory == "" {
+			return Config{}, fmt.Errorf(
+				"%q config value must be set when %q is %q",
+				ConfigKeyStateDirectory,
+				ConfigKeyDeleteDetection,
+				DeleteDetectionStateDiff,
+			)
+		}
+	}
+
+	if cfg.RecordSplitting, err = parseRecordSplitting(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.SplitterOptions, err = parseSplitterOptions(cfgRaw); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.SplitterOptions.DecodeJSON && cfg.RecordSplitting != iterator.RecordSplittingNone {
+		return Config{}, fmt.Errorf(
+			"%q config value requires %q to be %q",
+			ConfigKeyDecodeJSON,
+			ConfigKeyRecordSplitting,
+			iterator.RecordSplittingNone,
+		)
+	}
+
 	return cfg, nil
 }
 
@@ -67,6 +272,19 @@ func requiredConfigErr(name string) error {
 	return fmt.Errorf("%q config value must be set", name)
 }
 
+// parseContainerNames parses the comma-separated list of the names of the containers to monitor.
+func parseContainerNames(cfgRaw map[string]string) []string {
+	var names []string
+
+	for _, name := range strings.Split(cfgRaw[ConfigKeyContainerName], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
 func parsePollingPeriod(cfgRaw map[string]string) (time.Duration, error) {
 	pollingPeriodString, exists := cfgRaw[ConfigKeyPollingPeriod]
 	if !exists || pollingPeriodString == "" {
@@ -110,3 +328,174 @@ func parseMaxResults(cfgRaw map[string]string) (int32, error) {
 
 	return int32(maxResultsParsed), nil
 }
+
+// parsePattern parses the glob or regular expression blob names are matched against, or returns nil when not set.
+func parsePattern(cfgRaw map[string]string, key string) (*iterator.Pattern, error) {
+	patternString := cfgRaw[key]
+	if patternString == "" {
+		return nil, nil
+	}
+
+	pattern, err := iterator.ParsePattern(patternString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %q config value: %w", key, err)
+	}
+
+	return pattern, nil
+}
+
+// parseMaxDepth parses the number of virtual directory levels listed, where 0 means no limit.
+func parseMaxDepth(cfgRaw map[string]string) (int, error) {
+	maxDepthString := cfgRaw[ConfigKeyMaxDepth]
+	if maxDepthString == "" {
+		return 0, nil
+	}
+
+	maxDepth, err := strconv.Atoi(maxDepthString)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %q config value: %w", ConfigKeyMaxDepth, err)
+	}
+	if maxDepth < 0 {
+		return 0, fmt.Errorf("failed to parse %q config value: value must be greater than or equal to 0, %d provided", ConfigKeyMaxDepth, maxDepth)
+	}
+
+	return maxDepth, nil
+}
+
+func parseCDCMode(cfgRaw map[string]string) (string, error) {
+	mode := cfgRaw[ConfigKeyCDCMode]
+
+	switch mode {
+	case "":
+		return DefaultCDCMode, nil
+
+	case CDCModePolling, CDCModeChangeFeed, CDCModeEventGrid, CDCModeStorageQueue:
+		return mode, nil
+
+	default:
+		return "", fmt.Errorf("failed to parse %q config value: unsupported CDC mode %q", ConfigKeyCDCMode, mode)
+	}
+}
+
+// parseQueueVisibilityTimeout parses how long the queue messages received stay invisible to other consumers, which
+// the Queue service supports in whole seconds, up to 7 days.
+func parseQueueVisibilityTimeout(cfgRaw map[string]string) (time.Duration, error) {
+	timeoutString := cfgRaw[ConfigKeyQueueVisibilityTimeout]
+	if timeoutString == "" {
+		timeoutString = DefaultQueueVisibilityTimeout
+	}
+
+	timeout, err := time.ParseDuration(timeoutString)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %q config value: %w", ConfigKeyQueueVisibilityTimeout, err)
+	}
+	if timeout < time.Second || timeout > 7*24*time.Hour {
+		return 0, fmt.Errorf("failed to parse %q config value: value must be between 1s and 168h, %s provided", ConfigKeyQueueVisibilityTimeout, timeout)
+	}
+
+	return timeout, nil
+}
+
+func parseDeleteDetection(cfgRaw map[string]string) (string, error) {
+	method := cfgRaw[ConfigKeyDeleteDetection]
+
+	switch method {
+	case "":
+		return DefaultDeleteDetection, nil
+
+	case DeleteDetectionSoftDelete, DeleteDetectionStateDiff:
+		return method, nil
+
+	default:
+		return "", fmt.Errorf("failed to parse %q config value: unsupported delete detection %q", ConfigKeyDeleteDetection, method)
+	}
+}
+
+func parseRecordSplitting(cfgRaw map[string]string) (string, error) {
+	mode := cfgRaw[ConfigKeyRecordSplitting]
+
+	switch mode {
+	case "":
+		return DefaultRecordSplitting, nil
+
+	case iterator.RecordSplittingNone, iterator.RecordSplittingLines, iterator.RecordSplittingCSV, iterator.RecordSplittingParquet,
+		iterator.RecordSplittingAvro:
+		return mode, nil
+
+	default:
+		return "", fmt.Errorf("failed to parse %q config value: unsupported record splitting %q", ConfigKeyRecordSplitting, mode)
+	}
+}
+
+func parseSplitterOptions(cfgRaw map[string]string) (options iterator.SplitterOptions, err error) {
+	switch options.CSVHeader = cfgRaw[ConfigKeyCSVHeader]; options.CSVHeader {
+	case "":
+		options.CSVHeader = DefaultCSVHeader
+
+	case iterator.CSVHeaderAuto, iterator.CSVHeaderPresent, iterator.CSVHeaderAbsent:
+
+	default:
+		return iterator.SplitterOptions{}, fmt.Errorf("failed to parse %q config value: unsupported CSV header %q", ConfigKeyCSVHeader, options.CSVHeader)
+	}
+
+	if options.CSVDelimiter, err = parseCSVCharacter(cfgRaw, ConfigKeyCSVDelimiter, DefaultCSVDelimiter); err != nil {
+		return iterator.SplitterOptions{}, err
+	}
+
+	if options.CSVQuote, err = parseCSVCharacter(cfgRaw, ConfigKeyCSVQuote, DefaultCSVQuote); err != nil {
+		return iterator.SplitterOptions{}, err
+	}
+
+	if options.CSVQuote == options.CSVDelimiter {
+		return iterator.SplitterOptions{}, fmt.Errorf("%q and %q config values must differ", ConfigKeyCSVDelimiter, ConfigKeyCSVQuote)
+	}
+
+	options.CSVInferTypes = DefaultCSVInferTypes
+
+	if inferTypesString := cfgRaw[ConfigKeyCSVInferTypes]; inferTypesString != "" {
+		if options.CSVInferTypes, err = strconv.ParseBool(inferTypesString); err != nil {
+			return iterator.SplitterOptions{}, fmt.Errorf("failed to parse %q config value: %w", ConfigKeyCSVInferTypes, err)
+		}
+	}
+
+	options.CSVKeyColumn = cfgRaw[ConfigKeyCSVKeyColumn]
+
+	options.DecodeJSON = DefaultDecodeJSON
+
+	if decodeJSONString := cfgRaw[ConfigKeyDecodeJSON]; decodeJSONString != "" {
+		if options.DecodeJSON, err = strconv.ParseBool(decodeJSONString); err != nil {
+			return iterator.SplitterOptions{}, fmt.Errorf("failed to parse %q config value: %w", ConfigKeyDecodeJSON, err)
+		}
+	}
+
+	switch options.InvalidJSON = cfgRaw[ConfigKeyInvalidJSON]; options.InvalidJSON {
+	case "":
+		options.InvalidJSON = DefaultInvalidJSON
+
+	case iterator.InvalidJSONFail, iterator.InvalidJSONSkip, iterator.InvalidJSONRaw:
+
+	default:
+		return iterator.SplitterOptions{}, fmt.Errorf("failed to parse %q config value: unsupported invalid JSON handling %q", ConfigKeyInvalidJSON, options.InvalidJSON)
+	}
+
+	return options, nil
+}
+
+// parseCSVCharacter parses the single character of CSV files, where `\t` stands for the tab character.
+func parseCSVCharacter(cfgRaw map[string]string, key, defaultValue string) (rune, error) {
+	s := cfgRaw[key]
+
+	switch s {
+	case "":
+		s = defaultValue
+	case `\t`:
+		s = "\t"
+	}
+
+	c, size := utf8.DecodeRuneInString(s)
+	if size != len(s) || c == utf8.RuneError || c == '\r' || c == '\n' {
+		return 0, fmt.Errorf("failed to parse %q config value: a single character other than a line break is expected, got %q", key, s)
+	}
+
+	return c, nil
+}
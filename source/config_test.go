@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/jaswdr/faker"
+	"github.com/miquido/conduit-connector-azure-storage/source/iterator"
 	"github.com/stretchr/testify/require"
 )
 
@@ -43,12 +44,28 @@ func TestParseConfig(t *testing.T) {
 		},
 		{
 			name:  "Container Name is empty",
-			error: fmt.Sprintf("%q config value must be set", ConfigKeyContainerName),
+			error: fmt.Sprintf("%q or %q config value must be set", ConfigKeyContainerName, ConfigKeyContainerNamePattern),
 			cfg: map[string]string{
 				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
 				"nonExistentKey":          "value",
 			},
 		},
+		{
+			name:  "Container Name lists no names",
+			error: fmt.Sprintf("%q or %q config value must be set", ConfigKeyContainerName, ConfigKeyContainerNamePattern),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    " , ",
+			},
+		},
+		{
+			name:  "Container Name Pattern is invalid",
+			error: fmt.Sprintf("failed to parse %q config value: invalid pattern \"logs-{a\": unterminated alternatives", ConfigKeyContainerNamePattern),
+			cfg: map[string]string{
+				ConfigKeyConnectionString:     fakerInstance.Internet().Query(),
+				ConfigKeyContainerNamePattern: "logs-{a",
+			},
+		},
 		{
 			name:  "Pooling Period has invalid format",
 			error: fmt.Sprintf("%q config value should be a valid duration", ConfigKeyPollingPeriod),
@@ -119,6 +136,173 @@ func TestParseConfig(t *testing.T) {
 				"nonExistentKey":          "value",
 			},
 		},
+		{
+			name:  "Include is an invalid glob",
+			error: fmt.Sprintf("failed to parse %q config value: invalid pattern \"logs/[a-z\": unterminated character class", ConfigKeyInclude),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyInclude:          "logs/[a-z",
+			},
+		},
+		{
+			name:  "Exclude is an invalid regular expression",
+			error: fmt.Sprintf("failed to parse %q config value: invalid pattern \"regex:(tmp\": error parsing regexp: missing closing ): `(tmp`", ConfigKeyExclude),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyExclude:          "regex:(tmp",
+			},
+		},
+		{
+			name:  "Max Depth is negative",
+			error: fmt.Sprintf("failed to parse %q config value: value must be greater than or equal to 0, -1 provided", ConfigKeyMaxDepth),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyDelimiter:        "/",
+				ConfigKeyMaxDepth:         "-1",
+			},
+		},
+		{
+			name:  "CDC Mode is not supported",
+			error: fmt.Sprintf("failed to parse %q config value: unsupported CDC mode \"webhooks\"", ConfigKeyCDCMode),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyCDCMode:          "webhooks",
+			},
+		},
+		{
+			name:  "Event Grid Secret is not set in the Event Grid CDC Mode",
+			error: fmt.Sprintf("%q config value must be set when %q is %q", ConfigKeyEventGridSecret, ConfigKeyCDCMode, CDCModeEventGrid),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyCDCMode:          CDCModeEventGrid,
+			},
+		},
+		{
+			name:  "Queue Name is not set in the Storage Queue CDC Mode",
+			error: fmt.Sprintf("%q config value must be set when %q is %q", ConfigKeyQueueName, ConfigKeyCDCMode, CDCModeStorageQueue),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyCDCMode:          CDCModeStorageQueue,
+			},
+		},
+		{
+			name:  "Queue Visibility Timeout is too long",
+			error: fmt.Sprintf("failed to parse %q config value: value must be between 1s and 168h, 192h0m0s provided", ConfigKeyQueueVisibilityTimeout),
+			cfg: map[string]string{
+				ConfigKeyConnectionString:       fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:          fakerInstance.Lorem().Word(),
+				ConfigKeyQueueVisibilityTimeout: "192h",
+			},
+		},
+		{
+			name:  "Delete Detection is not supported",
+			error: fmt.Sprintf("failed to parse %q config value: unsupported delete detection \"never\"", ConfigKeyDeleteDetection),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyDeleteDetection:  "never",
+			},
+		},
+		{
+			name:  "State Directory is not set in the State Diff Delete Detection",
+			error: fmt.Sprintf("%q config value must be set when %q is %q", ConfigKeyStateDirectory, ConfigKeyDeleteDetection, DeleteDetectionStateDiff),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyDeleteDetection:  DeleteDetectionStateDiff,
+			},
+		},
+		{
+			name:  "State Diff Delete Detection is set in another CDC Mode",
+			error: fmt.Sprintf("%q config value %q requires %q to be %q", ConfigKeyDeleteDetection, DeleteDetectionStateDiff, ConfigKeyCDCMode, CDCModePolling),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyCDCMode:          CDCModeEventGrid,
+				ConfigKeyEventGridSecret:  "secret",
+				ConfigKeyDeleteDetection:  DeleteDetectionStateDiff,
+				ConfigKeyStateDirectory:   "/var/lib/conduit",
+			},
+		},
+		{
+			name:  "Record Splitting is not supported",
+			error: fmt.Sprintf("failed to parse %q config value: unsupported record splitting %q", ConfigKeyRecordSplitting, "words"),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyRecordSplitting:  "words",
+			},
+		},
+		{
+			name:  "CSV Header is not supported",
+			error: fmt.Sprintf("failed to parse %q config value: unsupported CSV header %q", ConfigKeyCSVHeader, "maybe"),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyCSVHeader:        "maybe",
+			},
+		},
+		{
+			name:  "CSV Delimiter is not a single character",
+			error: fmt.Sprintf("failed to parse %q config value: a single character other than a line break is expected, got %q", ConfigKeyCSVDelimiter, "||"),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyCSVDelimiter:     "||",
+			},
+		},
+		{
+			name:  "CSV Quote is the CSV Delimiter",
+			error: fmt.Sprintf("%q and %q config values must differ", ConfigKeyCSVDelimiter, ConfigKeyCSVQuote),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyCSVQuote:         ",",
+			},
+		},
+		{
+			name:  "CSV Infer Types is not a boolean",
+			error: fmt.Sprintf("failed to parse %q config value: strconv.ParseBool: parsing %q: invalid syntax", ConfigKeyCSVInferTypes, "sometimes"),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyCSVInferTypes:    "sometimes",
+			},
+		},
+		{
+			name:  "Invalid JSON handling is not supported",
+			error: fmt.Sprintf("failed to parse %q config value: unsupported invalid JSON handling %q", ConfigKeyInvalidJSON, "ignore"),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyInvalidJSON:      "ignore",
+			},
+		},
+		{
+			name:  "Decode JSON is set with Record Splitting",
+			error: fmt.Sprintf("%q config value requires %q to be %q", ConfigKeyDecodeJSON, ConfigKeyRecordSplitting, iterator.RecordSplittingNone),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyRecordSplitting:  iterator.RecordSplittingLines,
+				ConfigKeyDecodeJSON:       "true",
+			},
+		},
+		{
+			name:  "Max Depth is set without Delimiter",
+			error: fmt.Sprintf("%q and %q config values require %q to be set", ConfigKeyDirectories, ConfigKeyMaxDepth, ConfigKeyDelimiter),
+			cfg: map[string]string{
+				ConfigKeyConnectionString: fakerInstance.Internet().Query(),
+				ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
+				ConfigKeyMaxDepth:         "2",
+			},
+		},
 	} {
 		t.Run(fmt.Sprintf("Fails when: %s", tt.name), func(t *testing.T) {
 			_, err := ParseConfig(tt.cfg)
@@ -138,9 +322,29 @@ func TestParseConfig(t *testing.T) {
 
 		require.NoError(t, err)
 		require.Equal(t, cfgRaw[ConfigKeyConnectionString], config.ConnectionString)
-		require.Equal(t, cfgRaw[ConfigKeyContainerName], config.ContainerName)
+		require.Equal(t, []string{cfgRaw[ConfigKeyContainerName]}, config.ContainerNames)
+		require.Nil(t, config.ContainerPattern)
 		require.Equal(t, time.Second, config.PollingPeriod)
 		require.Equal(t, DefaultMaxResults, config.MaxResults)
+		require.Empty(t, config.Filter.Prefix)
+		require.Nil(t, config.Filter.Include)
+		require.Nil(t, config.Filter.Exclude)
+		require.Equal(t, CDCModePolling, config.CDCMode)
+		require.Equal(t, DefaultChangeFeedContainerName, config.ChangeFeedContainerName)
+		require.Equal(t, DefaultEventGridAddress, config.EventGridAddress)
+		require.Empty(t, config.QueueName)
+		require.Equal(t, 5*time.Minute, config.QueueVisibilityTimeout)
+		require.Equal(t, DeleteDetectionSoftDelete, config.DeleteDetection)
+		require.Empty(t, config.StateDirectory)
+		require.Equal(t, iterator.RecordSplittingNone, config.RecordSplitting)
+		require.Equal(t, iterator.SplitterOptions{
+			CSVHeader:     iterator.CSVHeaderAuto,
+			CSVDelimiter:  ',',
+			CSVQuote:      '"',
+			CSVInferTypes: true,
+			DecodeJSON:    false,
+			InvalidJSON:   iterator.InvalidJSONFail,
+		}, config.SplitterOptions)
 	})
 
 	t.Run("Returns config when all config values were provided", func(t *testing.T) {
@@ -151,19 +355,66 @@ func TestParseConfig(t *testing.T) {
 		)
 
 		cfgRaw := map[string]string{
-			ConfigKeyConnectionString: fakerInstance.Internet().Query(),
-			ConfigKeyContainerName:    fakerInstance.Lorem().Word(),
-			ConfigKeyPollingPeriod:    fmt.Sprintf("%d.%03ds", poolingPeriodSeconds, poolingPeriodMilliseconds),
-			ConfigKeyMaxResults:       strconv.FormatInt(maxResults, 10),
-			"nonExistentKey":          "value",
+			ConfigKeyConnectionString:        fakerInstance.Internet().Query(),
+			ConfigKeyContainerName:           "first, second",
+			ConfigKeyContainerNamePattern:    "logs-*",
+			ConfigKeyPollingPeriod:           fmt.Sprintf("%d.%03ds", poolingPeriodSeconds, poolingPeriodMilliseconds),
+			ConfigKeyMaxResults:              strconv.FormatInt(maxResults, 10),
+			ConfigKeyPrefix:                  "logs/",
+			ConfigKeyInclude:                 "logs/**.json",
+			ConfigKeyExclude:                 "regex:/tmp/",
+			ConfigKeyDelimiter:               "/",
+			ConfigKeyDirectories:             "logs/{,*/}",
+			ConfigKeyMaxDepth:                "3",
+			ConfigKeyCDCMode:                 CDCModeChangeFeed,
+			ConfigKeyChangeFeedContainerName: "changefeed",
+			ConfigKeyEventGridAddress:        "127.0.0.1:9090",
+			ConfigKeyEventGridSecret:         "secret",
+			ConfigKeyQueueName:               "events",
+			ConfigKeyQueueVisibilityTimeout:  "90s",
+			ConfigKeyDeleteDetection:         DeleteDetectionSoftDelete,
+			ConfigKeyStateDirectory:          "/var/lib/conduit",
+			ConfigKeyRecordSplitting:         iterator.RecordSplittingCSV,
+			ConfigKeyCSVHeader:               iterator.CSVHeaderPresent,
+			ConfigKeyCSVDelimiter:            `\t`,
+			ConfigKeyCSVQuote:                "'",
+			ConfigKeyCSVInferTypes:           "false",
+			ConfigKeyCSVKeyColumn:            "id",
+			ConfigKeyInvalidJSON:             iterator.InvalidJSONRaw,
+			"nonExistentKey":                 "value",
 		}
 
 		config, err := ParseConfig(cfgRaw)
 
 		require.NoError(t, err)
 		require.Equal(t, cfgRaw[ConfigKeyConnectionString], config.ConnectionString)
-		require.Equal(t, cfgRaw[ConfigKeyContainerName], config.ContainerName)
+		require.Equal(t, []string{"first", "second"}, config.ContainerNames)
+		require.True(t, config.ContainerPattern.Match("logs-2022"))
 		require.Equal(t, cfgRaw[ConfigKeyPollingPeriod], fmt.Sprintf("%.3fs", float64(config.PollingPeriod.Milliseconds())/1000.0))
 		require.EqualValues(t, maxResults, config.MaxResults)
+		require.Equal(t, "logs/", config.Filter.Prefix)
+		require.True(t, config.Filter.Matches("logs/2022/01/records.json"))
+		require.False(t, config.Filter.Matches("logs/tmp/records.json"))
+		require.False(t, config.Filter.Matches("data/records.json"))
+		require.Equal(t, "/", config.Filter.Delimiter)
+		require.True(t, config.Filter.Directories.Match("logs/2022/"))
+		require.Equal(t, 3, config.Filter.MaxDepth)
+		require.Equal(t, CDCModeChangeFeed, config.CDCMode)
+		require.Equal(t, "changefeed", config.ChangeFeedContainerName)
+		require.Equal(t, "127.0.0.1:9090", config.EventGridAddress)
+		require.Equal(t, "secret", config.EventGridSecret)
+		require.Equal(t, "events", config.QueueName)
+		require.Equal(t, 90*time.Second, config.QueueVisibilityTimeout)
+		require.Equal(t, DeleteDetectionSoftDelete, config.DeleteDetection)
+		require.Equal(t, "/var/lib/conduit", config.StateDirectory)
+		require.Equal(t, iterator.RecordSplittingCSV, config.RecordSplitting)
+		require.Equal(t, iterator.SplitterOptions{
+			CSVHeader:     iterator.CSVHeaderPresent,
+			CSVDelimiter:  '\t',
+			CSVQuote:      '\'',
+			CSVInferTypes: false,
+			CSVKeyColumn:  "id",
+			InvalidJSON:   iterator.InvalidJSONRaw,
+		}, config.SplitterOptions)
 	})
 }
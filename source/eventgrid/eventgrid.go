@@ -0,0 +1,336 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package eventgrid receives the Azure Event Grid events of the storage account's blobs, either pushed to an HTTP
+// endpoint or routed to a Storage Queue, subscribed to the account's system topic with the Event Grid schema.
+// See: https://docs.microsoft.com/azure/event-grid/event-schema-blob-storage
+package eventgrid
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types of the blob changes, and of the handshake validating the subscription.
+const (
+	EventTypeBlobCreated            = "Microsoft.Storage.BlobCreated"
+	EventTypeBlobDeleted            = "Microsoft.Storage.BlobDeleted"
+	EventTypeSubscriptionValidation = "Microsoft.EventGrid.SubscriptionValidationEvent"
+)
+
+// maxRequestBytes is the limit of the delivered batch's size, which is 1 MB at most.
+const maxRequestBytes = 1 << 20
+
+// The shared secret authorizing the requests is passed in the query parameter of the endpoint's URL subscribed
+// to Event Grid, or in the header set as the delivery property of the subscription.
+const (
+	SecretQueryParameter = "secret"
+	SecretHeader         = "X-Event-Grid-Secret"
+)
+
+// Event is the Event Grid event, with the data specific to the event type kept encoded.
+type Event struct {
+	ID          string          `json:"id"`
+	Topic       string          `json:"topic"`
+	Subject     string          `json:"subject"`
+	EventType   string          `json:"eventType"`
+	EventTime   time.Time       `json:"eventTime"`
+	Data        json.RawMessage `json:"data"`
+	DataVersion string          `json:"dataVersion"`
+
+	// Receipt identifies the delivery of the event to acknowledge once processed, if it needs to be
+	Receipt string `json:"-"`
+}
+
+// BlobData is the data of the blob events.
+type BlobData struct {
+	API           string `json:"api"`
+	ETag          string `json:"eTag"`
+	ContentType   string `json:"contentType"`
+	ContentLength int64  `json:"contentLength"`
+	BlobType      string `json:"blobType"`
+	URL           string `json:"url"`
+	Sequencer     string `json:"sequencer"`
+}
+
+// ValidationData is the data of the subscription validation event.
+type ValidationData struct {
+	ValidationCode string `json:"validationCode"`
+	ValidationURL  string `json:"validationUrl"`
+}
+
+// Blob returns the names of the container and of the blob the event's subject refers to,
+// e.g. /blobServices/default/containers/{container}/blobs/{name}.
+func (e Event) Blob() (container, name string, ok bool) {
+	const (
+		containersPrefix = "/blobServices/default/containers/"
+		blobsSeparator   = "/blobs/"
+	)
+
+	if !strings.HasPrefix(e.Subject, containersPrefix) {
+		return "", "", false
+	}
+
+	subject := strings.TrimPrefix(e.Subject, containersPrefix)
+
+	i := strings.Index(subject, blobsSeparator)
+	if i < 0 {
+		return "", "", false
+	}
+
+	return subject[:i], subject[i+len(blobsSeparator):], true
+}
+
+// BlobData decodes the data of the blob event.
+func (e Event) BlobData() (BlobData, error) {
+	var data BlobData
+
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return BlobData{}, fmt.Errorf("invalid data of the event %q: %w", e.ID, err)
+	}
+
+	return data, nil
+}
+
+// Receiver is notified about the events of a container's blobs.
+// Events are delivered again later when it returns an error.
+type Receiver interface {
+	Receive(ctx context.Context, event Event) error
+}
+
+// Publisher passes the events of the containers' blobs to the receivers subscribed, and is told once the events
+// were processed.
+type Publisher interface {
+	Subscribe(container string, receiver Receiver)
+	Unsubscribe(container string, receiver Receiver)
+	Ack(ctx context.Context, receipt string) error
+}
+
+// Subscriptions holds the receivers subscribed to the events of the containers' blobs, and the containers expected
+// to be subscribed to, e.g. once their snapshot is done.
+type Subscriptions struct {
+	lock      sync.RWMutex
+	receivers map[string]Receiver
+	expected  map[string]bool
+}
+
+// Expect tells the containers whose events are going to have a receiver, so their events are not dropped while
+// they have none yet.
+func (s *Subscriptions) Expect(containers ...string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.expected == nil {
+		s.expected = make(map[string]bool, len(containers))
+	}
+
+	for _, container := range containers {
+		s.expected[container] = true
+	}
+}
+
+// Awaits indicates whether the events of the container are expected to have a receiver, which is not subscribed
+// yet, so they cannot be processed now.
+func (s *Subscriptions) Awaits(container string) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, subscribed := s.receivers[container]
+
+	return s.expected[container] && !subscribed
+}
+
+// Subscribe passes the events of the container's blobs to the receiver, replacing the previous one.
+func (s *Subscriptions) Subscribe(container string, receiver Receiver) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.receivers == nil {
+		s.receivers = make(map[string]Receiver)
+	}
+
+	s.receivers[container] = receiver
+}
+
+// Unsubscribe stops passing the events of the container's blobs to the receiver.
+func (s *Subscriptions) Unsubscribe(container string, receiver Receiver) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.receivers[container] == receiver {
+		delete(s.receivers, container)
+	}
+}
+
+// Receiver returns the receiver of the events of the container's blobs, if any.
+func (s *Subscriptions) Receiver(container string) (Receiver, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	receiver, ok := s.receivers[container]
+
+	return receiver, ok
+}
+
+// Server is the HTTP endpoint the events are pushed to, passing them to the receivers of their containers.
+// Only the requests carrying the shared secret are accepted. Events of the containers no receiver is expected to be
+// subscribed to are dropped.
+type Server struct {
+	Subscriptions
+
+	secret string
+	server *http.Server
+}
+
+// NewServer creates the server listening on the address once started, accepting the requests with the secret.
+func NewServer(address, secret string) *Server {
+	s := &Server{secret: secret}
+
+	s.server = &http.Server{
+		Addr:              address,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return s
+}
+
+// Start starts accepting the connections in the background.
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("could not listen on %q: %w", s.server.Addr, err)
+	}
+
+	go func() {
+		_ = s.server.Serve(listener)
+	}()
+
+	return nil
+}
+
+// Shutdown stops the server, waiting for the requests being handled to complete.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.server.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// Ack does nothing, as the events pushed are acknowledged once passed to their receivers.
+func (s *Server) Ack(context.Context, string) error {
+	return nil
+}
+
+// ServeHTTP handles the batch of events delivered, answering the subscription validation handshake.
+// Any failure to pass an event to its receiver, or a receiver not subscribed yet, makes Event Grid deliver
+// the whole batch again later.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "invalid or missing secret", http.StatusUnauthorized)
+
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	var events []Event
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&events); err != nil {
+		http.Error(w, fmt.Sprintf("invalid events: %s", err), http.StatusBadRequest)
+
+		return
+	}
+
+	for _, event := range events {
+		if event.EventType == EventTypeSubscriptionValidation {
+			s.validate(w, event)
+
+			return
+		}
+
+		// None of the events is passed, so they are not received twice once delivered again
+		if container, _, ok := event.Blob(); ok && s.Awaits(container) {
+			http.Error(w, fmt.Sprintf("container %q is not read yet", container), http.StatusServiceUnavailable)
+
+			return
+		}
+	}
+
+	for _, event := range events {
+		container, _, ok := event.Blob()
+		if !ok {
+			continue
+		}
+
+		receiver, ok := s.Receiver(container)
+		if !ok {
+			continue
+		}
+
+		if err := receiver.Receive(r.Context(), event); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// authorized indicates whether the request carries the secret, either in the query or in the header.
+func (s *Server) authorized(r *http.Request) bool {
+	if s.secret == "" {
+		return false
+	}
+
+	secret := r.URL.Query().Get(SecretQueryParameter)
+	if secret == "" {
+		secret = r.Header.Get(SecretHeader)
+	}
+
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(s.secret)) == 1
+}
+
+// validate answers the subscription validation event with its validation code.
+func (s *Server) validate(w http.ResponseWriter, event Event) {
+	var data ValidationData
+
+	if err := json.Unmarshal(event.Data, &data); err != nil || data.ValidationCode == "" {
+		http.Error(w, "invalid subscription validation event", http.StatusBadRequest)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	_ = json.NewEncoder(w).Encode(struct {
+		ValidationResponse string `json:"validationResponse"`
+	}{
+		ValidationResponse: data.ValidationCode,
+	})
+}
@@ -0,0 +1,174 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package eventgrid
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type receiver struct {
+	events []Event
+	err    error
+}
+
+func (r *receiver) Receive(_ context.Context, event Event) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	r.events = append(r.events, event)
+
+	return nil
+}
+
+func TestEvent_Blob(t *testing.T) {
+	container, name, ok := Event{Subject: "/blobServices/default/containers/logs/blobs/2022/05/a.json"}.Blob()
+	require.True(t, ok)
+	require.Equal(t, "logs", container)
+	require.Equal(t, "2022/05/a.json", name)
+
+	_, _, ok = Event{Subject: "/blobServices/default/containers/logs"}.Blob()
+	require.False(t, ok)
+}
+
+func TestServer_ServeHTTP(t *testing.T) {
+	const blobCreated = `[{
+		"id": "1",
+		"topic": "/subscriptions/s/resourceGroups/g/providers/Microsoft.Storage/storageAccounts/a",
+		"subject": "/blobServices/default/containers/logs/blobs/a.json",
+		"eventType": "Microsoft.Storage.BlobCreated",
+		"eventTime": "2022-05-30T13:00:00Z",
+		"data": {"api": "PutBlob", "eTag": "0x1", "contentType": "application/json", "url": "https://a.blob.core.windows.net/logs/a.json"},
+		"dataVersion": ""
+	}]`
+
+	serve := func(server *Server, method, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(method, "/?secret=secret", strings.NewReader(body)))
+
+		return recorder
+	}
+
+	t.Run("Answers the subscription validation", func(t *testing.T) {
+		response := serve(NewServer("", "secret"), http.MethodPost, `[{
+			"id": "2",
+			"subject": "",
+			"eventType": "Microsoft.EventGrid.SubscriptionValidationEvent",
+			"eventTime": "2022-05-30T13:00:00Z",
+			"data": {"validationCode": "512d38b6-c7b8-40c8-89fe-f46f9e9622b6"}
+		}]`)
+
+		require.Equal(t, http.StatusOK, response.Code)
+		require.JSONEq(t, `{"validationResponse": "512d38b6-c7b8-40c8-89fe-f46f9e9622b6"}`, response.Body.String())
+	})
+
+	t.Run("Passes the events to the container's receiver", func(t *testing.T) {
+		received := &receiver{}
+
+		server := NewServer("", "secret")
+		server.Subscribe("logs", received)
+
+		response := serve(server, http.MethodPost, blobCreated)
+		require.Equal(t, http.StatusOK, response.Code)
+
+		require.Len(t, received.events, 1)
+		require.Equal(t, EventTypeBlobCreated, received.events[0].EventType)
+
+		data, err := received.events[0].BlobData()
+		require.NoError(t, err)
+		require.Equal(t, "application/json", data.ContentType)
+		require.Equal(t, "0x1", data.ETag)
+	})
+
+	t.Run("Drops the events of other containers", func(t *testing.T) {
+		received := &receiver{}
+
+		server := NewServer("", "secret")
+		server.Subscribe("data", received)
+
+		require.Equal(t, http.StatusOK, serve(server, http.MethodPost, blobCreated).Code)
+
+		server.Subscribe("logs", received)
+		server.Unsubscribe("logs", received)
+
+		require.Equal(t, http.StatusOK, serve(server, http.MethodPost, blobCreated).Code)
+		require.Empty(t, received.events)
+	})
+
+	t.Run("Asks for a redelivery when the receiver fails", func(t *testing.T) {
+		server := NewServer("", "secret")
+		server.Subscribe("logs", &receiver{err: errors.New("stopped")})
+
+		require.Equal(t, http.StatusServiceUnavailable, serve(server, http.MethodPost, blobCreated).Code)
+	})
+
+	t.Run("Asks for a redelivery when the container's receiver is not subscribed yet", func(t *testing.T) {
+		received := &receiver{}
+
+		server := NewServer("", "secret")
+		server.Expect("data", "logs")
+		server.Subscribe("data", received)
+
+		require.Equal(t, http.StatusServiceUnavailable, serve(server, http.MethodPost, blobCreated).Code)
+
+		server.Subscribe("logs", received)
+
+		require.Equal(t, http.StatusOK, serve(server, http.MethodPost, blobCreated).Code)
+		require.Len(t, received.events, 1)
+	})
+
+	t.Run("Rejects requests without the secret", func(t *testing.T) {
+		received := &receiver{}
+
+		server := NewServer("", "secret")
+		server.Subscribe("logs", received)
+
+		for _, target := range []string{"/", "/?secret=guess"} {
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, target, strings.NewReader(blobCreated)))
+
+			require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		}
+
+		// The secret can be passed in the header as well
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(blobCreated))
+		request.Header.Set(SecretHeader, "secret")
+		server.ServeHTTP(recorder, request)
+
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Len(t, received.events, 1)
+
+		// The server without a secret accepts no requests
+		recorder = httptest.NewRecorder()
+		NewServer("", "").ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(blobCreated)))
+
+		require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("Rejects invalid requests", func(t *testing.T) {
+		require.Equal(t, http.StatusBadRequest, serve(NewServer("", "secret"), http.MethodPost, `{"id": "1"}`).Code)
+		require.Equal(t, http.StatusMethodNotAllowed, serve(NewServer("", "secret"), http.MethodGet, "").Code)
+	})
+}
@@ -0,0 +1,315 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventgrid
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/source/queue"
+	"gopkg.in/tomb.v2"
+)
+
+const (
+	// minRetryDelay is the delay before the first retry of the request failed for a transient reason, doubled with
+	// every following retry up to maxRetryDelay.
+	minRetryDelay = time.Second
+	maxRetryDelay = time.Minute
+
+	// maxDeleteAttempts is the number of attempts to delete the message, before it is left to be received again.
+	maxDeleteAttempts = 5
+)
+
+var ErrQueueConsumerIsStopped = errors.New("queue consumer is stopped")
+
+// ParseEvents decodes the event, or the batch of events, of the queue message, encoded in JSON and possibly
+// Base64-encoded as well.
+func ParseEvents(text string) ([]Event, error) {
+	data := bytes.TrimSpace([]byte(text))
+
+	if len(data) > 0 && data[0] != '{' && data[0] != '[' {
+		decoded, err := base64.StdEncoding.DecodeString(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid events: %w", err)
+		}
+
+		data = bytes.TrimSpace(decoded)
+	}
+
+	if len(data) > 0 && data[0] == '{' {
+		var event Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("invalid events: %w", err)
+		}
+
+		return []Event{event}, nil
+	}
+
+	var events []Event
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("invalid events: %w", err)
+	}
+
+	return events, nil
+}
+
+// QueueConsumer receives the events routed to the Storage Queue every pollingPeriod, passing them to the receivers
+// of their containers. A message is deleted from the queue once all of its events were acknowledged, or right away
+// when none of them was taken by a receiver. Messages with events of containers expected but not subscribed yet are
+// left in the queue, and like all the messages not deleted within the visibilityTimeout, they are received again.
+// Requests failing for a transient reason are retried with backoff.
+type QueueConsumer struct {
+	Subscriptions
+
+	client            *queue.Client
+	visibilityTimeout time.Duration
+	retryDelay        time.Duration
+	maxRetryDelay     time.Duration
+	lock              sync.Mutex
+	deliveries        map[string]*delivery
+	ticker            *time.Ticker
+	tomb              tomb.Tomb
+}
+
+// delivery is the message received, with the number of its events not acknowledged yet.
+type delivery struct {
+	message queue.Message
+	pending int
+}
+
+// NewQueueConsumer creates the consumer of the client's queue, receiving the messages once started.
+func NewQueueConsumer(client *queue.Client, pollingPeriod, visibilityTimeout time.Duration) *QueueConsumer {
+	return &QueueConsumer{
+		client:            client,
+		visibilityTimeout: visibilityTimeout,
+		retryDelay:        minRetryDelay,
+		maxRetryDelay:     maxRetryDelay,
+		deliveries:        make(map[string]*delivery),
+		ticker:            time.NewTicker(pollingPeriod),
+		tomb:              tomb.Tomb{},
+	}
+}
+
+// Start starts receiving the messages in the background.
+func (c *QueueConsumer) Start() {
+	c.tomb.Go(c.producer)
+}
+
+// Stop stops receiving the messages. Messages not deleted yet are received again once their visibility timeout
+// passes.
+func (c *QueueConsumer) Stop() {
+	c.ticker.Stop()
+	c.tomb.Kill(ErrQueueConsumerIsStopped)
+	_ = c.tomb.Wait()
+}
+
+// Err returns the error the consumer stopped with, or nil while it is running.
+func (c *QueueConsumer) Err() error {
+	select {
+	case <-c.tomb.Dead():
+		return c.tomb.Err()
+
+	default:
+		return nil
+	}
+}
+
+// Ack acknowledges one of the events of the message received with the receipt, deleting the message once all of
+// its events are acknowledged.
+func (c *QueueConsumer) Ack(ctx context.Context, receipt string) error {
+	c.lock.Lock()
+
+	d, ok := c.deliveries[receipt]
+	if ok {
+		d.pending--
+
+		if d.pending > 0 {
+			ok = false
+		} else {
+			delete(c.deliveries, receipt)
+		}
+	}
+
+	c.lock.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	return c.deleteMessage(ctx, d.message)
+}
+
+// deleteMessage deletes the message, retrying the transient errors with backoff. The message which still cannot be
+// deleted is left to be received again, so only the errors which are not transient are returned.
+func (c *QueueConsumer) deleteMessage(ctx context.Context, message queue.Message) error {
+	var delay time.Duration
+
+	for attempt := 1; ; attempt++ {
+		err := c.client.DeleteMessage(ctx, message.ID, message.PopReceipt)
+		if err == nil || queue.IsNotFound(err) {
+			// The message not found was received again meanwhile, so its events are going to be passed again
+			return nil
+		}
+
+		if !queue.IsTransient(err) {
+			return fmt.Errorf("could not delete the queue message %q: %w", message.ID, err)
+		}
+
+		if attempt == maxDeleteAttempts {
+			sdk.Logger(ctx).Warn().Err(err).Str("message", message.ID).Msg("leaving the queue message which could not be deleted to be received again")
+
+			return nil
+		}
+
+		delay = c.nextRetryDelay(delay)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-time.After(delay):
+		}
+	}
+}
+
+// nextRetryDelay returns the delay before the retry following the one delayed by the given delay, or before the
+// first retry when the delay is zero.
+func (c *QueueConsumer) nextRetryDelay(delay time.Duration) time.Duration {
+	if delay == 0 {
+		return c.retryDelay
+	}
+
+	if delay *= 2; delay > c.maxRetryDelay {
+		return c.maxRetryDelay
+	}
+
+	return delay
+}
+
+func (c *QueueConsumer) producer() error {
+	ctx := c.tomb.Context(context.Background())
+
+	var delay time.Duration
+
+	for {
+		select {
+		case <-c.tomb.Dying():
+			return c.tomb.Err()
+
+		case <-c.ticker.C:
+			err := c.poll(ctx)
+			if err == nil {
+				delay = 0
+
+				continue
+			}
+
+			if !queue.IsTransient(err) {
+				return err
+			}
+
+			delay = c.nextRetryDelay(delay)
+
+			sdk.Logger(ctx).Warn().Err(err).Dur("retryIn", delay).Msg("retrying to receive the queue messages")
+
+			select {
+			case <-c.tomb.Dying():
+				return c.tomb.Err()
+
+			case <-time.After(delay):
+			}
+		}
+	}
+}
+
+// poll receives the messages until the queue is empty.
+func (c *QueueConsumer) poll(ctx context.Context) error {
+	for {
+		messages, err := c.client.GetMessages(ctx, queue.MaxMessages, c.visibilityTimeout)
+		if err != nil {
+			return fmt.Errorf("could not receive the queue messages: %w", err)
+		}
+
+		if len(messages) == 0 {
+			return nil
+		}
+
+		for _, message := range messages {
+			if err := c.dispatch(ctx, message); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// dispatch passes the message's events to the receivers of their containers.
+func (c *QueueConsumer) dispatch(ctx context.Context, message queue.Message) error {
+	events, err := ParseEvents(message.Text)
+	if err != nil {
+		// Messages which are not events would be received again and again otherwise
+		return c.deleteMessage(ctx, message)
+	}
+
+	for _, event := range events {
+		if container, _, ok := event.Blob(); ok && c.Awaits(container) {
+			// The container is still in its snapshot, so the message is left to be received again once its
+			// visibility timeout passes, instead of being deleted with the event not taken
+			return nil
+		}
+	}
+
+	receipt := message.ID + "/" + message.PopReceipt
+
+	// The message is held until all the events are passed, so it is not deleted once the first is acknowledged
+	c.lock.Lock()
+	c.deliveries[receipt] = &delivery{message: message, pending: 1}
+	c.lock.Unlock()
+
+	for _, event := range events {
+		container, _, ok := event.Blob()
+		if !ok {
+			continue
+		}
+
+		receiver, ok := c.Receiver(container)
+		if !ok {
+			continue
+		}
+
+		event.Receipt = receipt
+
+		c.lock.Lock()
+		c.deliveries[receipt].pending++
+		c.lock.Unlock()
+
+		if err := receiver.Receive(ctx, event); err != nil {
+			// The receiver is stopped, so the message is left to be received again
+			c.lock.Lock()
+			delete(c.deliveries, receipt)
+			c.lock.Unlock()
+
+			return nil
+		}
+	}
+
+	return c.Ack(ctx, receipt)
+}
@@ -0,0 +1,308 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package eventgrid
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miquido/conduit-connector-azure-storage/source/queue"
+	"github.com/stretchr/testify/require"
+)
+
+// channelReceiver passes the events received to the channel.
+type channelReceiver chan Event
+
+func (r channelReceiver) Receive(ctx context.Context, event Event) error {
+	select {
+	case r <- event:
+		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func blobEvent(id, container, name string) string {
+	return fmt.Sprintf(`{
+		"id": %q,
+		"subject": "/blobServices/default/containers/%s/blobs/%s",
+		"eventType": "Microsoft.Storage.BlobCreated",
+		"eventTime": "2022-05-30T13:00:00Z",
+		"data": {"api": "PutBlob"}
+	}`, id, container, name)
+}
+
+func TestParseEvents(t *testing.T) {
+	event := blobEvent("1", "logs", "a.json")
+
+	for _, tt := range []struct {
+		name string
+		text string
+		ids  []string
+	}{
+		{name: "Event", text: event, ids: []string{"1"}},
+		{name: "Batch of events", text: "[" + event + "," + blobEvent("2", "logs", "b.json") + "]", ids: []string{"1", "2"}},
+		{name: "Base64-encoded event", text: base64.StdEncoding.EncodeToString([]byte(event)), ids: []string{"1"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := ParseEvents(tt.text)
+			require.NoError(t, err)
+			require.Len(t, events, len(tt.ids))
+
+			for i, id := range tt.ids {
+				require.Equal(t, id, events[i].ID)
+				require.Equal(t, EventTypeBlobCreated, events[i].EventType)
+			}
+		})
+	}
+
+	for _, text := range []string{"not an event", `{"id": `, base64.StdEncoding.EncodeToString([]byte("[1]"))} {
+		_, err := ParseEvents(text)
+		require.Error(t, err, text)
+	}
+}
+
+func TestQueueConsumer(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		messages = []string{
+			"[" + blobEvent("1", "logs", "a.json") + "," + blobEvent("2", "other", "b.json") + "," + blobEvent("3", "logs", "c.json") + "]",
+			blobEvent("4", "other", "d.json"),
+		}
+		deleted = make(chan string, 2)
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte("<QueueMessagesList>"))
+
+			for i, text := range messages {
+				_, _ = fmt.Fprintf(w, "<QueueMessage><MessageId>m%d</MessageId><PopReceipt>r%d</PopReceipt><MessageText>%s</MessageText></QueueMessage>", i, i, text)
+			}
+
+			_, _ = w.Write([]byte("</QueueMessagesList>"))
+
+			messages = nil
+
+		case http.MethodDelete:
+			deleted <- strings.TrimPrefix(r.URL.Path, "/account/events/messages/")
+
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	client, err := queue.NewClientFromConnectionString("AccountName=account;SharedAccessSignature=sig=secret;QueueEndpoint="+server.URL+"/account", "events")
+	require.NoError(t, err)
+
+	receiver := make(channelReceiver)
+
+	consumer := NewQueueConsumer(client, 10*time.Millisecond, time.Minute)
+	consumer.Subscribe("logs", receiver)
+	consumer.Start()
+
+	t.Cleanup(consumer.Stop)
+
+	received := []Event{<-receiver, <-receiver}
+	require.Equal(t, "1", received[0].ID)
+	require.Equal(t, "3", received[1].ID)
+	require.Equal(t, received[0].Receipt, received[1].Receipt)
+
+	// The message none of the events was taken of is deleted right away
+	require.Equal(t, "m1", <-deleted)
+
+	// The message is deleted once all of its events are acknowledged
+	require.NoError(t, consumer.Ack(context.Background(), received[0].Receipt))
+
+	select {
+	case id := <-deleted:
+		t.Fatalf("message %q deleted before all of its events were acknowledged", id)
+
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, consumer.Ack(context.Background(), received[1].Receipt))
+	require.Equal(t, "m0", <-deleted)
+
+	require.NoError(t, consumer.Err())
+}
+
+func TestQueueConsumer_awaitedContainer(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		gets     int
+		received int
+		deleted  = make(chan string, 1)
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte("<QueueMessagesList>"))
+
+			// The message is received again by every other poll, as long as it is not deleted
+			if gets++; gets%2 == 1 {
+				received++
+
+				_, _ = fmt.Fprintf(w, "<QueueMessage><MessageId>m0</MessageId><PopReceipt>r%d</PopReceipt><MessageText>%s</MessageText></QueueMessage>", received, blobEvent("1", "logs", "a.json"))
+			}
+
+			_, _ = w.Write([]byte("</QueueMessagesList>"))
+
+		case http.MethodDelete:
+			deleted <- r.URL.Query().Get("popreceipt")
+
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	client, err := queue.NewClientFromConnectionString("AccountName=account;SharedAccessSignature=sig=secret;QueueEndpoint="+server.URL+"/account", "events")
+	require.NoError(t, err)
+
+	consumer := NewQueueConsumer(client, 10*time.Millisecond, time.Minute)
+	consumer.Expect("logs")
+	consumer.Start()
+
+	t.Cleanup(consumer.Stop)
+
+	// The message is left in the queue while the container awaits the receiver
+	require.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return received >= 2
+	}, time.Second, 5*time.Millisecond)
+
+	select {
+	case receipt := <-deleted:
+		t.Fatalf("message received with %q deleted before the container was subscribed", receipt)
+
+	default:
+	}
+
+	receiver := make(channelReceiver)
+	consumer.Subscribe("logs", receiver)
+
+	event := <-receiver
+	require.Equal(t, "1", event.ID)
+	require.NoError(t, consumer.Ack(context.Background(), event.Receipt))
+	require.Equal(t, event.Receipt, "m0/"+<-deleted)
+
+	require.NoError(t, consumer.Err())
+}
+
+func TestQueueConsumer_retries(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		failures = map[string]int{http.MethodGet: 2, http.MethodDelete: 2}
+		message  = blobEvent("1", "logs", "a.json")
+		deleted  = make(chan string, 1)
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if failures[r.Method] > 0 {
+			failures[r.Method]--
+
+			w.Header().Set("x-ms-error-code", "ServerBusy")
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte("<QueueMessagesList>"))
+
+			if message != "" {
+				_, _ = fmt.Fprintf(w, "<QueueMessage><MessageId>m0</MessageId><PopReceipt>r0</PopReceipt><MessageText>%s</MessageText></QueueMessage>", message)
+			}
+
+			_, _ = w.Write([]byte("</QueueMessagesList>"))
+
+			message = ""
+
+		case http.MethodDelete:
+			deleted <- strings.TrimPrefix(r.URL.Path, "/account/events/messages/")
+
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	client, err := queue.NewClientFromConnectionString("AccountName=account;SharedAccessSignature=sig=secret;QueueEndpoint="+server.URL+"/account", "events")
+	require.NoError(t, err)
+
+	receiver := make(channelReceiver)
+
+	consumer := NewQueueConsumer(client, 10*time.Millisecond, time.Minute)
+	consumer.retryDelay, consumer.maxRetryDelay = time.Millisecond, 5*time.Millisecond
+	consumer.Subscribe("logs", receiver)
+	consumer.Start()
+
+	t.Cleanup(consumer.Stop)
+
+	// The messages are received once the queue service recovers
+	event := <-receiver
+	require.Equal(t, "1", event.ID)
+
+	require.NoError(t, consumer.Ack(context.Background(), event.Receipt))
+	require.Equal(t, "m0", <-deleted)
+
+	require.NoError(t, consumer.Err())
+}
+
+func TestQueueConsumer_permanentError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ms-error-code", queue.ErrorCodeQueueNotFound)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	t.Cleanup(server.Close)
+
+	client, err := queue.NewClientFromConnectionString("AccountName=account;SharedAccessSignature=sig=secret;QueueEndpoint="+server.URL+"/account", "events")
+	require.NoError(t, err)
+
+	consumer := NewQueueConsumer(client, 10*time.Millisecond, time.Minute)
+	consumer.Start()
+
+	t.Cleanup(consumer.Stop)
+
+	require.Eventually(t, func() bool { return consumer.Err() != nil }, time.Second, 5*time.Millisecond)
+	require.True(t, queue.IsNotFound(consumer.Err()))
+}
@@ -0,0 +1,286 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/linkedin/goavro/v2"
+	"github.com/miquido/conduit-connector-azure-storage/internal/avroschema"
+)
+
+// MetadataKeyAvroSchemaFingerprint is the key of the record metadata holding the fingerprint of the Avro file's
+// schema, i.e. the hex-encoded CRC-64-AVRO (Rabin) fingerprint of the schema's Parsing Canonical Form.
+const MetadataKeyAvroSchemaFingerprint = "avroSchemaFingerprint"
+
+// avroSyncLength is the length of the sync marker following each block of Avro files.
+const avroSyncLength = 16
+
+// AvroSplitter splits Avro Object Container Files, compressed with the null, deflate or snappy codec, into their
+// datums read as structured data, using the schema embedded in the file. Values of unions are unwrapped, so they
+// are read as they are rather than keyed with the type's name.
+// Records are keyed with the blob's name followed by `#` and the datum's number, e.g. `data.avro#1`.
+// Offsets are the numbers of bytes preceding the datums' blocks, and the positions also hold the number of the
+// block's datums read before, so reading resumes within the block, while the blocks preceding it are skipped
+// without being decoded. Empty blobs have no datums.
+type AvroSplitter struct{}
+
+func (s AvroSplitter) Split(ctx context.Context, name string, r io.Reader, offset int64, emit func(Part) error) error {
+	return s.SplitBlocks(ctx, name, r, offset, 0, emit)
+}
+
+func (AvroSplitter) SplitBlocks(_ context.Context, name string, r io.Reader, offset, row int64, emit func(Part) error) error {
+	contents := &countingReader{reader: bufio.NewReader(r)}
+
+	if _, err := contents.reader.Peek(1); errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	ocf, err := goavro.NewOCFReader(contents)
+	if err != nil {
+		return err
+	}
+
+	var schema interface{}
+	if err := json.Unmarshal([]byte(ocf.Codec().Schema()), &schema); err != nil {
+		return fmt.Errorf("invalid Avro schema: %w", err)
+	}
+
+	values := newAvroValues(schema)
+	fingerprint := fmt.Sprintf("%016x", ocf.Codec().Rabin)
+
+	// The blocks preceding the offset are skipped, only their counts of datums are read
+	number, err := contents.skipBlocks(offset)
+	if err != nil {
+		return err
+	}
+
+	for begin, index := contents.n, int64(0); ; index++ {
+		if ocf.RemainingBlockItems() == 0 {
+			begin, index = contents.n, 0
+		}
+
+		if !ocf.Scan() {
+			return ocf.Err()
+		}
+
+		datum, err := ocf.Read()
+		if err != nil {
+			return err
+		}
+
+		number++
+
+		// The datums of the resumed block read before are skipped
+		if begin == offset && index < row {
+			continue
+		}
+
+		payload, ok := values.value(schema, "", datum).(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("Avro datum of the block at offset %d is not a record", begin)
+		}
+
+		part := Part{
+			Payload: sdk.StructuredData(payload),
+			Key:     fmt.Sprintf("%s#%d", name, number),
+			Metadata: map[string]string{
+				MetadataKeyAvroSchemaFingerprint: fingerprint,
+				MetadataKeyRow:                   strconv.FormatInt(index, 10),
+			},
+			Offset: begin,
+			Next:   begin,
+			Row:    index + 1,
+		}
+
+		// The datum following the last one of a block is the first one of the next block
+		if ocf.RemainingBlockItems() == 0 {
+			part.Next, part.Row = contents.n, 0
+		}
+
+		if err := emit(part); err != nil {
+			return err
+		}
+	}
+}
+
+// countingReader counts the bytes read, so the offsets of Avro blocks are known.
+type countingReader struct {
+	reader *bufio.Reader
+
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+
+	return n, err
+}
+
+func (r *countingReader) ReadByte() (byte, error) {
+	b, err := r.reader.ReadByte()
+	if err == nil {
+		r.n++
+	}
+
+	return b, err
+}
+
+// skipBlocks skips the Avro blocks preceding the offset, and returns the number of their datums. The zero offset
+// is the beginning of the file, so no block is skipped.
+func (r *countingReader) skipBlocks(offset int64) (int64, error) {
+	var datums int64
+
+	for r.n < offset {
+		// Blocks begin with the number of datums and the size of the datums, encoded as Avro longs
+		count, err := binary.ReadVarint(r)
+		if err != nil {
+			return 0, fmt.Errorf("could not read the Avro block at offset %d: %w", r.n, err)
+		}
+
+		size, err := binary.ReadVarint(r)
+		if err != nil {
+			return 0, fmt.Errorf("could not read the Avro block at offset %d: %w", r.n, err)
+		}
+
+		skipped, err := io.CopyN(io.Discard, r.reader, size+avroSyncLength)
+		r.n += skipped
+
+		if err != nil {
+			return 0, fmt.Errorf("could not skip the Avro block: %w", err)
+		}
+
+		datums += count
+	}
+
+	if offset > 0 && r.n != offset {
+		return 0, fmt.Errorf("offset %d is not the beginning of an Avro block", offset)
+	}
+
+	return datums, nil
+}
+
+// avroValues converts the datums decoded by goavro into structured data, unwrapping the values of unions, which
+// goavro decodes into single-entry maps keyed with the name of the value's type.
+type avroValues struct {
+	names avroschema.Names
+}
+
+func newAvroValues(schema interface{}) avroValues {
+	return avroValues{names: avroschema.NewNames(schema)}
+}
+
+// value converts the datum's value of the schema.
+func (v avroValues) value(schema interface{}, namespace string, value interface{}) interface{} {
+	switch s := schema.(type) {
+	case string:
+		if named, exists := v.names.Lookup(s, namespace); exists {
+			return v.value(named, namespace, value)
+		}
+
+		return value
+
+	case []interface{}:
+		union, ok := value.(map[string]interface{})
+		if !ok || len(union) != 1 {
+			return value
+		}
+
+		for typeName, branchValue := range union {
+			for _, branch := range s {
+				if v.names.TypeName(branch, namespace) == typeName {
+					return v.value(branch, namespace, branchValue)
+				}
+			}
+
+			return branchValue
+		}
+
+		return value
+
+	case map[string]interface{}:
+		if _, ok := s["name"].(string); ok {
+			namespace = avroschema.Namespace(s, namespace)
+		}
+
+		switch s["type"] {
+		case "record":
+			fields, ok := value.(map[string]interface{})
+			if !ok {
+				return value
+			}
+
+			schemaFields, _ := s["fields"].([]interface{})
+			values := make(map[string]interface{}, len(fields))
+
+			for _, f := range schemaFields {
+				field, _ := f.(map[string]interface{})
+				name, _ := field["name"].(string)
+
+				if fieldValue, exists := fields[name]; exists {
+					values[name] = v.value(field["type"], namespace, fieldValue)
+				}
+			}
+
+			return values
+
+		case "array":
+			items, ok := value.([]interface{})
+			if !ok {
+				return value
+			}
+
+			values := make([]interface{}, len(items))
+			for i, item := range items {
+				values[i] = v.value(s["items"], namespace, item)
+			}
+
+			return values
+
+		case "map":
+			entries, ok := value.(map[string]interface{})
+			if !ok {
+				return value
+			}
+
+			values := make(map[string]interface{}, len(entries))
+			for key, entry := range entries {
+				values[key] = v.value(s["values"], namespace, entry)
+			}
+
+			return values
+
+		default:
+			// Types described with a nested schema, e.g. `{"type": {"type": "array", ...}}`
+			if _, ok := s["type"].(string); !ok {
+				return v.value(s["type"], namespace, value)
+			}
+
+			return value
+		}
+
+	default:
+		return value
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package iterator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+)
+
+const avroTestSchema = `{
+	"type": "record",
+	"name": "User",
+	"namespace": "com.example",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "name", "type": ["null", "string"]},
+		{"name": "createdAt", "type": ["null", {"type": "long", "logicalType": "timestamp-millis"}]},
+		{"name": "address", "type": ["null", {"type": "record", "name": "Address", "fields": [{"name": "city", "type": "string"}]}]},
+		{"name": "previous", "type": {"type": "array", "items": ["null", "Address"]}},
+		{"name": "scores", "type": {"type": "map", "values": ["null", "int"]}}
+	]
+}`
+
+// writeAvro writes the blocks of datums into an Avro file compressed with the codec, and returns the file with
+// the offsets of its blocks and its end.
+func writeAvro(t *testing.T, compression string, blocks ...[]interface{}) ([]byte, []int64) {
+	var buffer bytes.Buffer
+
+	writer, err := goavro.NewOCFWriter(goavro.OCFConfig{W: &buffer, Schema: avroTestSchema, CompressionName: compression})
+	require.NoError(t, err)
+
+	var offsets []int64
+
+	for _, block := range blocks {
+		offsets = append(offsets, int64(buffer.Len()))
+		require.NoError(t, writer.Append(block))
+	}
+
+	return buffer.Bytes(), append(offsets, int64(buffer.Len()))
+}
+
+func avroTestUser(id int64) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        id,
+		"name":      nil,
+		"createdAt": nil,
+		"address":   nil,
+		"previous":  []interface{}{},
+		"scores":    map[string]interface{}{},
+	}
+}
+
+func splitAvro(t *testing.T, contents []byte, offset, row int64) []Part {
+	var parts []Part
+
+	err := AvroSplitter{}.SplitBlocks(context.Background(), "users.avro", bytes.NewReader(contents), offset, row, func(part Part) error {
+		parts = append(parts, part)
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	return parts
+}
+
+func TestAvroSplitter_SplitBlocks(t *testing.T) {
+	codec, err := goavro.NewCodec(avroTestSchema)
+	require.NoError(t, err)
+
+	fingerprint := fmt.Sprintf("%016x", codec.Rabin)
+
+	for _, compression := range []string{goavro.CompressionNullLabel, goavro.CompressionDeflateLabel, goavro.CompressionSnappyLabel} {
+		t.Run(compression, func(t *testing.T) {
+			contents, offsets := writeAvro(t, compression, []interface{}{avroTestUser(1), avroTestUser(2)}, []interface{}{avroTestUser(3)})
+
+			t.Run("Reads the datums of all the blocks", func(t *testing.T) {
+				parts := splitAvro(t, contents, 0, 0)
+
+				require.Equal(t, []Part{
+					{
+						Payload:  sdk.StructuredData(avroTestUser(1)),
+						Key:      "users.avro#1",
+						Metadata: map[string]string{MetadataKeyAvroSchemaFingerprint: fingerprint, MetadataKeyRow: "0"},
+						Offset:   offsets[0],
+						Next:     offsets[0],
+						Row:      1,
+					},
+					{
+						Payload:  sdk.StructuredData(avroTestUser(2)),
+						Key:      "users.avro#2",
+						Metadata: map[string]string{MetadataKeyAvroSchemaFingerprint: fingerprint, MetadataKeyRow: "1"},
+						Offset:   offsets[0],
+						Next:     offsets[1],
+					},
+					{
+						Payload:  sdk.StructuredData(avroTestUser(3)),
+						Key:      "users.avro#3",
+						Metadata: map[string]string{MetadataKeyAvroSchemaFingerprint: fingerprint, MetadataKeyRow: "0"},
+						Offset:   offsets[1],
+						Next:     offsets[2],
+					},
+				}, parts)
+			})
+
+			t.Run("Resumes within a block", func(t *testing.T) {
+				parts := splitAvro(t, contents, offsets[0], 1)
+
+				require.Len(t, parts, 2)
+				require.Equal(t, "users.avro#2", parts[0].Key)
+				require.Equal(t, "users.avro#3", parts[1].Key)
+			})
+
+			t.Run("Resumes at the beginning of a block", func(t *testing.T) {
+				parts := splitAvro(t, contents, offsets[1], 0)
+
+				require.Len(t, parts, 1)
+				require.Equal(t, "users.avro#3", parts[0].Key)
+				require.Equal(t, sdk.StructuredData(avroTestUser(3)), parts[0].Payload)
+			})
+
+			t.Run("Resumes after the last block", func(t *testing.T) {
+				require.Empty(t, splitAvro(t, contents, offsets[2], 0))
+			})
+		})
+	}
+}
+
+func TestAvroSplitter_SplitBlocks_Unions(t *testing.T) {
+	createdAt := time.Date(2022, 8, 1, 12, 30, 0, 0, time.UTC)
+
+	user := avroTestUser(1)
+	user["name"] = goavro.Union("string", "John")
+	user["createdAt"] = goavro.Union("long.timestamp-millis", createdAt)
+	user["address"] = goavro.Union("com.example.Address", map[string]interface{}{"city": "Warsaw"})
+	user["previous"] = []interface{}{nil, goavro.Union("com.example.Address", map[string]interface{}{"city": "Cracow"})}
+	user["scores"] = map[string]interface{}{"math": goavro.Union("int", int32(5)), "art": nil}
+
+	contents, _ := writeAvro(t, goavro.CompressionNullLabel, []interface{}{user})
+
+	parts := splitAvro(t, contents, 0, 0)
+
+	require.Len(t, parts, 1)
+	require.Equal(t, sdk.StructuredData{
+		"id":        int64(1),
+		"name":      "John",
+		"createdAt": createdAt,
+		"address":   map[string]interface{}{"city": "Warsaw"},
+		"previous":  []interface{}{nil, map[string]interface{}{"city": "Cracow"}},
+		"scores":    map[string]interface{}{"math": int32(5), "art": nil},
+	}, parts[0].Payload)
+}
+
+func TestAvroSplitter_SplitBlocks_Invalid(t *testing.T) {
+	emit := func(Part) error {
+		t.Fatal("no part is expected")
+
+		return nil
+	}
+
+	t.Run("Skips empty blobs", func(t *testing.T) {
+		require.NoError(t, AvroSplitter{}.Split(context.Background(), "empty.avro", strings.NewReader(""), 0, emit))
+	})
+
+	t.Run("Fails for other files", func(t *testing.T) {
+		err := AvroSplitter{}.Split(context.Background(), "data.csv", strings.NewReader("id,name\n1,John\n"), 0, emit)
+
+		require.Error(t, err)
+	})
+
+	t.Run("Fails when the offset is not the beginning of a block", func(t *testing.T) {
+		contents, offsets := writeAvro(t, goavro.CompressionNullLabel, []interface{}{avroTestUser(1)}, []interface{}{avroTestUser(2)})
+
+		err := AvroSplitter{}.SplitBlocks(context.Background(), "users.avro", bytes.NewReader(contents), offsets[1]-1, 0, emit)
+
+		require.EqualError(t, err, fmt.Sprintf("offset %d is not the beginning of an Avro block", offsets[1]-1))
+	})
+}
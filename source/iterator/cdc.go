@@ -18,7 +18,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
@@ -33,22 +32,28 @@ var ErrCDCIteratorIsStopped = errors.New("CDC iterator is stopped")
 func NewCDCIterator(
 	pollingPeriod time.Duration,
 	client *azblob.ContainerClient,
-	from time.Time,
+	p position.Position,
 	maxResults int32,
+	filter Filter,
+	state *ListingState,
+	splitter Splitter,
 ) (*CDCIterator, error) {
 	if maxResults < 1 {
 		return nil, fmt.Errorf("maxResults is expected to be greater than or equal to 1, got %d", maxResults)
 	}
 
 	cdc := CDCIterator{
-		client:        client,
-		buffer:        make(chan sdk.Record, 1),
-		ticker:        time.NewTicker(pollingPeriod),
-		isTruncated:   true,
-		nextKeyMarker: nil,
-		tomb:          tomb.Tomb{},
-		lastModified:  from,
-		maxResults:    maxResults,
+		client:       client,
+		buffer:       make(chan sdk.Record, 1),
+		ticker:       time.NewTicker(pollingPeriod),
+		isTruncated:  true,
+		tomb:         tomb.Tomb{},
+		lastModified: p.Timestamp,
+		maxResults:   maxResults,
+		filter:       filter,
+		state:        state,
+		splitter:     splitter,
+		resume:       p,
 	}
 
 	cdc.tomb.Go(cdc.producer)
@@ -57,14 +62,17 @@ func NewCDCIterator(
 }
 
 type CDCIterator struct {
-	client        *azblob.ContainerClient
-	buffer        chan sdk.Record
-	ticker        *time.Ticker
-	lastModified  time.Time
-	maxResults    int32
-	isTruncated   bool
-	nextKeyMarker *string
-	tomb          tomb.Tomb
+	client       *azblob.ContainerClient
+	buffer       chan sdk.Record
+	ticker       *time.Ticker
+	lastModified time.Time
+	maxResults   int32
+	filter       Filter
+	state        *ListingState
+	splitter     Splitter
+	resume       position.Position
+	isTruncated  bool
+	tomb         tomb.Tomb
 }
 
 func (w *CDCIterator) HasNext(_ context.Context) bool {
@@ -94,7 +102,7 @@ func (w *CDCIterator) Stop() {
 	_ = w.tomb.Wait()
 }
 
-// producer reads the container and reports all file changes since last time.
+// producer reads the container and reports all changes of files matching the filter since last time.
 func (w *CDCIterator) producer() error {
 	defer close(w.buffer)
 
@@ -104,99 +112,159 @@ func (w *CDCIterator) producer() error {
 			return w.tomb.Err()
 
 		case <-w.ticker.C:
-			currentLastModified := w.lastModified
-
-			// Prepare the storage iterator
-			blobListPager := w.client.ListBlobsFlat(&azblob.ContainerListBlobsFlatOptions{
-				Marker:     w.nextKeyMarker,
-				MaxResults: &w.maxResults,
-				Include: []azblob.ListBlobsIncludeItem{
-					azblob.ListBlobsIncludeItemDeleted,
-				},
-			})
-
-			ctx := context.Background()
-
-			for blobListPager.NextPage(w.tomb.Context(ctx)) {
-				resp := blobListPager.PageResponse()
-
-				for _, item := range resp.Segment.BlobItems {
-					itemLastModificationDate := *item.Properties.LastModified
-
-					// Reject item when it wasn't modified since the last iteration
-					if itemLastModificationDate.Before(w.lastModified) {
-						continue
-					}
-
-					// Prepare the sdk.Record
-					var output sdk.Record
-
-					if nil != item.Deleted && *item.Deleted {
-						var err error
-
-						output, err = w.createDeletedRecord(item)
-						if err != nil {
-							return err
-						}
-					} else {
-						blobClient, err := w.client.NewBlobClient(*item.Name)
-						if err != nil {
-							return err
-						}
-
-						downloadResponse, err := blobClient.Download(w.tomb.Context(ctx), nil)
-						if err != nil {
-							return err
-						}
-
-						output, err = w.createUpsertedRecord(item, downloadResponse)
-						if err != nil {
-							return err
-						}
-					}
-
-					// Send out the record if possible
-					select {
-					case <-w.tomb.Dying():
-						return w.tomb.Err()
-
-					case w.buffer <- output:
-						if currentLastModified.Before(itemLastModificationDate) {
-							currentLastModified = itemLastModificationDate
-						}
-					}
+			if err := w.poll(w.tomb.Context(context.Background()), w.send); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// poll lists the container once and emits all changes of files matching the filter since last time.
+// With the state set, deleted blobs are the ones missing from the listing compared to the previous one,
+// whose names and ETags are stored in the state file. Otherwise, they are the soft-deleted ones.
+func (w *CDCIterator) poll(ctx context.Context, emit func(sdk.Record) error) error {
+	currentLastModified := w.lastModified
+	include := []azblob.ListBlobsIncludeItem{azblob.ListBlobsIncludeItemDeleted}
+
+	var (
+		diff *stateDiff
+		last sdk.Position
+	)
+
+	if w.state != nil {
+		var err error
+
+		if diff, err = w.state.diff(); err != nil {
+			return err
+		}
+
+		defer diff.Close()
+
+		include = nil
+
+		// The listing is stored once the last record emitted is acknowledged
+		next := emit
+		emit = func(record sdk.Record) error {
+			if err := next(record); err != nil {
+				return err
+			}
+
+			last = record.Position
+
+			return nil
+		}
+	}
+
+	emitDeleted := func(name string) error {
+		if !w.filter.Matches(name) {
+			return nil
+		}
+
+		output, err := w.createMissingRecord(name, w.lastModified)
+		if err != nil {
+			return err
+		}
+
+		w.filter.setDirectory(output.Metadata, name)
+
+		return emit(output)
+	}
+
+	// Prepare the storage iterator
+	blobListPager := newBlobPager(w.client, w.filter, w.maxResults, include, "", listingStart{})
+
+	for blobListPager.NextPage(ctx) {
+		for _, item := range blobListPager.Items() {
+			if diff != nil {
+				knownETag, err := diff.Visit(*item.Name, etagOf(item), emitDeleted)
+				if err != nil {
+					return err
 				}
+
+				// Skip the blobs which did not change since the previous listing
+				if knownETag != "" && knownETag == etagOf(item) {
+					continue
+				}
+			}
+
+			// Skip the blobs not matching the include and exclude patterns, deleted ones included
+			if !w.filter.Matches(*item.Name) {
+				continue
+			}
+
+			itemLastModificationDate := *item.Properties.LastModified
+
+			// Reject item when it wasn't modified since the last iteration
+			if itemLastModificationDate.Before(w.lastModified) {
+				continue
 			}
 
-			// Update times
-			w.lastModified = currentLastModified.Add(time.Nanosecond)
+			// Prepare the sdk.Record and send it out if possible
+			if nil != item.Deleted && *item.Deleted {
+				output, err := w.createDeletedRecord(item)
+				if err != nil {
+					return err
+				}
+
+				w.filter.setDirectory(output.Metadata, *item.Name)
 
-			// Report a storage reading error
-			if err := blobListPager.Err(); err != nil {
+				if err := emit(output); err != nil {
+					return err
+				}
+			} else if err := w.emitUpserted(ctx, item, emit); err != nil {
 				return err
 			}
+
+			if currentLastModified.Before(itemLastModificationDate) {
+				currentLastModified = itemLastModificationDate
+			}
 		}
 	}
+
+	// Update times
+	w.lastModified = currentLastModified.Add(time.Nanosecond)
+
+	// Only the blobs listed first may be the one read partially
+	w.resume = position.Position{}
+
+	// Report a storage reading error
+	if err := blobListPager.Err(); err != nil {
+		return err
+	}
+
+	if diff == nil {
+		return nil
+	}
+
+	if err := diff.Finish(emitDeleted); err != nil {
+		return err
+	}
+
+	return w.state.save(diff, last)
 }
 
-// createUpsertedRecord converts blob item into sdk.Record with item's contents or returns error when failure.
-func (w *CDCIterator) createUpsertedRecord(entry *azblob.BlobItemInternal, object azblob.BlobDownloadResponse) (sdk.Record, error) {
-	// Try to read item's contents
-	rawBody, err := ioutil.ReadAll(object.Body(&azblob.RetryReaderOptions{
-		MaxRetryRequests: 0,
-	}))
+// send passes the record to the buffer, unless the iterator is being stopped.
+func (w *CDCIterator) send(record sdk.Record) error {
+	select {
+	case <-w.tomb.Dying():
+		return w.tomb.Err()
+
+	case w.buffer <- record:
+		return nil
+	}
+}
+
+// emitUpserted downloads the blob item and emits it as sdk.Record with item's contents, or as the records of its
+// parts when split, or returns error when failure.
+func (w *CDCIterator) emitUpserted(ctx context.Context, entry *azblob.BlobItemInternal, emit func(sdk.Record) error) error {
+	blobClient, err := w.client.NewBlobClient(*entry.Name)
 	if err != nil {
-		return sdk.Record{}, err
+		return err
 	}
 
 	// Prepare position information
 	p := position.NewCDCPosition(*entry.Name, *entry.Properties.LastModified)
 
-	recordPosition, err := p.ToRecordPosition()
-	if err != nil {
-		return sdk.Record{}, err
-	}
-
 	// Detect operation
 	var action internal.Operation
 
@@ -206,17 +274,17 @@ func (w *CDCIterator) createUpsertedRecord(entry *azblob.BlobItemInternal, objec
 		action = internal.OperationUpdate
 	}
 
-	// Return the record
-	return sdk.Record{
+	record := sdk.Record{
 		Metadata: map[string]string{
 			"action":       action,
-			"content-type": *object.ContentType,
+			"content-type": *entry.Properties.ContentType,
 		},
-		Position:  recordPosition,
-		Payload:   sdk.RawData(rawBody),
 		Key:       sdk.RawData(p.Key),
 		CreatedAt: p.Timestamp,
-	}, nil
+	}
+	w.filter.setDirectory(record.Metadata, *entry.Name)
+
+	return emitBlob(ctx, blobClient, w.splitter, w.resume, record, p, emit)
 }
 
 // createDeletedRecord converts blob item into sdk.Record indicating that item was removed or returns error
@@ -240,3 +308,35 @@ func (w *CDCIterator) createDeletedRecord(entry *azblob.BlobItemInternal) (sdk.R
 		CreatedAt: p.Timestamp,
 	}, nil
 }
+
+// createMissingRecord creates sdk.Record indicating that the blob missing from the listing was removed.
+// The removal time is unknown, so the position holds the time the poll detects changes since, as the blobs listed
+// after the missing one may be modified earlier than the removal, and must not be skipped when resumed.
+func (w *CDCIterator) createMissingRecord(name string, since time.Time) (sdk.Record, error) {
+	// Prepare position information
+	p := position.NewCDCPosition(name, since)
+
+	recordPosition, err := p.ToRecordPosition()
+	if err != nil {
+		return sdk.Record{}, err
+	}
+
+	// Return the record
+	return sdk.Record{
+		Metadata: map[string]string{
+			"action": internal.OperationDelete,
+		},
+		Position:  recordPosition,
+		Key:       sdk.RawData(p.Key),
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// etagOf returns the blob item's ETag, or an empty string when unknown.
+func etagOf(item *azblob.BlobItemInternal) string {
+	if item.Properties == nil || item.Properties.Etag == nil {
+		return ""
+	}
+
+	return *item.Properties.Etag
+}
@@ -25,6 +25,7 @@ import (
 	sdk "github.com/conduitio/conduit-connector-sdk"
 	"github.com/jaswdr/faker"
 	"github.com/miquido/conduit-connector-azure-storage/internal"
+	"github.com/miquido/conduit-connector-azure-storage/source/position"
 	helper "github.com/miquido/conduit-connector-azure-storage/test"
 	"github.com/stretchr/testify/require"
 )
@@ -39,7 +40,7 @@ func TestCDCIterator(t *testing.T) {
 		ctx := context.Background()
 		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
 
-		iterator, err := NewCDCIterator(time.Millisecond*500, containerClient, time.Now(), fakerInstance.Int32Between(1, 100))
+		iterator, err := NewCDCIterator(time.Millisecond*500, containerClient, position.NewCDCPosition("", time.Now()), fakerInstance.Int32Between(1, 100), Filter{}, nil, nil)
 		require.NoError(t, err)
 
 		// Let the Pooling Period pass and iterator to collect blobs
@@ -70,7 +71,7 @@ func TestCDCIterator(t *testing.T) {
 			ctx := context.Background()
 			containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
 
-			iterator, err := NewCDCIterator(time.Millisecond*100, containerClient, time.Now().AddDate(0, 0, -1), tt.maxResults)
+			iterator, err := NewCDCIterator(time.Millisecond*100, containerClient, position.NewCDCPosition("", time.Now().AddDate(0, 0, -1)), tt.maxResults, Filter{}, nil, nil)
 			require.NoError(t, err)
 
 			require.NoError(t, helper.CreateBlob(containerClient, record1Name, "text/plain", record1Contents))
@@ -116,7 +117,7 @@ func TestCDCIterator(t *testing.T) {
 		require.NoError(t, helper.CreateBlob(containerClient, record2Name, "text/plain", record2Contents))
 		require.NoError(t, helper.CreateBlob(containerClient, record3Name, "text/plain", record3Contents))
 
-		iterator, err := NewCDCIterator(time.Millisecond*100, containerClient, time.Now().AddDate(0, 0, -1), 2)
+		iterator, err := NewCDCIterator(time.Millisecond*100, containerClient, position.NewCDCPosition("", time.Now().AddDate(0, 0, -1)), 2, Filter{}, nil, nil)
 		require.NoError(t, err)
 
 		// Let the Pooling Period pass and iterator to collect blobs
@@ -164,7 +165,7 @@ func TestCDCIterator(t *testing.T) {
 		require.NoError(t, helper.CreateBlob(containerClient, record1Name, "text/plain", record1Contents))
 		require.NoError(t, helper.CreateBlob(containerClient, record2Name, "text/plain", record2Contents))
 
-		iterator, err := NewCDCIterator(time.Millisecond*100, containerClient, time.Now().AddDate(0, 0, -1), 100)
+		iterator, err := NewCDCIterator(time.Millisecond*100, containerClient, position.NewCDCPosition("", time.Now().AddDate(0, 0, -1)), 100, Filter{}, nil, nil)
 		require.NoError(t, err)
 
 		// Let the Goroutine start
@@ -207,7 +208,7 @@ func TestCDCIterator(t *testing.T) {
 		require.NoError(t, helper.CreateBlob(containerClient, record1Name, "text/plain", record1Contents))
 		require.NoError(t, helper.CreateBlob(containerClient, record2Name, "text/plain", record2Contents))
 
-		iterator, err := NewCDCIterator(time.Millisecond*100, containerClient, time.Now().AddDate(0, 0, -1), 100)
+		iterator, err := NewCDCIterator(time.Millisecond*100, containerClient, position.NewCDCPosition("", time.Now().AddDate(0, 0, -1)), 100, Filter{}, nil, nil)
 		require.NoError(t, err)
 
 		// Let the Goroutine start
@@ -248,7 +249,7 @@ func TestCDCIterator(t *testing.T) {
 
 		require.NoError(t, helper.CreateBlob(containerClient, record1Name, "text/plain", record1Contents))
 
-		iterator, err := NewCDCIterator(time.Millisecond*100, containerClient, time.Now().AddDate(0, 0, -1), 100)
+		iterator, err := NewCDCIterator(time.Millisecond*100, containerClient, position.NewCDCPosition("", time.Now().AddDate(0, 0, -1)), 100, Filter{}, nil, nil)
 		require.NoError(t, err)
 
 		// Let the Goroutine start
@@ -270,4 +271,165 @@ func TestCDCIterator(t *testing.T) {
 		require.True(t, helper.AssertRecordEquals(t, record2, record1Name, "text/plain", record1ContentsUpdated))
 		require.Equal(t, internal.OperationInsert, record2.Metadata["action"])
 	})
+
+	t.Run("Reads only changes of blobs matching the filter", func(t *testing.T) {
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+
+		contents := fakerInstance.Lorem().Sentence(16)
+
+		for _, name := range []string{"logs/a.json", "logs/a.json.tmp", "data/b.json"} {
+			require.NoError(t, helper.CreateBlob(containerClient, name, "text/plain", contents))
+		}
+
+		exclude, err := ParsePattern("regex:\\.tmp$")
+		require.NoError(t, err)
+
+		iterator, err := NewCDCIterator(time.Millisecond*100, containerClient, position.NewCDCPosition("", time.Now().AddDate(0, 0, -1)), 100, Filter{
+			Prefix:  "logs/",
+			Exclude: exclude,
+		}, nil, nil)
+		require.NoError(t, err)
+
+		// Let the Goroutine start
+		time.Sleep(time.Millisecond * 500)
+
+		require.True(t, iterator.HasNext(ctx))
+
+		record, err := iterator.Next(ctx)
+		require.NoError(t, err)
+		require.True(t, helper.AssertRecordEquals(t, record, "logs/a.json", "text/plain", contents))
+
+		// Let the Goroutine run another poll
+		time.Sleep(time.Millisecond * 500)
+
+		require.False(t, iterator.HasNext(ctx))
+
+		iterator.Stop()
+	})
+
+	t.Run("Detects deleted blobs by comparing the listings", func(t *testing.T) {
+		var (
+			record1Name     = fmt.Sprintf("a%s", fakerInstance.File().FilenameWithExtension())
+			record1Contents = fakerInstance.Lorem().Sentence(16)
+			record2Name     = fmt.Sprintf("b%s", fakerInstance.File().FilenameWithExtension())
+			record2Contents = fakerInstance.Lorem().Sentence(16)
+		)
+
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+		statePath := StatePath(t.TempDir(), containerClient.URL())
+		state := NewListingState(statePath)
+
+		t.Cleanup(state.Close)
+
+		require.NoError(t, helper.CreateBlob(containerClient, record1Name, "text/plain", record1Contents))
+		require.NoError(t, helper.CreateBlob(containerClient, record2Name, "text/plain", record2Contents))
+
+		iterator, err := NewCDCIterator(time.Millisecond*100, containerClient, position.NewCDCPosition("", time.Now().AddDate(0, 0, -1)), 100, Filter{}, state, nil)
+		require.NoError(t, err)
+
+		t.Cleanup(iterator.Stop)
+
+		for _, expected := range [][2]string{{record1Name, record1Contents}, {record2Name, record2Contents}} {
+			record, err := iterator.Next(ctx)
+			require.NoError(t, err)
+			require.True(t, helper.AssertRecordEquals(t, record, expected[0], "text/plain", expected[1]))
+
+			ackRecord(t, state, record)
+		}
+
+		// Let the Goroutine list the container again
+		time.Sleep(time.Millisecond * 500)
+
+		require.False(t, iterator.HasNext(ctx))
+
+		deletedAt := time.Now()
+
+		require.NoError(t, helper.DeleteBlob(containerClient, record1Name))
+
+		record, err := iterator.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, record1Name, string(record.Key.Bytes()))
+		require.Equal(t, internal.OperationDelete, record.Metadata["action"])
+
+		// The position of the deletion does not pass the blobs listed after the deleted one
+		p, err := position.NewFromRecordPosition(record.Position)
+		require.NoError(t, err)
+		require.True(t, p.Timestamp.Before(deletedAt))
+
+		ackRecord(t, state, record)
+
+		iterator.Stop()
+
+		// The known blobs are kept in the state, so blobs deleted while stopped are detected as well
+		require.NoError(t, helper.DeleteBlob(containerClient, record2Name))
+
+		resumed, err := NewCDCIterator(time.Millisecond*100, containerClient, position.NewCDCPosition("", time.Now()), 100, Filter{}, NewListingState(statePath), nil)
+		require.NoError(t, err)
+
+		t.Cleanup(resumed.Stop)
+
+		record, err = resumed.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, record2Name, string(record.Key.Bytes()))
+		require.Equal(t, internal.OperationDelete, record.Metadata["action"])
+	})
+
+	t.Run("Detects deleted blobs again when their records were not acknowledged", func(t *testing.T) {
+		var (
+			recordName     = fmt.Sprintf("a%s", fakerInstance.File().FilenameWithExtension())
+			recordContents = fakerInstance.Lorem().Sentence(16)
+		)
+
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+		statePath := StatePath(t.TempDir(), containerClient.URL())
+		state := NewListingState(statePath)
+
+		require.NoError(t, helper.CreateBlob(containerClient, recordName, "text/plain", recordContents))
+
+		iterator, err := NewCDCIterator(time.Millisecond*100, containerClient, position.NewCDCPosition("", time.Now().AddDate(0, 0, -1)), 100, Filter{}, state, nil)
+		require.NoError(t, err)
+
+		t.Cleanup(iterator.Stop)
+
+		record, err := iterator.Next(ctx)
+		require.NoError(t, err)
+		require.True(t, helper.AssertRecordEquals(t, record, recordName, "text/plain", recordContents))
+
+		ackRecord(t, state, record)
+
+		require.NoError(t, helper.DeleteBlob(containerClient, recordName))
+
+		record, err = iterator.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, recordName, string(record.Key.Bytes()))
+		require.Equal(t, internal.OperationDelete, record.Metadata["action"])
+
+		// The connector stops before the delete record is acknowledged
+		iterator.Stop()
+		state.Close()
+
+		state = NewListingState(statePath)
+
+		t.Cleanup(state.Close)
+
+		resumed, err := NewCDCIterator(time.Millisecond*100, containerClient, position.NewCDCPosition("", time.Now()), 100, Filter{}, state, nil)
+		require.NoError(t, err)
+
+		t.Cleanup(resumed.Stop)
+
+		record, err = resumed.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, recordName, string(record.Key.Bytes()))
+		require.Equal(t, internal.OperationDelete, record.Metadata["action"])
+	})
+}
+
+// ackRecord acknowledges the record to the state the iterator producing it stores the listings in.
+func ackRecord(t *testing.T, state *ListingState, record sdk.Record) {
+	p, err := position.NewFromRecordPosition(record.Position)
+	require.NoError(t, err)
+	require.NoError(t, state.Ack(p))
 }
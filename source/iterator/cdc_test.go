@@ -20,12 +20,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/miquido/conduit-connector-azure-storage/source/position"
 	"github.com/stretchr/testify/require"
 )
 
 func TestNewCDCIterator(t *testing.T) {
 	t.Run("Fail to create iterator with Max Results less than 1", func(t *testing.T) {
-		iterator, err := NewCDCIterator(time.Millisecond, nil, time.Now(), 0)
+		iterator, err := NewCDCIterator(time.Millisecond, nil, position.NewCDCPosition("", time.Now()), 0, Filter{}, nil, nil)
 		require.Nil(t, iterator)
 		require.EqualError(t, err, "maxResults is expected to be greater than or equal to 1, got 0")
 	})
@@ -0,0 +1,581 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/internal"
+	"github.com/miquido/conduit-connector-azure-storage/source/changefeed"
+	"github.com/miquido/conduit-connector-azure-storage/source/position"
+	"gopkg.in/tomb.v2"
+)
+
+// MetadataKeyEventType is the key of the record metadata holding the type of the change feed event.
+const MetadataKeyEventType = "eventType"
+
+var (
+	ErrChangeFeedIteratorIsStopped = errors.New("change feed iterator is stopped")
+	ErrChangeFeedReaderIsStopped   = errors.New("change feed reader is stopped")
+)
+
+// ChangeFeedCDC creates ChangeFeedIterators receiving the events read by the reader, with the blobs split into
+// multiple records by the splitter if set.
+func ChangeFeedCDC(reader *ChangeFeedReader, splitter Splitter) CDCFactory {
+	return func(client *azblob.ContainerClient, p position.Position, _ int32, filter Filter, _ *ListingState) (Iterator, error) {
+		iterator, err := NewChangeFeedIterator(reader, client, p, filter, splitter)
+		if err != nil {
+			return nil, err
+		}
+
+		return iterator, nil
+	}
+}
+
+// NewChangeFeedReader creates the reader of the change feed stored in the feedClient's container, shared by
+// the ChangeFeedIterators of all the containers read, so the feed is downloaded and parsed only once. The feed is
+// read every pollingPeriod, listing up to maxResults blobs at once, and every event is passed to the iterator of
+// the event's container, waiting until it is taken.
+func NewChangeFeedReader(feedClient *azblob.ContainerClient, pollingPeriod time.Duration, maxResults int32) (*ChangeFeedReader, error) {
+	if maxResults < 1 {
+		return nil, fmt.Errorf("maxResults is expected to be greater than or equal to 1, got %d", maxResults)
+	}
+
+	return &ChangeFeedReader{
+		feedClient:  feedClient,
+		maxResults:  maxResults,
+		subscribers: make(map[string]*ChangeFeedIterator),
+		joining:     make(map[string]*ChangeFeedIterator),
+		ticker:      time.NewTicker(pollingPeriod),
+		tomb:        tomb.Tomb{},
+	}, nil
+}
+
+type ChangeFeedReader struct {
+	feedClient *azblob.ContainerClient
+	maxResults int32
+
+	// subscribers are the iterators the events are passed to, keyed by the container name, and joining are
+	// the ones subscribed since the last poll
+	lock        sync.Mutex
+	subscribers map[string]*ChangeFeedIterator
+	joining     map[string]*ChangeFeedIterator
+
+	cursor position.ChangeFeedCursor
+	ticker *time.Ticker
+	tomb   tomb.Tomb
+}
+
+// Start starts reading the change feed in the background.
+func (r *ChangeFeedReader) Start() {
+	r.tomb.Go(r.producer)
+}
+
+// Stop stops reading the change feed.
+func (r *ChangeFeedReader) Stop() {
+	r.ticker.Stop()
+	r.tomb.Kill(ErrChangeFeedReaderIsStopped)
+	_ = r.tomb.Wait()
+}
+
+// subscribe passes the events of the iterator's container to the iterator, starting with the next poll.
+func (r *ChangeFeedReader) subscribe(iterator *ChangeFeedIterator) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.subscribers, iterator.container)
+	r.joining[iterator.container] = iterator
+}
+
+// unsubscribe stops passing the events to the iterator.
+func (r *ChangeFeedReader) unsubscribe(iterator *ChangeFeedIterator) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.subscribers[iterator.container] == iterator {
+		delete(r.subscribers, iterator.container)
+	}
+	if r.joining[iterator.container] == iterator {
+		delete(r.joining, iterator.container)
+	}
+}
+
+// join makes the iterators subscribed since the last poll receive the events, and returns all the subscribers.
+// The feed is read again from the earliest change the joining iterators have not read yet.
+func (r *ChangeFeedReader) join() map[string]*ChangeFeedIterator {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	rewind := len(r.subscribers) == 0
+
+	for container, iterator := range r.joining {
+		if rewind || compareCursors(iterator.start, r.cursor) < 0 {
+			r.cursor, rewind = iterator.start, false
+		}
+
+		r.subscribers[container] = iterator
+		delete(r.joining, container)
+	}
+
+	subscribers := make(map[string]*ChangeFeedIterator, len(r.subscribers))
+	for container, iterator := range r.subscribers {
+		subscribers[container] = iterator
+	}
+
+	return subscribers
+}
+
+// producer reads the change feed segments which became consumable since last time.
+func (r *ChangeFeedReader) producer() error {
+	for {
+		select {
+		case <-r.tomb.Dying():
+			return r.tomb.Err()
+
+		case <-r.ticker.C:
+			subscribers := r.join()
+			if len(subscribers) == 0 {
+				continue
+			}
+
+			if err := r.poll(r.tomb.Context(context.Background()), subscribers); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// poll reads the consumable segments, starting with the one of the cursor, shard by shard and chunk by chunk.
+func (r *ChangeFeedReader) poll(ctx context.Context, subscribers map[string]*ChangeFeedIterator) error {
+	metaData, err := r.download(ctx, changefeed.MetaPath)
+	if isNotFound(err) {
+		// Nothing was logged yet
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read the change feed meta: %w", err)
+	}
+
+	meta, err := changefeed.ParseMeta(metaData)
+	if err != nil {
+		return err
+	}
+
+	segmentPaths, err := r.list(ctx, changefeed.SegmentsPrefix)
+	if err != nil {
+		return fmt.Errorf("could not list the change feed segments: %w", err)
+	}
+
+	for _, segmentPath := range segmentPaths {
+		if segmentPath < r.cursor.Segment {
+			continue
+		}
+
+		segmentData, err := r.download(ctx, segmentPath)
+		if err != nil {
+			return fmt.Errorf("could not read the change feed segment %q: %w", segmentPath, err)
+		}
+
+		segment, err := changefeed.ParseSegment(segmentData)
+		if err != nil {
+			return err
+		}
+
+		// The following segments cannot be read either
+		if !segment.IsConsumable(meta) {
+			return nil
+		}
+
+		if segmentPath != r.cursor.Segment {
+			r.cursor = position.ChangeFeedCursor{Segment: segmentPath}
+		}
+
+		shards := segment.ShardPrefixes()
+
+		// Segments none of the subscribers reads changes from are skipped as a whole
+		if !needsSegment(subscribers, segmentPath, segment) {
+			r.cursor.Shard = len(shards)
+		}
+
+		for ; r.cursor.Shard < len(shards); r.cursor.Shard, r.cursor.Chunk, r.cursor.Event = r.cursor.Shard+1, "", 0 {
+			if err := r.readShard(ctx, shards[r.cursor.Shard], subscribers); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readShard passes the events of the shard's chunks, starting with the cursor's chunk, to the subscribers.
+func (r *ChangeFeedReader) readShard(ctx context.Context, shardPrefix string, subscribers map[string]*ChangeFeedIterator) error {
+	chunkPaths, err := r.list(ctx, shardPrefix)
+	if err != nil {
+		return fmt.Errorf("could not list the change feed chunks: %w", err)
+	}
+
+	for _, chunkPath := range chunkPaths {
+		if chunkPath < r.cursor.Chunk {
+			continue
+		}
+
+		if chunkPath != r.cursor.Chunk {
+			r.cursor.Chunk, r.cursor.Event = chunkPath, 0
+		}
+
+		chunkData, err := r.download(ctx, chunkPath)
+		if err != nil {
+			return fmt.Errorf("could not read the change feed chunk %q: %w", chunkPath, err)
+		}
+
+		events, err := changefeed.ReadEvents(bytes.NewReader(chunkData))
+		if err != nil {
+			return err
+		}
+
+		for r.cursor.Event < len(events) {
+			event := events[r.cursor.Event]
+			r.cursor.Event++
+
+			container, _, ok := event.Blob()
+			if !ok {
+				continue
+			}
+
+			// Events the iterator read before it subscribed, e.g. when the feed is read again for another one,
+			// are skipped
+			iterator, ok := subscribers[container]
+			if !ok || compareCursors(r.cursor, iterator.start) <= 0 {
+				continue
+			}
+
+			err := iterator.receive(ctx, changeFeedEvent{event: event, cursor: r.cursor})
+			if err != nil && !errors.Is(err, ErrChangeFeedIteratorIsStopped) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// list returns the names of the change feed blobs with given prefix, in the lexicographical order.
+func (r *ChangeFeedReader) list(ctx context.Context, prefix string) ([]string, error) {
+	pager := r.feedClient.ListBlobsFlat(&azblob.ContainerListBlobsFlatOptions{
+		MaxResults: &r.maxResults,
+		Prefix:     &prefix,
+	})
+
+	var names []string
+
+	for pager.NextPage(ctx) {
+		for _, item := range pager.PageResponse().Segment.BlobItems {
+			names = append(names, *item.Name)
+		}
+	}
+
+	return names, pager.Err()
+}
+
+func (r *ChangeFeedReader) download(ctx context.Context, name string) ([]byte, error) {
+	blobClient, err := r.feedClient.NewBlobClient(name)
+	if err != nil {
+		return nil, err
+	}
+
+	downloadResponse, err := blobClient.Download(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return readContents(downloadResponse)
+}
+
+// needsSegment indicates whether any of the subscribers reads the changes of the segment, i.e. it has not read
+// the segment yet, and the segment ends after the changes the subscriber reads from.
+func needsSegment(subscribers map[string]*ChangeFeedIterator, segmentPath string, segment changefeed.Segment) bool {
+	for _, iterator := range subscribers {
+		if segmentPath >= iterator.start.Segment && segment.End().After(iterator.from) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compareCursors returns -1, 0 or +1 when the change feed cursor a points before, at or after the cursor b.
+func compareCursors(a, b position.ChangeFeedCursor) int {
+	switch {
+	case a.Segment != b.Segment:
+		return compareStrings(a.Segment, b.Segment)
+	case a.Shard != b.Shard:
+		return compareInts(a.Shard, b.Shard)
+	case a.Chunk != b.Chunk:
+		return compareStrings(a.Chunk, b.Chunk)
+	default:
+		return compareInts(a.Event, b.Event)
+	}
+}
+
+func compareStrings(a, b string) int {
+	if a < b {
+		return -1
+	}
+
+	return 1
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// NewChangeFeedIterator creates an iterator reporting the changes of the client's container blobs logged by
+// the change feed, passed by the reader. Changes are read from the position's change feed cursor, or when there
+// is none, starting with the changes made at the position's timestamp.
+func NewChangeFeedIterator(
+	reader *ChangeFeedReader,
+	client *azblob.ContainerClient,
+	p position.Position,
+	filter Filter,
+	splitter Splitter,
+) (*ChangeFeedIterator, error) {
+	urlParts, err := azblob.NewBlobURLParts(client.URL())
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the container URL: %w", err)
+	}
+
+	iterator := ChangeFeedIterator{
+		reader:    reader,
+		client:    client,
+		container: urlParts.ContainerName,
+		filter:    filter,
+		splitter:  splitter,
+		from:      p.Timestamp,
+		events:    make(chan changeFeedEvent),
+		buffer:    make(chan sdk.Record, 1),
+		tomb:      tomb.Tomb{},
+	}
+
+	// Events of the shards are not ordered between each other, so the cursor is all that tells what was read
+	if p.ChangeFeed != nil {
+		iterator.start = *p.ChangeFeed
+		iterator.from = time.Time{}
+
+		// The event of the blob read partially is read again, from the offset following the last part read
+		if p.Offset > 0 {
+			iterator.resume = p
+		}
+	}
+
+	reader.subscribe(&iterator)
+
+	iterator.tomb.Go(iterator.producer)
+
+	return &iterator, nil
+}
+
+type ChangeFeedIterator struct {
+	reader    *ChangeFeedReader
+	client    *azblob.ContainerClient
+	container string
+	filter    Filter
+	splitter  Splitter
+	resume    position.Position
+	from      time.Time
+	start     position.ChangeFeedCursor
+	events    chan changeFeedEvent
+	buffer    chan sdk.Record
+	tomb      tomb.Tomb
+}
+
+// changeFeedEvent is the event passed by the reader, with the cursor pointing after the event.
+type changeFeedEvent struct {
+	event  changefeed.Event
+	cursor position.ChangeFeedCursor
+}
+
+func (w *ChangeFeedIterator) HasNext(_ context.Context) bool {
+	return len(w.buffer) > 0 || !w.tomb.Alive() // if tomb is dead we return true so caller will fetch error with Next
+}
+
+func (w *ChangeFeedIterator) Next(ctx context.Context) (sdk.Record, error) {
+	select {
+	case r, active := <-w.buffer:
+		if !active {
+			return sdk.Record{}, ErrChangeFeedIteratorIsStopped
+		}
+
+		return r, nil
+
+	case <-w.tomb.Dead():
+		return sdk.Record{}, w.tomb.Err()
+
+	case <-ctx.Done():
+		return sdk.Record{}, ctx.Err()
+	}
+}
+
+func (w *ChangeFeedIterator) Stop() {
+	w.reader.unsubscribe(w)
+	w.tomb.Kill(ErrChangeFeedIteratorIsStopped)
+	_ = w.tomb.Wait()
+}
+
+// receive passes the event to the producer, waiting until it is taken.
+func (w *ChangeFeedIterator) receive(ctx context.Context, event changeFeedEvent) error {
+	select {
+	case <-w.tomb.Dying():
+		return ErrChangeFeedIteratorIsStopped
+
+	case <-ctx.Done():
+		return ctx.Err()
+
+	case w.events <- event:
+		return nil
+	}
+}
+
+// producer reports the events passed by the reader, until the iterator or the reader is stopped.
+func (w *ChangeFeedIterator) producer() error {
+	defer close(w.buffer)
+
+	ctx := w.tomb.Context(context.Background())
+
+	for {
+		select {
+		case <-w.tomb.Dying():
+			return w.tomb.Err()
+
+		case <-w.reader.tomb.Dead():
+			return w.reader.tomb.Err()
+
+		case e := <-w.events:
+			if err := w.emitRecords(ctx, e.event, e.cursor, w.send); err != nil {
+				return err
+			}
+
+			// Only the event read first may be the one read partially
+			w.resume = position.Position{}
+		}
+	}
+}
+
+// emitRecords converts the event, read before the cursor, into sdk.Record, with the changed blob's contents
+// unless it was deleted, or into the records of the contents' parts when split.
+// Events of other containers, of blobs not matching the filter or of other types, and events older than
+// the changes read from, are skipped.
+func (w *ChangeFeedIterator) emitRecords(
+	ctx context.Context,
+	event changefeed.Event,
+	cursor position.ChangeFeedCursor,
+	emit func(sdk.Record) error,
+) error {
+	container, name, ok := event.Blob()
+	if !ok || container != w.container || !w.filter.Selects(name) || event.EventTime.Before(w.from) {
+		return nil
+	}
+
+	var action internal.Operation
+
+	switch event.EventType {
+	case changefeed.EventTypeBlobCreated:
+		action = internal.OperationInsert
+	case changefeed.EventTypeBlobPropertiesUpdated:
+		action = internal.OperationUpdate
+	case changefeed.EventTypeBlobDeleted:
+		action = internal.OperationDelete
+	default:
+		return nil
+	}
+
+	// Prepare position information
+	p := position.NewCDCPosition(name, event.EventTime)
+	p.ChangeFeed = &cursor
+
+	record := sdk.Record{
+		Metadata: map[string]string{
+			"action":             action,
+			MetadataKeyEventType: event.EventType,
+		},
+		Key:       sdk.RawData(name),
+		CreatedAt: event.EventTime,
+	}
+	w.filter.setDirectory(record.Metadata, name)
+
+	if action == internal.OperationDelete {
+		recordPosition, err := p.ToRecordPosition()
+		if err != nil {
+			return err
+		}
+
+		record.Position = recordPosition
+
+		return emit(record)
+	}
+
+	blobClient, err := w.client.NewBlobClient(name)
+	if err != nil {
+		return err
+	}
+
+	record.Metadata["content-type"] = event.Data.ContentType
+
+	// The parts' positions point at the event itself, so the rest of the blob is read after them
+	if w.splitter != nil {
+		cursor.Event--
+	}
+
+	err = emitBlob(ctx, blobClient, w.splitter, w.resume, record, p, emit)
+	if isNotFound(err) {
+		// The blob was deleted meanwhile, which is reported by its own event
+		return nil
+	}
+
+	return err
+}
+
+// send passes the record to the buffer, unless the iterator is being stopped.
+func (w *ChangeFeedIterator) send(record sdk.Record) error {
+	select {
+	case <-w.tomb.Dying():
+		return w.tomb.Err()
+
+	case w.buffer <- record:
+		return nil
+	}
+}
+
+// isNotFound indicates whether the blob or its container does not exist.
+func isNotFound(err error) bool {
+	var storageErr *azblob.StorageError
+
+	return errors.As(err, &storageErr) &&
+		(storageErr.ErrorCode == azblob.StorageErrorCodeBlobNotFound ||
+			storageErr.ErrorCode == azblob.StorageErrorCodeContainerNotFound)
+}
@@ -0,0 +1,157 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build integration
+
+package iterator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jaswdr/faker"
+	"github.com/miquido/conduit-connector-azure-storage/internal"
+	"github.com/miquido/conduit-connector-azure-storage/source/changefeed"
+	"github.com/miquido/conduit-connector-azure-storage/source/position"
+	helper "github.com/miquido/conduit-connector-azure-storage/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeFeedIterator(t *testing.T) {
+	fakerInstance := faker.New()
+	azureBlobServiceClient := helper.NewAzureBlobServiceClient()
+
+	var (
+		containerName = "change-feed-iterator"
+		feedName      = "change-feed-iterator-feed"
+	)
+
+	t.Run("Reads changes logged by the change feed", func(t *testing.T) {
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+		feedClient := helper.PrepareContainer(t, azureBlobServiceClient, feedName)
+
+		contents := fakerInstance.Lorem().Sentence(16)
+		require.NoError(t, helper.CreateBlob(containerClient, "b.txt", "text/plain", contents))
+
+		begin := time.Now().Add(-2 * time.Hour).Truncate(time.Hour)
+		subject := func(container, name string) string {
+			return "/blobServices/default/containers/" + container + "/blobs/" + name
+		}
+
+		require.NoError(t, helper.WriteChangeFeedSegment(feedClient, begin, []changefeed.Event{
+			{Subject: subject(containerName, "a.txt"), EventType: changefeed.EventTypeBlobCreated, EventTime: begin.Add(time.Minute)},
+			{Subject: subject("other", "b.txt"), EventType: changefeed.EventTypeBlobCreated, EventTime: begin.Add(2 * time.Minute)},
+			{Subject: subject(containerName, "b.txt"), EventType: changefeed.EventTypeBlobCreated, EventTime: begin.Add(3 * time.Minute)},
+			{Subject: subject(containerName, "a.txt"), EventType: changefeed.EventTypeBlobDeleted, EventTime: begin.Add(4 * time.Minute)},
+		}))
+
+		reader, err := NewChangeFeedReader(feedClient, time.Millisecond*100, 100)
+		require.NoError(t, err)
+
+		reader.Start()
+		t.Cleanup(reader.Stop)
+
+		iterator, err := NewChangeFeedIterator(reader, containerClient, position.NewCDCPosition("", begin), Filter{}, nil)
+		require.NoError(t, err)
+
+		t.Cleanup(iterator.Stop)
+
+		// Let the Goroutine run
+		time.Sleep(time.Millisecond * 500)
+
+		// a.txt no longer exists, so its creation is skipped
+		require.True(t, iterator.HasNext(ctx))
+		record, err := iterator.Next(ctx)
+		require.NoError(t, err)
+		require.True(t, helper.AssertRecordEquals(t, record, "b.txt", "text/plain", contents))
+		require.Equal(t, internal.OperationInsert, record.Metadata["action"])
+		require.Equal(t, changefeed.EventTypeBlobCreated, record.Metadata[MetadataKeyEventType])
+
+		// Let the Goroutine run
+		time.Sleep(time.Millisecond * 200)
+
+		require.True(t, iterator.HasNext(ctx))
+		record, err = iterator.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "a.txt", string(record.Key.Bytes()))
+		require.Equal(t, internal.OperationDelete, record.Metadata["action"])
+
+		p, err := position.NewFromRecordPosition(record.Position)
+		require.NoError(t, err)
+		require.Equal(t, 4, p.ChangeFeed.Event)
+
+		// Segments already read are not read again
+		time.Sleep(time.Millisecond * 500)
+
+		require.False(t, iterator.HasNext(ctx))
+
+		// The iterator resumes from the cursor
+		resumed, err := NewChangeFeedIterator(reader, containerClient, p, Filter{}, nil)
+		require.NoError(t, err)
+
+		t.Cleanup(resumed.Stop)
+
+		time.Sleep(time.Millisecond * 500)
+
+		require.False(t, resumed.HasNext(ctx))
+	})
+
+	t.Run("Passes the events read once to the iterators of their containers", func(t *testing.T) {
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+		otherClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName+"-other")
+		feedClient := helper.PrepareContainer(t, azureBlobServiceClient, feedName)
+
+		begin := time.Now().Add(-2 * time.Hour).Truncate(time.Hour)
+		subject := func(container, name string) string {
+			return "/blobServices/default/containers/" + container + "/blobs/" + name
+		}
+
+		require.NoError(t, helper.WriteChangeFeedSegment(feedClient, begin, []changefeed.Event{
+			{Subject: subject(containerName, "a.txt"), EventType: changefeed.EventTypeBlobDeleted, EventTime: begin.Add(time.Minute)},
+			{Subject: subject(containerName+"-other", "b.txt"), EventType: changefeed.EventTypeBlobDeleted, EventTime: begin.Add(2 * time.Minute)},
+		}))
+
+		reader, err := NewChangeFeedReader(feedClient, time.Millisecond*100, 100)
+		require.NoError(t, err)
+
+		reader.Start()
+		t.Cleanup(reader.Stop)
+
+		iterator, err := NewChangeFeedIterator(reader, containerClient, position.NewCDCPosition("", begin), Filter{}, nil)
+		require.NoError(t, err)
+
+		t.Cleanup(iterator.Stop)
+
+		other, err := NewChangeFeedIterator(reader, otherClient, position.NewCDCPosition("", begin), Filter{}, nil)
+		require.NoError(t, err)
+
+		t.Cleanup(other.Stop)
+
+		// Let the Goroutines run
+		time.Sleep(time.Millisecond * 500)
+
+		require.True(t, iterator.HasNext(ctx))
+		record, err := iterator.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "a.txt", string(record.Key.Bytes()))
+
+		require.True(t, other.HasNext(ctx))
+		record, err = other.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "b.txt", string(record.Key.Bytes()))
+	})
+}
@@ -0,0 +1,197 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package iterator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/internal"
+	"github.com/miquido/conduit-connector-azure-storage/source/changefeed"
+	"github.com/miquido/conduit-connector-azure-storage/source/position"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeFeedIterator_emitRecords(t *testing.T) {
+	from := time.Date(2022, 5, 30, 13, 0, 0, 0, time.UTC)
+
+	iterator := ChangeFeedIterator{
+		container: "container",
+		from:      from,
+	}
+
+	cursor := position.ChangeFeedCursor{
+		Segment: "idx/segments/2022/05/30/1300/meta.json",
+		Chunk:   "log/00/2022/05/30/1300/00000.avro",
+		Event:   3,
+	}
+
+	t.Run("Creates the record of the deleted blob", func(t *testing.T) {
+		var records []sdk.Record
+
+		err := iterator.emitRecords(context.Background(), changefeed.Event{
+			Subject:   "/blobServices/default/containers/container/blobs/a.txt",
+			EventType: changefeed.EventTypeBlobDeleted,
+			EventTime: from.Add(time.Minute),
+		}, cursor, collect(&records))
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+
+		record := records[0]
+
+		require.Equal(t, "a.txt", string(record.Key.Bytes()))
+		require.Nil(t, record.Payload)
+		require.Equal(t, internal.OperationDelete, record.Metadata["action"])
+		require.Equal(t, changefeed.EventTypeBlobDeleted, record.Metadata[MetadataKeyEventType])
+
+		p, err := position.NewFromRecordPosition(record.Position)
+		require.NoError(t, err)
+		require.Equal(t, position.TypeCDC, p.Type)
+		require.Equal(t, cursor, *p.ChangeFeed)
+	})
+
+	for _, tt := range []struct {
+		name  string
+		event changefeed.Event
+	}{
+		{
+			name: "Blob of another container",
+			event: changefeed.Event{
+				Subject:   "/blobServices/default/containers/other/blobs/a.txt",
+				EventType: changefeed.EventTypeBlobDeleted,
+				EventTime: from,
+			},
+		},
+		{
+			name: "Snapshot of the blob",
+			event: changefeed.Event{
+				Subject:   "/blobServices/default/containers/container/blobs/a.txt",
+				EventType: changefeed.EventTypeBlobSnapshotCreated,
+				EventTime: from,
+			},
+		},
+		{
+			name: "Change older than the changes read",
+			event: changefeed.Event{
+				Subject:   "/blobServices/default/containers/container/blobs/a.txt",
+				EventType: changefeed.EventTypeBlobDeleted,
+				EventTime: from.Add(-time.Nanosecond),
+			},
+		},
+	} {
+		t.Run("Skips the event: "+tt.name, func(t *testing.T) {
+			var records []sdk.Record
+
+			require.NoError(t, iterator.emitRecords(context.Background(), tt.event, cursor, collect(&records)))
+			require.Empty(t, records)
+		})
+	}
+}
+
+func TestChangeFeedReader_Join(t *testing.T) {
+	reader, err := NewChangeFeedReader(nil, time.Hour, 100)
+	require.NoError(t, err)
+
+	t.Cleanup(reader.ticker.Stop)
+
+	first := &ChangeFeedIterator{container: "first", start: position.ChangeFeedCursor{Segment: "idx/segments/2022/05/30/1300/meta.json", Shard: 1}}
+	reader.subscribe(first)
+
+	require.Equal(t, map[string]*ChangeFeedIterator{"first": first}, reader.join())
+	require.Equal(t, first.start, reader.cursor)
+
+	// The feed is read again for the iterator starting earlier
+	reader.cursor = position.ChangeFeedCursor{Segment: "idx/segments/2022/05/30/1500/meta.json"}
+
+	second := &ChangeFeedIterator{container: "second", start: position.ChangeFeedCursor{Segment: "idx/segments/2022/05/30/1400/meta.json"}}
+	reader.subscribe(second)
+
+	require.Equal(t, map[string]*ChangeFeedIterator{"first": first, "second": second}, reader.join())
+	require.Equal(t, second.start, reader.cursor)
+
+	// The feed is not read again for the iterator starting later
+	third := &ChangeFeedIterator{container: "third", start: position.ChangeFeedCursor{Segment: "idx/segments/2022/05/30/1600/meta.json"}}
+	reader.subscribe(third)
+
+	require.Len(t, reader.join(), 3)
+	require.Equal(t, second.start, reader.cursor)
+
+	reader.unsubscribe(first)
+	require.Len(t, reader.join(), 2)
+}
+
+func TestNeedsSegment(t *testing.T) {
+	segment := changefeed.Segment{Begin: time.Date(2022, 5, 30, 13, 0, 0, 0, time.UTC), IntervalSecs: 3600}
+	segmentPath := "idx/segments/2022/05/30/1300/meta.json"
+
+	subscribers := func(iterators ...*ChangeFeedIterator) map[string]*ChangeFeedIterator {
+		m := make(map[string]*ChangeFeedIterator)
+		for i, iterator := range iterators {
+			m[string(rune('a'+i))] = iterator
+		}
+
+		return m
+	}
+
+	require.True(t, needsSegment(subscribers(&ChangeFeedIterator{from: segment.Begin}), segmentPath, segment))
+	require.False(t, needsSegment(subscribers(&ChangeFeedIterator{from: segment.End()}), segmentPath, segment))
+	require.False(t, needsSegment(subscribers(&ChangeFeedIterator{start: position.ChangeFeedCursor{Segment: "idx/segments/2022/05/30/1400/meta.json"}}), segmentPath, segment))
+	require.True(t, needsSegment(subscribers(
+		&ChangeFeedIterator{from: segment.End()},
+		&ChangeFeedIterator{start: position.ChangeFeedCursor{Segment: segmentPath, Shard: 1}},
+	), segmentPath, segment))
+}
+
+func TestCompareCursors(t *testing.T) {
+	cursor := position.ChangeFeedCursor{
+		Segment: "idx/segments/2022/05/30/1300/meta.json",
+		Shard:   1,
+		Chunk:   "log/01/2022/05/30/1300/00000.avro",
+		Event:   3,
+	}
+
+	later := func(modify func(*position.ChangeFeedCursor)) position.ChangeFeedCursor {
+		c := cursor
+		modify(&c)
+
+		return c
+	}
+
+	require.Equal(t, 0, compareCursors(cursor, cursor))
+	require.Equal(t, -1, compareCursors(position.ChangeFeedCursor{}, cursor))
+
+	for _, c := range []position.ChangeFeedCursor{
+		later(func(c *position.ChangeFeedCursor) { c.Event = 4 }),
+		later(func(c *position.ChangeFeedCursor) { c.Chunk, c.Event = "log/01/2022/05/30/1300/00001.avro", 0 }),
+		later(func(c *position.ChangeFeedCursor) { c.Shard, c.Chunk, c.Event = 2, "", 0 }),
+		{Segment: "idx/segments/2022/05/30/1400/meta.json"},
+	} {
+		require.Equal(t, 1, compareCursors(c, cursor))
+		require.Equal(t, -1, compareCursors(cursor, c))
+	}
+}
+
+// collect returns the function emitting the records into the slice.
+func collect(records *[]sdk.Record) func(sdk.Record) error {
+	return func(record sdk.Record) error {
+		*records = append(*records, record)
+
+		return nil
+	}
+}
@@ -27,41 +27,79 @@ import (
 
 var ErrUnsupportedIterator = errors.New("unsupported iterator")
 
+// CDCFactory creates the iterator detecting the changes of the container's blobs since the position.
+// The state holds the container's listing, when deleted blobs are detected by comparing the listings.
+type CDCFactory func(
+	client *azblob.ContainerClient,
+	p position.Position,
+	maxResults int32,
+	filter Filter,
+	state *ListingState,
+) (Iterator, error)
+
+// PollingCDC creates CDCIterators polling the container every pollingPeriod.
+// With the state set, deleted blobs are detected by comparing the listings instead of relying on soft delete.
+// With the splitter set, the blobs are split into multiple records.
+func PollingCDC(pollingPeriod time.Duration, splitter Splitter) CDCFactory {
+	return func(
+		client *azblob.ContainerClient,
+		p position.Position,
+		maxResults int32,
+		filter Filter,
+		state *ListingState,
+	) (Iterator, error) {
+		iterator, err := NewCDCIterator(pollingPeriod, client, p, maxResults, filter, state, splitter)
+		if err != nil {
+			return nil, err
+		}
+
+		return iterator, nil
+	}
+}
+
 type CombinedIterator struct {
-	pollingPeriod time.Duration
-	client        *azblob.ContainerClient
-	maxResults    int32
+	newCDC     CDCFactory
+	client     *azblob.ContainerClient
+	maxResults int32
+	filter     Filter
+	splitter   Splitter
+	state      *ListingState
 
 	iterator Iterator
 }
 
 func NewCombinedIterator(
-	pollingPeriod time.Duration,
+	newCDC CDCFactory,
 	client *azblob.ContainerClient,
 	maxResults int32,
+	filter Filter,
+	splitter Splitter,
+	stateDirectory string,
 	p position.Position,
 ) (c *CombinedIterator, err error) {
 	c = &CombinedIterator{
-		pollingPeriod: pollingPeriod,
-		client:        client,
-		maxResults:    maxResults,
+		newCDC:     newCDC,
+		client:     client,
+		maxResults: maxResults,
+		filter:     filter,
+		splitter:   splitter,
+	}
+
+	// The snapshot stores the listing the polling compares the first listing with
+	if stateDirectory != "" {
+		c.state = NewListingState(StatePath(stateDirectory, client.URL()))
 	}
 
 	switch p.Type {
 	case position.TypeSnapshot:
-		if len(p.Key) != 0 {
-			fmt.Printf("Warning: got position: %+v, snapshot will be restarted from the beginning of the bucket\n", p)
-		}
-
-		p = position.NewDefaultSnapshotPosition() // always start snapshot from the beginning, so position is nil
-
-		c.iterator, err = NewSnapshotIterator(client, p, maxResults)
+		// The snapshot resumes after the last blob read, if any
+		c.iterator, err = NewSnapshotIterator(client, p, maxResults, filter, c.state, c.splitter)
 		if err != nil {
 			return nil, fmt.Errorf("could not create the snapshot iterator: %w", err)
 		}
 
 	case position.TypeCDC:
-		c.iterator, err = NewCDCIterator(pollingPeriod, client, p.Timestamp, maxResults)
+		c.iterator, err = newCDC(client, p, maxResults, filter, c.state)
 		if err != nil {
 			return nil, fmt.Errorf("could not create the CDC iterator: %w", err)
 		}
@@ -86,11 +124,11 @@ func (c *CombinedIterator) HasNext(ctx context.Context) bool {
 
 		return true
 
-	case *CDCIterator:
-		return c.iterator.HasNext(ctx)
+	case nil:
+		return false
 
 	default:
-		return false
+		return c.iterator.HasNext(ctx)
 	}
 }
 
@@ -112,11 +150,11 @@ func (c *CombinedIterator) Next(ctx context.Context) (sdk.Record, error) {
 
 		return r, nil
 
-	case *CDCIterator:
-		return c.iterator.Next(ctx)
+	case nil:
+		return sdk.Record{}, ErrUnsupportedIterator
 
 	default:
-		return sdk.Record{}, ErrUnsupportedIterator
+		return c.iterator.Next(ctx)
 	}
 }
 
@@ -125,6 +163,19 @@ func (c *CombinedIterator) Stop() {
 		c.iterator.Stop()
 		c.iterator = nil
 	}
+
+	if c.state != nil {
+		c.state.Close()
+	}
+}
+
+// Ack stores the container's listing once the last record emitted while listing is acknowledged.
+func (c *CombinedIterator) Ack(_ context.Context, p position.Position) error {
+	if c.state == nil {
+		return nil
+	}
+
+	return c.state.Ack(p)
 }
 
 // switchToCDCIterator switches the current iterator form Snapshot to CDC.
@@ -141,18 +192,18 @@ func (c *CombinedIterator) switchToCDCIterator() (err error) {
 
 		i.Stop()
 
-		c.iterator, err = NewCDCIterator(c.pollingPeriod, c.client, timestamp.Add(time.Nanosecond), c.maxResults)
+		c.iterator, err = c.newCDC(c.client, position.NewCDCPosition("", timestamp.Add(time.Nanosecond)), c.maxResults, c.filter, c.state)
 		if err != nil {
 			return fmt.Errorf("could not create cdc iterator: %w", err)
 		}
 
 		return nil
 
-	case *CDCIterator:
-		return nil
+	case nil:
+		return ErrUnsupportedIterator
 
 	default:
-		return ErrUnsupportedIterator
+		return nil
 	}
 }
 
@@ -164,6 +215,7 @@ func convertToCDCPosition(p sdk.Position) (sdk.Position, error) {
 	}
 
 	cdcPos.Type = position.TypeCDC
+	cdcPos.Marker = ""
 
 	return cdcPos.ToRecordPosition()
 }
@@ -39,7 +39,7 @@ func TestCombinedIterator(t *testing.T) {
 	t.Run("Empty container", func(t *testing.T) {
 		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
 
-		iterator, err := NewCombinedIterator(time.Millisecond*500, containerClient, fakerInstance.Int32Between(1, 100), position.NewDefaultSnapshotPosition())
+		iterator, err := NewCombinedIterator(PollingCDC(time.Millisecond*500, nil), containerClient, fakerInstance.Int32Between(1, 100), Filter{}, nil, "", position.NewDefaultSnapshotPosition())
 		require.NoError(t, err)
 
 		// Let the Goroutine finish
@@ -70,7 +70,7 @@ func TestCombinedIterator(t *testing.T) {
 		require.NoError(t, helper.CreateBlob(containerClient, record1Name, "text/plain", record1Contents))
 		require.NoError(t, helper.CreateBlob(containerClient, record2Name, "text/plain", record2Contents))
 
-		iterator, err := NewCombinedIterator(time.Millisecond*100, containerClient, 100, snapshotPosition)
+		iterator, err := NewCombinedIterator(PollingCDC(time.Millisecond*100, nil), containerClient, 100, Filter{}, nil, "", snapshotPosition)
 		require.NoError(t, err)
 
 		// Let the Goroutine run
@@ -111,4 +111,59 @@ func TestCombinedIterator(t *testing.T) {
 		require.False(t, iterator.HasNext(ctx))
 		require.IsType(t, &CDCIterator{}, iterator.iterator)
 	})
+
+	t.Run("Detects blobs deleted before the first poll by comparing with the snapshot listing", func(t *testing.T) {
+		var (
+			record1Name     = fmt.Sprintf("a%s", fakerInstance.File().FilenameWithExtension())
+			record1Contents = fakerInstance.Lorem().Sentence(16)
+			record2Name     = fmt.Sprintf("b%s", fakerInstance.File().FilenameWithExtension())
+			record2Contents = fakerInstance.Lorem().Sentence(16)
+		)
+
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+		stateDirectory := t.TempDir()
+
+		require.NoError(t, helper.CreateBlob(containerClient, record1Name, "text/plain", record1Contents))
+		require.NoError(t, helper.CreateBlob(containerClient, record2Name, "text/plain", record2Contents))
+
+		iterator, err := NewCombinedIterator(PollingCDC(time.Millisecond*100, nil), containerClient, 100, Filter{}, nil, stateDirectory, position.NewDefaultSnapshotPosition())
+		require.NoError(t, err)
+
+		t.Cleanup(iterator.Stop)
+
+		for _, expected := range [][2]string{{record1Name, record1Contents}, {record2Name, record2Contents}} {
+			require.True(t, iterator.HasNext(ctx))
+
+			record, err := iterator.Next(ctx)
+			require.NoError(t, err)
+			require.True(t, helper.AssertRecordEquals(t, record, expected[0], "text/plain", expected[1]))
+
+			p, err := position.NewFromRecordPosition(record.Position)
+			require.NoError(t, err)
+			require.NoError(t, iterator.Ack(ctx, p))
+		}
+
+		// Let the Goroutine finish, storing the listing once its last record is acknowledged
+		time.Sleep(time.Millisecond * 500)
+
+		require.FileExists(t, StatePath(stateDirectory, containerClient.URL()))
+
+		require.NoError(t, helper.DeleteBlob(containerClient, record1Name))
+
+		// Iterators were swapped
+		require.False(t, iterator.HasNext(ctx))
+		require.IsType(t, &CDCIterator{}, iterator.iterator)
+
+		// Let the Pooling Period pass and iterator to collect blobs
+		time.Sleep(time.Millisecond * 500)
+
+		require.True(t, iterator.HasNext(ctx))
+
+		record, err := iterator.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, record1Name, string(record.Key.Bytes()))
+		require.Equal(t, internal.OperationDelete, record.Metadata["action"])
+
+		require.False(t, iterator.HasNext(ctx))
+	})
 }
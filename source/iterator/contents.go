@@ -0,0 +1,130 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/miquido/conduit-connector-azure-storage/internal/compression"
+)
+
+// readContents reads the whole downloaded blob. Blobs written with the gzip, zstd or snappy Content-Encoding,
+// e.g. by the destination's compression, are decompressed, so the original bytes are returned.
+func readContents(object azblob.BlobDownloadResponse) ([]byte, error) {
+	reader, err := openContents(object)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	contents, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the %q encoded blob: %w", contentEncoding(object), err)
+	}
+
+	return contents, nil
+}
+
+// openContents returns the reader streaming the downloaded blob's contents, decompressed like by readContents.
+func openContents(object azblob.BlobDownloadResponse) (io.ReadCloser, error) {
+	body := object.Body(&azblob.RetryReaderOptions{
+		MaxRetryRequests: 0,
+	})
+
+	reader, err := compression.NewReader(body, contentEncoding(object))
+	if err != nil {
+		_ = body.Close()
+
+		return nil, fmt.Errorf("could not decompress the %q encoded blob: %w", contentEncoding(object), err)
+	}
+
+	return contentsReader{Reader: reader, closers: []io.Closer{reader, body}}, nil
+}
+
+func contentEncoding(object azblob.BlobDownloadResponse) string {
+	if object.ContentEncoding == nil {
+		return ""
+	}
+
+	return *object.ContentEncoding
+}
+
+// contentsReader reads the decompressed contents, closing both the decompressor and the body once closed.
+type contentsReader struct {
+	io.Reader
+
+	closers []io.Closer
+}
+
+func (r contentsReader) Close() error {
+	var err error
+
+	for _, closer := range r.closers {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// rangeReader reads the blob's contents with ranged Get Blob requests. The requests are conditioned on the ETag,
+// when known, so all the ranges are read from the same version of the blob.
+type rangeReader struct {
+	ctx    context.Context
+	client *azblob.BlobClient
+	etag   string
+}
+
+func (r rangeReader) ReadAt(p []byte, offset int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	options := azblob.BlobDownloadOptions{
+		Offset: to.Ptr(offset),
+		Count:  to.Ptr(int64(len(p))),
+	}
+
+	if r.etag != "" {
+		options.BlobAccessConditions = &azblob.BlobAccessConditions{
+			ModifiedAccessConditions: &azblob.ModifiedAccessConditions{IfMatch: to.Ptr(r.etag)},
+		}
+	}
+
+	object, err := r.client.Download(r.ctx, &options)
+	if err != nil {
+		return 0, fmt.Errorf("could not read the blob range at offset %d: %w", offset, err)
+	}
+
+	body := object.Body(&azblob.RetryReaderOptions{
+		MaxRetryRequests: 0,
+	})
+	defer body.Close()
+
+	// The range is shorter than requested at the end of the blob
+	n, err := io.ReadFull(body, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return n, io.EOF
+	}
+
+	return n, err
+}
@@ -0,0 +1,383 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// CSV header modes, i.e. whether the first row of CSV files is the header.
+const (
+	CSVHeaderAuto    = "auto"
+	CSVHeaderPresent = "present"
+	CSVHeaderAbsent  = "absent"
+)
+
+// Default characters separating and enclosing values of CSV files.
+const (
+	DefaultCSVDelimiter = ','
+	DefaultCSVQuote     = '"'
+)
+
+// CSVSplitter splits the contents of CSV files, as described in RFC 4180, into rows read as structured data.
+// Values are keyed with the names of the header's columns, or with `column1`, `column2` and so on when there
+// is no header. With InferTypes set, unquoted values are converted into numbers and booleans when possible,
+// and empty ones into nulls.
+// Records are keyed with the value of the KeyColumn, or when it is empty, with the blob's name followed by `#` and
+// the row's number, e.g. `data.csv#1`. Offsets are the numbers of bytes preceding the rows.
+type CSVSplitter struct {
+	Header     string
+	Delimiter  rune
+	Quote      rune
+	InferTypes bool
+	KeyColumn  string
+}
+
+func (s CSVSplitter) Split(_ context.Context, name string, r io.Reader, offset int64, emit func(Part) error) error {
+	reader := csvReader{
+		reader:    bufio.NewReader(r),
+		delimiter: s.Delimiter,
+		quote:     s.Quote,
+	}
+	if reader.delimiter == 0 {
+		reader.delimiter = DefaultCSVDelimiter
+	}
+	if reader.quote == 0 {
+		reader.quote = DefaultCSVQuote
+	}
+
+	var (
+		columns  []string
+		keyIndex = -1
+	)
+
+	// The rows are read from the beginning, so that the header and the numbers of the rows are known
+	for number := 0; ; {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if columns == nil {
+			isHeader := s.isHeader(row)
+
+			if columns, err = csvColumns(row, isHeader); err != nil {
+				return err
+			}
+
+			if keyIndex, err = csvKeyIndex(columns, s.KeyColumn); err != nil {
+				return err
+			}
+
+			if isHeader {
+				continue
+			}
+		}
+
+		number++
+
+		if len(row.fields) != len(columns) {
+			return fmt.Errorf("row %d has %d values, expected %d", number, len(row.fields), len(columns))
+		}
+
+		// The rows read before are not emitted again
+		if row.offset < offset {
+			continue
+		}
+
+		payload := make(sdk.StructuredData, len(columns))
+		for i, field := range row.fields {
+			payload[columns[i]] = s.value(field)
+		}
+
+		key := fmt.Sprintf("%s#%d", name, number)
+		if keyIndex >= 0 {
+			key = row.fields[keyIndex].value
+		}
+
+		if err := emit(Part{Payload: payload, Key: key, Offset: row.offset, Next: row.next}); err != nil {
+			return err
+		}
+	}
+}
+
+// isHeader tells whether the first row is the header. When detected, it is the header unless one of its values is
+// empty, duplicated, a number or a boolean.
+func (s CSVSplitter) isHeader(row csvRow) bool {
+	switch s.Header {
+	case CSVHeaderPresent:
+		return true
+
+	case CSVHeaderAbsent:
+		return false
+
+	default:
+		seen := make(map[string]bool, len(row.fields))
+
+		for _, field := range row.fields {
+			if _, ok := inferCSVValue(field.value).(string); !ok || seen[field.value] {
+				return false
+			}
+
+			seen[field.value] = true
+		}
+
+		return true
+	}
+}
+
+// value returns the field's value, converted from text when types are inferred and the value is not quoted.
+func (s CSVSplitter) value(field csvField) interface{} {
+	if !s.InferTypes || field.quoted {
+		return field.value
+	}
+
+	return inferCSVValue(field.value)
+}
+
+// csvColumns returns the names of the columns, read from the header or numbered from 1.
+func csvColumns(row csvRow, isHeader bool) ([]string, error) {
+	columns := make([]string, len(row.fields))
+	seen := make(map[string]bool, len(row.fields))
+
+	for i, field := range row.fields {
+		if !isHeader {
+			columns[i] = fmt.Sprintf("column%d", i+1)
+
+			continue
+		}
+
+		if field.value == "" {
+			return nil, fmt.Errorf("header column %d has an empty name", i+1)
+		}
+		if seen[field.value] {
+			return nil, fmt.Errorf("header column %q is duplicated", field.value)
+		}
+
+		seen[field.value] = true
+		columns[i] = field.value
+	}
+
+	return columns, nil
+}
+
+// csvKeyIndex returns the index of the key column, or -1 when there is none.
+func csvKeyIndex(columns []string, keyColumn string) (int, error) {
+	if keyColumn == "" {
+		return -1, nil
+	}
+
+	for i, column := range columns {
+		if column == keyColumn {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("key column %q is not one of the columns %q", keyColumn, columns)
+}
+
+// inferCSVValue converts the text into an integer, a float, or a boolean, when possible.
+// Empty text becomes null, numbers with leading zeros, e.g. postal codes, are kept as text.
+func inferCSVValue(text string) interface{} {
+	if text == "" {
+		return nil
+	}
+
+	if strings.EqualFold(text, "true") {
+		return true
+	}
+	if strings.EqualFold(text, "false") {
+		return false
+	}
+
+	if !isCSVNumber(text) {
+		return text
+	}
+
+	if integer, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return integer
+	}
+
+	if float, err := strconv.ParseFloat(text, 64); err == nil {
+		return float
+	}
+
+	return text
+}
+
+// isCSVNumber tells whether the text looks like a decimal number without leading zeros, excluding the special
+// values like `NaN` or `Inf` accepted by strconv.
+func isCSVNumber(text string) bool {
+	digits := strings.TrimLeft(text, "+-")
+
+	if len(digits) > 1 && digits[0] == '0' && digits[1] >= '0' && digits[1] <= '9' {
+		return false
+	}
+
+	hasDigit := false
+
+	for _, c := range digits {
+		switch {
+		case c >= '0' && c <= '9':
+			hasDigit = true
+		case c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-':
+		default:
+			return false
+		}
+	}
+
+	return hasDigit
+}
+
+type csvField struct {
+	value  string
+	quoted bool
+}
+
+// csvRow holds the row's values, along with the offsets the row and the following one begin at.
+type csvRow struct {
+	fields []csvField
+	offset int64
+	next   int64
+}
+
+// csvReader reads the rows of CSV contents, keeping track of the number of bytes read.
+type csvReader struct {
+	reader    *bufio.Reader
+	delimiter rune
+	quote     rune
+	offset    int64
+}
+
+// Read returns the next row, skipping the empty lines, or io.EOF when there are no more rows.
+func (r *csvReader) Read() (csvRow, error) {
+	for {
+		row, err := r.readRow()
+		if err != nil {
+			return csvRow{}, err
+		}
+
+		if len(row.fields) > 1 || row.fields[0].value != "" || row.fields[0].quoted {
+			return row, nil
+		}
+	}
+}
+
+func (r *csvReader) readRow() (csvRow, error) {
+	var (
+		row      = csvRow{offset: r.offset}
+		value    strings.Builder
+		quoted   bool
+		inQuotes bool
+	)
+
+	endField := func() {
+		row.fields = append(row.fields, csvField{value: value.String(), quoted: quoted})
+		value.Reset()
+		quoted = false
+	}
+
+	for read := false; ; {
+		c, err := r.readRune()
+		if errors.Is(err, io.EOF) {
+			if inQuotes {
+				return csvRow{}, fmt.Errorf("row at offset %d has an unterminated quoted value", row.offset)
+			}
+
+			// The last row does not need to be terminated
+			if !read {
+				return csvRow{}, io.EOF
+			}
+
+			endField()
+			row.next = r.offset
+
+			return row, nil
+		}
+		if err != nil {
+			return csvRow{}, err
+		}
+
+		// The byte order mark is not a part of the first value
+		if c == '\uFEFF' && r.offset == int64(len(string(c))) {
+			continue
+		}
+
+		read = true
+
+		switch {
+		case inQuotes:
+			if c != r.quote {
+				value.WriteRune(c)
+			} else if r.skip(r.quote) {
+				// Doubled quotes stand for the quote itself
+				value.WriteRune(r.quote)
+			} else {
+				inQuotes = false
+			}
+
+		case c == r.quote && value.Len() == 0 && !quoted:
+			inQuotes, quoted = true, true
+
+		case c == r.delimiter:
+			endField()
+
+		case c == '\n' || (c == '\r' && r.skip('\n')):
+			endField()
+			row.next = r.offset
+
+			return row, nil
+
+		default:
+			value.WriteRune(c)
+		}
+	}
+}
+
+func (r *csvReader) readRune() (rune, error) {
+	c, size, err := r.reader.ReadRune()
+	r.offset += int64(size)
+
+	return c, err
+}
+
+// skip reads the following character when it is the expected one.
+func (r *csvReader) skip(expected rune) bool {
+	c, size, err := r.reader.ReadRune()
+	if err != nil {
+		return false
+	}
+
+	if c != expected {
+		_ = r.reader.UnreadRune()
+
+		return false
+	}
+
+	r.offset += int64(size)
+
+	return true
+}
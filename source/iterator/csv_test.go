@@ -0,0 +1,168 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package iterator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVSplitter_Split(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		splitter CSVSplitter
+		contents string
+		offset   int64
+		expected []Part
+	}{
+		{
+			name:     "Detects the header and infers the types",
+			splitter: CSVSplitter{InferTypes: true},
+			contents: "id,name,price,active,zip\r\n1,\"Smith, John\",9.5,TRUE,02134\r\n\r\n2,\"\"\"Q\"\"\",,false,\"7\"",
+			expected: []Part{
+				{
+					Payload: sdk.StructuredData{"id": int64(1), "name": "Smith, John", "price": 9.5, "active": true, "zip": "02134"},
+					Key:     "data.csv#1",
+					Offset:  26,
+					Next:    58,
+				},
+				{
+					Payload: sdk.StructuredData{"id": int64(2), "name": `"Q"`, "price": nil, "active": false, "zip": "7"},
+					Key:     "data.csv#2",
+					Offset:  60,
+					Next:    80,
+				},
+			},
+		},
+		{
+			name:     "Detects there is no header",
+			splitter: CSVSplitter{Header: CSVHeaderAuto, InferTypes: true},
+			contents: "1,a\n2,b\n",
+			expected: []Part{
+				{Payload: sdk.StructuredData{"column1": int64(1), "column2": "a"}, Key: "data.csv#1", Offset: 0, Next: 4},
+				{Payload: sdk.StructuredData{"column1": int64(2), "column2": "b"}, Key: "data.csv#2", Offset: 4, Next: 8},
+			},
+		},
+		{
+			name:     "Reads the first row as values",
+			splitter: CSVSplitter{Header: CSVHeaderAbsent},
+			contents: "id,name\n1,a\n",
+			expected: []Part{
+				{Payload: sdk.StructuredData{"column1": "id", "column2": "name"}, Key: "data.csv#1", Offset: 0, Next: 8},
+				{Payload: sdk.StructuredData{"column1": "1", "column2": "a"}, Key: "data.csv#2", Offset: 8, Next: 12},
+			},
+		},
+		{
+			name:     "Reads the first row as the header",
+			splitter: CSVSplitter{Header: CSVHeaderPresent, InferTypes: true},
+			contents: "2021,2022\n1,2\n",
+			expected: []Part{
+				{Payload: sdk.StructuredData{"2021": int64(1), "2022": int64(2)}, Key: "data.csv#1", Offset: 10, Next: 14},
+			},
+		},
+		{
+			name:     "Uses the configured characters and the key column",
+			splitter: CSVSplitter{Delimiter: ';', Quote: '\'', KeyColumn: "id"},
+			contents: "\ufeffid;note\na1;'x;\ny'\n",
+			expected: []Part{
+				{Payload: sdk.StructuredData{"id": "a1", "note": "x;\ny"}, Key: "a1", Offset: 11, Next: 21},
+			},
+		},
+		{
+			name:     "Resumes after the row read",
+			splitter: CSVSplitter{InferTypes: true},
+			contents: "id\n1\n2\n",
+			offset:   5,
+			expected: []Part{
+				{Payload: sdk.StructuredData{"id": int64(2)}, Key: "data.csv#2", Offset: 5, Next: 7},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var parts []Part
+
+			err := tt.splitter.Split(context.Background(), "data.csv", strings.NewReader(tt.contents), tt.offset, func(part Part) error {
+				parts = append(parts, part)
+
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, parts)
+		})
+	}
+
+	for _, tt := range []struct {
+		name     string
+		splitter CSVSplitter
+		contents string
+		error    string
+	}{
+		{
+			name:     "Row has too many values",
+			contents: "id,name\n1,a,b\n",
+			error:    "row 1 has 3 values, expected 2",
+		},
+		{
+			name:     "Quoted value is not terminated",
+			contents: "id,name\n1,\"a\n",
+			error:    "row at offset 8 has an unterminated quoted value",
+		},
+		{
+			name:     "Header has duplicated columns",
+			splitter: CSVSplitter{Header: CSVHeaderPresent},
+			contents: "id,id\n1,2\n",
+			error:    `header column "id" is duplicated`,
+		},
+		{
+			name:     "Key column does not exist",
+			splitter: CSVSplitter{KeyColumn: "uuid"},
+			contents: "id,name\n1,a\n",
+			error:    `key column "uuid" is not one of the columns ["id" "name"]`,
+		},
+	} {
+		t.Run("Fails when "+tt.name, func(t *testing.T) {
+			err := tt.splitter.Split(context.Background(), "data.csv", strings.NewReader(tt.contents), 0, func(Part) error {
+				return nil
+			})
+			require.EqualError(t, err, tt.error)
+		})
+	}
+}
+
+func TestInferCSVValue(t *testing.T) {
+	for text, expected := range map[string]interface{}{
+		"":       nil,
+		"true":   true,
+		"False":  false,
+		"42":     int64(42),
+		"-42":    int64(-42),
+		"0":      int64(0),
+		"0.5":    0.5,
+		"1e3":    1000.0,
+		"007":    "007",
+		"NaN":    "NaN",
+		"Inf":    "Inf",
+		"1-2-3":  "1-2-3",
+		"london": "london",
+	} {
+		require.Equal(t, expected, inferCSVValue(text), text)
+	}
+}
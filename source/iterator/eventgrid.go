@@ -0,0 +1,295 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/internal"
+	"github.com/miquido/conduit-connector-azure-storage/source/eventgrid"
+	"github.com/miquido/conduit-connector-azure-storage/source/position"
+	"gopkg.in/tomb.v2"
+)
+
+var ErrEventGridIteratorIsStopped = errors.New("event grid iterator is stopped")
+
+// EventGridCDC creates EventGridIterators receiving the events passed by the publisher, with the blobs split into
+// multiple records by the splitter if set.
+func EventGridCDC(publisher eventgrid.Publisher, splitter Splitter) CDCFactory {
+	return func(client *azblob.ContainerClient, p position.Position, maxResults int32, filter Filter, _ *ListingState) (Iterator, error) {
+		iterator, err := NewEventGridIterator(publisher, client, p, maxResults, filter, splitter)
+		if err != nil {
+			return nil, err
+		}
+
+		return iterator, nil
+	}
+}
+
+// NewEventGridIterator creates an iterator reporting the changes of the client's container blobs passed by
+// the publisher, i.e. pushed by Event Grid or routed to a queue.
+// Changes made since the position's timestamp, while no events were received, are read first by listing
+// the container once.
+func NewEventGridIterator(
+	publisher eventgrid.Publisher,
+	client *azblob.ContainerClient,
+	p position.Position,
+	maxResults int32,
+	filter Filter,
+	splitter Splitter,
+) (*EventGridIterator, error) {
+	if maxResults < 1 {
+		return nil, fmt.Errorf("maxResults is expected to be greater than or equal to 1, got %d", maxResults)
+	}
+
+	urlParts, err := azblob.NewBlobURLParts(client.URL())
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the container URL: %w", err)
+	}
+
+	iterator := EventGridIterator{
+		publisher: publisher,
+		client:    client,
+		container: urlParts.ContainerName,
+		filter:    filter,
+		splitter:  splitter,
+		reconciler: &CDCIterator{
+			client:       client,
+			lastModified: p.Timestamp,
+			maxResults:   maxResults,
+			filter:       filter,
+			splitter:     splitter,
+			resume:       p,
+		},
+		events: make(chan eventgrid.Event),
+		buffer: make(chan sdk.Record, 1),
+		tomb:   tomb.Tomb{},
+	}
+
+	// Events are received from now on, so no change is missed while the container is listed
+	publisher.Subscribe(iterator.container, &iterator)
+
+	iterator.tomb.Go(iterator.producer)
+
+	return &iterator, nil
+}
+
+type EventGridIterator struct {
+	publisher  eventgrid.Publisher
+	client     *azblob.ContainerClient
+	container  string
+	filter     Filter
+	splitter   Splitter
+	reconciler *CDCIterator
+	events     chan eventgrid.Event
+	buffer     chan sdk.Record
+	tomb       tomb.Tomb
+}
+
+func (w *EventGridIterator) HasNext(_ context.Context) bool {
+	return len(w.buffer) > 0 || !w.tomb.Alive() // if tomb is dead we return true so caller will fetch error with Next
+}
+
+func (w *EventGridIterator) Next(ctx context.Context) (sdk.Record, error) {
+	select {
+	case r, active := <-w.buffer:
+		if !active {
+			return sdk.Record{}, ErrEventGridIteratorIsStopped
+		}
+
+		return r, nil
+
+	case <-w.tomb.Dead():
+		return sdk.Record{}, w.tomb.Err()
+
+	case <-ctx.Done():
+		return sdk.Record{}, ctx.Err()
+	}
+}
+
+func (w *EventGridIterator) Stop() {
+	w.publisher.Unsubscribe(w.container, w)
+	w.tomb.Kill(ErrEventGridIteratorIsStopped)
+	_ = w.tomb.Wait()
+}
+
+// Receive passes the event to the producer, waiting until it is taken.
+func (w *EventGridIterator) Receive(ctx context.Context, event eventgrid.Event) error {
+	select {
+	case <-w.tomb.Dying():
+		return ErrEventGridIteratorIsStopped
+
+	case <-ctx.Done():
+		return ctx.Err()
+
+	case w.events <- event:
+		return nil
+	}
+}
+
+// producer lists the changes made since the iterator's start time, and then reports the received events.
+func (w *EventGridIterator) producer() error {
+	defer close(w.buffer)
+
+	ctx := w.tomb.Context(context.Background())
+
+	if err := w.reconciler.poll(ctx, w.send); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-w.tomb.Dying():
+			return w.tomb.Err()
+
+		case event := <-w.events:
+			// Each record is sent once the following one is created, so the last one is known
+			var (
+				last    sdk.Record
+				pending bool
+			)
+
+			err := w.emitRecords(ctx, event, func(record sdk.Record) error {
+				if pending {
+					if err := w.send(last); err != nil {
+						return err
+					}
+				}
+
+				last, pending = record, true
+
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			if !pending {
+				// Events producing no record are done with right away
+				if err := w.publisher.Ack(ctx, event.Receipt); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			// The event is done with once its last record is acknowledged
+			if event.Receipt != "" {
+				if last.Position, err = withReceipt(last.Position, event.Receipt); err != nil {
+					return err
+				}
+			}
+
+			if err := w.send(last); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// send passes the record to the buffer, unless the iterator is being stopped.
+func (w *EventGridIterator) send(record sdk.Record) error {
+	select {
+	case <-w.tomb.Dying():
+		return w.tomb.Err()
+
+	case w.buffer <- record:
+		return nil
+	}
+}
+
+// emitRecords converts the event into sdk.Record, with the changed blob's contents unless it was deleted, or into
+// the records of the contents' parts when split.
+// Events of blobs not matching the filter, of other types, or with invalid data are skipped.
+func (w *EventGridIterator) emitRecords(ctx context.Context, event eventgrid.Event, emit func(sdk.Record) error) error {
+	_, name, ok := event.Blob()
+	if !ok || !w.filter.Selects(name) {
+		return nil
+	}
+
+	var action internal.Operation
+
+	switch event.EventType {
+	case eventgrid.EventTypeBlobCreated:
+		action = internal.OperationInsert
+	case eventgrid.EventTypeBlobDeleted:
+		action = internal.OperationDelete
+	default:
+		return nil
+	}
+
+	data, err := event.BlobData()
+	if err != nil {
+		// Events which cannot be read would stop the iterator again with every redelivery otherwise
+		sdk.Logger(ctx).Warn().Err(err).Str("event", event.ID).Str("blob", name).Msg("skipping the invalid event")
+
+		return nil
+	}
+
+	// Prepare position information
+	p := position.NewCDCPosition(name, event.EventTime)
+
+	record := sdk.Record{
+		Metadata: map[string]string{
+			"action":             action,
+			MetadataKeyEventType: event.EventType,
+		},
+		Key:       sdk.RawData(name),
+		CreatedAt: event.EventTime,
+	}
+	w.filter.setDirectory(record.Metadata, name)
+
+	if action == internal.OperationDelete {
+		recordPosition, err := p.ToRecordPosition()
+		if err != nil {
+			return err
+		}
+
+		record.Position = recordPosition
+
+		return emit(record)
+	}
+
+	blobClient, err := w.client.NewBlobClient(name)
+	if err != nil {
+		return err
+	}
+
+	record.Metadata["content-type"] = data.ContentType
+
+	err = emitBlob(ctx, blobClient, w.splitter, position.Position{}, record, p, emit)
+	if isNotFound(err) {
+		// The blob was deleted meanwhile, which is reported by its own event
+		return nil
+	}
+
+	return err
+}
+
+// withReceipt returns the record position with the receipt of the event it was read from.
+func withReceipt(recordPosition sdk.Position, receipt string) (sdk.Position, error) {
+	p, err := position.NewFromRecordPosition(recordPosition)
+	if err != nil {
+		return sdk.Position{}, err
+	}
+
+	p.Receipt = receipt
+
+	return p.ToRecordPosition()
+}
@@ -0,0 +1,135 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build integration
+
+package iterator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaswdr/faker"
+	"github.com/miquido/conduit-connector-azure-storage/internal"
+	"github.com/miquido/conduit-connector-azure-storage/source/eventgrid"
+	"github.com/miquido/conduit-connector-azure-storage/source/position"
+	helper "github.com/miquido/conduit-connector-azure-storage/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventGridIterator(t *testing.T) {
+	fakerInstance := faker.New()
+	azureBlobServiceClient := helper.NewAzureBlobServiceClient()
+
+	var containerName = "event-grid-iterator"
+
+	pushData := func(t *testing.T, url, eventType, name, data string) {
+		body := fmt.Sprintf(`[{
+			"id": %q,
+			"subject": "/blobServices/default/containers/%s/blobs/%s",
+			"eventType": %q,
+			"eventTime": %q,
+			"data": %s
+		}]`, fakerInstance.UUID().V4(), containerName, name, eventType, time.Now().UTC().Format(time.RFC3339Nano), data)
+
+		response, err := http.Post(url, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		require.NoError(t, response.Body.Close())
+		require.Equal(t, http.StatusOK, response.StatusCode)
+	}
+
+	push := func(t *testing.T, url, eventType, name string) {
+		pushData(t, url, eventType, name, `{"contentType": "text/plain"}`)
+	}
+
+	t.Run("Reconciles the changes and reads the pushed events", func(t *testing.T) {
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+
+		server := eventgrid.NewServer("", "secret")
+		httpServer := httptest.NewServer(server)
+
+		t.Cleanup(httpServer.Close)
+
+		contents := fakerInstance.Lorem().Sentence(16)
+		require.NoError(t, helper.CreateBlob(containerClient, "a.txt", "text/plain", contents))
+
+		iterator, err := NewEventGridIterator(server, containerClient, position.NewCDCPosition("", time.Now().AddDate(0, 0, -1)), 100, Filter{}, nil)
+		require.NoError(t, err)
+
+		t.Cleanup(iterator.Stop)
+
+		// Let the Goroutine list the container
+		time.Sleep(time.Millisecond * 500)
+
+		// The blob created before the iterator started
+		require.True(t, iterator.HasNext(ctx))
+		record, err := iterator.Next(ctx)
+		require.NoError(t, err)
+		require.True(t, helper.AssertRecordEquals(t, record, "a.txt", "text/plain", contents))
+
+		// The blobs changed afterwards
+		require.NoError(t, helper.CreateBlob(containerClient, "b.txt", "text/plain", contents))
+		push(t, httpServer.URL+"/?secret=secret", eventgrid.EventTypeBlobCreated, "b.txt")
+
+		record, err = iterator.Next(ctx)
+		require.NoError(t, err)
+		require.True(t, helper.AssertRecordEquals(t, record, "b.txt", "text/plain", contents))
+		require.Equal(t, internal.OperationInsert, record.Metadata["action"])
+		require.Equal(t, eventgrid.EventTypeBlobCreated, record.Metadata[MetadataKeyEventType])
+
+		push(t, httpServer.URL+"/?secret=secret", eventgrid.EventTypeBlobDeleted, "a.txt")
+
+		record, err = iterator.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "a.txt", string(record.Key.Bytes()))
+		require.Equal(t, internal.OperationDelete, record.Metadata["action"])
+
+		// Creations of blobs which no longer exist are skipped
+		push(t, httpServer.URL+"/?secret=secret", eventgrid.EventTypeBlobCreated, "c.txt")
+
+		time.Sleep(time.Millisecond * 200)
+
+		require.False(t, iterator.HasNext(ctx))
+	})
+	t.Run("Skips the events with invalid data", func(t *testing.T) {
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+
+		server := eventgrid.NewServer("", "secret")
+		httpServer := httptest.NewServer(server)
+
+		t.Cleanup(httpServer.Close)
+
+		iterator, err := NewEventGridIterator(server, containerClient, position.NewCDCPosition("", time.Now()), 100, Filter{}, nil)
+		require.NoError(t, err)
+
+		t.Cleanup(iterator.Stop)
+
+		contents := fakerInstance.Lorem().Sentence(16)
+		require.NoError(t, helper.CreateBlob(containerClient, "a.txt", "text/plain", contents))
+
+		pushData(t, httpServer.URL+"/?secret=secret", eventgrid.EventTypeBlobCreated, "a.txt", `"invalid"`)
+		push(t, httpServer.URL+"/?secret=secret", eventgrid.EventTypeBlobCreated, "a.txt")
+
+		record, err := iterator.Next(ctx)
+		require.NoError(t, err)
+		require.True(t, helper.AssertRecordEquals(t, record, "a.txt", "text/plain", contents))
+	})
+}
@@ -0,0 +1,187 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PatternRegexPrefix marks the pattern as a regular expression, instead of a glob.
+const PatternRegexPrefix = "regex:"
+
+// Filter selects the blobs read by the iterators. The prefix is pushed down to the List Blobs calls, while
+// the include and exclude patterns are evaluated against the names of the listed blobs.
+// With a delimiter, the container is listed hierarchically, descending only into the virtual directories matching
+// the directories pattern, if any, and up to MaxDepth levels, unless it is zero.
+type Filter struct {
+	Prefix  string
+	Include *Pattern
+	Exclude *Pattern
+
+	Delimiter   string
+	Directories *Pattern
+	MaxDepth    int
+}
+
+// Matches indicates whether the blob should be read. A blob is read when it starts with the prefix, it matches
+// the include pattern, if any, and it does not match the exclude pattern, if any.
+func (f Filter) Matches(name string) bool {
+	if !strings.HasPrefix(name, f.Prefix) {
+		return false
+	}
+
+	if f.Include != nil && !f.Include.Match(name) {
+		return false
+	}
+
+	if f.Exclude != nil && f.Exclude.Match(name) {
+		return false
+	}
+
+	return true
+}
+
+// listPrefix returns the prefix to be passed to the List Blobs calls, or nil when the whole container is listed.
+func (f Filter) listPrefix() *string {
+	if f.Prefix == "" {
+		return nil
+	}
+
+	prefix := f.Prefix
+
+	return &prefix
+}
+
+// Pattern matches the whole blob name against a glob or a regular expression.
+type Pattern struct {
+	source     string
+	expression *regexp.Regexp
+}
+
+// ParsePattern parses the glob, or the regular expression when prefixed with PatternRegexPrefix.
+// Globs support `*` matching any characters but `/`, `**` matching any characters, `?` matching a single character
+// but `/`, `[...]` character classes and `{a,b}` alternatives.
+func ParsePattern(s string) (*Pattern, error) {
+	var (
+		expression string
+		err        error
+	)
+
+	if strings.HasPrefix(s, PatternRegexPrefix) {
+		expression = strings.TrimPrefix(s, PatternRegexPrefix)
+	} else if expression, err = globToRegex(s); err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", s, err)
+	}
+
+	return &Pattern{source: s, expression: re}, nil
+}
+
+// Match indicates whether the blob name matches the pattern.
+// Globs have to match the whole name, while regular expressions are only anchored when written so.
+func (p *Pattern) Match(name string) bool {
+	return p.expression.MatchString(name)
+}
+
+// String returns the pattern as it was parsed.
+func (p *Pattern) String() string {
+	return p.source
+}
+
+// globToRegex translates the glob into an anchored regular expression.
+func globToRegex(glob string) (string, error) {
+	var (
+		sb           strings.Builder
+		alternatives int
+	)
+
+	sb.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+
+		case '?':
+			sb.WriteString("[^/]")
+
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("invalid pattern %q: unterminated character class", glob)
+			}
+
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+
+		case '{':
+			sb.WriteString("(?:")
+			alternatives++
+
+		case '}':
+			if alternatives == 0 {
+				sb.WriteString(regexp.QuoteMeta(string(c)))
+
+				continue
+			}
+
+			sb.WriteString(")")
+			alternatives--
+
+		case ',':
+			if alternatives == 0 {
+				sb.WriteByte(c)
+
+				continue
+			}
+
+			sb.WriteString("|")
+
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+
+			sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	if alternatives > 0 {
+		return "", fmt.Errorf("invalid pattern %q: unterminated alternatives", glob)
+	}
+
+	sb.WriteString("$")
+
+	return sb.String(), nil
+}
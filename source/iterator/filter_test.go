@@ -0,0 +1,186 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package iterator
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePattern(t *testing.T) {
+	for _, tt := range []struct {
+		pattern string
+		matches []string
+		misses  []string
+	}{
+		{
+			pattern: "*.json",
+			matches: []string{"records.json", ".json"},
+			misses:  []string{"logs/records.json", "records.json.tmp"},
+		},
+		{
+			pattern: "logs/**.json",
+			matches: []string{"logs/records.json", "logs/2022/01/records.json"},
+			misses:  []string{"records.json", "logs/records.csv"},
+		},
+		{
+			pattern: "logs/????/*.{csv,json}",
+			matches: []string{"logs/2022/a.csv", "logs/2022/b.json"},
+			misses:  []string{"logs/22/a.csv", "logs/2022/a.avro", "logs/2022/01/a.csv"},
+		},
+		{
+			pattern: "[!_]*.[ct]sv",
+			matches: []string{"records.csv", "records.tsv"},
+			misses:  []string{"_SUCCESS.csv", "records.psv"},
+		},
+		{
+			pattern: "data+1\\*.txt",
+			matches: []string{"data+1*.txt"},
+			misses:  []string{"data+12.txt", "dataa1*.txt"},
+		},
+		{
+			pattern: "regex:\\.tmp$",
+			matches: []string{"records.tmp", "logs/records.json.tmp"},
+			misses:  []string{"records.tmp.json"},
+		},
+	} {
+		t.Run(fmt.Sprintf("Pattern %s", tt.pattern), func(t *testing.T) {
+			pattern, err := ParsePattern(tt.pattern)
+			require.NoError(t, err)
+
+			for _, name := range tt.matches {
+				require.True(t, pattern.Match(name), name)
+			}
+
+			for _, name := range tt.misses {
+				require.False(t, pattern.Match(name), name)
+			}
+		})
+	}
+
+	t.Run("Fails when the glob has unterminated alternatives", func(t *testing.T) {
+		pattern, err := ParsePattern("*.{csv,json")
+
+		require.Nil(t, pattern)
+		require.EqualError(t, err, `invalid pattern "*.{csv,json": unterminated alternatives`)
+	})
+
+	t.Run("Fails when the regular expression is invalid", func(t *testing.T) {
+		pattern, err := ParsePattern("regex:[a-")
+
+		require.Nil(t, pattern)
+		require.EqualError(t, err, "invalid pattern \"regex:[a-\": error parsing regexp: missing closing ]: `[a-`")
+	})
+}
+
+func TestFilter_Matches(t *testing.T) {
+	include, err := ParsePattern("**.json")
+	require.NoError(t, err)
+
+	exclude, err := ParsePattern("**/_*")
+	require.NoError(t, err)
+
+	filter := Filter{
+		Prefix:  "logs/",
+		Include: include,
+		Exclude: exclude,
+	}
+
+	require.True(t, filter.Matches("logs/2022/records.json"))
+	require.False(t, filter.Matches("data/records.json"), "blob outside of the prefix")
+	require.False(t, filter.Matches("logs/2022/records.csv"), "blob not included")
+	require.False(t, filter.Matches("logs/2022/_marker.json"), "blob excluded")
+	require.True(t, Filter{}.Matches("anything"), "empty filter")
+}
+
+func TestFilter_descends(t *testing.T) {
+	directories, err := ParsePattern("logs/{,*/}")
+	require.NoError(t, err)
+
+	filter := Filter{
+		Delimiter:   "/",
+		Directories: directories,
+		MaxDepth:    2,
+	}
+
+	require.True(t, filter.descends("logs/", 2))
+	require.False(t, filter.descends("data/", 2), "directory not matching")
+	require.False(t, filter.descends("logs/2022/", 3), "directory too deep")
+	require.True(t, Filter{Delimiter: "/"}.descends("a/b/c/d/", 5), "no limits")
+}
+
+func TestFilter_Selects(t *testing.T) {
+	directories, err := ParsePattern("logs/{,*/}")
+	require.NoError(t, err)
+
+	filter := Filter{
+		Prefix:      "logs/",
+		Delimiter:   "/",
+		Directories: directories,
+		MaxDepth:    2,
+	}
+
+	require.True(t, filter.Selects("logs/a.txt"))
+	require.True(t, filter.Selects("logs/2022/a.txt"))
+	require.False(t, filter.Selects("logs/2022/01/a.txt"), "directory too deep")
+	require.False(t, filter.Selects("data/a.txt"), "blob outside of the prefix")
+	require.True(t, Filter{}.Selects("logs/2022/01/a.txt"), "flat listing")
+}
+
+func TestFilter_setDirectory(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		delimiter string
+		blobName  string
+		expected  map[string]string
+	}{
+		{
+			name:      "Flat listing",
+			delimiter: "",
+			blobName:  "logs/2022/records.json",
+			expected:  map[string]string{},
+		},
+		{
+			name:      "Nested blob",
+			delimiter: "/",
+			blobName:  "logs/2022/records.json",
+			expected:  map[string]string{MetadataKeyDirectory: "logs/2022/"},
+		},
+		{
+			name:      "Root blob",
+			delimiter: "/",
+			blobName:  "records.json",
+			expected:  map[string]string{MetadataKeyDirectory: ""},
+		},
+		{
+			name:      "Multi-character delimiter",
+			delimiter: "::",
+			blobName:  "logs::records.json",
+			expected:  map[string]string{MetadataKeyDirectory: "logs::"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := map[string]string{}
+
+			Filter{Delimiter: tt.delimiter}.setDirectory(metadata, tt.blobName)
+
+			require.Equal(t, tt.expected, metadata)
+		})
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 
 	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/source/position"
 )
 
 //go:generate moq -out iterator_moq_test.go . Iterator
@@ -32,3 +33,10 @@ type Iterator interface {
 	// All currently ongoing operations should be gracefully shut down.
 	Stop()
 }
+
+// Acknowledger is implemented by the iterators keeping some of their state until the records they produced are
+// acknowledged.
+type Acknowledger interface {
+	// Ack informs the iterator that the record at the position was acknowledged.
+	Ack(ctx context.Context, p position.Position) error
+}
@@ -0,0 +1,143 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+)
+
+// Handling of the JSON documents which cannot be decoded into structured data.
+const (
+	InvalidJSONFail = "fail"
+	InvalidJSONSkip = "skip"
+	InvalidJSONRaw  = "raw"
+)
+
+// MetadataKeyJSONError is the key of the record metadata holding why the record's raw payload could not be
+// decoded into structured data.
+const MetadataKeyJSONError = "jsonError"
+
+// JSONSplitter decodes the JSON documents into structured data. A top-level array is split into its elements,
+// whose offsets are the numbers of bytes preceding them, while any other document is read as a whole.
+// Elements are keyed with the blob's name followed by `#` and the element's number, e.g. `data.json#1`.
+// Documents and elements which are not JSON objects fail the reading, are skipped with a warning logged,
+// or are read as raw data with the reason in the metadata, depending on Invalid.
+type JSONSplitter struct {
+	Invalid string
+}
+
+// Splits tells whether the content type is `application/json`, `text/json`, or a JSON-based one, e.g.
+// `application/ld+json`.
+func (JSONSplitter) Splits(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func (s JSONSplitter) Split(ctx context.Context, name string, r io.Reader, offset int64, emit func(Part) error) error {
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	document := Part{Payload: sdk.RawData(contents), Offset: 0, Next: int64(len(contents))}
+
+	var value json.RawMessage
+	if err := json.Unmarshal(contents, &value); err != nil {
+		if offset > 0 {
+			return nil
+		}
+
+		return s.invalid(ctx, name, document, fmt.Errorf("invalid JSON: %w", err), emit)
+	}
+
+	if value[0] != '[' {
+		return s.emitObject(ctx, name, document, offset, emit)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+
+	// Skip the opening bracket
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+
+	for number := 1; decoder.More(); number++ {
+		var element json.RawMessage
+		if err := decoder.Decode(&element); err != nil {
+			return err
+		}
+
+		next := decoder.InputOffset()
+
+		part := Part{
+			Payload: sdk.RawData(element),
+			Key:     fmt.Sprintf("%s#%d", name, number),
+			Offset:  next - int64(len(element)),
+			Next:    next,
+		}
+
+		if err := s.emitObject(ctx, name, part, offset, emit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// emitObject emits the part decoded into structured data, unless it begins before the offset.
+func (s JSONSplitter) emitObject(ctx context.Context, name string, part Part, offset int64, emit func(Part) error) error {
+	if part.Offset < offset {
+		return nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(part.Payload.Bytes(), &fields); err != nil || fields == nil {
+		return s.invalid(ctx, name, part, fmt.Errorf("JSON value at offset %d is not an object", part.Offset), emit)
+	}
+
+	part.Payload = sdk.StructuredData(fields)
+
+	return emit(part)
+}
+
+// invalid handles the part which could not be decoded, as configured.
+func (s JSONSplitter) invalid(ctx context.Context, name string, part Part, reason error, emit func(Part) error) error {
+	switch s.Invalid {
+	case InvalidJSONSkip:
+		sdk.Logger(ctx).Warn().Err(reason).Str("blob", name).Msg("skipping the JSON which cannot be decoded")
+
+		return nil
+
+	case InvalidJSONRaw:
+		part.Metadata = map[string]string{MetadataKeyJSONError: reason.Error()}
+
+		return emit(part)
+
+	default:
+		return reason
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package iterator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSplitter_Splits(t *testing.T) {
+	for contentType, expected := range map[string]bool{
+		"application/json":                true,
+		"application/json; charset=utf-8": true,
+		"text/json":                       true,
+		"application/ld+json":             true,
+		"application/x-ndjson":            false,
+		"text/plain":                      false,
+		"":                                false,
+	} {
+		require.Equal(t, expected, JSONSplitter{}.Splits(contentType), contentType)
+	}
+}
+
+func TestJSONSplitter_Split(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		splitter JSONSplitter
+		contents string
+		offset   int64
+		expected []Part
+	}{
+		{
+			name:     "Decodes the object",
+			contents: ` {"id": 1, "tags": ["a"]}`,
+			expected: []Part{
+				{Payload: sdk.StructuredData{"id": 1.0, "tags": []interface{}{"a"}}, Offset: 0, Next: 25},
+			},
+		},
+		{
+			name:     "Splits the array into objects",
+			contents: `[{"id": 1}, {"id": 2}]`,
+			expected: []Part{
+				{Payload: sdk.StructuredData{"id": 1.0}, Key: "data.json#1", Offset: 1, Next: 10},
+				{Payload: sdk.StructuredData{"id": 2.0}, Key: "data.json#2", Offset: 12, Next: 21},
+			},
+		},
+		{
+			name:     "Resumes after the element read",
+			contents: `[{"id": 1}, {"id": 2}]`,
+			offset:   10,
+			expected: []Part{
+				{Payload: sdk.StructuredData{"id": 2.0}, Key: "data.json#2", Offset: 12, Next: 21},
+			},
+		},
+		{
+			name:     "Resumes after the object read",
+			contents: `{"id": 1}`,
+			offset:   9,
+			expected: nil,
+		},
+		{
+			name:     "Skips the invalid document",
+			splitter: JSONSplitter{Invalid: InvalidJSONSkip},
+			contents: `{"id": `,
+			expected: nil,
+		},
+		{
+			name:     "Skips the element which is not an object",
+			splitter: JSONSplitter{Invalid: InvalidJSONSkip},
+			contents: `[1, {"id": 2}]`,
+			expected: []Part{
+				{Payload: sdk.StructuredData{"id": 2.0}, Key: "data.json#2", Offset: 4, Next: 13},
+			},
+		},
+		{
+			name:     "Reads the invalid document as raw data",
+			splitter: JSONSplitter{Invalid: InvalidJSONRaw},
+			contents: `{"id": `,
+			expected: []Part{
+				{
+					Payload:  sdk.RawData(`{"id": `),
+					Metadata: map[string]string{MetadataKeyJSONError: "invalid JSON: unexpected end of JSON input"},
+					Offset:   0,
+					Next:     7,
+				},
+			},
+		},
+		{
+			name:     "Reads the element which is not an object as raw data",
+			splitter: JSONSplitter{Invalid: InvalidJSONRaw},
+			contents: `[null]`,
+			expected: []Part{
+				{
+					Payload:  sdk.RawData(`null`),
+					Key:      "data.json#1",
+					Metadata: map[string]string{MetadataKeyJSONError: "JSON value at offset 1 is not an object"},
+					Offset:   1,
+					Next:     5,
+				},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var parts []Part
+
+			err := tt.splitter.Split(context.Background(), "data.json", strings.NewReader(tt.contents), tt.offset, func(part Part) error {
+				parts = append(parts, part)
+
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, parts)
+		})
+	}
+
+	t.Run("Fails with the invalid document", func(t *testing.T) {
+		err := JSONSplitter{Invalid: InvalidJSONFail}.Split(context.Background(), "data.json", strings.NewReader(`"text"`), 0, func(Part) error {
+			return nil
+		})
+		require.EqualError(t, err, "JSON value at offset 0 is not an object")
+	})
+}
@@ -0,0 +1,291 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+// MetadataKeyDirectory is the key of the record metadata holding the virtual directory of the blob,
+// set when the container is listed hierarchically.
+const MetadataKeyDirectory = "directory"
+
+// blobPager lists the blobs of the container page by page, in the lexicographical order of their names.
+type blobPager interface {
+	NextPage(ctx context.Context) bool
+	Items() []*azblob.BlobItemInternal
+	Err() error
+
+	// Marker returns the continuation marker the current page was requested with, if any.
+	Marker() string
+}
+
+// listingStart is the blob the listing resumes at, e.g. the last one read before the snapshot was interrupted.
+// Blobs with lower names are skipped, and so is the blob itself, unless it is included, e.g. to be read again from
+// the offset it was read up to.
+type listingStart struct {
+	name     string
+	included bool
+}
+
+// skips indicates whether the blob of given name precedes the start of the listing.
+func (s listingStart) skips(name string) bool {
+	return name < s.name || (name == s.name && !s.included)
+}
+
+// newBlobPager lists the container flat, or hierarchically when the filter has a delimiter.
+// The listing starts from the page of given marker, when possible, and skips the blobs preceding the start.
+func newBlobPager(
+	client *azblob.ContainerClient,
+	filter Filter,
+	maxResults int32,
+	include []azblob.ListBlobsIncludeItem,
+	marker string,
+	start listingStart,
+) blobPager {
+	if filter.Delimiter == "" {
+		options := azblob.ContainerListBlobsFlatOptions{
+			MaxResults: &maxResults,
+			Prefix:     filter.listPrefix(),
+			Include:    include,
+		}
+		if marker != "" {
+			options.Marker = &marker
+		}
+
+		return &flatPager{
+			pager: client.ListBlobsFlat(&options),
+			start: start,
+			next:  marker,
+		}
+	}
+
+	p := hierarchyPager{
+		client:     client,
+		filter:     filter,
+		maxResults: maxResults,
+		include:    include,
+		start:      start,
+	}
+	p.push(filter.Prefix, 1)
+
+	return &p
+}
+
+type flatPager struct {
+	pager  *azblob.ContainerListBlobFlatPager
+	start  listingStart
+	marker string
+	next   string
+	items  []*azblob.BlobItemInternal
+}
+
+func (p *flatPager) NextPage(ctx context.Context) bool {
+	if !p.pager.NextPage(ctx) {
+		return false
+	}
+
+	resp := p.pager.PageResponse()
+
+	p.marker, p.next = p.next, ""
+	if resp.NextMarker != nil {
+		p.next = *resp.NextMarker
+	}
+
+	p.items = skipUntil(resp.Segment.BlobItems, p.start)
+
+	return true
+}
+
+func (p *flatPager) Items() []*azblob.BlobItemInternal {
+	return p.items
+}
+
+func (p *flatPager) Err() error {
+	return p.pager.Err()
+}
+
+func (p *flatPager) Marker() string {
+	return p.marker
+}
+
+// hierarchyLevel is a virtual directory being listed, at given depth counted from the listing's root,
+// with the rest of its current page.
+type hierarchyLevel struct {
+	pager    *azblob.ContainerListBlobHierarchyPager
+	depth    int
+	items    []*azblob.BlobItemInternal
+	prefixes []*azblob.BlobPrefix
+}
+
+// hierarchyPager lists the virtual directories depth-first, descending into every directory allowed by the filter
+// as soon as it is reached, so the blobs are listed in the same order as when listing the container flat.
+type hierarchyPager struct {
+	client     *azblob.ContainerClient
+	filter     Filter
+	maxResults int32
+	include    []azblob.ListBlobsIncludeItem
+	start      listingStart
+
+	stack []*hierarchyLevel
+	items []*azblob.BlobItemInternal
+	err   error
+}
+
+func (p *hierarchyPager) NextPage(ctx context.Context) bool {
+	for len(p.stack) > 0 {
+		level := p.stack[len(p.stack)-1]
+
+		if len(level.items) == 0 && len(level.prefixes) == 0 {
+			if !level.pager.NextPage(ctx) {
+				if err := level.pager.Err(); err != nil {
+					p.err = err
+
+					return false
+				}
+
+				p.stack = p.stack[:len(p.stack)-1]
+
+				continue
+			}
+
+			segment := level.pager.PageResponse().Segment
+
+			level.items, level.prefixes = segment.BlobItems, segment.BlobPrefixes
+		}
+
+		// The blobs sorted before the next directory are returned first
+		n := len(level.items)
+		if len(level.prefixes) > 0 {
+			next := *level.prefixes[0].Name
+
+			n = sort.Search(len(level.items), func(i int) bool {
+				return *level.items[i].Name > next
+			})
+		}
+
+		if n > 0 {
+			p.items, level.items = skipUntil(level.items[:n], p.start), level.items[n:]
+
+			return true
+		}
+
+		// Then the directory is listed, unless all of its blobs were already read
+		prefix := *level.prefixes[0].Name
+		level.prefixes = level.prefixes[1:]
+
+		if p.filter.descends(prefix, level.depth+1) && !(prefix < p.start.name && !strings.HasPrefix(p.start.name, prefix)) {
+			p.push(prefix, level.depth+1)
+		}
+	}
+
+	return false
+}
+
+func (p *hierarchyPager) Items() []*azblob.BlobItemInternal {
+	return p.items
+}
+
+func (p *hierarchyPager) Err() error {
+	return p.err
+}
+
+// Marker returns no marker, as continuation markers of the nested listings cannot be resumed from.
+func (p *hierarchyPager) Marker() string {
+	return ""
+}
+
+func (p *hierarchyPager) push(prefix string, depth int) {
+	options := azblob.ContainerListBlobsHierarchyOptions{
+		MaxResults: &p.maxResults,
+		Include:    p.include,
+	}
+	if prefix != "" {
+		options.Prefix = &prefix
+	}
+
+	p.stack = append(p.stack, &hierarchyLevel{
+		pager: p.client.ListBlobsHierarchy(p.filter.Delimiter, &options),
+		depth: depth,
+	})
+}
+
+// skipUntil skips the blobs preceding the start of the listing.
+func skipUntil(items []*azblob.BlobItemInternal, start listingStart) []*azblob.BlobItemInternal {
+	if start.name == "" {
+		return items
+	}
+
+	n := sort.Search(len(items), func(i int) bool {
+		return !start.skips(*items[i].Name)
+	})
+
+	return items[n:]
+}
+
+// Selects indicates whether the blob would be read by listing the container, i.e. it matches the filter and, when
+// listing hierarchically, it lies in the virtual directories the listing descends into.
+func (f Filter) Selects(name string) bool {
+	if !f.Matches(name) {
+		return false
+	}
+
+	if f.Delimiter == "" {
+		return true
+	}
+
+	offset, depth := len(f.Prefix), 1
+
+	for {
+		i := strings.Index(name[offset:], f.Delimiter)
+		if i < 0 {
+			return true
+		}
+
+		offset += i + len(f.Delimiter)
+		depth++
+
+		if !f.descends(name[:offset], depth) {
+			return false
+		}
+	}
+}
+
+// descends indicates whether the virtual directory at given depth should be listed.
+func (f Filter) descends(directory string, depth int) bool {
+	if f.MaxDepth > 0 && depth > f.MaxDepth {
+		return false
+	}
+
+	return f.Directories == nil || f.Directories.Match(directory)
+}
+
+// setDirectory adds the virtual directory of the blob to the record metadata, when listing hierarchically.
+func (f Filter) setDirectory(metadata map[string]string, name string) {
+	if f.Delimiter == "" {
+		return
+	}
+
+	var directory string
+	if i := strings.LastIndex(name, f.Delimiter); i >= 0 {
+		directory = name[:i+len(f.Delimiter)]
+	}
+
+	metadata[MetadataKeyDirectory] = directory
+}
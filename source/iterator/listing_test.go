@@ -0,0 +1,56 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package iterator
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkipUntil(t *testing.T) {
+	items := []*azblob.BlobItemInternal{
+		{Name: to.Ptr("a.txt")},
+		{Name: to.Ptr("b.txt")},
+		{Name: to.Ptr("b.txt\x00")},
+		{Name: to.Ptr("logs/c.txt")},
+	}
+
+	for _, tt := range []struct {
+		name     string
+		start    listingStart
+		expected []string
+	}{
+		{name: "Nothing read yet", start: listingStart{}, expected: []string{"a.txt", "b.txt", "b.txt\x00", "logs/c.txt"}},
+		{name: "Read up to the blob", start: listingStart{name: "b.txt"}, expected: []string{"b.txt\x00", "logs/c.txt"}},
+		{name: "Read the blob partially", start: listingStart{name: "b.txt", included: true}, expected: []string{"b.txt", "b.txt\x00", "logs/c.txt"}},
+		{name: "Read up to a deleted blob", start: listingStart{name: "b"}, expected: []string{"b.txt", "b.txt\x00", "logs/c.txt"}},
+		{name: "Read a deleted blob partially", start: listingStart{name: "b", included: true}, expected: []string{"b.txt", "b.txt\x00", "logs/c.txt"}},
+		{name: "Read everything", start: listingStart{name: "logs/c.txt"}, expected: []string{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			names := make([]string, 0)
+			for _, item := range skipUntil(items, tt.start) {
+				names = append(names, *item.Name)
+			}
+
+			require.Equal(t, tt.expected, names)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/source/position"
+)
+
+// MetadataKeyContainer is the key of the record metadata holding the name of the container the blob belongs to.
+const MetadataKeyContainer = "container"
+
+var ErrNoContainers = errors.New("no containers to read")
+
+// MultiIterator reads multiple containers in turns, each with its own CombinedIterator.
+// The positions of all the containers are folded into the composite position of every record.
+type MultiIterator struct {
+	names     []string
+	iterators []Iterator
+	positions map[string]position.Position
+	next      int
+}
+
+// NewMultiIterator creates a CombinedIterator for every container, detecting changes with iterators created by
+// newCDC and starting from the container's position held by p. With the state directory set, the snapshots store
+// the listings of the containers in it, to be compared with by the polling.
+func NewMultiIterator(
+	newCDC CDCFactory,
+	clients map[string]*azblob.ContainerClient,
+	maxResults int32,
+	filter Filter,
+	splitter Splitter,
+	stateDirectory string,
+	p position.Position,
+) (*MultiIterator, error) {
+	if len(clients) == 0 {
+		return nil, ErrNoContainers
+	}
+
+	names := make([]string, 0, len(clients))
+	for name := range clients {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	m := MultiIterator{
+		names:     names,
+		iterators: make([]Iterator, 0, len(names)),
+		positions: make(map[string]position.Position, len(names)),
+	}
+
+	for _, name := range names {
+		containerPosition := p.ContainerPosition(name, len(names) == 1)
+
+		iterator, err := NewCombinedIterator(newCDC, clients[name], maxResults, filter, splitter, stateDirectory, containerPosition)
+		if err != nil {
+			m.Stop()
+
+			return nil, fmt.Errorf("container %q: %w", name, err)
+		}
+
+		m.iterators = append(m.iterators, iterator)
+		m.positions[name] = containerPosition
+	}
+
+	return &m, nil
+}
+
+func (m *MultiIterator) HasNext(ctx context.Context) bool {
+	for _, iterator := range m.iterators {
+		if iterator.HasNext(ctx) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Next returns the record of the first container, following the one which produced the previous record, that has
+// a record available. An empty record is returned when none of the containers has one.
+func (m *MultiIterator) Next(ctx context.Context) (sdk.Record, error) {
+	for i := range m.iterators {
+		index := (m.next + i) % len(m.iterators)
+
+		if !m.iterators[index].HasNext(ctx) {
+			continue
+		}
+
+		m.next = index + 1
+
+		record, err := m.iterators[index].Next(ctx)
+		if err != nil {
+			return sdk.Record{}, fmt.Errorf("container %q: %w", m.names[index], err)
+		}
+
+		return m.withContainer(m.names[index], record)
+	}
+
+	return sdk.Record{}, nil
+}
+
+// Ack passes the acknowledgment to the iterator of the container the record was read from.
+func (m *MultiIterator) Ack(ctx context.Context, p position.Position) error {
+	for i, name := range m.names {
+		if name != p.Container || i >= len(m.iterators) {
+			continue
+		}
+
+		if acknowledger, ok := m.iterators[i].(Acknowledger); ok {
+			return acknowledger.Ack(ctx, p.Own())
+		}
+	}
+
+	return nil
+}
+
+func (m *MultiIterator) Stop() {
+	for _, iterator := range m.iterators {
+		iterator.Stop()
+	}
+
+	m.iterators = nil
+}
+
+// withContainer adds the container's name to the record metadata, and replaces the record's position with
+// the composite position holding the last positions of all the containers.
+func (m *MultiIterator) withContainer(name string, record sdk.Record) (sdk.Record, error) {
+	p, err := position.NewFromRecordPosition(record.Position)
+	if err != nil {
+		return sdk.Record{}, fmt.Errorf("container %q: %w", name, err)
+	}
+
+	m.positions[name] = p.Own()
+
+	p.Container = name
+	p.Containers = make(map[string]position.Position, len(m.positions))
+
+	for container, containerPosition := range m.positions {
+		p.Containers[container] = containerPosition
+	}
+
+	if record.Position, err = p.ToRecordPosition(); err != nil {
+		return sdk.Record{}, fmt.Errorf("container %q: %w", name, err)
+	}
+
+	if record.Metadata == nil {
+		record.Metadata = map[string]string{}
+	}
+
+	record.Metadata[MetadataKeyContainer] = name
+
+	return record, nil
+}
@@ -0,0 +1,149 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package iterator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/source/position"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMultiIterator(t *testing.T) {
+	t.Run("Fails when there are no containers", func(t *testing.T) {
+		iterator, err := NewMultiIterator(PollingCDC(time.Millisecond, nil), nil, 1, Filter{}, nil, "", position.NewDefaultSnapshotPosition())
+
+		require.Nil(t, iterator)
+		require.ErrorIs(t, err, ErrNoContainers)
+	})
+}
+
+func TestMultiIterator_Next(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Reads containers in turns and folds their positions", func(t *testing.T) {
+		first := newRecordsIteratorMock(t, position.NewSnapshotPosition("a.txt", time.Unix(1, 0)))
+		second := newRecordsIteratorMock(t,
+			position.NewCDCPosition("b.txt", time.Unix(2, 0)),
+			position.NewCDCPosition("c.txt", time.Unix(3, 0)),
+		)
+
+		iterator := MultiIterator{
+			names:     []string{"first", "second"},
+			iterators: []Iterator{first, second},
+			positions: map[string]position.Position{},
+		}
+
+		for _, expected := range []struct {
+			container string
+			key       string
+		}{
+			{container: "first", key: "a.txt"},
+			{container: "second", key: "b.txt"},
+			{container: "second", key: "c.txt"},
+		} {
+			require.True(t, iterator.HasNext(ctx))
+
+			record, err := iterator.Next(ctx)
+			require.NoError(t, err)
+			require.Equal(t, expected.container, record.Metadata[MetadataKeyContainer])
+
+			p, err := position.NewFromRecordPosition(record.Position)
+			require.NoError(t, err)
+			require.Equal(t, expected.container, p.Container)
+			require.Equal(t, expected.key, p.Key)
+			require.Equal(t, expected.key, p.ContainerPosition(expected.container, false).Key)
+		}
+
+		record, err := iterator.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, sdk.Record{}, record)
+		require.False(t, iterator.HasNext(ctx))
+
+		// The last position remembers the positions of both containers
+		p := iterator.positions
+		require.Equal(t, "a.txt", p["first"].Key)
+		require.Equal(t, position.TypeSnapshot, p["first"].Type)
+		require.Equal(t, "c.txt", p["second"].Key)
+		require.Equal(t, position.TypeCDC, p["second"].Type)
+	})
+
+	t.Run("Returns the error of the container's iterator", func(t *testing.T) {
+		readErr := errors.New("read error")
+
+		iterator := MultiIterator{
+			names: []string{"first"},
+			iterators: []Iterator{&IteratorMock{
+				HasNextFunc: func(ctx context.Context) bool { return true },
+				NextFunc: func(ctx context.Context) (sdk.Record, error) {
+					return sdk.Record{}, readErr
+				},
+			}},
+			positions: map[string]position.Position{},
+		}
+
+		_, err := iterator.Next(ctx)
+		require.ErrorIs(t, err, readErr)
+		require.EqualError(t, err, `container "first": read error`)
+	})
+}
+
+func TestMultiIterator_Stop(t *testing.T) {
+	iteratorMock := IteratorMock{
+		StopFunc: func() {},
+	}
+
+	iterator := MultiIterator{
+		iterators: []Iterator{&iteratorMock, &iteratorMock},
+	}
+
+	iterator.Stop()
+	require.Nil(t, iterator.iterators)
+	require.Len(t, iteratorMock.StopCalls(), 2)
+}
+
+// newRecordsIteratorMock returns the iterator producing records with given positions.
+func newRecordsIteratorMock(t *testing.T, positions ...position.Position) *IteratorMock {
+	records := make([]sdk.Record, 0, len(positions))
+
+	for _, p := range positions {
+		recordPosition, err := p.ToRecordPosition()
+		require.NoError(t, err)
+
+		records = append(records, sdk.Record{
+			Metadata: map[string]string{},
+			Position: recordPosition,
+			Key:      sdk.RawData(p.Key),
+		})
+	}
+
+	return &IteratorMock{
+		HasNextFunc: func(ctx context.Context) bool {
+			return len(records) > 0
+		},
+		NextFunc: func(ctx context.Context) (sdk.Record, error) {
+			record := records[0]
+			records = records[1:]
+
+			return record, nil
+		},
+	}
+}
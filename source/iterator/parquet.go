@@ -0,0 +1,405 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/schema"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/types"
+)
+
+// Keys of the record metadata locating the record's row in the Parquet file.
+const (
+	MetadataKeyRowGroup = "rowGroup"
+	MetadataKeyRow      = "row"
+)
+
+const (
+	// parquetBatchSize is the maximum number of rows decoded at once
+	parquetBatchSize = 1000
+
+	// parquetReadAhead is the minimum number of bytes requested at once, so the small reads of the footer and
+	// page headers do not make a request each
+	parquetReadAhead = 1 << 20
+)
+
+var ErrNotParquet = errors.New("not a Parquet file")
+
+// ParquetSplitter splits Parquet files, e.g. written by Spark, into their rows read as structured data, keyed
+// with the columns' names. Row groups are read one by one, each column's chunk with its own ranged request.
+// Records are keyed with the blob's name followed by `#` and the row's number, e.g. `part-00000.parquet#1`.
+// Offsets are the numbers of rows preceding the rows in the file, and the positions also hold the row group and
+// the row within it the reading resumes from. Empty blobs, e.g. Spark's `_SUCCESS` markers, have no rows.
+type ParquetSplitter struct{}
+
+// Split reads the whole contents into memory, for the blobs which cannot be read in ranges.
+func (s ParquetSplitter) Split(ctx context.Context, name string, r io.Reader, offset int64, emit func(Part) error) error {
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return s.SplitRanges(ctx, name, bytes.NewReader(contents), int64(len(contents)), offset, emit)
+}
+
+func (ParquetSplitter) SplitRanges(
+	_ context.Context,
+	name string,
+	r io.ReaderAt,
+	size, offset int64,
+	emit func(Part) error,
+) error {
+	if size == 0 {
+		return nil
+	}
+
+	if err := checkParquetMagic(r, size); err != nil {
+		return err
+	}
+
+	pr, err := reader.NewParquetReader(&parquetFile{reader: r, size: size}, nil, 1)
+	if err != nil {
+		return fmt.Errorf("could not read the Parquet footer: %w", err)
+	}
+	defer pr.ReadStop()
+
+	names := newParquetNames(pr.SchemaHandler)
+	rowGroups := pr.Footer.GetRowGroups()
+
+	// The row groups preceding the offset are not read at all, the rows preceding it in its row group are skipped
+	group, first := 0, int64(0)
+	for group < len(rowGroups) && first+rowGroups[group].GetNumRows() <= offset {
+		first += rowGroups[group].GetNumRows()
+		group++
+	}
+
+	if group == len(rowGroups) {
+		return nil
+	}
+
+	if group > 0 {
+		for _, columnBuffer := range pr.ColumnBuffers {
+			for i := 0; i < group; i++ {
+				if err := columnBuffer.NextRowGroup(); err != nil {
+					return fmt.Errorf("could not skip the row groups: %w", err)
+				}
+			}
+		}
+	}
+
+	if err := pr.SkipRows(offset - first); err != nil {
+		return fmt.Errorf("could not skip the rows: %w", err)
+	}
+
+	for row := offset - first; group < len(rowGroups); group, first, row = group+1, first+rowGroups[group].GetNumRows(), 0 {
+		for count := rowGroups[group].GetNumRows(); row < count; {
+			rows, err := pr.ReadByNumber(int(minInt64(count-row, parquetBatchSize)))
+			if err != nil {
+				return fmt.Errorf("could not read the row group %d: %w", group, err)
+			}
+
+			if len(rows) == 0 {
+				return fmt.Errorf("row group %d has %d rows, expected %d", group, row, count)
+			}
+
+			for _, value := range rows {
+				part := Part{
+					Payload: sdk.StructuredData(names.value(reflect.ValueOf(value), 0).(map[string]interface{})),
+					Key:     fmt.Sprintf("%s#%d", name, first+row+1),
+					Metadata: map[string]string{
+						MetadataKeyRowGroup: strconv.Itoa(group),
+						MetadataKeyRow:      strconv.FormatInt(row, 10),
+					},
+					Offset:   first + row,
+					Next:     first + row + 1,
+					RowGroup: group,
+					Row:      row + 1,
+				}
+
+				// The row following the last one of a row group is the first one of the next group
+				if part.Row == count {
+					part.RowGroup, part.Row = group+1, 0
+				}
+
+				if err := emit(part); err != nil {
+					return err
+				}
+
+				row++
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkParquetMagic fails when the contents do not begin and end with Parquet's magic bytes.
+func checkParquetMagic(r io.ReaderAt, size int64) error {
+	const magic = "PAR1"
+
+	if size < 2*int64(len(magic)) {
+		return ErrNotParquet
+	}
+
+	head, tail := make([]byte, len(magic)), make([]byte, len(magic))
+
+	if _, err := r.ReadAt(head, 0); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	if _, err := r.ReadAt(tail, size-int64(len(magic))); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	if string(head) != magic || string(tail) != magic {
+		return ErrNotParquet
+	}
+
+	return nil
+}
+
+// parquetNames converts the rows decoded by the Parquet reader, whose fields are named after the columns in the
+// Go way, into structured data keyed with the columns' names as written in the file.
+type parquetNames struct {
+	handler *schema.SchemaHandler
+
+	// children are the indices of each schema element's children
+	children [][]int
+}
+
+func newParquetNames(handler *schema.SchemaHandler) parquetNames {
+	names := parquetNames{
+		handler:  handler,
+		children: make([][]int, len(handler.SchemaElements)),
+	}
+
+	// Elements are listed depth-first, each one followed by its children
+	var walk func(index int) int
+	walk = func(index int) int {
+		next := index + 1
+		for i := 0; i < int(handler.SchemaElements[index].GetNumChildren()); i++ {
+			names.children[index] = append(names.children[index], next)
+			next = walk(next)
+		}
+
+		return next
+	}
+	walk(0)
+
+	return names
+}
+
+// value converts the decoded value of the schema element.
+func (n parquetNames) value(v reflect.Value, index int) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+
+		return n.value(v.Elem(), index)
+
+	case reflect.Struct:
+		fields := make(map[string]interface{}, v.NumField())
+		for i, child := range n.children[index] {
+			fields[n.handler.Infos[child].ExName] = n.value(v.Field(i), child)
+		}
+
+		return fields
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+
+		// Lists are either the LIST groups holding the repeated elements, or the repeated fields themselves
+		element := index
+		if n.isGroup(index, parquet.ConvertedType_LIST) {
+			element = n.children[n.children[index][0]][0]
+		}
+
+		values := make([]interface{}, v.Len())
+		for i := range values {
+			values[i] = n.value(v.Index(i), element)
+		}
+
+		return values
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+
+		keyValue := n.children[index][0]
+		key, value := n.children[keyValue][0], n.children[keyValue][1]
+
+		values := make(map[string]interface{}, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			values[fmt.Sprint(n.value(iter.Key(), key))] = n.value(iter.Value(), value)
+		}
+
+		return values
+
+	default:
+		return n.primitive(v.Interface(), n.handler.SchemaElements[index])
+	}
+}
+
+// isGroup tells whether the schema element is a group of the converted type.
+func (n parquetNames) isGroup(index int, convertedType parquet.ConvertedType) bool {
+	element := n.handler.SchemaElements[index]
+
+	return element.GetNumChildren() > 0 && element.ConvertedType != nil && *element.ConvertedType == convertedType
+}
+
+// primitive converts the value of the primitive type to the logical one, e.g. timestamps stored as INT96 or
+// decimals stored as bytes. Binary values which are not text are returned as bytes.
+func (parquetNames) primitive(value interface{}, element *parquet.SchemaElement) interface{} {
+	switch v := value.(type) {
+	case int32:
+		switch element.GetConvertedType() {
+		case parquet.ConvertedType_DATE:
+			return time.Unix(int64(v)*24*60*60, 0).UTC()
+		case parquet.ConvertedType_DECIMAL:
+			return types.DECIMAL_INT_ToString(int64(v), int(element.GetPrecision()), int(element.GetScale()))
+		}
+
+	case int64:
+		switch element.GetConvertedType() {
+		case parquet.ConvertedType_TIMESTAMP_MILLIS:
+			return types.TIMESTAMP_MILLISToTime(v, true)
+		case parquet.ConvertedType_TIMESTAMP_MICROS:
+			return types.TIMESTAMP_MICROSToTime(v, true)
+		case parquet.ConvertedType_DECIMAL:
+			return types.DECIMAL_INT_ToString(v, int(element.GetPrecision()), int(element.GetScale()))
+		}
+
+	case string:
+		if element.GetType() == parquet.Type_INT96 {
+			return types.INT96ToTime(v)
+		}
+
+		if element.ConvertedType == nil && element.LogicalType == nil {
+			return []byte(v)
+		}
+
+		if element.GetConvertedType() == parquet.ConvertedType_DECIMAL {
+			return types.DECIMAL_BYTE_ARRAY_ToString([]byte(v), int(element.GetPrecision()), int(element.GetScale()))
+		}
+	}
+
+	return value
+}
+
+// parquetFile is the Parquet reader's file, reading the contents with the reader. Each file opened, e.g. for each
+// column, reads ahead on its own, so the following reads of the same column are served from memory.
+type parquetFile struct {
+	reader io.ReaderAt
+	size   int64
+	offset int64
+
+	buffer       []byte
+	bufferOffset int64
+}
+
+func (f *parquetFile) Open(_ string) (source.ParquetFile, error) {
+	return &parquetFile{reader: f.reader, size: f.size}, nil
+}
+
+func (f *parquetFile) Create(_ string) (source.ParquetFile, error) {
+	return nil, errors.New("parquet file is read-only")
+}
+
+func (f *parquetFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return f.offset, fmt.Errorf("invalid whence %d", whence)
+	}
+
+	if offset < 0 {
+		return f.offset, fmt.Errorf("invalid offset %d", offset)
+	}
+
+	f.offset = offset
+
+	return f.offset, nil
+}
+
+func (f *parquetFile) Read(p []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+
+	if f.offset < f.bufferOffset || f.offset >= f.bufferOffset+int64(len(f.buffer)) {
+		length := minInt64(f.size-f.offset, maxInt64(int64(len(p)), parquetReadAhead))
+
+		buffer := make([]byte, length)
+
+		n, err := f.reader.ReadAt(buffer, f.offset)
+		if n == 0 && err != nil {
+			return 0, err
+		}
+
+		f.buffer, f.bufferOffset = buffer[:n], f.offset
+	}
+
+	n := copy(p, f.buffer[f.offset-f.bufferOffset:])
+	f.offset += int64(n)
+
+	return n, nil
+}
+
+func (f *parquetFile) Write(_ []byte) (int, error) {
+	return 0, errors.New("parquet file is read-only")
+}
+
+func (f *parquetFile) Close() error {
+	f.buffer = nil
+
+	return nil
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+
+	return b
+}
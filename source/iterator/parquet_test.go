@@ -0,0 +1,226 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package iterator
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go/types"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+type parquetTestAddress struct {
+	City string `parquet:"name=city, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type parquetTestRow struct {
+	ID        int64               `parquet:"name=id, type=INT64"`
+	Name      *string             `parquet:"name=user_name, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Tags      []string            `parquet:"name=tags, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	Scores    map[string]int32    `parquet:"name=scores, type=MAP, convertedtype=MAP, keytype=BYTE_ARRAY, keyconvertedtype=UTF8, valuetype=INT32"`
+	Address   *parquetTestAddress `parquet:"name=address, repetitiontype=OPTIONAL"`
+	CreatedAt string              `parquet:"name=created_at, type=INT96"`
+	Raw       string              `parquet:"name=raw, type=BYTE_ARRAY"`
+}
+
+// writeParquet writes the rows into a Parquet file, with a row group for every given number of rows.
+func writeParquet(t *testing.T, rows []parquetTestRow, rowGroupRows int) []byte {
+	var buffer bytes.Buffer
+
+	pw, err := writer.NewParquetWriterFromWriter(&buffer, new(parquetTestRow), 1)
+	require.NoError(t, err)
+
+	for i, row := range rows {
+		require.NoError(t, pw.Write(row))
+
+		if (i+1)%rowGroupRows == 0 {
+			require.NoError(t, pw.Flush(true))
+		}
+	}
+
+	require.NoError(t, pw.WriteStop())
+
+	return buffer.Bytes()
+}
+
+func TestParquetSplitter_SplitRanges(t *testing.T) {
+	createdAt := time.Date(2022, 8, 1, 12, 30, 0, 0, time.UTC)
+	name := "John"
+
+	rows := []parquetTestRow{
+		{
+			ID:        1,
+			Name:      &name,
+			Tags:      []string{"a", "b"},
+			Scores:    map[string]int32{"math": 5},
+			Address:   &parquetTestAddress{City: "Warsaw"},
+			CreatedAt: types.TimeToINT96(createdAt),
+			Raw:       "\x00\x01",
+		},
+		{ID: 2, CreatedAt: types.TimeToINT96(createdAt)},
+		{ID: 3, CreatedAt: types.TimeToINT96(createdAt)},
+		{ID: 4, CreatedAt: types.TimeToINT96(createdAt)},
+		{ID: 5, CreatedAt: types.TimeToINT96(createdAt)},
+	}
+
+	contents := writeParquet(t, rows, 2)
+
+	split := func(offset int64) []Part {
+		var parts []Part
+
+		err := ParquetSplitter{}.SplitRanges(context.Background(), "part-00000.parquet", bytes.NewReader(contents), int64(len(contents)), offset, func(part Part) error {
+			parts = append(parts, part)
+
+			return nil
+		})
+		require.NoError(t, err)
+
+		return parts
+	}
+
+	t.Run("Reads the rows of all the row groups", func(t *testing.T) {
+		parts := split(0)
+
+		require.Len(t, parts, 5)
+		require.Equal(t, sdk.StructuredData{
+			"id":         int64(1),
+			"user_name":  "John",
+			"tags":       []interface{}{"a", "b"},
+			"scores":     map[string]interface{}{"math": int32(5)},
+			"address":    map[string]interface{}{"city": "Warsaw"},
+			"created_at": createdAt,
+			"raw":        []byte{0, 1},
+		}, parts[0].Payload)
+		require.Equal(t, "part-00000.parquet#1", parts[0].Key)
+		require.Nil(t, parts[1].Payload.(sdk.StructuredData)["user_name"])
+		require.Nil(t, parts[1].Payload.(sdk.StructuredData)["address"])
+
+		for i, part := range parts {
+			require.Equal(t, int64(i), part.Offset)
+			require.Equal(t, int64(i+1), part.Next)
+			require.Equal(t, int64(i+1), part.Payload.(sdk.StructuredData)["id"])
+		}
+
+		require.Equal(t, map[string]string{MetadataKeyRowGroup: "1", MetadataKeyRow: "1"}, parts[3].Metadata)
+		require.Equal(t, 1, parts[2].RowGroup)
+		require.Equal(t, int64(1), parts[2].Row)
+		require.Equal(t, 2, parts[3].RowGroup)
+		require.Equal(t, int64(0), parts[3].Row)
+	})
+
+	t.Run("Resumes within a row group", func(t *testing.T) {
+		parts := split(3)
+
+		require.Len(t, parts, 2)
+		require.Equal(t, int64(4), parts[0].Payload.(sdk.StructuredData)["id"])
+		require.Equal(t, "part-00000.parquet#4", parts[0].Key)
+		require.Equal(t, int64(5), parts[1].Payload.(sdk.StructuredData)["id"])
+	})
+
+	t.Run("Resumes at the beginning of a row group", func(t *testing.T) {
+		parts := split(4)
+
+		require.Len(t, parts, 1)
+		require.Equal(t, int64(5), parts[0].Payload.(sdk.StructuredData)["id"])
+		require.Equal(t, 3, parts[0].RowGroup)
+		require.Equal(t, int64(0), parts[0].Row)
+	})
+
+	t.Run("Resumes after the last row", func(t *testing.T) {
+		require.Empty(t, split(5))
+	})
+}
+
+func TestParquetSplitter_Split(t *testing.T) {
+	createdAt := types.TimeToINT96(time.Now())
+	contents := writeParquet(t, []parquetTestRow{{ID: 1, CreatedAt: createdAt}, {ID: 2, CreatedAt: createdAt}}, 1)
+
+	var parts []Part
+
+	err := ParquetSplitter{}.Split(context.Background(), "data.parquet", bytes.NewReader(contents), 1, func(part Part) error {
+		parts = append(parts, part)
+
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Len(t, parts, 1)
+	require.Equal(t, int64(2), parts[0].Payload.(sdk.StructuredData)["id"])
+	require.Equal(t, int64(1), parts[0].Offset)
+}
+
+func TestParquetSplitter_SplitRanges_Invalid(t *testing.T) {
+	emit := func(Part) error {
+		t.Fatal("no part is expected")
+
+		return nil
+	}
+
+	t.Run("Skips empty blobs", func(t *testing.T) {
+		require.NoError(t, ParquetSplitter{}.Split(context.Background(), "_SUCCESS", strings.NewReader(""), 0, emit))
+	})
+
+	t.Run("Fails for other files", func(t *testing.T) {
+		err := ParquetSplitter{}.Split(context.Background(), "data.csv", strings.NewReader("id,name\n1,John\n"), 0, emit)
+
+		require.ErrorIs(t, err, ErrNotParquet)
+	})
+}
+
+func TestParquetFile_Read(t *testing.T) {
+	contents := []byte(strings.Repeat("0123456789", 10))
+	reads := 0
+
+	file := &parquetFile{
+		reader: readerAtFunc(func(p []byte, offset int64) (int, error) {
+			reads++
+
+			return bytes.NewReader(contents).ReadAt(p, offset)
+		}),
+		size: int64(len(contents)),
+	}
+
+	buffer := make([]byte, 4)
+
+	_, err := file.Seek(-8, 2)
+	require.NoError(t, err)
+
+	n, err := file.Read(buffer)
+	require.NoError(t, err)
+	require.Equal(t, "2345", string(buffer[:n]))
+
+	n, err = file.Read(buffer)
+	require.NoError(t, err)
+	require.Equal(t, "6789", string(buffer[:n]))
+	require.Equal(t, 1, reads, "the second read is expected to be served from memory")
+
+	_, err = file.Read(buffer)
+	require.ErrorIs(t, err, io.EOF)
+}
+
+type readerAtFunc func(p []byte, offset int64) (int, error)
+
+func (f readerAtFunc) ReadAt(p []byte, offset int64) (int, error) {
+	return f(p, offset)
+}
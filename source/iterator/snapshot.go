@@ -18,7 +18,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
@@ -34,16 +33,26 @@ func NewSnapshotIterator(
 	client *azblob.ContainerClient,
 	p position.Position,
 	maxResults int32,
+	filter Filter,
+	state *ListingState,
+	splitter Splitter,
 ) (*SnapshotIterator, error) {
 	if maxResults < 1 {
 		return nil, fmt.Errorf("maxResults is expected to be greater than or equal to 1, got %d", maxResults)
 	}
 
+	// The blob read partially is read again, from the offset following the last part read
+	start := listingStart{name: p.Key, included: p.Offset > 0}
+
 	iterator := SnapshotIterator{
-		client: client,
-		paginator: client.ListBlobsFlat(&azblob.ContainerListBlobsFlatOptions{
-			MaxResults: &maxResults,
-		}),
+		client:          client,
+		paginator:       newBlobPager(client, filter, maxResults, nil, p.Marker, start),
+		maxResults:      maxResults,
+		start:           start,
+		filter:          filter,
+		state:           state,
+		splitter:        splitter,
+		resume:          p,
 		maxLastModified: p.Timestamp,
 		buffer:          make(chan sdk.Record, 1),
 		tomb:            tomb.Tomb{},
@@ -56,7 +65,14 @@ func NewSnapshotIterator(
 
 type SnapshotIterator struct {
 	client          *azblob.ContainerClient
-	paginator       *azblob.ContainerListBlobFlatPager
+	paginator       blobPager
+	maxResults      int32
+	start           listingStart
+	filter          Filter
+	state           *ListingState
+	last            sdk.Position
+	splitter        Splitter
+	resume          position.Position
 	maxLastModified time.Time
 	buffer          chan sdk.Record
 	tomb            tomb.Tomb
@@ -93,17 +109,40 @@ func (w *SnapshotIterator) Stop() {
 	_ = w.tomb.Wait()
 }
 
-// producer reads the container and reports all files found.
+// producer reads the container and reports all files matching the filter.
+// With the state set, the names and ETags of the blobs listed are stored in the state once the listing ends and
+// its last record is acknowledged, so the CDC detects the blobs deleted since the snapshot.
 func (w *SnapshotIterator) producer() error {
 	defer close(w.buffer)
 
 	ctx := context.Background()
 
+	var listing *stateDiff
+
+	if w.state != nil {
+		var err error
+
+		if listing, err = w.newListing(w.tomb.Context(ctx)); err != nil {
+			return err
+		}
+
+		defer listing.Close()
+	}
+
 	for {
 		if w.paginator.NextPage(w.tomb.Context(ctx)) {
-			resp := w.paginator.PageResponse()
+			for _, item := range w.paginator.Items() {
+				if listing != nil {
+					if _, err := listing.Visit(*item.Name, etagOf(item), ignoreDeleted); err != nil {
+						return err
+					}
+				}
+
+				// Skip the blobs not matching the include and exclude patterns
+				if !w.filter.Matches(*item.Name) {
+					continue
+				}
 
-			for _, item := range resp.Segment.BlobItems {
 				// Check if maxLastModified should be updated
 				if w.maxLastModified.Before(*item.Properties.LastModified) {
 					w.maxLastModified = *item.Properties.LastModified
@@ -115,25 +154,13 @@ func (w *SnapshotIterator) producer() error {
 					return err
 				}
 
-				downloadResponse, err := blobClient.Download(w.tomb.Context(ctx), nil)
-				if err != nil {
-					return err
-				}
-
-				rawBody, err := ioutil.ReadAll(downloadResponse.Body(&azblob.RetryReaderOptions{
-					MaxRetryRequests: 0,
-				}))
-				if err != nil {
-					return err
-				}
-
 				// Prepare the record position
 				p := position.NewSnapshotPosition(*item.Name, w.maxLastModified)
+				p.Marker = w.paginator.Marker()
 
-				recordPosition, err := p.ToRecordPosition()
-				if err != nil {
-					return err
-				}
+				// Only the first blob listed may be the one read partially
+				resume := w.resume
+				w.resume = position.Position{}
 
 				// Prepare the sdk.Record
 				record := sdk.Record{
@@ -141,19 +168,14 @@ func (w *SnapshotIterator) producer() error {
 						"action":       internal.OperationInsert,
 						"content-type": *item.Properties.ContentType,
 					},
-					Position:  recordPosition,
-					Payload:   sdk.RawData(rawBody),
 					Key:       sdk.RawData(*item.Name),
 					CreatedAt: *item.Properties.CreationTime,
 				}
+				w.filter.setDirectory(record.Metadata, *item.Name)
 
-				// Send out the record if possible
-				select {
-				case w.buffer <- record:
-					// sdk.Record was sent successfully
-
-				case <-w.tomb.Dying():
-					return nil
+				// Send out the records if possible
+				if err := emitBlob(w.tomb.Context(ctx), blobClient, w.splitter, resume, record, p, w.send); err != nil {
+					return err
 				}
 			}
 
@@ -165,6 +187,60 @@ func (w *SnapshotIterator) producer() error {
 			return err
 		}
 
+		if listing != nil {
+			return w.state.save(listing, w.last)
+		}
+
 		return nil
 	}
 }
+
+// newListing starts the listing stored in the state, holding the blobs preceding the start of the snapshot's
+// listing, which are not listed by its paginator again.
+func (w *SnapshotIterator) newListing(ctx context.Context) (*stateDiff, error) {
+	listing, err := w.state.diff()
+	if err != nil {
+		return nil, err
+	}
+
+	if w.start.name == "" {
+		return listing, nil
+	}
+
+	paginator := newBlobPager(w.client, w.filter, w.maxResults, nil, "", listingStart{})
+
+	for paginator.NextPage(ctx) {
+		for _, item := range paginator.Items() {
+			if !w.start.skips(*item.Name) {
+				return listing, nil
+			}
+
+			if _, err := listing.Visit(*item.Name, etagOf(item), ignoreDeleted); err != nil {
+				listing.Close()
+
+				return nil, err
+			}
+		}
+	}
+
+	if err := paginator.Err(); err != nil {
+		listing.Close()
+
+		return nil, err
+	}
+
+	return listing, nil
+}
+
+// send passes the record to the buffer, unless the iterator is being stopped.
+func (w *SnapshotIterator) send(record sdk.Record) error {
+	select {
+	case w.buffer <- record:
+		w.last = record.Position
+
+		return nil
+
+	case <-w.tomb.Dying():
+		return w.tomb.Err()
+	}
+}
@@ -17,6 +17,7 @@
 package iterator
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"testing"
@@ -24,10 +25,13 @@ import (
 
 	sdk "github.com/conduitio/conduit-connector-sdk"
 	"github.com/jaswdr/faker"
+	"github.com/linkedin/goavro/v2"
 	"github.com/miquido/conduit-connector-azure-storage/internal"
+	"github.com/miquido/conduit-connector-azure-storage/internal/compression"
 	"github.com/miquido/conduit-connector-azure-storage/source/position"
 	helper "github.com/miquido/conduit-connector-azure-storage/test"
 	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go/writer"
 )
 
 func TestSnapshotIterator(t *testing.T) {
@@ -40,7 +44,7 @@ func TestSnapshotIterator(t *testing.T) {
 		ctx := context.Background()
 		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
 
-		iterator, err := NewSnapshotIterator(containerClient, position.NewDefaultSnapshotPosition(), fakerInstance.Int32Between(1, 100))
+		iterator, err := NewSnapshotIterator(containerClient, position.NewDefaultSnapshotPosition(), fakerInstance.Int32Between(1, 100), Filter{}, nil, nil)
 		require.NoError(t, err)
 
 		// Let the Goroutine finish
@@ -75,7 +79,7 @@ func TestSnapshotIterator(t *testing.T) {
 			require.NoError(t, helper.CreateBlob(containerClient, record1Name, "text/plain", record1Contents))
 			require.NoError(t, helper.CreateBlob(containerClient, record2Name, "text/plain", record2Contents))
 
-			iterator, err := NewSnapshotIterator(containerClient, snapshotPosition, tt.maxResults)
+			iterator, err := NewSnapshotIterator(containerClient, snapshotPosition, tt.maxResults, Filter{}, nil, nil)
 			require.NoError(t, err)
 
 			// Let the Goroutine start
@@ -123,7 +127,7 @@ func TestSnapshotIterator(t *testing.T) {
 		require.NoError(t, helper.CreateBlob(containerClient, record2Name, "text/plain", record2Contents))
 		require.NoError(t, helper.CreateBlob(containerClient, record3Name, "text/plain", record3Contents))
 
-		iterator, err := NewSnapshotIterator(containerClient, snapshotPosition, 2)
+		iterator, err := NewSnapshotIterator(containerClient, snapshotPosition, 2, Filter{}, nil, nil)
 		require.NoError(t, err)
 
 		// Let the Goroutine start
@@ -173,7 +177,7 @@ func TestSnapshotIterator(t *testing.T) {
 		require.NoError(t, helper.CreateBlob(containerClient, record1Name, "text/plain", record1Contents))
 		require.NoError(t, helper.CreateBlob(containerClient, record2Name, "text/plain", record2Contents))
 
-		iterator, err := NewSnapshotIterator(containerClient, snapshotPosition, 100)
+		iterator, err := NewSnapshotIterator(containerClient, snapshotPosition, 100, Filter{}, nil, nil)
 		require.NoError(t, err)
 
 		// Let the Goroutine start
@@ -220,7 +224,7 @@ func TestSnapshotIterator(t *testing.T) {
 		require.NoError(t, helper.CreateBlob(containerClient, record2Name, "text/plain", record2Contents))
 		require.NoError(t, helper.CreateBlob(containerClient, record3Name, "text/plain", record3Contents))
 
-		iterator, err := NewSnapshotIterator(containerClient, snapshotPosition, 100)
+		iterator, err := NewSnapshotIterator(containerClient, snapshotPosition, 100, Filter{}, nil, nil)
 		require.NoError(t, err)
 
 		// Let the Goroutine start
@@ -248,4 +252,408 @@ func TestSnapshotIterator(t *testing.T) {
 		require.EqualError(t, errN, "context canceled")
 		require.Equal(t, sdk.Record{}, recordN)
 	})
+
+	t.Run("Decompresses blobs written with Content-Encoding", func(t *testing.T) {
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+
+		contents := map[string]string{
+			"a.txt.gz":  fakerInstance.Lorem().Sentence(16),
+			"b.txt.zst": fakerInstance.Lorem().Sentence(16),
+			"c.txt.sz":  fakerInstance.Lorem().Sentence(16),
+		}
+
+		require.NoError(t, helper.CreateEncodedBlob(containerClient, "a.txt.gz", "text/plain", compression.Gzip, contents["a.txt.gz"]))
+		require.NoError(t, helper.CreateEncodedBlob(containerClient, "b.txt.zst", "text/plain", compression.Zstd, contents["b.txt.zst"]))
+		require.NoError(t, helper.CreateEncodedBlob(containerClient, "c.txt.sz", "text/plain", compression.Snappy, contents["c.txt.sz"]))
+
+		iterator, err := NewSnapshotIterator(containerClient, position.NewDefaultSnapshotPosition(), 100, Filter{}, nil, nil)
+		require.NoError(t, err)
+
+		// Let the Goroutine start
+		time.Sleep(time.Millisecond * 500)
+
+		for _, name := range []string{"a.txt.gz", "b.txt.zst", "c.txt.sz"} {
+			require.True(t, iterator.HasNext(ctx))
+
+			record, err := iterator.Next(ctx)
+			require.NoError(t, err)
+			require.True(t, helper.AssertRecordEquals(t, record, name, "text/plain", contents[name]))
+		}
+	})
+
+	t.Run("Reads only blobs matching the filter", func(t *testing.T) {
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+
+		contents := fakerInstance.Lorem().Sentence(16)
+
+		for _, name := range []string{"logs/a.json", "logs/a.json.tmp", "logs/_SUCCESS", "data/b.json"} {
+			require.NoError(t, helper.CreateBlob(containerClient, name, "text/plain", contents))
+		}
+
+		include, err := ParsePattern("logs/*.json")
+		require.NoError(t, err)
+
+		iterator, err := NewSnapshotIterator(containerClient, position.NewDefaultSnapshotPosition(), 100, Filter{
+			Prefix:  "logs/",
+			Include: include,
+		}, nil, nil)
+		require.NoError(t, err)
+
+		// Let the Goroutine start
+		time.Sleep(time.Millisecond * 500)
+
+		require.True(t, iterator.HasNext(ctx))
+
+		record, err := iterator.Next(ctx)
+		require.NoError(t, err)
+		require.True(t, helper.AssertRecordEquals(t, record, "logs/a.json", "text/plain", contents))
+
+		// Let the Goroutine finish
+		require.NoError(t, iterator.tomb.Wait())
+
+		require.False(t, iterator.HasNext(ctx))
+	})
+
+	t.Run("Lists virtual directories up to the max depth", func(t *testing.T) {
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+
+		contents := fakerInstance.Lorem().Sentence(16)
+
+		for _, name := range []string{"a.txt", "logs/b.txt", "logs/2022/c.txt", "data/d.txt"} {
+			require.NoError(t, helper.CreateBlob(containerClient, name, "text/plain", contents))
+		}
+
+		directories, err := ParsePattern("logs/")
+		require.NoError(t, err)
+
+		iterator, err := NewSnapshotIterator(containerClient, position.NewDefaultSnapshotPosition(), 100, Filter{
+			Delimiter:   "/",
+			Directories: directories,
+			MaxDepth:    2,
+		}, nil, nil)
+		require.NoError(t, err)
+
+		// Let the Goroutine start
+		time.Sleep(time.Millisecond * 500)
+
+		for _, expected := range []struct {
+			name      string
+			directory string
+		}{
+			{name: "a.txt", directory: ""},
+			{name: "logs/b.txt", directory: "logs/"},
+		} {
+			require.True(t, iterator.HasNext(ctx))
+
+			record, err := iterator.Next(ctx)
+			require.NoError(t, err)
+			require.True(t, helper.AssertRecordEquals(t, record, expected.name, "text/plain", contents))
+			require.Equal(t, expected.directory, record.Metadata[MetadataKeyDirectory])
+		}
+
+		// Let the Goroutine finish
+		require.NoError(t, iterator.tomb.Wait())
+
+		require.False(t, iterator.HasNext(ctx))
+	})
+
+	t.Run("Resumes after the last blob read", func(t *testing.T) {
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+
+		contents := map[string]string{
+			"a.txt": fakerInstance.Lorem().Sentence(16),
+			"b.txt": fakerInstance.Lorem().Sentence(16),
+			"c.txt": fakerInstance.Lorem().Sentence(16),
+		}
+
+		for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+			require.NoError(t, helper.CreateBlob(containerClient, name, "text/plain", contents[name]))
+		}
+
+		iterator, err := NewSnapshotIterator(containerClient, position.NewDefaultSnapshotPosition(), 2, Filter{}, nil, nil)
+		require.NoError(t, err)
+
+		// Let the Goroutine start
+		time.Sleep(time.Millisecond * 500)
+
+		var record sdk.Record
+
+		for _, name := range []string{"a.txt", "b.txt"} {
+			require.True(t, iterator.HasNext(ctx))
+
+			record, err = iterator.Next(ctx)
+			require.NoError(t, err)
+			require.True(t, helper.AssertRecordEquals(t, record, name, "text/plain", contents[name]))
+		}
+
+		iterator.Stop()
+
+		interrupted, err := position.NewFromRecordPosition(record.Position)
+		require.NoError(t, err)
+		require.Equal(t, "b.txt", interrupted.Key)
+
+		// The snapshot resumes from the stored position
+		iterator, err = NewSnapshotIterator(containerClient, interrupted, 2, Filter{}, nil, nil)
+		require.NoError(t, err)
+
+		// Let the Goroutine start
+		time.Sleep(time.Millisecond * 500)
+
+		require.True(t, iterator.HasNext(ctx))
+
+		record, err = iterator.Next(ctx)
+		require.NoError(t, err)
+		require.True(t, helper.AssertRecordEquals(t, record, "c.txt", "text/plain", contents["c.txt"]))
+
+		// Let the Goroutine finish
+		require.NoError(t, iterator.tomb.Wait())
+
+		require.False(t, iterator.HasNext(ctx))
+		require.False(t, iterator.maxLastModified.Before(interrupted.Timestamp))
+	})
+
+	t.Run("Resumes hierarchical listing after the last blob read", func(t *testing.T) {
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+
+		contents := fakerInstance.Lorem().Sentence(16)
+
+		for _, name := range []string{"a.txt", "a/b.txt", "a/c/d.txt", "a0.txt", "e/f.txt"} {
+			require.NoError(t, helper.CreateBlob(containerClient, name, "text/plain", contents))
+		}
+
+		iterator, err := NewSnapshotIterator(containerClient, position.NewSnapshotPosition("a/b.txt", time.Time{}), 100, Filter{
+			Delimiter: "/",
+		}, nil, nil)
+		require.NoError(t, err)
+
+		// Let the Goroutine start
+		time.Sleep(time.Millisecond * 500)
+
+		for _, name := range []string{"a/c/d.txt", "a0.txt", "e/f.txt"} {
+			require.True(t, iterator.HasNext(ctx))
+
+			record, err := iterator.Next(ctx)
+			require.NoError(t, err)
+			require.True(t, helper.AssertRecordEquals(t, record, name, "text/plain", contents))
+		}
+
+		// Let the Goroutine finish
+		require.NoError(t, iterator.tomb.Wait())
+
+		require.False(t, iterator.HasNext(ctx))
+	})
+
+	t.Run("Splits blobs into lines and resumes within the blob", func(t *testing.T) {
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+
+		require.NoError(t, helper.CreateBlob(containerClient, "a.ndjson", "application/x-ndjson", "{\"a\":1}\n{\"b\":2}\r\n\n{\"c\":3}"))
+		require.NoError(t, helper.CreateBlob(containerClient, "b.ndjson", "application/x-ndjson", "{\"d\":4}\n"))
+
+		iterator, err := NewSnapshotIterator(containerClient, position.NewDefaultSnapshotPosition(), 100, Filter{}, nil, LinesSplitter{})
+		require.NoError(t, err)
+
+		var record sdk.Record
+
+		for _, expected := range []struct {
+			payload string
+			offset  string
+		}{
+			{payload: `{"a":1}`, offset: "0"},
+			{payload: `{"b":2}`, offset: "8"},
+		} {
+			record, err = iterator.Next(ctx)
+			require.NoError(t, err)
+			require.True(t, helper.AssertRecordEquals(t, record, "a.ndjson", "application/x-ndjson", expected.payload))
+			require.Equal(t, expected.offset, record.Metadata[MetadataKeyOffset])
+		}
+
+		iterator.Stop()
+
+		interrupted, err := position.NewFromRecordPosition(record.Position)
+		require.NoError(t, err)
+		require.Equal(t, "a.ndjson", interrupted.Key)
+		require.NotEmpty(t, interrupted.ETag)
+		require.EqualValues(t, 17, interrupted.Offset)
+
+		// The snapshot resumes with the rest of the blob read partially
+		iterator, err = NewSnapshotIterator(containerClient, interrupted, 100, Filter{}, nil, LinesSplitter{})
+		require.NoError(t, err)
+
+		for _, expected := range [][2]string{{"a.ndjson", `{"c":3}`}, {"b.ndjson", `{"d":4}`}} {
+			record, err = iterator.Next(ctx)
+			require.NoError(t, err)
+			require.True(t, helper.AssertRecordEquals(t, record, expected[0], "application/x-ndjson", expected[1]))
+		}
+
+		// Let the Goroutine finish
+		require.NoError(t, iterator.tomb.Wait())
+
+		require.False(t, iterator.HasNext(ctx))
+	})
+
+	t.Run("Splits CSV blobs into structured rows", func(t *testing.T) {
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+
+		require.NoError(t, helper.CreateBlob(containerClient, "data.csv", "text/csv", "id,name,active\r\n1,a,true\r\n2,b,false\r\n"))
+
+		iterator, err := NewSnapshotIterator(containerClient, position.NewDefaultSnapshotPosition(), 100, Filter{}, nil, CSVSplitter{InferTypes: true})
+		require.NoError(t, err)
+
+		for _, expected := range []sdk.StructuredData{
+			{"id": int64(1), "name": "a", "active": true},
+			{"id": int64(2), "name": "b", "active": false},
+		} {
+			record, err := iterator.Next(ctx)
+			require.NoError(t, err)
+			require.Equal(t, fmt.Sprintf("data.csv#%d", expected["id"]), string(record.Key.Bytes()))
+			require.Equal(t, expected, record.Payload)
+			require.Equal(t, "text/csv", record.Metadata["content-type"])
+		}
+
+		// Let the Goroutine finish
+		require.NoError(t, iterator.tomb.Wait())
+
+		require.False(t, iterator.HasNext(ctx))
+	})
+
+	t.Run("Reads Parquet blobs row by row, resuming within a row group", func(t *testing.T) {
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+
+		type row struct {
+			ID int64 `parquet:"name=id, type=INT64"`
+		}
+
+		var contents bytes.Buffer
+
+		pw, err := writer.NewParquetWriterFromWriter(&contents, new(row), 1)
+		require.NoError(t, err)
+
+		for id := int64(1); id <= 4; id++ {
+			require.NoError(t, pw.Write(row{ID: id}))
+
+			if id%2 == 0 {
+				require.NoError(t, pw.Flush(true))
+			}
+		}
+
+		require.NoError(t, pw.WriteStop())
+		require.NoError(t, helper.CreateBlob(containerClient, "part-00000.parquet", "application/octet-stream", contents.String()))
+
+		iterator, err := NewSnapshotIterator(containerClient, position.NewDefaultSnapshotPosition(), 100, Filter{}, nil, ParquetSplitter{})
+		require.NoError(t, err)
+
+		var last sdk.Record
+
+		for id := int64(1); id <= 3; id++ {
+			last, err = iterator.Next(ctx)
+			require.NoError(t, err)
+			require.Equal(t, fmt.Sprintf("part-00000.parquet#%d", id), string(last.Key.Bytes()))
+			require.Equal(t, sdk.StructuredData{"id": id}, last.Payload)
+		}
+
+		iterator.Stop()
+
+		p, err := position.NewFromRecordPosition(last.Position)
+		require.NoError(t, err)
+		require.Equal(t, int64(3), p.Offset)
+		require.Equal(t, 1, p.RowGroup)
+		require.Equal(t, int64(1), p.Row)
+
+		iterator, err = NewSnapshotIterator(containerClient, p, 100, Filter{}, nil, ParquetSplitter{})
+		require.NoError(t, err)
+
+		record, err := iterator.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, sdk.StructuredData{"id": int64(4)}, record.Payload)
+		require.Equal(t, "1", record.Metadata[MetadataKeyRowGroup])
+		require.Equal(t, "1", record.Metadata[MetadataKeyRow])
+
+		// Let the Goroutine finish
+		require.NoError(t, iterator.tomb.Wait())
+
+		require.False(t, iterator.HasNext(ctx))
+	})
+
+	t.Run("Reads Avro blobs datum by datum, resuming within a block", func(t *testing.T) {
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+
+		var contents bytes.Buffer
+
+		ocfWriter, err := goavro.NewOCFWriter(goavro.OCFConfig{
+			W:               &contents,
+			Schema:          `{"type": "record", "name": "User", "fields": [{"name": "id", "type": "long"}]}`,
+			CompressionName: goavro.CompressionDeflateLabel,
+		})
+		require.NoError(t, err)
+		require.NoError(t, ocfWriter.Append([]interface{}{map[string]interface{}{"id": 1}, map[string]interface{}{"id": 2}}))
+		require.NoError(t, ocfWriter.Append([]interface{}{map[string]interface{}{"id": 3}}))
+		require.NoError(t, helper.CreateBlob(containerClient, "users.avro", "application/avro", contents.String()))
+
+		iterator, err := NewSnapshotIterator(containerClient, position.NewDefaultSnapshotPosition(), 100, Filter{}, nil, AvroSplitter{})
+		require.NoError(t, err)
+
+		record, err := iterator.Next(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "users.avro#1", string(record.Key.Bytes()))
+		require.Equal(t, sdk.StructuredData{"id": int64(1)}, record.Payload)
+		require.NotEmpty(t, record.Metadata[MetadataKeyAvroSchemaFingerprint])
+
+		iterator.Stop()
+
+		p, err := position.NewFromRecordPosition(record.Position)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), p.Row)
+
+		iterator, err = NewSnapshotIterator(containerClient, p, 100, Filter{}, nil, AvroSplitter{})
+		require.NoError(t, err)
+
+		for id := int64(2); id <= 3; id++ {
+			record, err := iterator.Next(ctx)
+			require.NoError(t, err)
+			require.Equal(t, fmt.Sprintf("users.avro#%d", id), string(record.Key.Bytes()))
+			require.Equal(t, sdk.StructuredData{"id": id}, record.Payload)
+		}
+
+		// Let the Goroutine finish
+		require.NoError(t, iterator.tomb.Wait())
+
+		require.False(t, iterator.HasNext(ctx))
+	})
+
+	t.Run("Decodes JSON blobs into structured payloads", func(t *testing.T) {
+		ctx := context.Background()
+		containerClient := helper.PrepareContainer(t, azureBlobServiceClient, containerName)
+
+		require.NoError(t, helper.CreateBlob(containerClient, "a.json", "application/json", `[{"id":1},{"id":2}]`))
+		require.NoError(t, helper.CreateBlob(containerClient, "b.txt", "text/plain", `{"id":3}`))
+
+		iterator, err := NewSnapshotIterator(containerClient, position.NewDefaultSnapshotPosition(), 100, Filter{}, nil, JSONSplitter{})
+		require.NoError(t, err)
+
+		for _, expected := range []sdk.StructuredData{{"id": 1.0}, {"id": 2.0}} {
+			record, err := iterator.Next(ctx)
+			require.NoError(t, err)
+			require.Equal(t, fmt.Sprintf("a.json#%.0f", expected["id"]), string(record.Key.Bytes()))
+			require.Equal(t, expected, record.Payload)
+		}
+
+		// Blobs of other content types are read as they are
+		record, err := iterator.Next(ctx)
+		require.NoError(t, err)
+		require.True(t, helper.AssertRecordEquals(t, record, "b.txt", "text/plain", `{"id":3}`))
+
+		// Let the Goroutine finish
+		require.NoError(t, iterator.tomb.Wait())
+
+		require.False(t, iterator.HasNext(ctx))
+	})
 }
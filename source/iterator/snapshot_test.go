@@ -25,7 +25,7 @@ import (
 
 func TestNewSnapshotIterator(t *testing.T) {
 	t.Run("Fail to create iterator with Max Results less than 1", func(t *testing.T) {
-		iterator, err := NewSnapshotIterator(nil, position.Position{}, 0)
+		iterator, err := NewSnapshotIterator(nil, position.Position{}, 0, Filter{}, nil, nil)
 		require.Nil(t, iterator)
 		require.EqualError(t, err, "maxResults is expected to be greater than or equal to 1, got 0")
 	})
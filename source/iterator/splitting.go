@@ -0,0 +1,456 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/source/position"
+)
+
+// Record splitting modes, i.e. how the contents of a blob are turned into records.
+const (
+	RecordSplittingNone    = "none"
+	RecordSplittingLines   = "lines"
+	RecordSplittingCSV     = "csv"
+	RecordSplittingParquet = "parquet"
+	RecordSplittingAvro    = "avro"
+)
+
+// MetadataKeyOffset is the key of the record metadata holding the offset the record's part of the blob begins at.
+const MetadataKeyOffset = "offset"
+
+var ErrUnsupportedRecordSplitting = errors.New("unsupported record splitting")
+
+// Part is the piece of the blob's contents read into its own record.
+type Part struct {
+	// Payload is the record's payload
+	Payload sdk.Data
+
+	// Key is the record's key, the blob's name is used when empty
+	Key string
+
+	// Metadata is added to the record's metadata
+	Metadata map[string]string
+
+	// Offset is where the part begins in the blob's contents
+	Offset int64
+
+	// Next is where the following part begins, i.e. where reading the blob resumes after the part
+	Next int64
+
+	// RowGroup is the index of the row group the following part belongs to, in the formats storing rows in groups
+	RowGroup int
+
+	// Row is the index of the following part's row within its row group, in the formats storing rows in groups
+	Row int64
+}
+
+// Splitter splits the contents of a blob into the parts read into records.
+type Splitter interface {
+	// Split reads the contents of the named blob from their beginning, and emits the parts beginning at the offset
+	// or after it.
+	Split(ctx context.Context, name string, r io.Reader, offset int64, emit func(Part) error) error
+}
+
+// contentTypeSplitter is the splitter of the blobs of some content types only, other blobs are read as a whole.
+type contentTypeSplitter interface {
+	Splitter
+
+	// Splits tells whether the blobs of the content type are split.
+	Splits(contentType string) bool
+}
+
+// rangeSplitter is the splitter of the formats read with ranged requests rather than streamed from the beginning,
+// e.g. the ones holding their metadata at the end of the file. Blobs with a Content-Encoding cannot be read in
+// ranges, and they are streamed to the splitter's Split instead.
+type rangeSplitter interface {
+	Splitter
+
+	// SplitRanges reads the contents of the named blob of the given size with the reader, and emits the parts
+	// beginning at the offset or after it.
+	SplitRanges(ctx context.Context, name string, r io.ReaderAt, size, offset int64, emit func(Part) error) error
+}
+
+// offsetSplitter is the splitter of the formats whose parts can be read starting at the offset of any of them,
+// so resumed blobs are downloaded from the offset rather than from their beginning. Blobs with a Content-Encoding
+// cannot be read in ranges, and they are streamed to the splitter's Split instead.
+type offsetSplitter interface {
+	Splitter
+
+	// SplitFrom reads the contents of the named blob starting at the offset, and emits the parts beginning there
+	// or after it.
+	SplitFrom(ctx context.Context, name string, r io.Reader, offset int64, emit func(Part) error) error
+}
+
+// blockSplitter is the splitter of the formats storing the parts in blocks, whose offsets are the offsets of the
+// blocks. Reading is resumed from the block at the offset, after the number of its parts given as the row.
+type blockSplitter interface {
+	Splitter
+
+	// SplitBlocks reads the contents of the named blob from their beginning, and emits the parts of the block at
+	// the offset following the row, and of the blocks after it.
+	SplitBlocks(ctx context.Context, name string, r io.Reader, offset, row int64, emit func(Part) error) error
+}
+
+// SplitterOptions configure the splitters of the modes which need more than the mode's name.
+type SplitterOptions struct {
+	// CSVHeader tells whether the first row of CSV files is the header
+	CSVHeader string
+
+	// CSVDelimiter is the character separating values of CSV files
+	CSVDelimiter rune
+
+	// CSVQuote is the character enclosing values of CSV files
+	CSVQuote rune
+
+	// CSVInferTypes tells whether numbers and booleans of CSV files are converted from text
+	CSVInferTypes bool
+
+	// CSVKeyColumn is the column of CSV files holding the records' keys
+	CSVKeyColumn string
+
+	// DecodeJSON tells whether the JSON blobs read as a whole are decoded into structured data
+	DecodeJSON bool
+
+	// InvalidJSON is how the JSON blobs which cannot be decoded are handled
+	InvalidJSON string
+}
+
+// NewSplitter returns the splitter of the record splitting mode, or nil when the blobs are read as a whole.
+func NewSplitter(mode string, options SplitterOptions) (Splitter, error) {
+	switch mode {
+	case "", RecordSplittingNone:
+		if options.DecodeJSON {
+			return JSONSplitter{Invalid: options.InvalidJSON}, nil
+		}
+
+		return nil, nil
+	case RecordSplittingLines:
+		return LinesSplitter{}, nil
+	case RecordSplittingCSV:
+		return CSVSplitter{
+			Header:     options.CSVHeader,
+			Delimiter:  options.CSVDelimiter,
+			Quote:      options.CSVQuote,
+			InferTypes: options.CSVInferTypes,
+			KeyColumn:  options.CSVKeyColumn,
+		}, nil
+	case RecordSplittingParquet:
+		return ParquetSplitter{}, nil
+	case RecordSplittingAvro:
+		return AvroSplitter{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedRecordSplitting, mode)
+	}
+}
+
+// LinesSplitter splits the contents into the lines terminated with "\n" or "\r\n", skipping the empty ones.
+// Offsets are the numbers of bytes preceding the lines.
+type LinesSplitter struct{}
+
+func (s LinesSplitter) Split(ctx context.Context, name string, r io.Reader, offset int64, emit func(Part) error) error {
+	// The lines read before are not scanned again
+	if _, err := io.CopyN(io.Discard, r, offset); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		return err
+	}
+
+	return s.SplitFrom(ctx, name, r, offset, emit)
+}
+
+func (LinesSplitter) SplitFrom(_ context.Context, _ string, r io.Reader, offset int64, emit func(Part) error) error {
+	reader := bufio.NewReader(r)
+
+	for next := offset; ; {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		begin := next
+		next += int64(len(line))
+
+		if payload := bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r")); len(payload) > 0 {
+			if err := emit(Part{Payload: sdk.RawData(payload), Offset: begin, Next: next}); err != nil {
+				return err
+			}
+		}
+
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+// emitBlob downloads the blob and emits its contents with emitContents. The position's ETag is set to the version
+// of the blob downloaded, and the contents are read from the resumed position when it points into that version.
+// Blobs resumed by offset splitters are downloaded from the resumed offset, unless they changed meanwhile, and
+// blobs of range splitters are read with ranged requests only, once their properties are read.
+// The record's content type is set to the downloaded blob's one.
+func emitBlob(
+	ctx context.Context,
+	client *azblob.BlobClient,
+	splitter Splitter,
+	resume position.Position,
+	record sdk.Record,
+	p position.Position,
+	emit func(sdk.Record) error,
+) error {
+	if s, ok := splitter.(offsetSplitter); ok && resume.Offset > 0 && resume.Key == p.Key && resume.ETag != "" {
+		done, err := emitFromOffset(ctx, client, s, resume, record, p, emit)
+		if done || err != nil {
+			return err
+		}
+	}
+
+	if s, ok := splitter.(rangeSplitter); ok {
+		done, err := emitRanges(ctx, client, s, resume, record, p, emit)
+		if done || err != nil {
+			return err
+		}
+	}
+
+	object, err := client.Download(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	p.ETag = downloadETag(object)
+	p.Offset, p.Row = resumeFrom(resume, p.Key, p.ETag)
+
+	setContentType(record.Metadata, object.ContentType)
+
+	return emitContents(ctx, object, splitter, record, p, emit)
+}
+
+// emitRanges reads the blob's size and ETag, and emits the parts read with ranged requests conditioned on the ETag.
+// It tells whether the blob was read, i.e. whether it is not encoded, so it can be read in ranges.
+func emitRanges(
+	ctx context.Context,
+	client *azblob.BlobClient,
+	splitter rangeSplitter,
+	resume position.Position,
+	record sdk.Record,
+	p position.Position,
+	emit func(sdk.Record) error,
+) (bool, error) {
+	props, err := client.GetProperties(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	if props.ContentEncoding != nil && *props.ContentEncoding != "" {
+		return false, nil
+	}
+
+	var size int64
+	if props.ContentLength != nil {
+		size = *props.ContentLength
+	}
+
+	if props.ETag != nil {
+		p.ETag = *props.ETag
+	}
+	p.Offset, p.Row = resumeFrom(resume, p.Key, p.ETag)
+
+	setContentType(record.Metadata, props.ContentType)
+
+	r := rangeReader{ctx: ctx, client: client, etag: p.ETag}
+
+	if err := splitter.SplitRanges(ctx, p.Key, r, size, p.Offset, partEmitter(record, p, emit)); err != nil {
+		return true, fmt.Errorf("could not split the blob %q: %w", p.Key, err)
+	}
+
+	return true, nil
+}
+
+// emitFromOffset downloads the resumed version of the blob from the resumed offset, and emits the parts read
+// from there. It tells whether the blob was read, i.e. whether it still has the resumed version, and it is not
+// encoded, so the offset can be downloaded from.
+func emitFromOffset(
+	ctx context.Context,
+	client *azblob.BlobClient,
+	splitter offsetSplitter,
+	resume position.Position,
+	record sdk.Record,
+	p position.Position,
+	emit func(sdk.Record) error,
+) (bool, error) {
+	object, err := client.Download(ctx, &azblob.BlobDownloadOptions{
+		Offset: to.Ptr(resume.Offset),
+		BlobAccessConditions: &azblob.BlobAccessConditions{
+			ModifiedAccessConditions: &azblob.ModifiedAccessConditions{IfMatch: to.Ptr(resume.ETag)},
+		},
+	})
+
+	var storageErr *azblob.StorageError
+	if errors.As(err, &storageErr) {
+		switch storageErr.ErrorCode {
+		case azblob.StorageErrorCodeInvalidRange:
+			// The blob was read up to its end
+			return true, nil
+		case azblob.StorageErrorCodeConditionNotMet:
+			// The blob changed, so it is read again from its beginning
+			return false, nil
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+
+	body := object.Body(&azblob.RetryReaderOptions{MaxRetryRequests: 0})
+	defer body.Close()
+
+	if contentEncoding(object) != "" {
+		return false, nil
+	}
+
+	p.ETag, p.Offset, p.Row = resume.ETag, resume.Offset, resume.Row
+
+	setContentType(record.Metadata, object.ContentType)
+
+	if err := splitter.SplitFrom(ctx, p.Key, body, p.Offset, partEmitter(record, p, emit)); err != nil {
+		return true, fmt.Errorf("could not split the blob %q: %w", p.Key, err)
+	}
+
+	return true, nil
+}
+
+// setContentType sets the content type of the record to the blob's one, when known.
+func setContentType(metadata map[string]string, contentType *string) {
+	if contentType != nil {
+		metadata["content-type"] = *contentType
+	}
+}
+
+// emitContents emits the record with the downloaded blob's contents or, with the splitter set, the records of
+// the parts beginning at the position's offset or after it. The position of each part's record holds the offset
+// of the following part, so reading the blob can be resumed after the record.
+func emitContents(
+	ctx context.Context,
+	object azblob.BlobDownloadResponse,
+	splitter Splitter,
+	record sdk.Record,
+	p position.Position,
+	emit func(sdk.Record) error,
+) error {
+	if s, ok := splitter.(contentTypeSplitter); ok && !s.Splits(record.Metadata["content-type"]) {
+		splitter = nil
+	}
+
+	if splitter == nil {
+		rawBody, err := readContents(object)
+		if err != nil {
+			return err
+		}
+
+		if record.Position, err = p.ToRecordPosition(); err != nil {
+			return err
+		}
+
+		record.Payload = sdk.RawData(rawBody)
+
+		return emit(record)
+	}
+
+	emitPart := partEmitter(record, p, emit)
+
+	contents, err := openContents(object)
+	if err != nil {
+		return err
+	}
+	defer contents.Close()
+
+	if s, ok := splitter.(blockSplitter); ok {
+		err = s.SplitBlocks(ctx, p.Key, contents, p.Offset, p.Row, emitPart)
+	} else {
+		err = splitter.Split(ctx, p.Key, contents, p.Offset, emitPart)
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not split the blob %q: %w", p.Key, err)
+	}
+
+	return nil
+}
+
+// partEmitter returns the function emitting the records of the blob's parts, with the positions holding
+// the offsets of the following parts.
+func partEmitter(record sdk.Record, p position.Position, emit func(sdk.Record) error) func(Part) error {
+	return func(part Part) error {
+		partPosition := p
+		partPosition.Offset = part.Next
+		partPosition.RowGroup = part.RowGroup
+		partPosition.Row = part.Row
+
+		recordPosition, err := partPosition.ToRecordPosition()
+		if err != nil {
+			return err
+		}
+
+		output := record
+		output.Position = recordPosition
+		output.Payload = part.Payload
+
+		if part.Key != "" {
+			output.Key = sdk.RawData(part.Key)
+		}
+		output.Metadata = make(map[string]string, len(record.Metadata)+len(part.Metadata)+1)
+
+		for key, value := range record.Metadata {
+			output.Metadata[key] = value
+		}
+
+		for key, value := range part.Metadata {
+			output.Metadata[key] = value
+		}
+
+		output.Metadata[MetadataKeyOffset] = strconv.FormatInt(part.Offset, 10)
+
+		return emit(output)
+	}
+}
+
+// resumeFrom returns the offset and the row the blob's contents are read from, i.e. the resumed position's ones
+// when it points into the same version of the blob, and zeros otherwise.
+func resumeFrom(resume position.Position, name, etag string) (offset, row int64) {
+	if resume.Offset > 0 && resume.Key == name && etag != "" && resume.ETag == etag {
+		return resume.Offset, resume.Row
+	}
+
+	return 0, 0
+}
+
+// downloadETag returns the downloaded blob's ETag, or an empty string when unknown.
+func downloadETag(object azblob.BlobDownloadResponse) string {
+	if object.ETag == nil {
+		return ""
+	}
+
+	return *object.ETag
+}
@@ -0,0 +1,336 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package iterator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/internal/compression"
+	"github.com/miquido/conduit-connector-azure-storage/source/position"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSplitter(t *testing.T) {
+	t.Run("Blobs are read as a whole", func(t *testing.T) {
+		for _, mode := range []string{"", RecordSplittingNone} {
+			splitter, err := NewSplitter(mode, SplitterOptions{})
+			require.NoError(t, err)
+			require.Nil(t, splitter)
+		}
+	})
+
+	t.Run("JSON blobs are decoded", func(t *testing.T) {
+		splitter, err := NewSplitter(RecordSplittingNone, SplitterOptions{DecodeJSON: true, InvalidJSON: InvalidJSONSkip})
+		require.NoError(t, err)
+		require.Equal(t, JSONSplitter{Invalid: InvalidJSONSkip}, splitter)
+	})
+
+	t.Run("Blobs are split into lines", func(t *testing.T) {
+		splitter, err := NewSplitter(RecordSplittingLines, SplitterOptions{})
+		require.NoError(t, err)
+		require.Equal(t, LinesSplitter{}, splitter)
+	})
+
+	t.Run("Blobs are read as Parquet files", func(t *testing.T) {
+		splitter, err := NewSplitter(RecordSplittingParquet, SplitterOptions{})
+		require.NoError(t, err)
+		require.Equal(t, ParquetSplitter{}, splitter)
+	})
+
+	t.Run("Blobs are read as Avro files", func(t *testing.T) {
+		splitter, err := NewSplitter(RecordSplittingAvro, SplitterOptions{})
+		require.NoError(t, err)
+		require.Equal(t, AvroSplitter{}, splitter)
+	})
+
+	t.Run("Fails with unsupported mode", func(t *testing.T) {
+		_, err := NewSplitter("words", SplitterOptions{})
+		require.ErrorIs(t, err, ErrUnsupportedRecordSplitting)
+	})
+}
+
+func TestLinesSplitter_Split(t *testing.T) {
+	contents := "{\"a\":1}\n\n{\"b\":2}\r\n{\"c\":3}"
+
+	for _, tt := range []struct {
+		name     string
+		offset   int64
+		expected []Part
+	}{
+		{
+			name:   "Splits the contents from the beginning",
+			offset: 0,
+			expected: []Part{
+				{Payload: sdk.RawData(`{"a":1}`), Offset: 0, Next: 8},
+				{Payload: sdk.RawData(`{"b":2}`), Offset: 9, Next: 18},
+				{Payload: sdk.RawData(`{"c":3}`), Offset: 18, Next: 25},
+			},
+		},
+		{
+			name:   "Resumes after the part read",
+			offset: 18,
+			expected: []Part{
+				{Payload: sdk.RawData(`{"c":3}`), Offset: 18, Next: 25},
+			},
+		},
+		{
+			name:     "Resumes after the last part",
+			offset:   25,
+			expected: nil,
+		},
+		{
+			name:     "Resumes after the end of changed contents",
+			offset:   100,
+			expected: nil,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var parts []Part
+
+			err := LinesSplitter{}.Split(context.Background(), "a.ndjson", strings.NewReader(contents), tt.offset, func(part Part) error {
+				parts = append(parts, part)
+
+				return nil
+			})
+			require.NoError(t, err)
+
+			require.Len(t, parts, len(tt.expected))
+
+			for i, part := range parts {
+				require.Equal(t, tt.expected[i].Payload.Bytes(), part.Payload.Bytes())
+				require.Equal(t, tt.expected[i].Offset, part.Offset)
+				require.Equal(t, tt.expected[i].Next, part.Next)
+			}
+		})
+	}
+
+	t.Run("Stops when the part cannot be emitted", func(t *testing.T) {
+		stopped := errors.New("stopped")
+		calls := 0
+
+		err := LinesSplitter{}.Split(context.Background(), "a.ndjson", strings.NewReader(contents), 0, func(part Part) error {
+			calls++
+
+			return stopped
+		})
+		require.ErrorIs(t, err, stopped)
+		require.Equal(t, 1, calls)
+	})
+}
+
+func TestResumeFrom(t *testing.T) {
+	resume := position.NewSnapshotPosition("a.avro", time.Time{})
+	resume.ETag = "0x1"
+	resume.Offset = 42
+	resume.Row = 3
+
+	resumeOffset := func(name, etag string) int64 {
+		offset, _ := resumeFrom(resume, name, etag)
+
+		return offset
+	}
+
+	offset, row := resumeFrom(resume, "a.avro", "0x1")
+	require.EqualValues(t, 42, offset)
+	require.EqualValues(t, 3, row)
+	require.Zero(t, resumeOffset("a.avro", "0x2"), "blob was changed since")
+	require.Zero(t, resumeOffset("b.avro", "0x1"), "another blob")
+	require.Zero(t, resumeOffset("a.avro", ""), "unknown version")
+}
+
+func TestEmitBlob(t *testing.T) {
+	ctx := context.Background()
+	contents := "{\"a\":1}\n\n{\"b\":2}\r\n{\"c\":3}"
+
+	resume := position.NewSnapshotPosition("a.ndjson", time.Time{})
+	resume.ETag = "0x1"
+	resume.Offset = 18
+
+	emitAll := func(t *testing.T, transport *blobTransport, splitter Splitter, resume position.Position) []sdk.Record {
+		client, err := azblob.NewBlobClientWithNoCredential("https://example.com/container/a.ndjson", &azblob.ClientOptions{
+			Transport: transport,
+			Retry:     policy.RetryOptions{MaxRetries: -1},
+		})
+		require.NoError(t, err)
+
+		var records []sdk.Record
+
+		record := sdk.Record{Metadata: map[string]string{"content-type": "text/plain"}, Key: sdk.RawData("a.ndjson")}
+		p := position.NewSnapshotPosition("a.ndjson", time.Time{})
+
+		require.NoError(t, emitBlob(ctx, client, splitter, resume, record, p, func(record sdk.Record) error {
+			records = append(records, record)
+
+			return nil
+		}))
+
+		return records
+	}
+
+	t.Run("Downloads the resumed blob from the offset", func(t *testing.T) {
+		transport := &blobTransport{contents: []byte(contents), etag: "0x1"}
+
+		records := emitAll(t, transport, LinesSplitter{}, resume)
+
+		require.Len(t, transport.requests, 1)
+		require.Equal(t, "bytes=18-", transport.requests[0].Header.Get("x-ms-range"))
+		require.Equal(t, "0x1", transport.requests[0].Header.Get("If-Match"))
+
+		require.Len(t, records, 1)
+		require.Equal(t, `{"c":3}`, string(records[0].Payload.Bytes()))
+		require.Equal(t, "18", records[0].Metadata[MetadataKeyOffset])
+		require.Equal(t, "application/x-ndjson", records[0].Metadata["content-type"])
+
+		p, err := position.NewFromRecordPosition(records[0].Position)
+		require.NoError(t, err)
+		require.EqualValues(t, 25, p.Offset)
+		require.Equal(t, "0x1", p.ETag)
+	})
+
+	t.Run("Emits nothing when the resumed blob was read up to its end", func(t *testing.T) {
+		transport := &blobTransport{contents: []byte(contents), etag: "0x1"}
+
+		resume := resume
+		resume.Offset = 25
+
+		require.Empty(t, emitAll(t, transport, LinesSplitter{}, resume))
+		require.Len(t, transport.requests, 1)
+	})
+
+	t.Run("Downloads the changed blob from the beginning", func(t *testing.T) {
+		transport := &blobTransport{contents: []byte(contents), etag: "0x2"}
+
+		records := emitAll(t, transport, LinesSplitter{}, resume)
+
+		require.Len(t, transport.requests, 2)
+		require.Empty(t, transport.requests[1].Header.Get("x-ms-range"))
+		require.Len(t, records, 3)
+	})
+
+	t.Run("Downloads the encoded blob from the beginning", func(t *testing.T) {
+		compressed, err := compression.Compress([]byte(contents), compression.Gzip, compression.DefaultLevel)
+		require.NoError(t, err)
+
+		transport := &blobTransport{contents: compressed, etag: "0x1", encoding: compression.ContentEncoding(compression.Gzip)}
+
+		records := emitAll(t, transport, LinesSplitter{}, resume)
+
+		require.Len(t, transport.requests, 2)
+		require.Empty(t, transport.requests[1].Header.Get("x-ms-range"))
+		require.Len(t, records, 1)
+		require.Equal(t, `{"c":3}`, string(records[0].Payload.Bytes()))
+	})
+
+	t.Run("Reads the blob of a range splitter in ranges only", func(t *testing.T) {
+		transport := &blobTransport{contents: []byte(contents), etag: "0x1"}
+
+		records := emitAll(t, transport, firstRangeSplitter{}, position.Position{})
+
+		require.Len(t, transport.requests, 2)
+		require.Equal(t, http.MethodHead, transport.requests[0].Method)
+		require.Equal(t, http.MethodGet, transport.requests[1].Method)
+		require.Equal(t, "bytes=0-6", transport.requests[1].Header.Get("x-ms-range"))
+		require.Equal(t, "0x1", transport.requests[1].Header.Get("If-Match"))
+
+		require.Len(t, records, 1)
+		require.Equal(t, fmt.Sprintf(`{"a":1}/%d`, len(contents)), string(records[0].Payload.Bytes()))
+		require.Equal(t, "application/x-ndjson", records[0].Metadata["content-type"])
+	})
+}
+
+// firstRangeSplitter emits the first 7 bytes of the contents followed by their size.
+type firstRangeSplitter struct{}
+
+func (firstRangeSplitter) Split(context.Context, string, io.Reader, int64, func(Part) error) error {
+	return errors.New("contents are expected to be read in ranges")
+}
+
+func (firstRangeSplitter) SplitRanges(_ context.Context, _ string, r io.ReaderAt, size, _ int64, emit func(Part) error) error {
+	first := make([]byte, 7)
+	if _, err := r.ReadAt(first, 0); err != nil {
+		return err
+	}
+
+	return emit(Part{Payload: sdk.RawData(fmt.Sprintf("%s/%d", first, size))})
+}
+
+// blobTransport serves the blob of given contents, ETag and Content-Encoding, honouring the range and If-Match
+// headers of the requests, which are all kept.
+type blobTransport struct {
+	contents []byte
+	etag     string
+	encoding string
+	requests []*http.Request
+}
+
+func (t *blobTransport) Do(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req)
+
+	header := make(http.Header)
+	header.Set("ETag", t.etag)
+	header.Set("Content-Type", "application/x-ndjson")
+	if t.encoding != "" {
+		header.Set("Content-Encoding", t.encoding)
+	}
+
+	if ifMatch := req.Header.Get("If-Match"); ifMatch != "" && ifMatch != t.etag {
+		header.Set("x-ms-error-code", string(azblob.StorageErrorCodeConditionNotMet))
+
+		return &http.Response{StatusCode: http.StatusPreconditionFailed, Header: header, Body: http.NoBody, Request: req}, nil
+	}
+
+	status, body := http.StatusOK, t.contents
+
+	if r := req.Header.Get("x-ms-range"); r != "" {
+		offset, end := int64(0), int64(len(t.contents)-1)
+		if _, err := fmt.Sscanf(r, "bytes=%d-%d", &offset, &end); err != nil && !strings.HasSuffix(r, "-") {
+			return nil, err
+		}
+
+		if offset >= int64(len(t.contents)) {
+			header.Set("x-ms-error-code", string(azblob.StorageErrorCodeInvalidRange))
+
+			return &http.Response{StatusCode: http.StatusRequestedRangeNotSatisfiable, Header: header, Body: http.NoBody, Request: req}, nil
+		}
+
+		if end >= int64(len(t.contents)) {
+			end = int64(len(t.contents) - 1)
+		}
+
+		status, body = http.StatusPartialContent, t.contents[offset:end+1]
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	if req.Method == http.MethodHead {
+		body = nil
+	}
+
+	return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(bytes.NewReader(body)), Request: req}, nil
+}
@@ -0,0 +1,399 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iterator
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/source/position"
+)
+
+// maxStateStringLength is the length of the names and ETags stored in the state file above which the file is
+// considered corrupted, as blob names are at most 1024 characters long.
+const maxStateStringLength = 1 << 14
+
+// StatePath returns the path of the file in the directory holding the state of the container with given URL.
+func StatePath(directory, containerURL string) string {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(containerURL))
+
+	return filepath.Join(directory, fmt.Sprintf("%016x.state", hash.Sum64()))
+}
+
+// ListingState is the listing of the container's blobs stored in the state file, compared with the following
+// listing to detect the deleted blobs. Listings of the snapshot and the polls replace the stored one only once
+// the last record emitted while listing is acknowledged, so the records not acknowledged yet are emitted again
+// after a restart. Until then, the listings are kept pending, and the newest one is compared with.
+type ListingState struct {
+	path string
+
+	lock    sync.Mutex
+	pending []pendingListing
+	acked   *position.Position
+}
+
+// pendingListing is the file of the listing waiting for the record at the position to be acknowledged.
+type pendingListing struct {
+	path     string
+	position position.Position
+}
+
+// NewListingState creates the state stored in the file at the path.
+func NewListingState(path string) *ListingState {
+	return &ListingState{path: path}
+}
+
+// diff compares the listing with the newest one, pending or stored.
+func (s *ListingState) diff() (*stateDiff, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	previous := s.path
+	if len(s.pending) > 0 {
+		previous = s.pending[len(s.pending)-1].path
+	}
+
+	return openStateDiff(previous, s.path)
+}
+
+// save keeps the listing compared by the diff pending until the record at the last position, the last one emitted
+// while listing, is acknowledged. Without a last position, the listing waits for the pending listing it replaces,
+// or is stored right away when there is none.
+func (s *ListingState) save(d *stateDiff, last sdk.Position) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if last == nil && len(s.pending) == 0 {
+		return d.Commit()
+	}
+
+	name, err := d.save()
+	if err != nil {
+		return err
+	}
+
+	if last == nil {
+		// The listing is newer than the pending one, waiting for the same record
+		previous := s.pending[len(s.pending)-1]
+		_ = os.Remove(previous.path)
+
+		s.pending[len(s.pending)-1].path = name
+
+		return nil
+	}
+
+	p, err := position.NewFromRecordPosition(last)
+	if err != nil {
+		_ = os.Remove(name)
+
+		return err
+	}
+
+	s.pending = append(s.pending, pendingListing{path: name, position: p})
+
+	// The record may be acknowledged before the listing ends
+	if s.acked != nil && samePosition(*s.acked, p) {
+		return s.store(len(s.pending) - 1)
+	}
+
+	return nil
+}
+
+// Ack stores the newest listing whose last record is the one at the position, discarding the older ones.
+func (s *ListingState) Ack(p position.Position) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.acked = &p
+
+	for i := len(s.pending) - 1; i >= 0; i-- {
+		if samePosition(s.pending[i].position, p) {
+			return s.store(i)
+		}
+	}
+
+	return nil
+}
+
+// store replaces the stored listing with the pending one at the index, discarding the older ones.
+// It is expected to be called with the lock held.
+func (s *ListingState) store(index int) error {
+	if err := os.Rename(s.pending[index].path, s.path); err != nil {
+		return fmt.Errorf("could not replace the state: %w", err)
+	}
+
+	for _, older := range s.pending[:index] {
+		_ = os.Remove(older.path)
+	}
+
+	s.pending = s.pending[index+1:]
+
+	return nil
+}
+
+// Close discards the pending listings, keeping the stored one.
+func (s *ListingState) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, pending := range s.pending {
+		_ = os.Remove(pending.path)
+	}
+
+	s.pending = nil
+}
+
+// samePosition indicates whether the positions point at the same record, regardless of the type of the iterator
+// which produced it, as the snapshot's last record is reported with the CDC position.
+func samePosition(a, b position.Position) bool {
+	return a.Key == b.Key &&
+		a.ETag == b.ETag &&
+		a.Timestamp.Equal(b.Timestamp) &&
+		a.Offset == b.Offset &&
+		a.RowGroup == b.RowGroup &&
+		a.Row == b.Row
+}
+
+// stateEntry is the name and the ETag of the blob known from the previous listing.
+type stateEntry struct {
+	name string
+	etag string
+}
+
+// stateDiff compares the blobs of the container, listed in the lexicographical order, with the ones known from
+// the previous listing, whose names and ETags are stored in the state file sorted by name.
+// Both are read in the order of the names, so only a single entry of each is kept in memory.
+// The blobs listed are written to the next state, replacing the previous one once committed.
+type stateDiff struct {
+	path string
+
+	previous     *os.File
+	reader       *bufio.Reader
+	peeked       stateEntry
+	peekedExists bool
+
+	next   *os.File
+	writer *bufio.Writer
+	last   string
+	listed bool
+}
+
+// newStateDiff opens the state file at the path, which is considered empty when it does not exist yet.
+func newStateDiff(path string) (*stateDiff, error) {
+	return openStateDiff(path, path)
+}
+
+// openStateDiff compares the listing with the state stored in the file at the previous path, which is considered
+// empty when it does not exist yet, and writes the next state to replace the one at the path.
+func openStateDiff(previousPath, path string) (*stateDiff, error) {
+	d := &stateDiff{path: path}
+
+	previous, err := os.Open(previousPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		// Nothing was listed yet
+
+	case err != nil:
+		return nil, fmt.Errorf("could not open the state: %w", err)
+
+	default:
+		d.previous = previous
+		d.reader = bufio.NewReader(previous)
+
+		if err := d.peek(); err != nil {
+			d.Close()
+
+			return nil, err
+		}
+	}
+
+	if d.next, err = os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp"); err != nil {
+		d.Close()
+
+		return nil, fmt.Errorf("could not create the state: %w", err)
+	}
+
+	d.writer = bufio.NewWriter(d.next)
+
+	return d, nil
+}
+
+// Visit records the blob listed, calling onDeleted with the names of the known blobs missing from the listing
+// before it, and returns the ETag the blob was known with, if any.
+func (d *stateDiff) Visit(name, etag string, onDeleted func(name string) error) (string, error) {
+	if d.listed && name <= d.last {
+		return "", fmt.Errorf("blob %q was listed out of order, after %q", name, d.last)
+	}
+
+	d.last, d.listed = name, true
+
+	if err := d.writeEntry(stateEntry{name: name, etag: etag}); err != nil {
+		return "", err
+	}
+
+	for d.peekedExists && d.peeked.name < name {
+		if err := onDeleted(d.peeked.name); err != nil {
+			return "", err
+		}
+
+		if err := d.peek(); err != nil {
+			return "", err
+		}
+	}
+
+	if d.peekedExists && d.peeked.name == name {
+		known := d.peeked.etag
+
+		return known, d.peek()
+	}
+
+	return "", nil
+}
+
+// ignoreDeleted is passed to Visit when the listing replaces the previous state without being compared with it.
+func ignoreDeleted(string) error {
+	return nil
+}
+
+// Finish calls onDeleted with the names of the known blobs missing from the end of the listing.
+func (d *stateDiff) Finish(onDeleted func(name string) error) error {
+	for d.peekedExists {
+		if err := onDeleted(d.peeked.name); err != nil {
+			return err
+		}
+
+		if err := d.peek(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Commit replaces the previous state with the blobs listed.
+func (d *stateDiff) Commit() error {
+	name, err := d.save()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(name, d.path); err != nil {
+		_ = os.Remove(name)
+
+		return fmt.Errorf("could not replace the state: %w", err)
+	}
+
+	return nil
+}
+
+// save writes the blobs listed to the file of the next state, without replacing the previous state, and returns
+// the file's path.
+func (d *stateDiff) save() (string, error) {
+	if err := d.writer.Flush(); err != nil {
+		return "", fmt.Errorf("could not write the state: %w", err)
+	}
+
+	if err := d.next.Sync(); err != nil {
+		return "", fmt.Errorf("could not write the state: %w", err)
+	}
+
+	if err := d.next.Close(); err != nil {
+		return "", fmt.Errorf("could not write the state: %w", err)
+	}
+
+	name := d.next.Name()
+	d.next = nil
+
+	return name, nil
+}
+
+// Close releases the files, discarding the next state unless committed.
+func (d *stateDiff) Close() {
+	if d.previous != nil {
+		_ = d.previous.Close()
+		d.previous = nil
+	}
+
+	if d.next != nil {
+		_ = d.next.Close()
+		_ = os.Remove(d.next.Name())
+		d.next = nil
+	}
+}
+
+// peek reads the next entry of the previous state.
+func (d *stateDiff) peek() error {
+	d.peekedExists = false
+
+	name, err := readString(d.reader)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read the state: %w", err)
+	}
+
+	etag, err := readString(d.reader)
+	if err != nil {
+		return fmt.Errorf("could not read the state: %w", err)
+	}
+
+	d.peeked, d.peekedExists = stateEntry{name: name, etag: etag}, true
+
+	return nil
+}
+
+// writeEntry appends the entry to the next state, as the length-prefixed name followed by the length-prefixed ETag.
+func (d *stateDiff) writeEntry(entry stateEntry) error {
+	for _, s := range []string{entry.name, entry.etag} {
+		var length [binary.MaxVarintLen64]byte
+
+		if _, err := d.writer.Write(length[:binary.PutUvarint(length[:], uint64(len(s)))]); err != nil {
+			return fmt.Errorf("could not write the state: %w", err)
+		}
+
+		if _, err := d.writer.WriteString(s); err != nil {
+			return fmt.Errorf("could not write the state: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func readString(r *bufio.Reader) (string, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	if length > maxStateStringLength {
+		return "", fmt.Errorf("corrupted state: entry of %d bytes", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
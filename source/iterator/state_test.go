@@ -0,0 +1,222 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package iterator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/source/position"
+	"github.com/stretchr/testify/require"
+)
+
+// list visits the blobs with the state diff and commits it, returning the names of the deleted blobs and
+// the ETags the blobs were known with.
+func list(t *testing.T, path string, blobs [][2]string) (deleted []string, known []string) {
+	diff, err := newStateDiff(path)
+	require.NoError(t, err)
+
+	defer diff.Close()
+
+	onDeleted := func(name string) error {
+		deleted = append(deleted, name)
+
+		return nil
+	}
+
+	for _, blob := range blobs {
+		etag, err := diff.Visit(blob[0], blob[1], onDeleted)
+		require.NoError(t, err)
+
+		known = append(known, etag)
+	}
+
+	require.NoError(t, diff.Finish(onDeleted))
+	require.NoError(t, diff.Commit())
+
+	return deleted, known
+}
+
+func TestStateDiff(t *testing.T) {
+	t.Run("Reports the blobs missing from the listing", func(t *testing.T) {
+		path := StatePath(t.TempDir(), "http://127.0.0.1:10000/devstoreaccount1/container")
+
+		// Nothing is known before the first listing
+		deleted, known := list(t, path, [][2]string{{"a.txt", "0x1"}, {"b.txt", "0x2"}, {"c\n.txt", "0x3"}, {"d.txt", "0x4"}})
+		require.Empty(t, deleted)
+		require.Equal(t, []string{"", "", "", ""}, known)
+
+		deleted, known = list(t, path, [][2]string{{"b.txt", "0x5"}, {"c\n.txt", "0x3"}, {"e.txt", "0x6"}})
+		require.Equal(t, []string{"a.txt", "d.txt"}, deleted)
+		require.Equal(t, []string{"0x2", "0x3", ""}, known)
+
+		deleted, known = list(t, path, nil)
+		require.Equal(t, []string{"b.txt", "c\n.txt", "e.txt"}, deleted)
+		require.Empty(t, known)
+
+		deleted, _ = list(t, path, nil)
+		require.Empty(t, deleted)
+	})
+
+	t.Run("Keeps the previous state unless committed", func(t *testing.T) {
+		directory := t.TempDir()
+		path := StatePath(directory, "container")
+
+		list(t, path, [][2]string{{"a.txt", "0x1"}})
+
+		diff, err := newStateDiff(path)
+		require.NoError(t, err)
+
+		_, err = diff.Visit("b.txt", "0x2", func(string) error { return nil })
+		require.NoError(t, err)
+
+		diff.Close()
+
+		deleted, known := list(t, path, [][2]string{{"a.txt", "0x1"}})
+		require.Empty(t, deleted)
+		require.Equal(t, []string{"0x1"}, known)
+
+		// Temporary files are removed
+		files, err := os.ReadDir(directory)
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		require.Equal(t, filepath.Base(path), files[0].Name())
+	})
+
+	t.Run("Fails when blobs are listed out of order", func(t *testing.T) {
+		diff, err := newStateDiff(StatePath(t.TempDir(), "container"))
+		require.NoError(t, err)
+
+		defer diff.Close()
+
+		_, err = diff.Visit("b.txt", "0x1", func(string) error { return nil })
+		require.NoError(t, err)
+
+		_, err = diff.Visit("a.txt", "0x2", func(string) error { return nil })
+		require.EqualError(t, err, `blob "a.txt" was listed out of order, after "b.txt"`)
+	})
+}
+
+// listPending visits the blobs with the diff of the listing state and saves it, pending until the record at
+// the position is acknowledged, returning the names of the deleted blobs.
+func listPending(t *testing.T, state *ListingState, blobs []string, last sdk.Position) (deleted []string) {
+	diff, err := state.diff()
+	require.NoError(t, err)
+
+	defer diff.Close()
+
+	onDeleted := func(name string) error {
+		deleted = append(deleted, name)
+
+		return nil
+	}
+
+	for _, blob := range blobs {
+		_, err := diff.Visit(blob, "0x1", onDeleted)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, diff.Finish(onDeleted))
+	require.NoError(t, state.save(diff, last))
+
+	return deleted
+}
+
+// stored returns the names of the blobs stored in the state file, leaving it intact.
+func stored(t *testing.T, path string) (names []string) {
+	diff, err := newStateDiff(path)
+	require.NoError(t, err)
+
+	defer diff.Close()
+
+	require.NoError(t, diff.Finish(func(name string) error {
+		names = append(names, name)
+
+		return nil
+	}))
+
+	return names
+}
+
+func TestListingState(t *testing.T) {
+	recordPosition := func(key string) (position.Position, sdk.Position) {
+		p := position.NewCDCPosition(key, time.Date(2022, 7, 14, 12, 0, 0, 0, time.UTC))
+
+		rp, err := p.ToRecordPosition()
+		require.NoError(t, err)
+
+		return p, rp
+	}
+
+	t.Run("Stores the listing once its last record is acknowledged", func(t *testing.T) {
+		path := StatePath(t.TempDir(), "container")
+		state := NewListingState(path)
+
+		t.Cleanup(state.Close)
+
+		// The listing without records is stored right away
+		require.Empty(t, listPending(t, state, []string{"a.txt", "b.txt"}, nil))
+		require.FileExists(t, path)
+
+		p, rp := recordPosition("a.txt")
+
+		// The newest listing is compared with, though not stored yet
+		require.Equal(t, []string{"a.txt"}, listPending(t, state, []string{"b.txt"}, rp))
+		require.Empty(t, listPending(t, state, []string{"b.txt"}, nil))
+
+		require.Equal(t, []string{"a.txt", "b.txt"}, stored(t, path))
+
+		require.NoError(t, state.Ack(p))
+		require.Equal(t, []string{"b.txt"}, stored(t, path))
+	})
+
+	t.Run("Stores the listing whose last record was acknowledged before it ended", func(t *testing.T) {
+		path := StatePath(t.TempDir(), "container")
+		state := NewListingState(path)
+
+		t.Cleanup(state.Close)
+
+		p, rp := recordPosition("a.txt")
+
+		require.NoError(t, state.Ack(p))
+		require.Empty(t, listPending(t, state, []string{"a.txt"}, rp))
+		require.Equal(t, []string{"a.txt"}, stored(t, path))
+	})
+
+	t.Run("Discards the pending listings when closed", func(t *testing.T) {
+		directory := t.TempDir()
+		state := NewListingState(StatePath(directory, "container"))
+
+		_, rp := recordPosition("a.txt")
+
+		listPending(t, state, []string{"a.txt"}, rp)
+		state.Close()
+
+		files, err := os.ReadDir(directory)
+		require.NoError(t, err)
+		require.Empty(t, files)
+	})
+}
+
+func TestStatePath(t *testing.T) {
+	require.Equal(t, StatePath("state", "http://a/container"), StatePath("state", "http://a/container"))
+	require.NotEqual(t, StatePath("state", "http://a/container"), StatePath("state", "http://b/container"))
+	require.Equal(t, "state", filepath.Dir(StatePath("state", "http://a/container")))
+}
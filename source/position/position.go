@@ -82,6 +82,73 @@ type Position struct {
 
 	// Type represents the type of iterator that produced the record
 	Type Type
+
+	// ETag represents the version of the blob item the record was read from
+	ETag string
+
+	// Offset represents where the part of the blob item's contents following the record begins, when the contents
+	// are split into multiple records
+	Offset int64
+
+	// RowGroup represents the index of the row group holding the row following the record, when the blob item is
+	// a file storing rows in groups, e.g. Parquet
+	RowGroup int
+
+	// Row represents the index of the row following the record within its row group, or within the block at the
+	// offset, when the blob item is a file storing rows in blocks, e.g. Avro
+	Row int64
+
+	// Marker represents the continuation marker of the List Blobs page the snapshot read the blob item from
+	Marker string
+
+	// ChangeFeed represents the position in the change feed the blob item's change was read from
+	ChangeFeed *ChangeFeedCursor
+
+	// Receipt represents the delivery of the event the blob item's change was read from, acknowledged once the
+	// record is
+	Receipt string
+
+	// Container represents the name of the container the blob item belongs to
+	Container string
+
+	// Containers represents the last positions of all the containers read, keyed by the container name
+	Containers map[string]Position
+}
+
+// ChangeFeedCursor represents the position in the change feed: the path of the segment being read, the index of
+// the segment's shard, the path of the shard's chunk file and the number of the chunk's events already read.
+// When the position has an offset, the event whose blob was read partially is not counted as read.
+type ChangeFeedCursor struct {
+	Segment string
+	Shard   int
+	Chunk   string
+	Event   int
+}
+
+// Own returns the position without the positions of the containers.
+func (p Position) Own() Position {
+	p.Container, p.Containers = "", nil
+
+	return p
+}
+
+// ContainerPosition returns the position the given container should be read from.
+// Positions produced before the source supported multiple containers hold no container positions, and they apply
+// to the container when it is the only one read.
+func (p Position) ContainerPosition(container string, single bool) Position {
+	if p.Containers == nil {
+		if single {
+			return p.Own()
+		}
+
+		return NewDefaultSnapshotPosition()
+	}
+
+	if cp, ok := p.Containers[container]; ok {
+		return cp
+	}
+
+	return NewDefaultSnapshotPosition()
 }
 
 // ToRecordPosition converts Position into sdk.Position.
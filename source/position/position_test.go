@@ -140,6 +140,41 @@ func TestPosition_ToRecordPosition(t *testing.T) {
 	})
 }
 
+func TestPosition_ContainerPosition(t *testing.T) {
+	fakerInstance := faker.New()
+
+	cdcPosition := NewCDCPosition(fakerInstance.Lorem().Word(), time.Now())
+
+	t.Run("Legacy Position applies to the only container", func(t *testing.T) {
+		require.True(t, assertPositionsAreEqual(t, cdcPosition, cdcPosition.ContainerPosition("a", true)))
+	})
+
+	t.Run("Legacy Position is ignored for multiple containers", func(t *testing.T) {
+		require.True(t, assertPositionsAreEqual(t, NewDefaultSnapshotPosition(), cdcPosition.ContainerPosition("a", false)))
+	})
+
+	t.Run("Composite Position returns the position of the container", func(t *testing.T) {
+		p := Position{
+			Key:       cdcPosition.Key,
+			Timestamp: cdcPosition.Timestamp,
+			Type:      TypeCDC,
+			Container: "a",
+			Containers: map[string]Position{
+				"a": cdcPosition,
+			},
+		}
+
+		recordPosition, err := p.ToRecordPosition()
+		require.NoError(t, err)
+
+		decoded, err := NewFromRecordPosition(recordPosition)
+		require.NoError(t, err)
+
+		require.True(t, assertPositionsAreEqual(t, cdcPosition, decoded.ContainerPosition("a", false)))
+		require.True(t, assertPositionsAreEqual(t, NewDefaultSnapshotPosition(), decoded.ContainerPosition("b", false)))
+	})
+}
+
 func assertPositionsAreEqual(t *testing.T, expected, actual Position) bool {
 	return assert.Equal(t, expected.Type, actual.Type) &&
 		assert.Equal(t, expected.Key, actual.Key) &&
@@ -0,0 +1,359 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package queue calls the Azure Queue Storage REST API of a single queue, to consume the messages of the blob
+// events routed to it by Event Grid.
+// See: https://docs.microsoft.com/rest/api/storageservices/queue-service-rest-api
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+)
+
+const (
+	// serviceVersion is the version of the REST API called.
+	serviceVersion = "2019-12-12"
+
+	// MaxMessages is the maximum number of messages received at once.
+	MaxMessages = 32
+)
+
+// Error codes of the Queue service.
+const (
+	ErrorCodeQueueNotFound      = "QueueNotFound"
+	ErrorCodeMessageNotFound    = "MessageNotFound"
+	ErrorCodePopReceiptMismatch = "PopReceiptMismatch"
+)
+
+var ErrConnectionString = errors.New("connection string is either blank or malformed")
+
+// StorageError is the error returned by the Queue service.
+type StorageError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *StorageError) Error() string {
+	return fmt.Sprintf("queue service responded with status %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Message is the message received from the queue.
+type Message struct {
+	ID           string `xml:"MessageId"`
+	PopReceipt   string `xml:"PopReceipt"`
+	DequeueCount int64  `xml:"DequeueCount"`
+	Text         string `xml:"MessageText"`
+}
+
+// Client calls the Queue service on behalf of the account, authorized either with the shared key credential or
+// the shared access signature.
+type Client struct {
+	url        *url.URL
+	credential *azblob.SharedKeyCredential
+	httpClient *http.Client
+}
+
+// NewClientFromConnectionString creates the client of the queue named queueName, in the account described by
+// the storage account connection string.
+func NewClientFromConnectionString(connectionString, queueName string) (*Client, error) {
+	values := make(map[string]string)
+
+	for _, part := range strings.Split(strings.TrimRight(connectionString, ";"), ";") {
+		keyValue := strings.SplitN(part, "=", 2)
+		if len(keyValue) != 2 {
+			return nil, ErrConnectionString
+		}
+
+		values[keyValue[0]] = keyValue[1]
+	}
+
+	accountName := values["AccountName"]
+	if accountName == "" {
+		return nil, ErrConnectionString
+	}
+
+	endpoint := values["QueueEndpoint"]
+	if endpoint == "" {
+		protocol, suffix := values["DefaultEndpointsProtocol"], values["EndpointSuffix"]
+		if protocol == "" {
+			protocol = "https"
+		}
+		if suffix == "" {
+			suffix = "core.windows.net"
+		}
+
+		endpoint = fmt.Sprintf("%s://%s.queue.%s", protocol, accountName, suffix)
+	}
+
+	queueURL, err := url.Parse(strings.TrimRight(endpoint, "/") + "/" + url.PathEscape(queueName))
+	if err != nil {
+		return nil, fmt.Errorf("invalid queue endpoint: %w", err)
+	}
+
+	client := &Client{
+		url:        queueURL,
+		httpClient: &http.Client{Timeout: time.Minute},
+	}
+
+	if accountKey, ok := values["AccountKey"]; ok {
+		if client.credential, err = azblob.NewSharedKeyCredential(accountName, accountKey); err != nil {
+			return nil, err
+		}
+	} else if sas, ok := values["SharedAccessSignature"]; ok {
+		client.url.RawQuery = strings.TrimPrefix(sas, "?")
+	} else {
+		return nil, ErrConnectionString
+	}
+
+	return client, nil
+}
+
+// URL returns the URL of the queue, without the shared access signature.
+func (c *Client) URL() string {
+	u := *c.url
+	u.RawQuery = ""
+
+	return u.String()
+}
+
+// GetProperties checks the queue exists and is accessible.
+func (c *Client) GetProperties(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "", url.Values{"comp": {"metadata"}}, nil, nil)
+}
+
+// Create creates the queue, unless it exists already.
+func (c *Client) Create(ctx context.Context) error {
+	return c.do(ctx, http.MethodPut, "", nil, nil, nil)
+}
+
+// Delete deletes the queue with all its messages.
+func (c *Client) Delete(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "", nil, nil, nil)
+}
+
+// PutMessage adds the message with given text to the queue.
+func (c *Client) PutMessage(ctx context.Context, text string) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"QueueMessage"`
+		Text    string   `xml:"MessageText"`
+	}{
+		Text: text,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.do(ctx, http.MethodPost, "/messages", nil, body, nil)
+}
+
+// GetMessages receives up to count messages, which stay invisible to other consumers for the visibilityTimeout,
+// unless deleted meanwhile.
+func (c *Client) GetMessages(ctx context.Context, count int, visibilityTimeout time.Duration) ([]Message, error) {
+	var response struct {
+		Messages []Message `xml:"QueueMessage"`
+	}
+
+	err := c.do(ctx, http.MethodGet, "/messages", url.Values{
+		"numofmessages":     {strconv.Itoa(count)},
+		"visibilitytimeout": {strconv.Itoa(int(visibilityTimeout.Seconds()))},
+	}, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Messages, nil
+}
+
+// DeleteMessage deletes the message received with given pop receipt.
+func (c *Client) DeleteMessage(ctx context.Context, id, popReceipt string) error {
+	return c.do(ctx, http.MethodDelete, "/messages/"+url.PathEscape(id), url.Values{
+		"popreceipt": {popReceipt},
+	}, nil, nil)
+}
+
+// do sends the request to the path relative to the queue URL, and decodes the XML response into out, if any.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body []byte, out interface{}) error {
+	u := *c.url
+	u.Path += path
+
+	if len(query) > 0 {
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+
+		u.RawQuery += query.Encode()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("x-ms-version", serviceVersion)
+	request.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+
+	if len(body) > 0 {
+		request.Header.Set("Content-Type", "application/xml")
+	}
+
+	if c.credential != nil {
+		signature, err := c.credential.ComputeHMACSHA256(c.stringToSign(request))
+		if err != nil {
+			return fmt.Errorf("could not sign the request: %w", err)
+		}
+
+		request.Header.Set("Authorization", fmt.Sprintf("SharedKey %s:%s", c.credential.AccountName(), signature))
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		storageErr := &StorageError{
+			StatusCode: response.StatusCode,
+			Code:       response.Header.Get("x-ms-error-code"),
+		}
+
+		var errorBody struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+
+		if xml.Unmarshal(data, &errorBody) == nil {
+			storageErr.Message = errorBody.Message
+
+			if storageErr.Code == "" {
+				storageErr.Code = errorBody.Code
+			}
+		}
+
+		return storageErr
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+
+	if err := xml.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("could not decode the queue service response: %w", err)
+	}
+
+	return nil
+}
+
+// stringToSign returns the string signed with the Shared Key authorization.
+// See: https://docs.microsoft.com/rest/api/storageservices/authorize-with-shared-key
+func (c *Client) stringToSign(request *http.Request) string {
+	contentLength := ""
+	if request.ContentLength > 0 {
+		contentLength = strconv.FormatInt(request.ContentLength, 10)
+	}
+
+	// Canonicalized headers, the x-ms- ones sorted by name
+	var msHeaders []string
+
+	for name, values := range request.Header {
+		if name = strings.ToLower(name); strings.HasPrefix(name, "x-ms-") {
+			msHeaders = append(msHeaders, name+":"+strings.Join(values, ","))
+		}
+	}
+
+	sort.Strings(msHeaders)
+
+	// Canonicalized resource, followed by the query parameters sorted by name
+	resource := "/" + c.credential.AccountName() + request.URL.EscapedPath()
+
+	query := request.URL.Query()
+	names := make([]string, 0, len(query))
+
+	for name := range query {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		values := query[name]
+		sort.Strings(values)
+
+		resource += "\n" + strings.ToLower(name) + ":" + strings.Join(values, ",")
+	}
+
+	return strings.Join([]string{
+		request.Method,
+		request.Header.Get("Content-Encoding"),
+		request.Header.Get("Content-Language"),
+		contentLength,
+		request.Header.Get("Content-MD5"),
+		request.Header.Get("Content-Type"),
+		"", // The x-ms-date header is used instead
+		request.Header.Get("If-Modified-Since"),
+		request.Header.Get("If-Match"),
+		request.Header.Get("If-None-Match"),
+		request.Header.Get("If-Unmodified-Since"),
+		request.Header.Get("Range"),
+		strings.Join(msHeaders, "\n"),
+		resource,
+	}, "\n")
+}
+
+// IsTransient indicates whether the request failed for a reason likely to pass when retried: the connection
+// failing, the request timing out or being throttled, or the service failing temporarily.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var storageErr *StorageError
+	if errors.As(err, &storageErr) {
+		return storageErr.StatusCode == http.StatusRequestTimeout ||
+			storageErr.StatusCode == http.StatusTooManyRequests ||
+			storageErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	return true
+}
+
+// IsNotFound indicates whether the message, or its queue, does not exist anymore, or was received again meanwhile.
+func IsNotFound(err error) bool {
+	var storageErr *StorageError
+
+	return errors.As(err, &storageErr) &&
+		(storageErr.Code == ErrorCodeMessageNotFound ||
+			storageErr.Code == ErrorCodeQueueNotFound ||
+			storageErr.Code == ErrorCodePopReceiptMismatch)
+}
@@ -0,0 +1,181 @@
+// Copyright © 2022 Meroxa, Inc. and Miquido
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const accountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+func TestNewClientFromConnectionString(t *testing.T) {
+	for _, tt := range []struct {
+		name             string
+		connectionString string
+		url              string
+		error            string
+	}{
+		{
+			name:             "Account's default queue endpoint",
+			connectionString: "DefaultEndpointsProtocol=https;AccountName=account;AccountKey=" + accountKey + ";EndpointSuffix=core.windows.net",
+			url:              "https://account.queue.core.windows.net/events",
+		},
+		{
+			name:             "Explicit queue endpoint",
+			connectionString: "AccountName=devstoreaccount1;AccountKey=" + accountKey + ";BlobEndpoint=http://127.0.0.1:10000/devstoreaccount1;QueueEndpoint=http://127.0.0.1:10001/devstoreaccount1;",
+			url:              "http://127.0.0.1:10001/devstoreaccount1/events",
+		},
+		{
+			name:             "Shared access signature",
+			connectionString: "AccountName=account;SharedAccessSignature=sv=2020-08-04&sig=secret",
+			url:              "https://account.queue.core.windows.net/events",
+		},
+		{
+			name:             "No account name",
+			connectionString: "AccountKey=" + accountKey,
+			error:            ErrConnectionString.Error(),
+		},
+		{
+			name:             "No credential",
+			connectionString: "AccountName=account",
+			error:            ErrConnectionString.Error(),
+		},
+		{
+			name:             "Malformed",
+			connectionString: "AccountName",
+			error:            ErrConnectionString.Error(),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClientFromConnectionString(tt.connectionString, "events")
+
+			if tt.error != "" {
+				require.EqualError(t, err, tt.error)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.url, client.URL())
+		})
+	}
+}
+
+func TestClient(t *testing.T) {
+	var requests []*http.Request
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/messages"):
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?>
+				<QueueMessagesList>
+					<QueueMessage>
+						<MessageId>id-1</MessageId>
+						<PopReceipt>receipt-1</PopReceipt>
+						<DequeueCount>2</DequeueCount>
+						<MessageText>text</MessageText>
+					</QueueMessage>
+				</QueueMessagesList>`))
+
+		case r.Method == http.MethodDelete:
+			w.Header().Set("x-ms-error-code", ErrorCodeMessageNotFound)
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><Error><Code>MessageNotFound</Code><Message>The specified message does not exist.</Message></Error>`))
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	client, err := NewClientFromConnectionString("AccountName=account;AccountKey="+accountKey+";QueueEndpoint="+server.URL+"/account", "events")
+	require.NoError(t, err)
+
+	messages, err := client.GetMessages(context.Background(), MaxMessages, 5*time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []Message{{ID: "id-1", PopReceipt: "receipt-1", DequeueCount: 2, Text: "text"}}, messages)
+
+	require.Equal(t, "/account/events/messages", requests[0].URL.Path)
+	require.Equal(t, "32", requests[0].URL.Query().Get("numofmessages"))
+	require.Equal(t, "300", requests[0].URL.Query().Get("visibilitytimeout"))
+	require.True(t, strings.HasPrefix(requests[0].Header.Get("Authorization"), "SharedKey account:"))
+	require.NotEmpty(t, requests[0].Header.Get("x-ms-date"))
+
+	err = client.DeleteMessage(context.Background(), "id-1", "receipt-1")
+	require.True(t, IsNotFound(err))
+	require.EqualError(t, err, "queue service responded with status 404: MessageNotFound: The specified message does not exist.")
+
+	require.Equal(t, "/account/events/messages/id-1", requests[1].URL.Path)
+	require.Equal(t, "receipt-1", requests[1].URL.Query().Get("popreceipt"))
+}
+
+func TestClient_stringToSign(t *testing.T) {
+	client, err := NewClientFromConnectionString("AccountName=account;AccountKey="+accountKey, "events")
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "https://account.queue.core.windows.net/events/messages?visibilitytimeout=0", strings.NewReader("<QueueMessage/>"))
+	require.NoError(t, err)
+
+	request.Header.Set("Content-Type", "application/xml")
+	request.Header.Set("x-ms-version", serviceVersion)
+	request.Header.Set("x-ms-date", "Mon, 30 May 2022 13:00:00 GMT")
+
+	require.Equal(t, strings.Join([]string{
+		"POST",
+		"",
+		"",
+		"15",
+		"",
+		"application/xml",
+		"",
+		"",
+		"",
+		"",
+		"",
+		"",
+		"x-ms-date:Mon, 30 May 2022 13:00:00 GMT",
+		"x-ms-version:2019-12-12",
+		"/account/events/messages",
+		"visibilitytimeout:0",
+	}, "\n"), client.stringToSign(request))
+}
+
+func TestIsTransient(t *testing.T) {
+	for _, tt := range []struct {
+		err       error
+		transient bool
+	}{
+		{err: errors.New("connection reset by peer"), transient: true},
+		{err: &StorageError{StatusCode: http.StatusServiceUnavailable, Code: "ServerBusy"}, transient: true},
+		{err: &StorageError{StatusCode: http.StatusTooManyRequests}, transient: true},
+		{err: &StorageError{StatusCode: http.StatusRequestTimeout, Code: "OperationTimedOut"}, transient: true},
+		{err: &StorageError{StatusCode: http.StatusNotFound, Code: ErrorCodeQueueNotFound}},
+		{err: &StorageError{StatusCode: http.StatusForbidden, Code: "AuthenticationFailed"}},
+		{err: context.Canceled},
+		{err: nil},
+	} {
+		require.Equal(t, tt.transient, IsTransient(tt.err), "%v", tt.err)
+	}
+}
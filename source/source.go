@@ -19,19 +19,26 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"reflect"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/source/eventgrid"
 	"github.com/miquido/conduit-connector-azure-storage/source/iterator"
 	"github.com/miquido/conduit-connector-azure-storage/source/position"
+	"github.com/miquido/conduit-connector-azure-storage/source/queue"
 )
 
 type Source struct {
 	sdk.UnimplementedSource
 
-	config   Config
-	iterator iterator.Iterator
+	config           Config
+	iterator         iterator.Iterator
+	changeFeedReader *iterator.ChangeFeedReader
+	eventGridServer  *eventgrid.Server
+	queueConsumer    *eventgrid.QueueConsumer
 }
 
 func NewSource() sdk.Source {
@@ -63,16 +70,28 @@ func (s *Source) Open(ctx context.Context, rp sdk.Position) error {
 		return fmt.Errorf("connector open error: could not establish a connection: unexpected response status %d", accountInfo.RawResponse.StatusCode)
 	}
 
-	// Create container client
-	containerClient, err := serviceClient.NewContainerClient(s.config.ContainerName)
+	// Find the containers to monitor
+	containerNames, err := s.containerNames(ctx, serviceClient)
 	if err != nil {
-		return fmt.Errorf("connector open error: could not create container connection client: %w", err)
+		return fmt.Errorf("connector open error: could not list containers: %w", err)
 	}
 
-	// Check if container exists
-	_, err = containerClient.GetProperties(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("connector open error: could not create container connection client: %w", err)
+	containerClients := make(map[string]*azblob.ContainerClient, len(containerNames))
+
+	for _, containerName := range containerNames {
+		// Create container client
+		containerClient, err := serviceClient.NewContainerClient(containerName)
+		if err != nil {
+			return fmt.Errorf("connector open error: could not create container connection client: %w", err)
+		}
+
+		// Check if container exists
+		_, err = containerClient.GetProperties(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("connector open error: could not create container connection client: %w", err)
+		}
+
+		containerClients[containerName] = containerClient
 	}
 
 	// Parse position to start from
@@ -81,16 +100,42 @@ func (s *Source) Open(ctx context.Context, rp sdk.Position) error {
 		return fmt.Errorf("connector open error: invalid or unsupported position: %w", err)
 	}
 
-	// Create container's items iterator
-	s.iterator, err = iterator.NewCombinedIterator(s.config.PollingPeriod, containerClient, s.config.MaxResults, recordPosition)
+	splitter, err := iterator.NewSplitter(s.config.RecordSplitting, s.config.SplitterOptions)
+	if err != nil {
+		return fmt.Errorf("connector open error: %w", err)
+	}
+
+	var stateDirectory string
+
+	if s.config.DeleteDetection == DeleteDetectionStateDiff {
+		stateDirectory = s.config.StateDirectory
+
+		if err := os.MkdirAll(stateDirectory, 0o755); err != nil {
+			return fmt.Errorf("connector open error: could not create the state directory: %w", err)
+		}
+	}
+
+	newCDC, err := s.cdcFactory(ctx, serviceClient, containerNames, splitter)
+	if err != nil {
+		return fmt.Errorf("connector open error: %w", err)
+	}
+
+	// Create containers' items iterator
+	s.iterator, err = iterator.NewMultiIterator(newCDC, containerClients, s.config.MaxResults, s.config.Filter, splitter, stateDirectory, recordPosition)
 	if err != nil {
-		return fmt.Errorf("connector open error: couldn't create a combined iterator: %w", err)
+		return fmt.Errorf("connector open error: couldn't create a multi-container iterator: %w", err)
 	}
 
 	return nil
 }
 
 func (s *Source) Read(ctx context.Context) (sdk.Record, error) {
+	if s.queueConsumer != nil {
+		if err := s.queueConsumer.Err(); err != nil {
+			return sdk.Record{}, fmt.Errorf("read error: %w", err)
+		}
+	}
+
 	if !s.iterator.HasNext(ctx) {
 		return sdk.Record{}, sdk.ErrBackoffRetry
 	}
@@ -113,17 +158,177 @@ func (s *Source) Read(ctx context.Context) (sdk.Record, error) {
 	return record, nil
 }
 
-func (s *Source) Ack(ctx context.Context, position sdk.Position) error {
-	sdk.Logger(ctx).Debug().Str("position", string(position)).Msg("got ack")
+func (s *Source) Ack(ctx context.Context, rp sdk.Position) error {
+	sdk.Logger(ctx).Debug().Str("position", string(rp)).Msg("got ack")
+
+	acknowledger, ok := s.iterator.(iterator.Acknowledger)
+
+	if s.queueConsumer == nil && !ok {
+		return nil // no ack needed
+	}
+
+	p, err := position.NewFromRecordPosition(rp)
+	if err != nil {
+		return fmt.Errorf("ack error: invalid or unsupported position: %w", err)
+	}
+
+	// The listings of the containers are stored once their last records are acknowledged
+	if ok {
+		if err := acknowledger.Ack(ctx, p); err != nil {
+			return fmt.Errorf("ack error: %w", err)
+		}
+	}
+
+	// The queue message the record was read from is deleted once all of its records are acknowledged
+	if s.queueConsumer == nil || p.Receipt == "" {
+		return nil
+	}
+
+	if err := s.queueConsumer.Ack(ctx, p.Receipt); err != nil {
+		return fmt.Errorf("ack error: %w", err)
+	}
 
-	return nil // no ack needed
+	return nil
 }
 
-func (s *Source) Teardown(_ context.Context) error {
+func (s *Source) Teardown(ctx context.Context) error {
 	if s.iterator != nil {
 		s.iterator.Stop()
 		s.iterator = nil
 	}
 
+	if s.changeFeedReader != nil {
+		s.changeFeedReader.Stop()
+		s.changeFeedReader = nil
+	}
+
+	if s.eventGridServer != nil {
+		if err := s.eventGridServer.Shutdown(ctx); err != nil {
+			return fmt.Errorf("could not stop the event grid endpoint: %w", err)
+		}
+
+		s.eventGridServer = nil
+	}
+
+	if s.queueConsumer != nil {
+		s.queueConsumer.Stop()
+		s.queueConsumer = nil
+	}
+
 	return nil
 }
+
+// cdcFactory returns the factory of the iterators detecting changes in the configured CDC mode.
+func (s *Source) cdcFactory(
+	ctx context.Context,
+	serviceClient *azblob.ServiceClient,
+	containerNames []string,
+	splitter iterator.Splitter,
+) (iterator.CDCFactory, error) {
+	switch s.config.CDCMode {
+	case CDCModeChangeFeed:
+		feedClient, err := serviceClient.NewContainerClient(s.config.ChangeFeedContainerName)
+		if err != nil {
+			return nil, fmt.Errorf("could not create change feed container connection client: %w", err)
+		}
+
+		// The change feed container exists only when the change feed is enabled
+		if _, err = feedClient.GetProperties(ctx, nil); err != nil {
+			return nil, fmt.Errorf("could not access the change feed, make sure it is enabled: %w", err)
+		}
+
+		// The change feed logs the changes of all the account's containers, so it is read once for all of them
+		reader, err := iterator.NewChangeFeedReader(feedClient, s.config.PollingPeriod, s.config.MaxResults)
+		if err != nil {
+			return nil, err
+		}
+
+		reader.Start()
+
+		s.changeFeedReader = reader
+
+		return iterator.ChangeFeedCDC(reader, splitter), nil
+
+	case CDCModeEventGrid:
+		server := eventgrid.NewServer(s.config.EventGridAddress, s.config.EventGridSecret)
+
+		// Events of the containers are delivered again while the containers are still in the snapshot
+		server.Expect(containerNames...)
+
+		if err := server.Start(); err != nil {
+			return nil, fmt.Errorf("could not start the event grid endpoint: %w", err)
+		}
+
+		s.eventGridServer = server
+
+		return iterator.EventGridCDC(server, splitter), nil
+
+	case CDCModeStorageQueue:
+		queueClient, err := queue.NewClientFromConnectionString(s.config.ConnectionString, s.config.QueueName)
+		if err != nil {
+			return nil, fmt.Errorf("could not create queue connection client: %w", err)
+		}
+
+		// Check if queue exists
+		if err := queueClient.GetProperties(ctx); err != nil {
+			return nil, fmt.Errorf("could not access the queue %q: %w", s.config.QueueName, err)
+		}
+
+		s.queueConsumer = eventgrid.NewQueueConsumer(queueClient, s.config.PollingPeriod, s.config.QueueVisibilityTimeout)
+
+		// Messages with events of the containers are received again while the containers are still in the snapshot
+		s.queueConsumer.Expect(containerNames...)
+
+		s.queueConsumer.Start()
+
+		return iterator.EventGridCDC(s.queueConsumer, splitter), nil
+
+	default:
+		return iterator.PollingCDC(s.config.PollingPeriod, splitter), nil
+	}
+}
+
+// containerNames returns the names of the configured containers, followed by the names of all the account's
+// containers matching the configured pattern. System containers, named with the `$` prefix, e.g. `$logs`, are read
+// only when configured explicitly.
+func (s *Source) containerNames(ctx context.Context, serviceClient *azblob.ServiceClient) ([]string, error) {
+	names := append([]string{}, s.config.ContainerNames...)
+
+	if s.config.ContainerPattern == nil {
+		return names, nil
+	}
+
+	pager := serviceClient.ListContainers(nil)
+
+	for pager.NextPage(ctx) {
+		for _, item := range pager.PageResponse().ContainerItems {
+			if strings.HasPrefix(*item.Name, "$") {
+				continue
+			}
+
+			if s.config.ContainerPattern.Match(*item.Name) && !contains(names, *item.Name) {
+				names = append(names, *item.Name)
+			}
+		}
+	}
+
+	if err := pager.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no containers match %q", s.config.ContainerPattern)
+	}
+
+	return names, nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -18,11 +18,13 @@ package source
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	sdk "github.com/conduitio/conduit-connector-sdk"
 	"github.com/jaswdr/faker"
+	"github.com/miquido/conduit-connector-azure-storage/source/iterator"
 	"github.com/miquido/conduit-connector-azure-storage/source/position"
 	helper "github.com/miquido/conduit-connector-azure-storage/test"
 	"github.com/stretchr/testify/require"
@@ -319,3 +321,134 @@ func TestSource_CDCIteratorOmitsAlreadyReadItems(t *testing.T) {
 	require.Equal(t, sdk.Record{}, record2)
 	require.ErrorIs(t, err, sdk.ErrBackoffRetry)
 }
+
+func TestSource_ReadsContainersMatchingPattern(t *testing.T) {
+	ctx := context.Background()
+	fakerInstance := faker.New()
+
+	var (
+		cfgRaw = map[string]string{
+			ConfigKeyConnectionString:     helper.GetConnectionString(),
+			ConfigKeyContainerNamePattern: "source-integration-multi-*",
+			ConfigKeyPollingPeriod:        "100ms",
+			ConfigKeyMaxResults:           "100",
+		}
+
+		contents = map[string]string{
+			"source-integration-multi-a": fakerInstance.Lorem().Sentence(16),
+			"source-integration-multi-b": fakerInstance.Lorem().Sentence(16),
+		}
+	)
+
+	for containerName, blobContents := range contents {
+		containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+
+		require.NoError(t, helper.CreateBlob(containerClient, "blob.txt", "text/plain", blobContents))
+	}
+
+	src := NewSource().(*Source)
+
+	require.NoError(t, src.Configure(ctx, cfgRaw))
+	require.NoError(t, src.Open(ctx, nil))
+
+	t.Cleanup(func() {
+		_ = src.Teardown(ctx)
+	})
+
+	time.Sleep(time.Second)
+
+	positions := make(map[string]position.Position)
+
+	for _, containerName := range []string{"source-integration-multi-a", "source-integration-multi-b"} {
+		record, err := src.Read(ctx)
+		require.NoError(t, err)
+		require.True(t, helper.AssertRecordEquals(t, record, "blob.txt", "text/plain", contents[containerName]))
+		require.Equal(t, containerName, record.Metadata[iterator.MetadataKeyContainer])
+		require.NoError(t, src.Ack(ctx, record.Position))
+
+		p, err := position.NewFromRecordPosition(record.Position)
+		require.NoError(t, err)
+		require.Equal(t, containerName, p.Container)
+
+		positions = p.Containers
+	}
+
+	// The last record's position holds the positions of both containers
+	require.Len(t, positions, 2)
+	require.Equal(t, "blob.txt", positions["source-integration-multi-a"].Key)
+	require.Equal(t, "blob.txt", positions["source-integration-multi-b"].Key)
+}
+
+func TestSource_StorageQueueCDCDeletesMessagesOnceAcked(t *testing.T) {
+	ctx := context.Background()
+	fakerInstance := faker.New()
+
+	var (
+		containerName = "source-integration-queue"
+		queueName     = "source-integration-queue"
+
+		cfgRaw = map[string]string{
+			ConfigKeyConnectionString:       helper.GetConnectionString(),
+			ConfigKeyContainerName:          containerName,
+			ConfigKeyPollingPeriod:          "100ms",
+			ConfigKeyCDCMode:                CDCModeStorageQueue,
+			ConfigKeyQueueName:              queueName,
+			ConfigKeyQueueVisibilityTimeout: "2s",
+		}
+
+		contents = fakerInstance.Lorem().Sentence(16)
+	)
+
+	containerClient := helper.PrepareContainer(t, helper.NewAzureBlobServiceClient(), containerName)
+	queueClient := helper.PrepareQueue(t, queueName)
+
+	src := NewSource().(*Source)
+
+	require.NoError(t, src.Configure(ctx, cfgRaw))
+	require.NoError(t, src.Open(ctx, nil))
+
+	t.Cleanup(func() {
+		_ = src.Teardown(ctx)
+	})
+
+	// The snapshot of the empty container switches to CDC
+	_, err := src.Read(ctx)
+	require.ErrorIs(t, err, sdk.ErrBackoffRetry)
+
+	require.NoError(t, helper.CreateBlob(containerClient, "a.txt", "text/plain", contents))
+	require.NoError(t, helper.PutBlobEvent(queueClient, "Microsoft.Storage.BlobCreated", containerName, "a.txt"))
+
+	time.Sleep(time.Second)
+
+	// The blob is read either by the reconciliation listing or from the queue message
+	var receipts int
+
+	for {
+		record, err := src.Read(ctx)
+		if errors.Is(err, sdk.ErrBackoffRetry) {
+			break
+		}
+
+		require.NoError(t, err)
+		require.True(t, helper.AssertRecordEquals(t, record, "a.txt", "text/plain", contents))
+		require.NoError(t, src.Ack(ctx, record.Position))
+
+		p, err := position.NewFromRecordPosition(record.Position)
+		require.NoError(t, err)
+
+		if p.Receipt != "" {
+			receipts++
+		}
+	}
+
+	require.Equal(t, 1, receipts)
+
+	// The acknowledged message does not become visible again
+	require.NoError(t, src.Teardown(ctx))
+
+	time.Sleep(3 * time.Second)
+
+	messages, err := queueClient.GetMessages(ctx, 1, time.Second)
+	require.NoError(t, err)
+	require.Empty(t, messages)
+}
@@ -18,17 +18,182 @@ import (
 	"strconv"
 
 	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/destination"
 	"github.com/miquido/conduit-connector-azure-storage/source"
 )
 
 func Specification() sdk.Specification {
 	return sdk.Specification{
-		Name:              "azure-storage",
-		Summary:           "An Azure Storage source plugin for Conduit.",
-		Version:           "v0.1.0",
-		Author:            "Miquido",
+		Name:    "azure-storage",
+		Summary: "An Azure Storage source and destination plugin for Conduit.",
+		Version: "v0.1.0",
+		Author:  "Miquido",
 		DestinationParams: map[string]sdk.Parameter{
-			//
+			destination.ConfigKeyConnectionString: {
+				Default:     "",
+				Required:    true,
+				Description: "The Azure Storage connection string.",
+			},
+			destination.ConfigKeyContainerName: {
+				Default:     "",
+				Required:    true,
+				Description: "The name of the container to write blobs to.",
+			},
+			destination.ConfigKeyBlobNameTemplate: {
+				Default:     destination.DefaultBlobNameTemplate,
+				Required:    false,
+				Description: "The Go template used to name the blob of each record. Key, Metadata, Position, CreatedAt and WrittenAt of the record are available.",
+			},
+			destination.ConfigKeyFormat: {
+				Default:     destination.DefaultFormat,
+				Required:    false,
+				Description: "The format of written blobs: `raw` writes each record's payload into a separate blob, `ndjson` writes batches of records as JSON Lines, `parquet`, `avro` and `csv` write batches of structured payloads as Parquet, Avro or CSV files.",
+			},
+			destination.ConfigKeyBlobType: {
+				Default:     destination.DefaultBlobType,
+				Required:    false,
+				Description: "The type of written blobs: `block` or `append`. Append blobs support only `raw` and `ndjson` formats.",
+			},
+			destination.ConfigKeyRotationInterval: {
+				Default:     destination.DefaultRotationInterval,
+				Required:    false,
+				Description: "The time after which a new append blob is started, formatted as a time.Duration string. Zero disables the rotation.",
+			},
+			destination.ConfigKeyRotationBytes: {
+				Default:     strconv.FormatInt(destination.DefaultRotationBytes, 10),
+				Required:    false,
+				Description: "The size, in bytes, after which a new append blob is started. Zero disables the rotation.",
+			},
+			destination.ConfigKeyBatchSize: {
+				Default:     strconv.Itoa(destination.DefaultBatchSize),
+				Required:    false,
+				Description: "The maximum number of records in a single batch.",
+			},
+			destination.ConfigKeyBatchBytes: {
+				Default:     strconv.Itoa(destination.DefaultBatchBytes),
+				Required:    false,
+				Description: "The maximum size, in bytes, of records' keys and payloads in a single batch.",
+			},
+			destination.ConfigKeyBatchInterval: {
+				Default:     destination.DefaultBatchInterval,
+				Required:    false,
+				Description: "The maximum time a record waits in a batch before it is written, formatted as a time.Duration string.",
+			},
+			destination.ConfigKeyBlockSize: {
+				Default:     strconv.FormatInt(destination.DefaultBlockSize, 10),
+				Required:    false,
+				Description: "The size, in bytes, of blocks staged when uploading blobs larger than a single block. At most 104857600.",
+			},
+			destination.ConfigKeyUploadParallelism: {
+				Default:     strconv.Itoa(destination.DefaultUploadParallelism),
+				Required:    false,
+				Description: "The maximum number of blocks of a single blob uploaded at once.",
+			},
+			destination.ConfigKeyBlockRetries: {
+				Default:     strconv.Itoa(destination.DefaultBlockRetries),
+				Required:    false,
+				Description: "The number of times a failed request is retried.",
+			},
+			destination.ConfigKeyParquetSchema: {
+				Default:     "",
+				Required:    false,
+				Description: "The schema of Parquet files, as a comma-separated list of `name:type` columns. Inferred from the records of each batch when empty.",
+			},
+			destination.ConfigKeyParquetRowGroupSize: {
+				Default:     strconv.Itoa(destination.DefaultParquetRowGroupSize),
+				Required:    false,
+				Description: "The maximum number of rows in a single Parquet row group.",
+			},
+			destination.ConfigKeyAvroSchema: {
+				Default:     "",
+				Required:    false,
+				Description: "The Avro schema of the record written to Avro files. Inferred from the records of each batch when empty.",
+			},
+			destination.ConfigKeyAvroCodec: {
+				Default:     destination.DefaultAvroCodec,
+				Required:    false,
+				Description: "The codec used to compress blocks of Avro files: `null`, `deflate` or `snappy`.",
+			},
+			destination.ConfigKeyCSVColumns: {
+				Default:     "",
+				Required:    false,
+				Description: "The comma-separated, ordered list of columns of CSV files. Inferred from the records of each batch when empty.",
+			},
+			destination.ConfigKeyCSVDelimiter: {
+				Default:     destination.DefaultCSVDelimiter,
+				Required:    false,
+				Description: "The character separating values of CSV files. `\\t` stands for the tab character.",
+			},
+			destination.ConfigKeyWritePolicy: {
+				Default:     destination.DefaultWritePolicy,
+				Required:    false,
+				Description: "The condition of writing blobs: `overwrite`, `createOnly` or `ifMatchLastSeen`.",
+			},
+			destination.ConfigKeyConflictReaction: {
+				Default:     destination.DefaultConflictReaction,
+				Required:    false,
+				Description: "The reaction on a blob which cannot be written because of a conflict: `fail`, `skip` or `conflictPrefix`.",
+			},
+			destination.ConfigKeyConflictPrefix: {
+				Default:     destination.DefaultConflictPrefix,
+				Required:    false,
+				Description: "The prefix of names of blobs written on a conflict with `conflictReaction` set to `conflictPrefix`.",
+			},
+			destination.ConfigKeyMetadataMapping: {
+				Default:     "",
+				Required:    false,
+				Description: "The comma-separated list of `from:to` rules copying Record metadata keys into blob metadata.",
+			},
+			destination.ConfigKeyHeadersMapping: {
+				Default:     destination.DefaultHeadersMapping,
+				Required:    false,
+				Description: "The comma-separated list of `from:header` rules copying Record metadata keys into `Content-Type`, `Content-Encoding`, `Cache-Control` or `Content-Disposition` headers.",
+			},
+			destination.ConfigKeyTagsMapping: {
+				Default:     "",
+				Required:    false,
+				Description: "The comma-separated list of `from:to` rules copying Record metadata keys into blob index tags. At most 10 tags are allowed.",
+			},
+			destination.ConfigKeyAccessTier: {
+				Default:     "",
+				Required:    false,
+				Description: "The access tier of written blobs: `Hot`, `Cool`, `Cold` or `Archive`. The account's default tier is used when empty.",
+			},
+			destination.ConfigKeyAccessTierMetadataKey: {
+				Default:     "",
+				Required:    false,
+				Description: "The Record metadata key holding the access tier of the blob, overriding `accessTier`.",
+			},
+			destination.ConfigKeyImmutabilityPeriod: {
+				Default:     destination.DefaultImmutabilityPeriod,
+				Required:    false,
+				Description: "The time written blobs are protected from being modified or deleted by the immutability policy, formatted as a time.Duration string. `0s` disables the policy.",
+			},
+			destination.ConfigKeyImmutabilityMode: {
+				Default:     destination.DefaultImmutabilityMode,
+				Required:    false,
+				Description: "The mode of the immutability policy: `unlocked` or `locked`.",
+			},
+			destination.ConfigKeyLegalHold: {
+				Default:     strconv.FormatBool(destination.DefaultLegalHold),
+				Required:    false,
+				Description: "Whether the legal hold is placed on written blobs.",
+			},
+			destination.ConfigKeyExpiryPeriod: {
+				Default:     destination.DefaultExpiryPeriod,
+				Required:    false,
+				Description: "The time after which written blobs are deleted, formatted as a time.Duration string. `0s` disables the expiry. Requires an account with a hierarchical namespace.",
+			},
+			destination.ConfigKeyCompression: {
+				Default:     destination.DefaultCompression,
+				Required:    false,
+				Description: "The compression of written blobs: `none`, `gzip`, `zstd` or `snappy`.",
+			},
+			destination.ConfigKeyCompressionLevel: {
+				Default:     strconv.Itoa(destination.DefaultCompressionLevel),
+				Required:    false,
+				Description: "The compression level: from `1` to `9` for gzip, from `1` to `22` for zstd. `0` stands for the algorithm's default level.",
+			},
 		},
 		SourceParams: map[string]sdk.Parameter{
 			source.ConfigKeyConnectionString: {
@@ -38,8 +203,13 @@ func Specification() sdk.Specification {
 			},
 			source.ConfigKeyContainerName: {
 				Default:     "",
-				Required:    true,
-				Description: "The name of the container to monitor.",
+				Required:    false,
+				Description: "The name of the container to monitor, or the comma-separated names of the containers. Required unless containerNamePattern is set.",
+			},
+			source.ConfigKeyContainerNamePattern: {
+				Default:     "",
+				Required:    false,
+				Description: "All containers with names matching the glob, or the regular expression prefixed with `regex:`, are monitored.",
 			},
 			source.ConfigKeyPollingPeriod: {
 				Default:     source.DefaultPollingPeriod,
@@ -51,6 +221,116 @@ func Specification() sdk.Specification {
 				Required:    false,
 				Description: "The maximum number of items, per page, when reading container's items.",
 			},
+			source.ConfigKeyPrefix: {
+				Default:     "",
+				Required:    false,
+				Description: "Only blobs with names starting with the prefix are listed.",
+			},
+			source.ConfigKeyInclude: {
+				Default:     "",
+				Required:    false,
+				Description: "Only blobs with names matching the glob, or the regular expression prefixed with `regex:`, are read.",
+			},
+			source.ConfigKeyExclude: {
+				Default:     "",
+				Required:    false,
+				Description: "Blobs with names matching the glob, or the regular expression prefixed with `regex:`, are skipped.",
+			},
+			source.ConfigKeyDelimiter: {
+				Default:     "",
+				Required:    false,
+				Description: "Lists the container hierarchically, treating blob name parts separated by the delimiter as virtual directories.",
+			},
+			source.ConfigKeyDirectories: {
+				Default:     "",
+				Required:    false,
+				Description: "Only virtual directories with paths matching the glob, or the regular expression prefixed with `regex:`, are read.",
+			},
+			source.ConfigKeyMaxDepth: {
+				Default:     "0",
+				Required:    false,
+				Description: "The maximum number of virtual directory levels read, where 0 means no limit.",
+			},
+			source.ConfigKeyCDCMode: {
+				Default:     source.DefaultCDCMode,
+				Required:    false,
+				Description: "How changes are detected after the snapshot: polling lists the container every pollingPeriod, changeFeed reads the change feed, eventGrid receives Event Grid events, storageQueue consumes them from a queue.",
+			},
+			source.ConfigKeyChangeFeedContainerName: {
+				Default:     source.DefaultChangeFeedContainerName,
+				Required:    false,
+				Description: "The name of the container the change feed is read from.",
+			},
+			source.ConfigKeyEventGridAddress: {
+				Default:     source.DefaultEventGridAddress,
+				Required:    false,
+				Description: "The address the HTTP endpoint receiving the Event Grid events listens on, with cdcMode set to eventGrid.",
+			},
+			source.ConfigKeyEventGridSecret: {
+				Default:     "",
+				Required:    false,
+				Description: "The shared secret every request to the Event Grid endpoint has to carry, in the secret query parameter or the X-Event-Grid-Secret header. Required with cdcMode set to eventGrid.",
+			},
+			source.ConfigKeyDeleteDetection: {
+				Default:     source.DefaultDeleteDetection,
+				Required:    false,
+				Description: "How the polling detects deleted files: softDelete relies on soft delete, stateDiff compares the listings.",
+			},
+			source.ConfigKeyStateDirectory: {
+				Default:     "",
+				Required:    false,
+				Description: "The directory the listings compared are stored in. Required with deleteDetection set to stateDiff.",
+			},
+			source.ConfigKeyQueueName: {
+				Default:     "",
+				Required:    false,
+				Description: "The name of the Storage Queue the Event Grid events are consumed from. Required with cdcMode set to storageQueue.",
+			},
+			source.ConfigKeyQueueVisibilityTimeout: {
+				Default:     source.DefaultQueueVisibilityTimeout,
+				Required:    false,
+				Description: "How long the queue messages received stay invisible to other consumers, between 1s and 168h.",
+			},
+			source.ConfigKeyRecordSplitting: {
+				Default:     source.DefaultRecordSplitting,
+				Required:    false,
+				Description: "How files are read into records: none reads each file into one record, lines reads every line, csv every CSV row, parquet every Parquet row, and avro every Avro datum, into its own record.",
+			},
+			source.ConfigKeyCSVHeader: {
+				Default:     source.DefaultCSVHeader,
+				Required:    false,
+				Description: "Whether the first row of CSV files is the header: auto, present or absent.",
+			},
+			source.ConfigKeyCSVDelimiter: {
+				Default:     source.DefaultCSVDelimiter,
+				Required:    false,
+				Description: "The character separating values of CSV files. `\\t` stands for the tab character.",
+			},
+			source.ConfigKeyCSVQuote: {
+				Default:     source.DefaultCSVQuote,
+				Required:    false,
+				Description: "The character enclosing values of CSV files.",
+			},
+			source.ConfigKeyCSVInferTypes: {
+				Default:     strconv.FormatBool(source.DefaultCSVInferTypes),
+				Required:    false,
+				Description: "Whether unquoted numbers, booleans and empty values of CSV files are converted from text.",
+			},
+			source.ConfigKeyCSVKeyColumn: {
+				Default:     "",
+				Required:    false,
+				Description: "The column of CSV files holding the records' keys. Records are keyed with the file's name and the row's number when empty.",
+			},
+			source.ConfigKeyDecodeJSON: {
+				Default:     strconv.FormatBool(source.DefaultDecodeJSON),
+				Required:    false,
+				Description: "Whether JSON files are decoded into structured data, with top-level arrays split into their elements. Requires recordSplitting to be none.",
+			},
+			source.ConfigKeyInvalidJSON: {
+				Default:     source.DefaultInvalidJSON,
+				Required:    false,
+				Description: "How JSON which cannot be decoded is handled: fail, skip or raw.",
+			},
 		},
 	}
 }
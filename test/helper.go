@@ -15,16 +15,24 @@
 package test
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	sdk "github.com/conduitio/conduit-connector-sdk"
+	"github.com/miquido/conduit-connector-azure-storage/internal/compression"
+	"github.com/miquido/conduit-connector-azure-storage/source/changefeed"
+	"github.com/miquido/conduit-connector-azure-storage/source/queue"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -42,11 +50,12 @@ var (
 
 	// https://docs.microsoft.com/en-us/azure/storage/common/storage-use-azurite?tabs=docker-hub#http-connection-strings
 	connectionString = fmt.Sprintf(
-		"DefaultEndpointsProtocol=%s;AccountName=%s;AccountKey=%s;BlobEndpoint=%s",
+		"DefaultEndpointsProtocol=%s;AccountName=%s;AccountKey=%s;BlobEndpoint=%s;QueueEndpoint=%s",
 		defaultEndpointsProtocol,
 		accountName,
 		accountKey,
 		fmt.Sprintf("http://127.0.0.1:10000/%s", accountName),
+		fmt.Sprintf("http://127.0.0.1:10001/%s", accountName),
 	)
 )
 
@@ -81,6 +90,38 @@ func PrepareContainer(t *testing.T, serviceClient *azblob.ServiceClient, contain
 	return containerClient
 }
 
+// PrepareQueue creates the queue, deleted once the test completes.
+func PrepareQueue(t *testing.T, queueName string) *queue.Client {
+	queueClient, err := queue.NewClientFromConnectionString(connectionString, queueName)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = queueClient.Delete(context.Background())
+	})
+
+	require.NoError(t, queueClient.Create(context.Background()))
+
+	return queueClient
+}
+
+// PutBlobEvent adds the message of the Event Grid event of the container's blob to the queue, Base64-encoded.
+func PutBlobEvent(queueClient *queue.Client, eventType, containerName, blobName string) error {
+	event, err := json.Marshal(map[string]interface{}{
+		"id":          fmt.Sprintf("%d", time.Now().UnixNano()),
+		"topic":       fmt.Sprintf("/subscriptions/test/resourceGroups/test/providers/Microsoft.Storage/storageAccounts/%s", accountName),
+		"subject":     fmt.Sprintf("/blobServices/default/containers/%s/blobs/%s", containerName, blobName),
+		"eventType":   eventType,
+		"eventTime":   time.Now().UTC(),
+		"data":        map[string]interface{}{"api": "PutBlob"},
+		"dataVersion": "",
+	})
+	if err != nil {
+		return err
+	}
+
+	return queueClient.PutMessage(context.Background(), base64.StdEncoding.EncodeToString(event))
+}
+
 func CreateBlob(containerClient *azblob.ContainerClient, blobName, contentType, contents string) error {
 	blockBlobClient, err := containerClient.NewBlockBlobClient(blobName)
 	if err != nil {
@@ -103,6 +144,124 @@ func CreateBlob(containerClient *azblob.ContainerClient, blobName, contentType,
 	return nil
 }
 
+// CreateEncodedBlob creates the blob with the contents compressed with the given algorithm,
+// and the matching Content-Encoding.
+func DeleteBlob(containerClient *azblob.ContainerClient, blobName string) error {
+	blobClient, err := containerClient.NewBlobClient(blobName)
+	if err != nil {
+		return err
+	}
+
+	_, err = blobClient.Delete(context.Background(), nil)
+
+	return err
+}
+
+func CreateEncodedBlob(containerClient *azblob.ContainerClient, blobName, contentType, algorithm, contents string) error {
+	compressed, err := compression.Compress([]byte(contents), algorithm, compression.DefaultLevel)
+	if err != nil {
+		return err
+	}
+
+	blockBlobClient, err := containerClient.NewBlockBlobClient(blobName)
+	if err != nil {
+		return err
+	}
+
+	_, err = blockBlobClient.Upload(
+		context.Background(),
+		streaming.NopCloser(bytes.NewReader(compressed)),
+		&azblob.BlockBlobUploadOptions{
+			HTTPHeaders: &azblob.BlobHTTPHeaders{
+				BlobContentType:     to.Ptr(contentType),
+				BlobContentEncoding: to.Ptr(compression.ContentEncoding(algorithm)),
+			},
+		},
+	)
+
+	return err
+}
+
+// WriteChangeFeedSegment emulates the change feed, which Azurite does not support, by writing a finalized
+// single-shard segment beginning at given time, with the events in a single chunk, into the container.
+func WriteChangeFeedSegment(containerClient *azblob.ContainerClient, begin time.Time, events []changefeed.Event) error {
+	urlParts, err := azblob.NewBlobURLParts(containerClient.URL())
+	if err != nil {
+		return err
+	}
+
+	begin = begin.UTC().Truncate(time.Hour)
+	datePath := begin.Format("2006/01/02/1504")
+	shardPath := fmt.Sprintf("log/00/%s/", datePath)
+
+	var chunk bytes.Buffer
+	if err := changefeed.WriteEvents(&chunk, events); err != nil {
+		return err
+	}
+
+	segment, err := json.Marshal(changefeed.Segment{
+		Begin:          begin,
+		IntervalSecs:   3600,
+		Status:         changefeed.SegmentStatusFinalized,
+		ChunkFilePaths: []string{urlParts.ContainerName + "/" + shardPath},
+	})
+	if err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(changefeed.Meta{
+		LastConsumable: begin.Add(time.Hour),
+	})
+	if err != nil {
+		return err
+	}
+
+	for name, contents := range map[string][]byte{
+		shardPath + "00000.avro": chunk.Bytes(),
+		fmt.Sprintf("%s%s/meta.json", changefeed.SegmentsPrefix, datePath): segment,
+		changefeed.MetaPath: meta,
+	} {
+		if err := CreateBlob(containerClient, name, "application/octet-stream", string(contents)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ReadBlob(containerClient *azblob.ContainerClient, blobName string) (string, error) {
+	blobClient, err := containerClient.NewBlobClient(blobName)
+	if err != nil {
+		return "", err
+	}
+
+	downloadResponse, err := blobClient.Download(context.Background(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	contents, err := ioutil.ReadAll(downloadResponse.Body(nil))
+	if err != nil {
+		return "", err
+	}
+
+	return string(contents), nil
+}
+
+func ListBlobNames(containerClient *azblob.ContainerClient) ([]string, error) {
+	var names []string
+
+	pager := containerClient.ListBlobsFlat(nil)
+
+	for pager.NextPage(context.Background()) {
+		for _, item := range pager.PageResponse().Segment.BlobItems {
+			names = append(names, *item.Name)
+		}
+	}
+
+	return names, pager.Err()
+}
+
 func AssertRecordEquals(t *testing.T, record sdk.Record, fileName, contentType, contents string) bool {
 	return assert.NotNil(t, record.Key, "Record Key is not set.") &&
 		assert.NotNil(t, record.Payload, "Record Payload is not set.") &&